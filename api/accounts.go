@@ -0,0 +1,45 @@
+package api
+
+import (
+	"errors"
+	"fynance/utils"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// listAccounts returns every account with where it stands
+func listAccounts(w http.ResponseWriter, r *http.Request) {
+	balances, err := utils.AccountBalances(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, balances)
+}
+
+// accountHistory returns the balance of an account month by month
+func accountHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "no such account")
+		return
+	}
+	account, err := utils.GetAccount(r.Context(), id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		writeError(w, http.StatusNotFound, "no such account")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	history, err := utils.AccountHistory(r.Context(), account)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"fynance/models"
+	"fynance/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// category is an income or expense category. Path is its full name, which
+// records are filed under, such as "Transport > Fuel".
+type category struct {
+	ID        primitive.ObjectID `json:"id"`
+	Type      string             `json:"type"`
+	Name      string             `json:"name"`
+	Path      string             `json:"path"`
+	ParentID  string             `json:"parent_id,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+type categoryInput struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	ParentID string `json:"parent_id"`
+}
+
+// newCategory describes a category of a tree
+func newCategory(kind string, c utils.Category) category {
+	created := category{ID: c.ID, Type: kind, Name: c.Name, Path: c.Path, CreatedAt: c.CreatedAt}
+	if !c.ParentID.IsZero() {
+		created.ParentID = c.ParentID.Hex()
+	}
+	return created
+}
+
+// findCategories returns the categories of kind, or of both kinds when
+// empty, each followed by its subcategories
+func findCategories(r *http.Request, kind string) ([]category, error) {
+	categories := []category{}
+	for _, k := range []string{"income", "expense"} {
+		if kind != "" && kind != k {
+			continue
+		}
+		tree, err := utils.LoadCategoryTree(r.Context(), k)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range tree.Categories {
+			categories = append(categories, newCategory(k, c))
+		}
+	}
+	return categories, nil
+}
+
+// checkType writes a 400 response unless kind is income or expense
+func checkType(w http.ResponseWriter, kind string) bool {
+	if kind != "income" && kind != "expense" {
+		writeError(w, http.StatusBadRequest, "type must be income or expense")
+		return false
+	}
+	return true
+}
+
+// parseParent reads a parent_id, empty for a top-level category
+func parseParent(w http.ResponseWriter, parent string) (primitive.ObjectID, bool) {
+	if parent == "" {
+		return primitive.NilObjectID, true
+	}
+	id, err := primitive.ObjectIDFromHex(parent)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "parent_id must be a category id")
+		return id, false
+	}
+	return id, true
+}
+
+// checkName writes an error response unless the category with id, nil for a
+// new one, can have name and parent
+func checkName(w http.ResponseWriter, r *http.Request, kind string, id primitive.ObjectID, name string, parentID primitive.ObjectID) bool {
+	err := utils.CheckCategory(r.Context(), kind, id, name, parentID)
+	switch {
+	case errors.Is(err, utils.ErrCategoryExists):
+		writeError(w, http.StatusConflict, err.Error())
+		return false
+	case err != nil:
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return false
+	}
+	return true
+}
+
+// lookupCategory finds the category named by the {type} and {id} path values
+func lookupCategory(w http.ResponseWriter, r *http.Request) (category, bool) {
+	kind := r.PathValue("type")
+	if !checkType(w, kind) {
+		return category{}, false
+	}
+
+	categories, err := findCategories(r, kind)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return category{}, false
+	}
+	for _, c := range categories {
+		if c.ID.Hex() == r.PathValue("id") {
+			return c, true
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "no such category")
+	return category{}, false
+}
+
+// reloadCategory describes the category with id as it is now stored
+func reloadCategory(r *http.Request, kind string, id primitive.ObjectID) (category, error) {
+	tree, err := utils.LoadCategoryTree(r.Context(), kind)
+	if err != nil {
+		return category{}, err
+	}
+	c, ok := tree.Find(id)
+	if !ok {
+		return category{}, errors.New("the category was not saved")
+	}
+	return newCategory(kind, c), nil
+}
+
+func listCategories(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("type")
+	if kind != "" && !checkType(w, kind) {
+		return
+	}
+
+	categories, err := findCategories(r, kind)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, categories)
+}
+
+func createCategory(w http.ResponseWriter, r *http.Request) {
+	var input categoryInput
+	if !readJSON(w, r, &input) || !checkType(w, input.Type) {
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	parentID, ok := parseParent(w, input.ParentID)
+	if !ok || !checkName(w, r, input.Type, primitive.NilObjectID, input.Name, parentID) {
+		return
+	}
+
+	parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id := primitive.NewObjectID()
+	if input.Type == "income" {
+		err = utils.AddDetail(models.IncomeDetail{ID: id, IncomeCategory: input.Name, ParentID: parentID, CreatedAt: parsedTime}, nil)
+	} else {
+		err = utils.AddExpenseDetail(models.ExpenseDetail{ID: id, ExpenseCategory: input.Name, ParentID: parentID, CreatedAt: parsedTime}, nil)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	created, err := reloadCategory(r, input.Type, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordChange(r, "Added "+created.Path, userFrom(r).Username+" Added "+created.Path)
+	w.Header().Set("Location", "/api/v1/categories/"+created.Type+"/"+created.ID.Hex())
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// renameCategory renames a category or moves it under another one. Records
+// filed under it, or under its subcategories, follow.
+func renameCategory(w http.ResponseWriter, r *http.Request) {
+	existing, ok := lookupCategory(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name     string  `json:"name"`
+		ParentID *string `json:"parent_id"`
+	}
+	if !readJSON(w, r, &input) {
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" && input.ParentID != nil {
+		input.Name = existing.Name // only moving it
+	}
+	parent := existing.ParentID
+	if input.ParentID != nil {
+		parent = *input.ParentID
+	}
+	parentID, ok := parseParent(w, parent)
+	if !ok || !checkName(w, r, existing.Type, existing.ID, input.Name, parentID) {
+		return
+	}
+
+	parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if existing.Type == "income" {
+		err = utils.UpdateDetail(models.IncomeDetail{ID: existing.ID, IncomeCategory: input.Name, ParentID: parentID, CreatedAt: existing.CreatedAt, UpdatedAt: parsedTime}, nil)
+	} else {
+		err = utils.UpdateExpenseDetail(models.ExpenseDetail{ID: existing.ID, ExpenseCategory: input.Name, ParentID: parentID, CreatedAt: existing.CreatedAt, UpdatedAt: parsedTime}, nil)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	updated, err := reloadCategory(r, existing.Type, existing.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordChange(r, "Edited "+existing.Path+" to "+updated.Path, userFrom(r).Username+" Edited "+updated.Path)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// deleteCategory deletes a category no records use. With reassign_to, its
+// records are moved to that category first.
+func deleteCategory(w http.ResponseWriter, r *http.Request) {
+	existing, ok := lookupCategory(w, r)
+	if !ok {
+		return
+	}
+
+	if reassign := r.URL.Query().Get("reassign_to"); reassign != "" {
+		target, err := primitive.ObjectIDFromHex(reassign)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "reassign_to must be a category id")
+			return
+		}
+		moved, err := utils.ReassignCategory(r.Context(), existing.Type, existing.ID, target)
+		if errors.Is(err, utils.ErrBulkUnavailable) {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if errors.Is(err, utils.ErrReconciled) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		recordChange(r, fmt.Sprintf("moved %d %s records from %s", moved, existing.Type, existing.Path),
+			fmt.Sprintf("%s moved %d records from %s", userFrom(r).Username, moved, existing.Path))
+	}
+
+	var err error
+	if existing.Type == "income" {
+		err = utils.DeleteDetail(existing.ID, nil)
+	} else {
+		err = utils.DeleteExpenseDetail(existing.ID, nil)
+	}
+	if errors.Is(err, utils.ErrCategoryInUse) {
+		writeError(w, http.StatusConflict, err.Error()+"; move its subcategories, or pass reassign_to to move its records and rules")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordChange(r, "deleted "+existing.Type+" category "+existing.Path, userFrom(r).Username+" deleted "+existing.Path)
+	w.WriteHeader(http.StatusNoContent)
+}
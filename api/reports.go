@@ -0,0 +1,132 @@
+package api
+
+import (
+	"fynance/helpers"
+	"fynance/models"
+	"fynance/utils"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// reportYear reads the year query parameter, the current year by default
+func reportYear(w http.ResponseWriter, r *http.Request) (string, bool) {
+	year := r.URL.Query().Get("year")
+	if year == "" {
+		return time.Now().Format("2006"), true
+	}
+	if _, err := strconv.Atoi(year); err != nil || len(year) != 4 {
+		writeError(w, http.StatusBadRequest, "year must have four digits")
+		return "", false
+	}
+	return year, true
+}
+
+// monthlyReport returns the income, expenses and balance of every month
+func monthlyReport(w http.ResponseWriter, r *http.Request) {
+	year, ok := reportYear(w, r)
+	if !ok {
+		return
+	}
+
+	months, err := utils.MonthlyReport(r.Context(), year, helpers.Months)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var total models.Report
+	for _, month := range months {
+		total.TotalIncome += month.TotalIncome
+		total.TotalExpense += month.TotalExpense
+		total.Balance += month.Balance
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"year":          year,
+		"months":        months,
+		"total_income":  total.TotalIncome,
+		"total_expense": total.TotalExpense,
+		"balance":       total.Balance,
+	})
+}
+
+// tagReport returns the income and expenses of every tag
+func tagReport(w http.ResponseWriter, r *http.Request) {
+	year, ok := reportYear(w, r)
+	if !ok {
+		return
+	}
+
+	tags, err := utils.TagReport(r.Context(), year)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if tags == nil {
+		tags = []models.TagTotal{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"year": year, "tags": tags})
+}
+
+// categoryReport returns what was recorded under every category, with
+// subcategories rolled up into their parents
+func categoryReport(w http.ResponseWriter, r *http.Request) {
+	year, ok := reportYear(w, r)
+	if !ok {
+		return
+	}
+	kind := r.URL.Query().Get("type")
+	if kind != "" && !checkType(w, kind) {
+		return
+	}
+
+	categories, err := utils.CategoryReport(r.Context(), kind, year)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if categories == nil {
+		categories = []models.CategoryTotal{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"year": year, "categories": categories})
+}
+
+// dashboardStats returns the figures shown on the dashboard
+func dashboardStats(w http.ResponseWriter, r *http.Request) {
+	year, ok := reportYear(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+
+	totalIncome, err := utils.SumIncomes(ctx, year)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	totalExpense, err := utils.SumExpenses(ctx, year)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	incomeStats, err := utils.GetIncomeStats(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	expenseStats, err := utils.GetExpenseStats(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"year":                   year,
+		"total_income":           totalIncome,
+		"total_expense":          totalExpense,
+		"balance":                totalIncome - totalExpense,
+		"top_income_categories":  incomeStats,
+		"top_expense_categories": expenseStats,
+	})
+}
@@ -0,0 +1,162 @@
+// Package api serves Fynance data as JSON over HTTP for local tools. Every
+// request except the OpenAPI description needs a per-user API token.
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fynance/models"
+	"fynance/utils"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultAddress only accepts connections from the same machine
+const DefaultAddress = "127.0.0.1:8765"
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// maxBodySize limits request bodies, every payload is a small JSON object
+const maxBodySize = 1 << 20
+
+type contextKey struct{}
+
+// userFrom returns the user the request was authenticated as
+func userFrom(r *http.Request) models.User {
+	return r.Context().Value(contextKey{}).(models.User)
+}
+
+// NewHandler returns the API routes
+func NewHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, authenticate(handler))
+	}
+
+	for _, store := range []transactionStore{incomeStore, expenseStore} {
+		handle("GET /api/v1/"+store.path, store.list)
+		handle("POST /api/v1/"+store.path, store.create)
+		handle("GET /api/v1/"+store.path+"/{id}", store.get)
+		handle("PUT /api/v1/"+store.path+"/{id}", store.update)
+		handle("DELETE /api/v1/"+store.path+"/{id}", store.delete)
+	}
+
+	handle("GET /api/v1/categories", listCategories)
+	handle("POST /api/v1/categories", createCategory)
+	handle("PUT /api/v1/categories/{type}/{id}", renameCategory)
+	handle("DELETE /api/v1/categories/{type}/{id}", deleteCategory)
+
+	handle("GET /api/v1/accounts", listAccounts)
+	handle("GET /api/v1/accounts/{id}/history", accountHistory)
+
+	handle("GET /api/v1/report", monthlyReport)
+	handle("GET /api/v1/report/tags", tagReport)
+	handle("GET /api/v1/report/categories", categoryReport)
+	handle("GET /api/v1/stats", dashboardStats)
+
+	return mux
+}
+
+// authenticate checks the bearer token and passes its user to next
+func authenticate(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fynance"`)
+			writeError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		user, err := utils.UserForAPIToken(r.Context(), strings.TrimSpace(token))
+		if errors.Is(err, utils.ErrInvalidToken) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fynance", error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		next(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, user)))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// readJSON decodes the request body into value, rejecting unknown fields
+func readJSON(w http.ResponseWriter, r *http.Request, value any) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// recordChange writes the activity log entry and notification for a change made
+// through the API. Failures there don't undo the change, so they are ignored.
+func recordChange(r *http.Request, detail, message string) {
+	user := userFrom(r)
+	utils.LogEvent(user.Username+" "+detail, "SUCCESS")
+	utils.InsertNotification(r.Context(), models.Notification{
+		UserID:  user.ID,
+		Message: message,
+		IsRead:  false,
+	})
+}
+
+// Server is a running API server
+type Server struct {
+	httpServer *http.Server
+	listener   net.Listener
+}
+
+// Start listens on addr and serves the API in the background
+func Start(addr string) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		httpServer: &http.Server{
+			Handler:           NewHandler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		listener: listener,
+	}
+	go s.httpServer.Serve(listener)
+	return s, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Shutdown stops accepting requests and waits for the running ones
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
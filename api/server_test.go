@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fynance/internal/testdb"
+	"fynance/models"
+	"fynance/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testAPI is an API server on a scratch database with one user
+type testAPI struct {
+	server *httptest.Server
+	user   models.User
+	token  string
+}
+
+// newTestAPI serves the API on a scratch database for a new user
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	ctx := testdb.Connect(t, "fynance_api_test")
+
+	user := models.User{ID: primitive.NewObjectID(), Username: "tester"}
+	if _, err := utils.GetCollection("users").InsertOne(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := utils.CreateAPIToken(ctx, user.ID, "tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewHandler())
+	t.Cleanup(server.Close)
+	return &testAPI{server: server, user: user, token: token}
+}
+
+// call sends a request with the API token, encoding body as JSON unless it
+// is a string, and returns the response with its body read
+func (a *testAPI) call(t *testing.T, method, path string, body any) (*http.Response, []byte) {
+	t.Helper()
+	return a.callWith(t, "Bearer "+a.token, method, path, body)
+}
+
+// callWith sends a request with the given Authorization header, if any
+func (a *testAPI) callWith(t *testing.T, authorization, method, path string, body any) (*http.Response, []byte) {
+	t.Helper()
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case string:
+		reader = bytes.NewBufferString(body)
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequest(method, a.server.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	response, err := a.server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response, data
+}
+
+// expectStatus fails the test when response doesn't have status
+func expectStatus(t *testing.T, response *http.Response, body []byte, status int) {
+	t.Helper()
+	if response.StatusCode != status {
+		t.Fatalf("%s %s: got %d %s, want %d", response.Request.Method, response.Request.URL.Path, response.StatusCode, body, status)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	api := newTestAPI(t)
+
+	revoked, token, err := utils.CreateAPIToken(context.Background(), api.user.ID, "revoked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.RevokeAPIToken(context.Background(), api.user.ID, token.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name          string
+		authorization string
+	}{
+		{"missing token", ""},
+		{"other scheme", "Basic dGVzdGVyOnNlY3JldA=="},
+		{"invalid token", "Bearer fyn_not-a-real-token"},
+		{"foreign token", "Bearer ghp_0123456789"},
+		{"revoked token", "Bearer " + revoked},
+	}
+	for _, c := range cases {
+		response, body := api.callWith(t, c.authorization, http.MethodGet, "/api/v1/incomes", nil)
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: got %d %s, want 401", c.name, response.StatusCode, body)
+		}
+		if response.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate header", c.name)
+		}
+	}
+
+	response, body := api.call(t, http.MethodGet, "/api/v1/incomes", nil)
+	expectStatus(t, response, body, http.StatusOK)
+
+	// the description is public
+	response, body = api.callWith(t, "", http.MethodGet, "/api/v1/openapi.json", nil)
+	expectStatus(t, response, body, http.StatusOK)
+}
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fynance/helpers"
+	"fynance/models"
+	"fynance/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// transactionInput is the body of a create or update request
+type transactionInput struct {
+	Category string   `json:"category"`
+	Month    string   `json:"month"`
+	Year     string   `json:"year"`
+	Amount   float64  `json:"amount"`
+	Payee    string   `json:"payee"`
+	Notes    string   `json:"notes"`
+	Tags     []string `json:"tags"`
+	Account  string   `json:"account_id"`
+}
+
+// transactionStore serves incomes or expenses. The two models have the same
+// fields, so expenses are converted to and from models.Income here.
+type transactionStore struct {
+	path       string // "incomes" or "expenses"
+	label      string // "Income" or "Expense", as used in the activity log
+	categories func(ctx context.Context) ([]string, error)
+	page       func(ctx context.Context, filter utils.ExportFilter, after *utils.PageCursor, limit int64) ([]models.Income, error)
+	findByID   func(ctx context.Context, id primitive.ObjectID) (models.Income, error)
+	add        func(t models.Income) error
+	save       func(t models.Income) error
+	remove     func(id primitive.ObjectID) error
+}
+
+var incomeStore = transactionStore{
+	path:  "incomes",
+	label: "Income",
+	categories: func(ctx context.Context) ([]string, error) {
+		return utils.CategoryPaths(ctx, "income")
+	},
+	page:     utils.ListIncomesPage,
+	findByID: utils.FindIncomeByID,
+	add:      func(t models.Income) error { return utils.AddIncome(t, nil) },
+	save:     func(t models.Income) error { return utils.UpdateIncome(t, nil) },
+	remove:   func(id primitive.ObjectID) error { return utils.DeleteIncome(id, nil) },
+}
+
+var expenseStore = transactionStore{
+	path:  "expenses",
+	label: "Expense",
+	categories: func(ctx context.Context) ([]string, error) {
+		return utils.CategoryPaths(ctx, "expense")
+	},
+	page: func(ctx context.Context, filter utils.ExportFilter, after *utils.PageCursor, limit int64) ([]models.Income, error) {
+		expenses, err := utils.ListExpensesPage(ctx, filter, after, limit)
+		var transactions []models.Income
+		for _, expense := range expenses {
+			transactions = append(transactions, models.Income(expense))
+		}
+		return transactions, err
+	},
+	findByID: func(ctx context.Context, id primitive.ObjectID) (models.Income, error) {
+		expense, err := utils.FindExpenseByID(ctx, id)
+		return models.Income(expense), err
+	},
+	add:    func(t models.Income) error { return utils.AddExpense(models.Expense(t), nil) },
+	save:   func(t models.Income) error { return utils.UpdateExpense(models.Expense(t), nil) },
+	remove: func(id primitive.ObjectID) error { return utils.DeleteExpense(id, nil) },
+}
+
+// parseFilter reads the from, to, category, tag and search query parameters
+func parseFilter(r *http.Request) (utils.ExportFilter, error) {
+	query := r.URL.Query()
+	filter := utils.ExportFilter{Category: query.Get("category"), Tag: query.Get("tag"), Search: query.Get("search")}
+
+	var err error
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.ParseInLocation("2006-01-02", from, time.Local); err != nil {
+			return filter, errors.New("from must be a YYYY-MM-DD date")
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.ParseInLocation("2006-01-02", to, time.Local); err != nil {
+			return filter, errors.New("to must be a YYYY-MM-DD date")
+		}
+	}
+	return filter, nil
+}
+
+// encodeCursor returns the opaque cursor for the page following transaction
+func encodeCursor(transaction models.Income) string {
+	value := strconv.FormatInt(transaction.CreatedAt.UnixMilli(), 10) + "." + transaction.ID.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+// decodeCursor reads a cursor made by encodeCursor
+func decodeCursor(cursor string) (*utils.PageCursor, error) {
+	invalid := errors.New("invalid cursor")
+	value, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	millis, idHex, ok := strings.Cut(string(value), ".")
+	if !ok {
+		return nil, invalid
+	}
+	createdAt, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return nil, invalid
+	}
+	id, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		return nil, invalid
+	}
+	return &utils.PageCursor{CreatedAt: time.UnixMilli(createdAt), ID: id}, nil
+}
+
+// lookup finds the record named by the {id} path value, writing the error
+// response when there is none
+func (s transactionStore) lookup(w http.ResponseWriter, r *http.Request) (models.Income, bool) {
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "no such "+s.path[:len(s.path)-1])
+		return models.Income{}, false
+	}
+
+	transaction, err := s.findByID(r.Context(), id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		writeError(w, http.StatusNotFound, "no such "+s.path[:len(s.path)-1])
+		return transaction, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return transaction, false
+	}
+	return transaction, true
+}
+
+// validate checks the input the same way the app's forms do
+func (s transactionStore) validate(w http.ResponseWriter, r *http.Request, input transactionInput) bool {
+	categories, err := s.categories(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if err := helpers.ValidateTransaction(input.Category, input.Month, input.Year, input.Amount, categories); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return false
+	}
+	return true
+}
+
+// writeStatus is the status of a failed write: a conflict for reconciled
+// records, which are locked
+func writeStatus(err error) int {
+	if errors.Is(err, utils.ErrReconciled) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// account reads the account of the input, which is optional
+func (s transactionStore) account(w http.ResponseWriter, r *http.Request, input transactionInput) (primitive.ObjectID, bool) {
+	if input.Account == "" {
+		return primitive.NilObjectID, true
+	}
+	id, err := primitive.ObjectIDFromHex(input.Account)
+	if err == nil {
+		_, err = utils.GetAccount(r.Context(), id)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return id, false
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "no such account "+strconv.Quote(input.Account))
+		return id, false
+	}
+	return id, true
+}
+
+func (s transactionStore) list(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit := int64(100)
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 1 || limit > 1000 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+	}
+
+	var after *utils.PageCursor
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		if after, err = decodeCursor(cursor); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// One more than asked for tells whether there is a next page
+	transactions, err := s.page(r.Context(), filter, after, limit+1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if int64(len(transactions)) > limit {
+		transactions = transactions[:limit]
+		w.Header().Set("X-Next-Cursor", encodeCursor(transactions[limit-1]))
+	}
+	if transactions == nil {
+		transactions = []models.Income{}
+	}
+	writeJSON(w, http.StatusOK, transactions)
+}
+
+func (s transactionStore) get(w http.ResponseWriter, r *http.Request) {
+	if transaction, ok := s.lookup(w, r); ok {
+		writeJSON(w, http.StatusOK, transaction)
+	}
+}
+
+func (s transactionStore) create(w http.ResponseWriter, r *http.Request) {
+	var input transactionInput
+	if !readJSON(w, r, &input) || !s.validate(w, r, input) {
+		return
+	}
+	accountID, ok := s.account(w, r, input)
+	if !ok {
+		return
+	}
+
+	parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	transaction := models.Income{
+		ID:        primitive.NewObjectID(),
+		Category:  input.Category,
+		Month:     input.Month,
+		Year:      input.Year,
+		Amount:    input.Amount,
+		Payee:     strings.TrimSpace(input.Payee),
+		Notes:     strings.TrimSpace(input.Notes),
+		Tags:      helpers.ParseTags(strings.Join(input.Tags, ",")),
+		AccountID: accountID,
+		CreatedAt: parsedTime,
+		CreatedBy: userFrom(r).ID,
+	}
+	if err := s.add(transaction); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recordChange(r, "Added "+s.label+": "+transaction.Category, s.label+" added successfully:"+transaction.Category)
+	w.Header().Set("Location", "/api/v1/"+s.path+"/"+transaction.ID.Hex())
+	writeJSON(w, http.StatusCreated, transaction)
+}
+
+func (s transactionStore) update(w http.ResponseWriter, r *http.Request) {
+	transaction, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	var input transactionInput
+	if !readJSON(w, r, &input) || !s.validate(w, r, input) {
+		return
+	}
+	accountID, ok := s.account(w, r, input)
+	if !ok {
+		return
+	}
+
+	parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	transaction.Category = input.Category
+	transaction.Month = input.Month
+	transaction.Year = input.Year
+	transaction.Amount = input.Amount
+	transaction.Payee = strings.TrimSpace(input.Payee)
+	transaction.Notes = strings.TrimSpace(input.Notes)
+	transaction.Tags = helpers.ParseTags(strings.Join(input.Tags, ","))
+	transaction.AccountID = accountID
+	transaction.UpdatedAt = parsedTime
+	if err := s.save(transaction); err != nil {
+		writeError(w, writeStatus(err), err.Error())
+		return
+	}
+
+	recordChange(r, "Edited "+s.label+": "+transaction.Category, s.label+" edited successfully:"+transaction.Category)
+	writeJSON(w, http.StatusOK, transaction)
+}
+
+func (s transactionStore) delete(w http.ResponseWriter, r *http.Request) {
+	transaction, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.remove(transaction.ID); err != nil {
+		writeError(w, writeStatus(err), err.Error())
+		return
+	}
+
+	recordChange(r, "deleted "+s.label+" "+transaction.Category, s.label+" deleted: "+transaction.Category)
+	w.WriteHeader(http.StatusNoContent)
+}
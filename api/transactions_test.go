@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"fynance/models"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+// addCategory creates a top level category through the API
+func (a *testAPI) addCategory(t *testing.T, kind, name string) {
+	t.Helper()
+	response, body := a.call(t, http.MethodPost, "/api/v1/categories", categoryInput{Type: kind, Name: name})
+	expectStatus(t, response, body, http.StatusCreated)
+}
+
+// decode unmarshals a response body, failing the test on error
+func decode[T any](t *testing.T, body []byte) T {
+	t.Helper()
+	var value T
+	if err := json.Unmarshal(body, &value); err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+	return value
+}
+
+func TestTransactionCRUD(t *testing.T) {
+	api := newTestAPI(t)
+	api.addCategory(t, "income", "Salary")
+	api.addCategory(t, "expense", "Rent")
+
+	for path, category := range map[string]string{"incomes": "Salary", "expenses": "Rent"} {
+		base := "/api/v1/" + path
+		input := transactionInput{Category: category, Month: "March", Year: "2026", Amount: 1200, Tags: []string{"Work"}}
+
+		response, body := api.call(t, http.MethodPost, base, input)
+		expectStatus(t, response, body, http.StatusCreated)
+		created := decode[models.Income](t, body)
+		location := base + "/" + created.ID.Hex()
+		if response.Header.Get("Location") != location {
+			t.Fatalf("%s: Location %q, want %q", path, response.Header.Get("Location"), location)
+		}
+		if created.CreatedBy != api.user.ID || !slices.Equal(created.Tags, []string{"work"}) {
+			t.Fatalf("%s: created %+v", path, created)
+		}
+
+		response, body = api.call(t, http.MethodGet, location, nil)
+		expectStatus(t, response, body, http.StatusOK)
+		if got := decode[models.Income](t, body); got.Amount != 1200 || got.Category != category {
+			t.Fatalf("%s: read %+v", path, got)
+		}
+
+		input.Amount = 1350.5
+		response, body = api.call(t, http.MethodPut, location, input)
+		expectStatus(t, response, body, http.StatusOK)
+		response, body = api.call(t, http.MethodGet, location, nil)
+		expectStatus(t, response, body, http.StatusOK)
+		if got := decode[models.Income](t, body); got.Amount != 1350.5 {
+			t.Fatalf("%s: amount after update %v, want 1350.5", path, got.Amount)
+		}
+
+		response, body = api.call(t, http.MethodDelete, location, nil)
+		expectStatus(t, response, body, http.StatusNoContent)
+
+		for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+			response, body = api.call(t, method, location, input)
+			expectStatus(t, response, body, http.StatusNotFound)
+		}
+		response, body = api.call(t, http.MethodGet, base+"/not-an-id", nil)
+		expectStatus(t, response, body, http.StatusNotFound)
+	}
+}
+
+func TestTransactionValidation(t *testing.T) {
+	api := newTestAPI(t)
+	api.addCategory(t, "income", "Salary")
+	valid := transactionInput{Category: "Salary", Month: "March", Year: "2026", Amount: 10}
+
+	invalid := func(edit func(*transactionInput)) transactionInput {
+		input := valid
+		edit(&input)
+		return input
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		status int
+	}{
+		{"malformed JSON", http.MethodPost, "/api/v1/incomes", `{"category":`, http.StatusBadRequest},
+		{"unknown field", http.MethodPost, "/api/v1/incomes", `{"category":"Salary","colour":"red"}`, http.StatusBadRequest},
+		{"wrong field type", http.MethodPost, "/api/v1/incomes", `{"amount":"ten"}`, http.StatusBadRequest},
+		{"limit too small", http.MethodGet, "/api/v1/incomes?limit=0", nil, http.StatusBadRequest},
+		{"limit too large", http.MethodGet, "/api/v1/incomes?limit=1001", nil, http.StatusBadRequest},
+		{"invalid date", http.MethodGet, "/api/v1/incomes?from=01-03-2026", nil, http.StatusBadRequest},
+		{"invalid cursor", http.MethodGet, "/api/v1/incomes?cursor=not-a-cursor", nil, http.StatusBadRequest},
+		{"unknown category", http.MethodPost, "/api/v1/incomes", invalid(func(i *transactionInput) { i.Category = "Lottery" }), http.StatusUnprocessableEntity},
+		{"unknown month", http.MethodPost, "/api/v1/incomes", invalid(func(i *transactionInput) { i.Month = "Smarch" }), http.StatusUnprocessableEntity},
+		{"invalid year", http.MethodPost, "/api/v1/incomes", invalid(func(i *transactionInput) { i.Year = "26" }), http.StatusUnprocessableEntity},
+		{"zero amount", http.MethodPost, "/api/v1/incomes", invalid(func(i *transactionInput) { i.Amount = 0 }), http.StatusUnprocessableEntity},
+		{"unknown account", http.MethodPost, "/api/v1/incomes", invalid(func(i *transactionInput) { i.Account = "0123456789abcdef01234567" }), http.StatusUnprocessableEntity},
+	}
+	for _, c := range cases {
+		response, body := api.call(t, c.method, c.path, c.body)
+		if response.StatusCode != c.status {
+			t.Errorf("%s: got %d %s, want %d", c.name, response.StatusCode, body, c.status)
+		}
+	}
+
+	// nothing was written by the rejected requests
+	response, body := api.call(t, http.MethodGet, "/api/v1/incomes", nil)
+	expectStatus(t, response, body, http.StatusOK)
+	if got := decode[[]models.Income](t, body); len(got) != 0 {
+		t.Fatalf("%d incomes were created", len(got))
+	}
+}
+
+func TestTransactionPagination(t *testing.T) {
+	api := newTestAPI(t)
+	api.addCategory(t, "expense", "Fuel")
+
+	// created within the same second, so paging has to order by ID as well
+	const total = 7
+	for range total {
+		input := transactionInput{Category: "Fuel", Month: "May", Year: "2026", Amount: 40}
+		response, body := api.call(t, http.MethodPost, "/api/v1/expenses", input)
+		expectStatus(t, response, body, http.StatusCreated)
+	}
+
+	response, body := api.call(t, http.MethodGet, "/api/v1/expenses", nil)
+	expectStatus(t, response, body, http.StatusOK)
+	if response.Header.Get("X-Next-Cursor") != "" {
+		t.Fatal("a cursor was returned for a single page")
+	}
+	var want []string
+	for _, expense := range decode[[]models.Income](t, body) {
+		want = append(want, expense.ID.Hex())
+	}
+
+	var got []string
+	var sizes []int
+	cursor := ""
+	for {
+		query := url.Values{"limit": {"3"}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		response, body := api.call(t, http.MethodGet, "/api/v1/expenses?"+query.Encode(), nil)
+		expectStatus(t, response, body, http.StatusOK)
+
+		page := decode[[]models.Income](t, body)
+		sizes = append(sizes, len(page))
+		for _, expense := range page {
+			got = append(got, expense.ID.Hex())
+		}
+
+		cursor = response.Header.Get("X-Next-Cursor")
+		if cursor == "" {
+			break
+		}
+		if len(sizes) > total {
+			t.Fatal("paging does not end")
+		}
+	}
+
+	if !slices.Equal(sizes, []int{3, 3, 1}) {
+		t.Fatalf("page sizes %v, want [3 3 1]", sizes)
+	}
+	if len(want) != total || !slices.Equal(got, want) {
+		t.Fatalf("paged %v, want %v", got, want)
+	}
+}
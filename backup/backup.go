@@ -0,0 +1,265 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fynance/migrations"
+	"fynance/utils"
+	"io"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FormatVersion is the archive layout written by this build. Archives with a
+// higher version are refused on restore.
+const FormatVersion = 1
+
+// FileExtension is used for backup archives (a gzipped tar)
+const FileExtension = ".fynbak"
+
+const (
+	formatName       = "fynance-backup"
+	manifestFileName = "manifest.json"
+	collectionsDir   = "collections/"
+)
+
+// Collections lists every collection included in a backup
+var Collections = []string{
+	"users",
+	"income",
+	"expenses",
+	"income_details",
+	"expense_details",
+	"logs",
+	"notifications",
+	"api_tokens",
+	"webhooks",
+	"webhook_deliveries",
+	"rules",
+	"saved_searches",
+	"import_batches",
+	"accounts",
+	"transfers",
+	"reconciliations",
+	"attachments.files",
+	"attachments.chunks",
+	"schema_version",
+}
+
+// CollectionInfo describes one collection stored in the archive
+type CollectionInfo struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Count  int64  `json:"count"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest is the first entry of every archive
+type Manifest struct {
+	Format      string           `json:"format"`
+	Version     int              `json:"version"`
+	Database    string           `json:"database"`
+	Schema      int              `json:"schema_version"` // zero for archives from before schema versioning
+	CreatedAt   time.Time        `json:"created_at"`
+	Collections []CollectionInfo `json:"collections"`
+}
+
+// TotalDocuments returns the number of documents across all collections
+func (m *Manifest) TotalDocuments() int64 {
+	var total int64
+	for _, collection := range m.Collections {
+		total += collection.Count
+	}
+	return total
+}
+
+// dumpedCollection is a collection exported to a temporary file
+type dumpedCollection struct {
+	info CollectionInfo
+	file *os.File
+}
+
+// close closes and removes the temporary file
+func (d *dumpedCollection) close() {
+	d.file.Close()
+	os.Remove(d.file.Name())
+}
+
+// dumpCollection writes every document of a collection as one canonical
+// extended JSON document per line, hashing as it goes.
+func dumpCollection(ctx context.Context, name string, onDocument func()) (*dumpedCollection, error) {
+	file, err := os.CreateTemp("", "fynance-backup-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	dump := &dumpedCollection{file: file}
+
+	hash := sha256.New()
+	out := io.MultiWriter(file, hash)
+
+	cursor, err := utils.GetCollection(name).Find(ctx, bson.M{})
+	if err != nil {
+		dump.close()
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var count int64
+	for cursor.Next(ctx) {
+		line, err := bson.MarshalExtJSON(cursor.Current, true, false)
+		if err != nil {
+			dump.close()
+			return nil, err
+		}
+		if _, err := out.Write(append(line, '\n')); err != nil {
+			dump.close()
+			return nil, err
+		}
+		count++
+		onDocument()
+	}
+	if err := cursor.Err(); err != nil {
+		dump.close()
+		return nil, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		dump.close()
+		return nil, err
+	}
+
+	dump.info = CollectionInfo{
+		Name:   name,
+		File:   collectionsDir + name + ".jsonl",
+		Count:  count,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}
+	return dump, nil
+}
+
+// Create writes a backup archive of every collection to w, encrypted when a
+// passphrase is given. progress receives values between 0 and 1 and may be nil.
+func Create(ctx context.Context, w io.Writer, passphrase string, progress func(float64)) (*Manifest, error) {
+	// Count up front so progress is accurate
+	var total, done int64
+	for _, name := range Collections {
+		count, err := utils.GetCollection(name).EstimatedDocumentCount(ctx)
+		if err != nil {
+			return nil, err
+		}
+		total += count
+	}
+	onDocument := func() {
+		done++
+		if progress != nil && total > 0 && done%500 == 0 {
+			progress(min(float64(done)/float64(total), 0.99))
+		}
+	}
+
+	schema, err := migrations.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		Format:    formatName,
+		Version:   FormatVersion,
+		Database:  utils.CurrentDBConfig().Database,
+		Schema:    schema,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	// Collections are dumped to temporary files first so the manifest,
+	// with counts and checksums, can be the first entry of the archive.
+	var dumps []*dumpedCollection
+	defer func() {
+		for _, dump := range dumps {
+			dump.close()
+		}
+	}()
+	for _, name := range Collections {
+		dump, err := dumpCollection(ctx, name, onDocument)
+		if err != nil {
+			return nil, err
+		}
+		dumps = append(dumps, dump)
+		manifest.Collections = append(manifest.Collections, dump.info)
+	}
+
+	var encrypted io.WriteCloser
+	if passphrase != "" {
+		var err error
+		encrypted, err = NewEncryptWriter(w, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		w = encrypted
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:    manifestFileName,
+		Mode:    0600,
+		Size:    int64(len(manifestBytes)),
+		ModTime: manifest.CreatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(manifestBytes); err != nil {
+		return nil, err
+	}
+
+	for _, dump := range dumps {
+		stat, err := dump.file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		err = tw.WriteHeader(&tar.Header{
+			Name:    dump.info.File,
+			Mode:    0600,
+			Size:    stat.Size(),
+			ModTime: manifest.CreatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(tw, dump.file); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if encrypted != nil {
+		if err := encrypted.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if progress != nil {
+		progress(1)
+	}
+	return manifest, nil
+}
+
+// FileName returns the default archive name for a backup taken at t
+func FileName(t time.Time) string {
+	return "fynance-backup-" + t.Format("20060102-150405") + FileExtension
+}
@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"fynance/internal/testdb"
+	"fynance/utils"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCreateRestore(t *testing.T) {
+	ctx := testdb.Connect(t, "fynance_backup_test")
+
+	const retention = 30 * 24 * time.Hour
+	if err := utils.EnsureIndexes(ctx, retention); err != nil {
+		t.Fatal(err)
+	}
+
+	income := utils.GetCollection("income")
+	var docs []any
+	for i := range 1500 {
+		docs = append(docs, bson.M{"description": "Salary", "amount": float64(1000 + i)})
+	}
+	if _, err := income.InsertMany(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	manifest, err := Create(ctx, &archive, testPassphrase, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(archive.Bytes(), encryptedMagic) {
+		t.Fatal("the archive is not encrypted")
+	}
+	if manifest.TotalDocuments() < int64(len(docs)) {
+		t.Fatalf("the manifest lists %d documents, want at least %d", manifest.TotalDocuments(), len(docs))
+	}
+
+	// changes made after the backup are undone by the restore
+	if _, err := income.DeleteMany(ctx, bson.M{"amount": bson.M{"$lt": 1500}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Restore(ctx, bytes.NewReader(archive.Bytes()), "wrong", nil); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("got %v, want ErrWrongPassphrase", err)
+	}
+	truncated := archive.Bytes()[:archive.Len()-1]
+	if _, err := Restore(ctx, bytes.NewReader(truncated), testPassphrase, nil); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("got %v, want ErrCorrupt", err)
+	}
+
+	if _, err := Restore(ctx, bytes.NewReader(archive.Bytes()), testPassphrase, nil); err != nil {
+		t.Fatal(err)
+	}
+	count, err := income.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != int64(len(docs)) {
+		t.Fatalf("restored %d income records, want %d", count, len(docs))
+	}
+
+	// the restored collections replaced the indexed ones
+	missing, err := utils.MissingIndexes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) > 0 {
+		t.Fatalf("indexes missing after the restore: %v", missing)
+	}
+	if got, err := utils.LogRetention(ctx); err != nil || got != retention {
+		t.Fatalf("log retention after the restore: %v %v, want %v", got, err, retention)
+	}
+}
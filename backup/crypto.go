@@ -0,0 +1,269 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted archives start with a fixed header followed by the archive split
+// into chunks, each sealed with XChaCha20-Poly1305. The key is derived from
+// the passphrase with scrypt. Every chunk nonce carries the chunk number and
+// a flag on the last chunk, so reordered, dropped or truncated chunks fail to
+// open. A key check value in the header tells a wrong passphrase apart from
+// a damaged archive.
+//
+//	magic(8) | logN(1) r(1) p(1) | salt(16) | nonce prefix(16) | key check(32)
+
+var (
+	ErrPassphraseRequired = errors.New("the backup is encrypted, a passphrase is required")
+	ErrWrongPassphrase    = errors.New("wrong passphrase for this backup")
+)
+
+var encryptedMagic = []byte("FYNENC01")
+
+const (
+	scryptLogN    = 15
+	scryptR       = 8
+	scryptP       = 1
+	saltSize      = 16
+	prefixSize    = 16
+	keyCheckSize  = sha256.Size
+	headerSize    = 8 + 3 + saltSize + prefixSize + keyCheckSize
+	chunkSize     = 64 * 1024
+	sealedChunk   = chunkSize + chacha20poly1305.Overhead
+	lastChunkFlag = 1
+
+	// The header parameters are bounded so a crafted archive can't make
+	// scrypt allocate unbounded memory. This leaves room above what this
+	// build writes (32MiB) for stronger settings later.
+	maxScryptMemory = 256 << 20
+	maxScryptP      = 4
+)
+
+// deriveKeys returns the encryption key and the key check value for header
+func deriveKeys(passphrase string, header []byte) (cipher.AEAD, []byte, error) {
+	logN, r, p := header[8], header[9], header[10]
+	if logN < 10 || logN > 22 || r == 0 || p == 0 || p > maxScryptP {
+		return nil, nil, ErrCorrupt
+	}
+	if 128*uint64(r)<<logN > maxScryptMemory {
+		return nil, nil, ErrCorrupt
+	}
+	salt := header[11 : 11+saltSize]
+
+	keys, err := scrypt.Key([]byte(passphrase), salt, 1<<logN, int(r), int(p), 2*chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := chacha20poly1305.NewX(keys[:chacha20poly1305.KeySize])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mac := hmac.New(sha256.New, keys[chacha20poly1305.KeySize:])
+	mac.Write(header[:headerSize-keyCheckSize])
+	return aead, mac.Sum(nil), nil
+}
+
+// chunkNonce builds the nonce for chunk number index: the random prefix, a
+// 7 byte counter and the last chunk flag
+func chunkNonce(prefix []byte, index uint64, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	copy(nonce, prefix)
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], index)
+	copy(nonce[prefixSize:prefixSize+7], counter[1:])
+
+	if last {
+		nonce[len(nonce)-1] = lastChunkFlag
+	}
+	return nonce
+}
+
+// encryptWriter seals everything written to it into chunks
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	index  uint64
+	closed bool
+}
+
+// NewEncryptWriter returns a writer that encrypts into w with a key derived
+// from passphrase. Close must be called to write the final chunk.
+func NewEncryptWriter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	header := make([]byte, headerSize)
+	copy(header, encryptedMagic)
+	header[8], header[9], header[10] = scryptLogN, scryptR, scryptP
+	if _, err := rand.Read(header[11 : 11+saltSize+prefixSize]); err != nil {
+		return nil, err
+	}
+
+	aead, keyCheck, err := deriveKeys(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	copy(header[headerSize-keyCheckSize:], keyCheck)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+// seal encrypts the buffered plaintext as the next chunk
+func (e *encryptWriter) seal(last bool) error {
+	prefix := e.header[11+saltSize : 11+saltSize+prefixSize]
+	sealed := e.aead.Seal(nil, chunkNonce(prefix, e.index, last), e.buf, e.header)
+	e.index++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed encrypted backup")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// Only seal a full chunk once more data arrives, so the last
+		// chunk is always the one sealed by Close.
+		if len(e.buf) == chunkSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the final chunk. It does not close the underlying writer.
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+// decryptReader opens chunks as they are read
+type decryptReader struct {
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	header []byte
+	plain  []byte
+	index  uint64
+	done   bool
+}
+
+// NewDecryptReader checks the passphrase against the header of an encrypted
+// archive and returns a reader of the decrypted archive.
+func NewDecryptReader(r io.Reader, passphrase string) (io.Reader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: the header is truncated", ErrCorrupt)
+	}
+	if !bytes.Equal(header[:8], encryptedMagic) {
+		return nil, ErrNotBackup
+	}
+
+	aead, keyCheck, err := deriveKeys(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(keyCheck, header[headerSize-keyCheckSize:]) {
+		return nil, ErrWrongPassphrase
+	}
+
+	return &decryptReader{
+		r:      bufio.NewReaderSize(r, sealedChunk+1),
+		aead:   aead,
+		header: header,
+	}, nil
+}
+
+// open reads and decrypts the next chunk
+func (d *decryptReader) open() error {
+	sealed := make([]byte, sealedChunk)
+	n, err := io.ReadFull(d.r, sealed)
+	switch {
+	case err == io.EOF:
+		// Close always writes a final chunk, so running out here means
+		// chunks were cut off.
+		return fmt.Errorf("%w: the archive is truncated", ErrCorrupt)
+	case err == io.ErrUnexpectedEOF:
+		// a short chunk can only be the last one
+	case err != nil:
+		return err
+	}
+
+	// A full chunk is the last one when nothing follows it
+	last := n < sealedChunk
+	if !last {
+		if _, err := d.r.Peek(1); err == io.EOF {
+			last = true
+		}
+	}
+
+	prefix := d.header[11+saltSize : 11+saltSize+prefixSize]
+	plain, err := d.aead.Open(nil, chunkNonce(prefix, d.index, last), sealed[:n], d.header)
+	if err != nil {
+		if last {
+			return fmt.Errorf("%w: the archive is truncated or has been modified", ErrCorrupt)
+		}
+		return fmt.Errorf("%w: the archive has been modified", ErrCorrupt)
+	}
+
+	d.index++
+	d.plain = plain
+	d.done = last
+	return nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// IsEncrypted reports whether the archive read by r is encrypted, without
+// consuming any of it.
+func IsEncrypted(r *bufio.Reader) bool {
+	magic, err := r.Peek(len(encryptedMagic))
+	return err == nil && bytes.Equal(magic, encryptedMagic)
+}
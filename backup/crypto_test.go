@@ -0,0 +1,138 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+// encrypt seals plain with passphrase
+func encrypt(t *testing.T, plain []byte, passphrase string) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := NewEncryptWriter(&out, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+// decrypt opens an encrypted archive and reads all of it
+func decrypt(data []byte, passphrase string) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(data), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// randomBytes returns n bytes that won't compress
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	sizes := []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 100}
+	for _, size := range sizes {
+		plain := randomBytes(t, size)
+		sealed := encrypt(t, plain, testPassphrase)
+		if !IsEncrypted(bufio.NewReader(bytes.NewReader(sealed))) {
+			t.Fatalf("size %d: the archive is not recognised as encrypted", size)
+		}
+
+		got, err := decrypt(sealed, testPassphrase)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("size %d: the decrypted data differs", size)
+		}
+	}
+}
+
+func TestDecryptRejectsDamage(t *testing.T) {
+	// three full chunks and a short final one
+	sealed := encrypt(t, randomBytes(t, 3*chunkSize+100), testPassphrase)
+
+	flip := func(offset int) []byte {
+		damaged := bytes.Clone(sealed)
+		damaged[offset] ^= 0x01
+		return damaged
+	}
+
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"flipped byte in the first chunk", flip(headerSize + 10)},
+		{"flipped byte in the last chunk", flip(len(sealed) - 1)},
+		{"truncated header", sealed[:headerSize-1]},
+		{"truncated at a chunk boundary", sealed[:headerSize+2*sealedChunk]},
+		{"truncated after the full chunks", sealed[:headerSize+3*sealedChunk]},
+		{"truncated mid-chunk", sealed[:headerSize+sealedChunk+100]},
+		{"chunks swapped", swapChunks(sealed, 0, 1)},
+	}
+	for _, c := range cases {
+		_, err := decrypt(c.data, testPassphrase)
+		if !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: got %v, want ErrCorrupt", c.name, err)
+		}
+	}
+}
+
+func TestDecryptWrongPassphrase(t *testing.T) {
+	sealed := encrypt(t, []byte("fynance"), testPassphrase)
+	if _, err := decrypt(sealed, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("got %v, want ErrWrongPassphrase", err)
+	}
+}
+
+func TestDecryptRejectsCostlyParameters(t *testing.T) {
+	sealed := encrypt(t, []byte("fynance"), testPassphrase)
+
+	cases := []struct {
+		name       string
+		logN, r, p byte
+	}{
+		{"large r", scryptLogN, 255, scryptP},
+		{"large p", scryptLogN, scryptR, 255},
+		{"large N", 22, scryptR, scryptP},
+		{"small N", 9, scryptR, scryptP},
+		{"zero r", scryptLogN, 0, scryptP},
+	}
+	for _, c := range cases {
+		crafted := bytes.Clone(sealed)
+		crafted[8], crafted[9], crafted[10] = c.logN, c.r, c.p
+		if _, err := decrypt(crafted, testPassphrase); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: got %v, want ErrCorrupt", c.name, err)
+		}
+	}
+}
+
+// swapChunks exchanges two full chunks of an encrypted archive
+func swapChunks(sealed []byte, i, j int) []byte {
+	swapped := bytes.Clone(sealed)
+	chunk := func(data []byte, index int) []byte {
+		start := headerSize + index*sealedChunk
+		return data[start : start+sealedChunk]
+	}
+	copy(chunk(swapped, i), chunk(sealed, j))
+	copy(chunk(swapped, j), chunk(sealed, i))
+	return swapped
+}
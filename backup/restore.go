@@ -0,0 +1,322 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fynance/migrations"
+	"fynance/utils"
+	"io"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	ErrNotBackup   = errors.New("the file is not a Fynance backup archive")
+	ErrNewerFormat = errors.New("the backup was made by a newer version of Fynance")
+	ErrCorrupt     = errors.New("the backup archive is corrupted")
+)
+
+const (
+	stagingPrefix  = "restore_staging_"
+	previousPrefix = "restore_previous_"
+	insertBatch    = 1000
+	maxLineSize    = 17 * 1024 * 1024 // a BSON document is at most 16MB
+)
+
+// openArchive decrypts the archive if needed, then reads and validates the
+// manifest at its start
+func openArchive(r io.Reader, passphrase string) (*tar.Reader, *Manifest, error) {
+	br := bufio.NewReader(r)
+	r = br
+	if IsEncrypted(br) {
+		if passphrase == "" {
+			return nil, nil, ErrPassphraseRequired
+		}
+		var err error
+		r, err = NewDecryptReader(br, passphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Decryption failures surface through the readers below and are
+	// reported as such, anything else means this isn't a backup at all
+	notBackup := func(err error) error {
+		if errors.Is(err, ErrCorrupt) {
+			return err
+		}
+		return ErrNotBackup
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, notBackup(err)
+	}
+	tr := tar.NewReader(gz)
+
+	header, err := tr.Next()
+	if err != nil {
+		return nil, nil, notBackup(err)
+	}
+	if header.Name != manifestFileName {
+		return nil, nil, ErrNotBackup
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, nil, notBackup(err)
+	}
+	if manifest.Format != formatName {
+		return nil, nil, ErrNotBackup
+	}
+	if manifest.Version > FormatVersion || manifest.Schema > migrations.Latest() {
+		return nil, nil, ErrNewerFormat
+	}
+
+	for _, collection := range manifest.Collections {
+		if !slices.Contains(Collections, collection.Name) {
+			return nil, nil, fmt.Errorf("%w: unknown collection %q", ErrNotBackup, collection.Name)
+		}
+	}
+
+	return tr, &manifest, nil
+}
+
+// Inspect reads only the manifest of an archive so the user can review what
+// a restore would replace. It returns ErrPassphraseRequired for an encrypted
+// archive when passphrase is empty.
+func Inspect(r io.Reader, passphrase string) (*Manifest, error) {
+	_, manifest, err := openArchive(r, passphrase)
+	return manifest, err
+}
+
+// CurrentCounts returns the number of documents currently in each collection
+func CurrentCounts(ctx context.Context) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for _, name := range Collections {
+		count, err := utils.GetCollection(name).CountDocuments(ctx, bson.M{})
+		if err != nil {
+			return nil, err
+		}
+		counts[name] = count
+	}
+	return counts, nil
+}
+
+// readCollection decodes one archive entry, handing documents to insert in
+// batches, and verifies the entry against the manifest.
+func readCollection(r io.Reader, info CollectionInfo, insert func(docs []any) error, onDocument func()) error {
+	hash := sha256.New()
+	scanner := bufio.NewScanner(io.TeeReader(r, hash))
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	var count int64
+	var docs []any
+	for scanner.Scan() {
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &doc); err != nil {
+			return fmt.Errorf("%w: %s line %d: %v", ErrCorrupt, info.File, count+1, err)
+		}
+		docs = append(docs, doc)
+		count++
+		onDocument()
+
+		if len(docs) == insertBatch {
+			if err := insert(docs); err != nil {
+				return err
+			}
+			docs = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrCorrupt, info.File, err)
+	}
+	if len(docs) > 0 {
+		if err := insert(docs); err != nil {
+			return err
+		}
+	}
+
+	if count != info.Count || hex.EncodeToString(hash.Sum(nil)) != info.SHA256 {
+		return fmt.Errorf("%w: checksum mismatch in %s", ErrCorrupt, info.File)
+	}
+	return nil
+}
+
+// stageCollection loads one archive entry into its staging collection
+func stageCollection(ctx context.Context, r io.Reader, info CollectionInfo, onDocument func()) error {
+	staging := utils.GetCollection(stagingPrefix + info.Name)
+	if err := staging.Drop(ctx); err != nil {
+		return err
+	}
+	if err := utils.GetDatabase().CreateCollection(ctx, staging.Name()); err != nil {
+		return err
+	}
+
+	insert := func(docs []any) error {
+		_, err := staging.InsertMany(ctx, docs)
+		return err
+	}
+	return readCollection(r, info, insert, onDocument)
+}
+
+// renameCollection renames within the Fynance database, replacing the target
+func renameCollection(ctx context.Context, from, to string) error {
+	db := utils.GetDatabase().Name()
+	return utils.Client().Database("admin").RunCommand(ctx, bson.D{
+		{Key: "renameCollection", Value: db + "." + from},
+		{Key: "to", Value: db + "." + to},
+		{Key: "dropTarget", Value: true},
+	}).Err()
+}
+
+// swapCollections moves every staged collection into place. The live
+// collections are first set aside, so any failure puts them all back.
+func swapCollections(ctx context.Context, names []string) error {
+	db := utils.GetDatabase()
+	existing, err := db.ListCollectionNames(ctx, bson.M{"name": bson.M{"$in": names}})
+	if err != nil {
+		return err
+	}
+
+	type rename struct{ from, to string }
+	var done []rename
+	apply := func(from, to string) error {
+		if err := renameCollection(ctx, from, to); err != nil {
+			return err
+		}
+		done = append(done, rename{from, to})
+		return nil
+	}
+
+	for _, name := range names {
+		if slices.Contains(existing, name) {
+			err = apply(name, previousPrefix+name)
+		}
+		if err == nil {
+			err = apply(stagingPrefix+name, name)
+		}
+		if err != nil {
+			// Undo in reverse order. A fresh context is used so a
+			// cancelled restore still rolls back.
+			for i := len(done) - 1; i >= 0; i-- {
+				renameCollection(context.Background(), done[i].to, done[i].from)
+			}
+			return err
+		}
+	}
+
+	for _, name := range existing {
+		db.Collection(previousPrefix + name).Drop(ctx)
+	}
+	return nil
+}
+
+// dropStaging removes any staging collections left from this restore
+func dropStaging(names []string) {
+	for _, name := range names {
+		utils.GetCollection(stagingPrefix + name).Drop(context.Background())
+	}
+}
+
+// readEntries hands every collection entry of the archive to stage and
+// checks that none listed in the manifest is missing
+func readEntries(tr *tar.Reader, manifest *Manifest, stage func(r io.Reader, info CollectionInfo) error) error {
+	staged := make(map[string]bool)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+
+		index := slices.IndexFunc(manifest.Collections, func(c CollectionInfo) bool {
+			return c.File == header.Name
+		})
+		if index < 0 {
+			return fmt.Errorf("%w: unexpected entry %q", ErrNotBackup, header.Name)
+		}
+
+		info := manifest.Collections[index]
+		if err := stage(tr, info); err != nil {
+			return err
+		}
+		staged[info.Name] = true
+	}
+
+	for _, collection := range manifest.Collections {
+		if !staged[collection.Name] {
+			return fmt.Errorf("%w: %s is missing from the archive", ErrCorrupt, collection.Name)
+		}
+	}
+	return nil
+}
+
+// Restore replaces the collections in the archive with its contents. It is
+// all-or-nothing: everything is loaded and verified in staging collections
+// before the live data is touched, and the swap rolls back on failure. The
+// indexes of the replaced collections are created again afterwards.
+// progress receives values between 0 and 1 and may be nil.
+func Restore(ctx context.Context, r io.Reader, passphrase string, progress func(float64)) (*Manifest, error) {
+	tr, manifest, err := openArchive(r, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, collection := range manifest.Collections {
+		names = append(names, collection.Name)
+	}
+	defer dropStaging(names)
+
+	total := manifest.TotalDocuments()
+	var done int64
+	onDocument := func() {
+		done++
+		if progress != nil && total > 0 && done%500 == 0 {
+			progress(min(float64(done)/float64(total), 0.99))
+		}
+	}
+
+	stage := func(r io.Reader, info CollectionInfo) error {
+		return stageCollection(ctx, r, info, onDocument)
+	}
+	if err := readEntries(tr, manifest, stage); err != nil {
+		return nil, err
+	}
+
+	// Staging collections are created bare, so the indexes are made again
+	// once they are in place, keeping the log retention already set
+	retention, err := utils.LogRetention(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := swapCollections(ctx, names); err != nil {
+		return nil, err
+	}
+	utils.InvalidateCounts()
+
+	// bring data from an older schema up to date
+	if err := migrations.Restored(ctx, manifest.Schema); err != nil {
+		return nil, fmt.Errorf("the data was restored but could not be migrated: %w", err)
+	}
+	if err := utils.EnsureIndexes(ctx, retention); err != nil {
+		return nil, fmt.Errorf("the data was restored but its indexes could not be created: %w", err)
+	}
+
+	if progress != nil {
+		progress(1)
+	}
+	return manifest, nil
+}
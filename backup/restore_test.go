@@ -0,0 +1,256 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testCollection is a collection written into a test archive
+type testCollection struct {
+	name string
+	docs []bson.D
+}
+
+// testCollections returns sample data spread over a few collections, with
+// enough documents to fill several encrypted chunks
+func testCollections() []testCollection {
+	var income []bson.D
+	for i := range 8000 {
+		reference := make([]byte, 24)
+		rand.Read(reference)
+		income = append(income, bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "description", Value: "Salary"},
+			{Key: "reference", Value: hex.EncodeToString(reference)},
+			{Key: "amount", Value: float64(1000 + i)},
+			{Key: "date", Value: primitive.NewDateTimeFromTime(time.Date(2025, 1, 1+i%28, 0, 0, 0, 0, time.UTC))},
+		})
+	}
+	return []testCollection{
+		{name: "income", docs: income},
+		{name: "expenses", docs: []bson.D{
+			{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "description", Value: "Rent"}, {Key: "amount", Value: 850.5}},
+		}},
+		{name: "rules", docs: nil},
+	}
+}
+
+// buildArchive writes collections as Create does, encrypted when a
+// passphrase is given. edit may change the manifest before it is written.
+func buildArchive(t *testing.T, collections []testCollection, passphrase string, edit func(*Manifest)) []byte {
+	t.Helper()
+	manifest := &Manifest{
+		Format:    formatName,
+		Version:   FormatVersion,
+		Database:  "fynance",
+		CreatedAt: time.Now().UTC(),
+	}
+
+	files := make([][]byte, len(collections))
+	for i, collection := range collections {
+		var file bytes.Buffer
+		for _, doc := range collection.docs {
+			line, err := bson.MarshalExtJSON(doc, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.Write(append(line, '\n'))
+		}
+		sum := sha256.Sum256(file.Bytes())
+		files[i] = file.Bytes()
+		manifest.Collections = append(manifest.Collections, CollectionInfo{
+			Name:   collection.name,
+			File:   collectionsDir + collection.name + ".jsonl",
+			Count:  int64(len(collection.docs)),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	if edit != nil {
+		edit(manifest)
+	}
+
+	var out bytes.Buffer
+	var w io.Writer = &out
+	var encrypted io.WriteCloser
+	if passphrase != "" {
+		var err error
+		encrypted, err = NewEncryptWriter(&out, passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = encrypted
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeEntry(t, tw, manifestFileName, manifestBytes)
+	for i, collection := range collections {
+		writeEntry(t, tw, collectionsDir+collection.name+".jsonl", files[i])
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if encrypted != nil {
+		if err := encrypted.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out.Bytes()
+}
+
+func writeEntry(t *testing.T, tw *tar.Writer, name string, data []byte) {
+	t.Helper()
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// restoreToMemory runs the reading and verification of Restore, keeping the
+// documents in memory instead of staging collections
+func restoreToMemory(archive []byte, passphrase string) (map[string][]any, error) {
+	tr, manifest, err := openArchive(bytes.NewReader(archive), passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := make(map[string][]any)
+	stage := func(r io.Reader, info CollectionInfo) error {
+		insert := func(docs []any) error {
+			restored[info.Name] = append(restored[info.Name], docs...)
+			return nil
+		}
+		return readCollection(r, info, insert, func() {})
+	}
+	if err := readEntries(tr, manifest, stage); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+func TestRestoreRoundTrip(t *testing.T) {
+	collections := testCollections()
+	for _, passphrase := range []string{"", testPassphrase} {
+		archive := buildArchive(t, collections, passphrase, nil)
+		if encrypted := passphrase != ""; bytes.HasPrefix(archive, encryptedMagic) != encrypted {
+			t.Fatalf("encrypted archive: got %v, want %v", !encrypted, encrypted)
+		}
+
+		restored, err := restoreToMemory(archive, passphrase)
+		if err != nil {
+			t.Fatalf("passphrase %q: %v", passphrase, err)
+		}
+		for _, collection := range collections {
+			docs := restored[collection.name]
+			if len(docs) != len(collection.docs) {
+				t.Fatalf("%s: restored %d documents, want %d", collection.name, len(docs), len(collection.docs))
+			}
+			for i, doc := range docs {
+				got, _ := bson.MarshalExtJSON(doc, true, false)
+				want, _ := bson.MarshalExtJSON(collection.docs[i], true, false)
+				if !bytes.Equal(got, want) {
+					t.Fatalf("%s document %d: got %s, want %s", collection.name, i, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestRestoreRejectsDamagedArchive(t *testing.T) {
+	archive := buildArchive(t, testCollections(), testPassphrase, nil)
+	if len(archive) < headerSize+3*sealedChunk {
+		t.Fatalf("the test archive has too few chunks (%d bytes)", len(archive))
+	}
+
+	flipped := bytes.Clone(archive)
+	flipped[headerSize+sealedChunk+10] ^= 0x01
+
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"flipped byte", flipped},
+		{"truncated at a chunk boundary", archive[:headerSize+2*sealedChunk]},
+		{"truncated mid-chunk", archive[:headerSize+sealedChunk+100]},
+	}
+	for _, c := range cases {
+		_, err := restoreToMemory(c.data, testPassphrase)
+		if !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: got %v, want ErrCorrupt", c.name, err)
+		}
+	}
+}
+
+func TestRestoreWrongPassphrase(t *testing.T) {
+	archive := buildArchive(t, testCollections(), testPassphrase, nil)
+
+	if _, err := restoreToMemory(archive, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("got %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := restoreToMemory(archive, ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Fatalf("got %v, want ErrPassphraseRequired", err)
+	}
+}
+
+func TestRestoreRejectsManifestMismatch(t *testing.T) {
+	cases := []struct {
+		name string
+		edit func(*Manifest)
+	}{
+		{"checksum", func(m *Manifest) { m.Collections[0].SHA256 = hex.EncodeToString(make([]byte, sha256.Size)) }},
+		{"count", func(m *Manifest) { m.Collections[1].Count++ }},
+		{"missing entry", func(m *Manifest) {
+			m.Collections = append(m.Collections, CollectionInfo{Name: "logs", File: collectionsDir + "logs.jsonl"})
+		}},
+	}
+	for _, c := range cases {
+		archive := buildArchive(t, testCollections(), "", c.edit)
+		if _, err := restoreToMemory(archive, ""); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: got %v, want ErrCorrupt", c.name, err)
+		}
+	}
+}
+
+func TestInspectRejectsOtherFiles(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"plain text", []byte("date,amount\n2025-01-01,10\n")},
+		{"other format", buildArchive(t, nil, "", func(m *Manifest) { m.Format = "other" })},
+		{"unknown collection", buildArchive(t, []testCollection{{name: "secrets"}}, "", nil)},
+	}
+	for _, c := range cases {
+		if _, err := Inspect(bytes.NewReader(c.data), ""); !errors.Is(err, ErrNotBackup) {
+			t.Errorf("%s: got %v, want ErrNotBackup", c.name, err)
+		}
+	}
+
+	newer := buildArchive(t, nil, "", func(m *Manifest) { m.Version = FormatVersion + 1 })
+	if _, err := Inspect(bytes.NewReader(newer), ""); !errors.Is(err, ErrNewerFormat) {
+		t.Errorf("newer format: got %v, want ErrNewerFormat", err)
+	}
+}
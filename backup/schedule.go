@@ -0,0 +1,198 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PassphraseEnv supplies the passphrase for unattended (CLI and scheduled)
+// backups. Scheduled backups are left unencrypted when it is not set.
+const PassphraseEnv = "FYNANCE_BACKUP_PASSPHRASE"
+
+// ErrInvalidInterval is returned for a schedule without a positive interval
+var ErrInvalidInterval = errors.New("the backup interval must be positive")
+
+// retryAfter is the wait before a failed scheduled backup is tried again
+const retryAfter = time.Hour
+
+// Schedule configures automatic backups
+type Schedule struct {
+	Interval   time.Duration // time between backups
+	Dir        string        // folder the archives are written to
+	Keep       int           // number of archives kept, older ones are removed
+	AlertAfter time.Duration // report when the last success is older than this
+}
+
+// Result describes one scheduler event, either a backup run or an overdue check
+type Result struct {
+	Path        string    // archive written, empty when the run failed
+	Err         error     // why the run failed
+	Removed     []string  // old archives deleted by rotation
+	LastSuccess time.Time // newest archive in the folder, zero if none
+	Overdue     bool      // no successful backup within AlertAfter
+}
+
+// Scheduler runs backups in the background until stopped
+type Scheduler struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// archives returns the backup archives in dir, newest first
+func archives(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, "fynance-backup-") && strings.HasSuffix(name, FileExtension) {
+			names = append(names, name)
+		}
+	}
+
+	// The timestamp in the name sorts chronologically
+	slices.Sort(names)
+	slices.Reverse(names)
+	return names, nil
+}
+
+// lastSuccess returns when the newest archive in dir was written
+func lastSuccess(dir string) time.Time {
+	names, err := archives(dir)
+	if err != nil || len(names) == 0 {
+		return time.Time{}
+	}
+	info, err := os.Stat(filepath.Join(dir, names[0]))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// rotate removes all but the newest keep archives in dir
+func rotate(dir string, keep int) ([]string, error) {
+	names, err := archives(dir)
+	if err != nil || keep <= 0 || len(names) <= keep {
+		return nil, err
+	}
+
+	var removed []string
+	for _, name := range names[keep:] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
+
+// RunOnce writes one archive into the schedule folder and rotates old ones.
+// The archive is written under a temporary name so a failed run never
+// leaves a partial file that looks like a backup.
+func RunOnce(ctx context.Context, schedule Schedule) Result {
+	if err := os.MkdirAll(schedule.Dir, 0o755); err != nil {
+		return Result{Err: err, LastSuccess: lastSuccess(schedule.Dir)}
+	}
+
+	path := filepath.Join(schedule.Dir, FileName(time.Now()))
+	partial := path + ".partial"
+
+	err := func() error {
+		file, err := os.Create(partial)
+		if err != nil {
+			return err
+		}
+		_, err = Create(ctx, file, os.Getenv(PassphraseEnv), nil)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		return os.Rename(partial, path)
+	}()
+	if err != nil {
+		os.Remove(partial)
+		return Result{Err: err, LastSuccess: lastSuccess(schedule.Dir)}
+	}
+
+	removed, err := rotate(schedule.Dir, schedule.Keep)
+	return Result{Path: path, Err: err, Removed: removed, LastSuccess: time.Now()}
+}
+
+// StartScheduler runs a backup whenever one is due and checks hourly that the
+// last success isn't older than AlertAfter. Every run and every overdue check
+// is passed to report, from the scheduler goroutine.
+func StartScheduler(schedule Schedule, report func(Result)) (*Scheduler, error) {
+	if schedule.Interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(s.done)
+
+		checks := time.NewTicker(time.Hour)
+		defer checks.Stop()
+
+		// A backup is due one interval after the last success, right away
+		// when there is none. A failed run is retried sooner, without
+		// touching the last success the overdue check relies on.
+		last := lastSuccess(schedule.Dir)
+		next := last.Add(schedule.Interval)
+		var lastAlert time.Time
+		overdue := func() bool {
+			return schedule.AlertAfter > 0 && time.Since(last) > schedule.AlertAfter
+		}
+
+		for {
+			timer := time.NewTimer(max(time.Until(next), 0))
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+
+			case <-timer.C:
+				result := RunOnce(ctx, schedule)
+				if ctx.Err() != nil {
+					return
+				}
+				if result.Path != "" {
+					last = result.LastSuccess
+					next = last.Add(schedule.Interval)
+				} else {
+					next = time.Now().Add(min(retryAfter, schedule.Interval))
+				}
+				result.Overdue = overdue()
+				report(result)
+
+			case <-checks.C:
+				timer.Stop()
+				// Remind at most once a day
+				if overdue() && time.Since(lastAlert) > 24*time.Hour {
+					lastAlert = time.Now()
+					report(Result{LastSuccess: last, Overdue: true})
+				}
+			}
+		}
+	}()
+
+	return s, nil
+}
+
+// Stop cancels the scheduler and waits for a running backup to finish
+func (s *Scheduler) Stop() {
+	s.cancel()
+	<-s.done
+}
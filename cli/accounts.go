@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"fynance/helpers"
+	"fynance/models"
+	"fynance/utils"
+	"strings"
+	"time"
+)
+
+func runAccounts(s *session, args []string) error {
+	usage := "accounts list|add|history|transfer [flags]"
+	action, args, err := subcommand(args, usage)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "list":
+		return accountsList(s, args)
+	case "add":
+		return accountsAdd(s, args)
+	case "history":
+		return accountsHistory(s, args)
+	case "transfer":
+		return accountsTransfer(s, args)
+	default:
+		return usageError(usage)
+	}
+}
+
+// findAccount returns the account called name, ignoring case
+func findAccount(s *session, name string) (models.Account, error) {
+	accounts, err := utils.ListAccounts(s.ctx)
+	if err != nil {
+		return models.Account{}, err
+	}
+	for _, account := range accounts {
+		if strings.EqualFold(account.Name, name) {
+			return account, nil
+		}
+	}
+	return models.Account{}, fmt.Errorf("no account named %q", name)
+}
+
+func accountsList(s *session, args []string) error {
+	if len(args) > 0 {
+		return usageError("accounts list")
+	}
+	balances, err := utils.AccountBalances(s.ctx)
+	if err != nil {
+		return err
+	}
+
+	t := table{headers: []string{"NAME", "TYPE", "OPENING", "INCOME", "EXPENSES", "TRANSFERS", "BALANCE"}}
+	var total float64
+	for _, b := range balances {
+		t.add(b.Account.Name, b.Account.Type, formatAmount(b.Account.OpeningBalance), formatAmount(b.Income),
+			formatAmount(b.Expenses), formatAmount(b.TransfersIn-b.TransfersOut), formatAmount(b.Balance))
+		total += b.Balance
+	}
+	t.add("", "", "", "", "", "TOTAL", formatAmount(total))
+	if balances == nil {
+		balances = []models.AccountBalance{}
+	}
+	return s.print(balances, t)
+}
+
+func accountsAdd(s *session, args []string) error {
+	usage := "accounts add [-type TYPE] [-opening AMOUNT] NAME"
+	flags := flag.NewFlagSet("accounts add", flag.ContinueOnError)
+	kind := flags.String("type", models.AccountBank, "one of "+strings.Join(utils.AccountTypes, ", "))
+	opening := flags.Float64("opening", 0, "the opening balance")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return usageError(usage)
+	}
+
+	account, err := utils.AddAccount(s.ctx, models.Account{Name: flags.Arg(0), Type: *kind, OpeningBalance: *opening})
+	if err != nil {
+		return err
+	}
+	s.audit("Added account " + account.Name)
+	return s.printMessage(fmt.Sprintf("Account added: %s (%s), opening balance %s", account.Name, account.Type, formatAmount(account.OpeningBalance)), map[string]any{"id": account.ID.Hex()})
+}
+
+func accountsHistory(s *session, args []string) error {
+	if len(args) != 1 {
+		return usageError("accounts history NAME")
+	}
+	account, err := findAccount(s, args[0])
+	if err != nil {
+		return err
+	}
+	history, err := utils.AccountHistory(s.ctx, account)
+	if err != nil {
+		return err
+	}
+
+	t := table{headers: []string{"MONTH", "YEAR", "INCOME", "EXPENSES", "TRANSFERS", "BALANCE"}}
+	t.add("Opening", "", "", "", "", formatAmount(account.OpeningBalance))
+	for _, point := range history {
+		t.add(point.Month, point.Year, formatAmount(point.Income), formatAmount(point.Expenses),
+			formatAmount(point.TransfersIn-point.TransfersOut), formatAmount(point.Balance))
+	}
+	return s.print(history, t)
+}
+
+func accountsTransfer(s *session, args []string) error {
+	usage := "accounts transfer -from NAME -to NAME -amount AMOUNT [-month MONTH] [-year YEAR] [-notes TEXT]"
+	flags := flag.NewFlagSet("accounts transfer", flag.ContinueOnError)
+	from := flags.String("from", "", "account the money leaves (required)")
+	to := flags.String("to", "", "account the money goes into (required)")
+	amount := flags.Float64("amount", 0, "amount (required)")
+	month := flags.String("month", helpers.Months[time.Now().Month()-1], "month")
+	year := flags.String("year", time.Now().Format("2006"), "year")
+	notes := flags.String("notes", "", "free text")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 || *from == "" || *to == "" {
+		return usageError(usage)
+	}
+
+	fromAccount, err := findAccount(s, *from)
+	if err != nil {
+		return err
+	}
+	toAccount, err := findAccount(s, *to)
+	if err != nil {
+		return err
+	}
+
+	transfer, err := utils.AddTransfer(s.ctx, models.Transfer{
+		FromID:    fromAccount.ID,
+		ToID:      toAccount.ID,
+		Amount:    *amount,
+		Month:     *month,
+		Year:      *year,
+		Notes:     *notes,
+		CreatedBy: s.user.ID,
+	})
+	if err != nil {
+		return err
+	}
+	detail := fmt.Sprintf("Transferred %s from %s to %s", formatAmount(transfer.Amount), fromAccount.Name, toAccount.Name)
+	s.audit(detail)
+	return s.printMessage(detail, map[string]any{"id": transfer.ID.Hex()})
+}
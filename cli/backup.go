@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"fynance/backup"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// readPassphrase takes the backup passphrase from the environment or asks
+// for it on the terminal, twice when confirm is set
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(backup.PassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Backup passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New("the passphrase cannot be empty")
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+		repeat, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(repeat) != string(passphrase) {
+			return "", errors.New("the passphrases do not match")
+		}
+	}
+
+	return string(passphrase), nil
+}
+
+// runBackup writes a backup archive of the whole database
+func runBackup(s *session, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	output := flags.String("o", backup.FileName(time.Now()), "archive to write")
+	encrypt := flags.Bool("encrypt", false, "encrypt the archive with a passphrase (or "+backup.PassphraseEnv+")")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return usageError("backup [-o ARCHIVE] [-encrypt]")
+	}
+
+	var passphrase string
+	if *encrypt {
+		var err error
+		if passphrase, err = readPassphrase(true); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := backup.Create(s.ctx, file, passphrase, nil)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*output)
+		return fmt.Errorf("backup failed: %w", err)
+	}
+
+	detail := fmt.Sprintf("backed up %d records to %s", manifest.TotalDocuments(), *output)
+	s.audit(detail)
+	return s.printMessage(detail, map[string]any{"file": *output, "records": manifest.TotalDocuments()})
+}
+
+// runRestore replaces the database contents with a backup archive
+func runRestore(s *session, args []string) error {
+	usage := "restore [-yes] ARCHIVE"
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	yes := flags.Bool("yes", false, "restore without asking for confirmation")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return usageError(usage)
+	}
+	path := flags.Arg(0)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var passphrase string
+	manifest, err := backup.Inspect(file, passphrase)
+	if errors.Is(err, backup.ErrPassphraseRequired) {
+		if passphrase, err = readPassphrase(false); err != nil {
+			return err
+		}
+		if _, err = file.Seek(0, 0); err == nil {
+			manifest, err = backup.Inspect(file, passphrase)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := backup.CurrentCounts(s.ctx)
+	if err != nil {
+		return err
+	}
+
+	// The summary goes to stderr so JSON output stays clean
+	fmt.Fprintf(os.Stderr, "Backup taken %s\n", manifest.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(os.Stderr, "%-20s %10s %10s\n", "COLLECTION", "CURRENT", "BACKUP")
+	for _, collection := range manifest.Collections {
+		fmt.Fprintf(os.Stderr, "%-20s %10d %10d\n", collection.Name, current[collection.Name], collection.Count)
+	}
+
+	if !*yes {
+		fmt.Fprint(os.Stderr, "All current data in these collections will be replaced. Type 'yes' to continue: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			return errors.New("restore cancelled")
+		}
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		return err
+	}
+
+	if _, err := backup.Restore(s.ctx, file, passphrase, nil); err != nil {
+		return fmt.Errorf("restore failed, no data was changed: %w", err)
+	}
+
+	detail := fmt.Sprintf("restored %d records from %s", manifest.TotalDocuments(), path)
+	s.audit(detail)
+	return s.printMessage(detail, map[string]any{"file": path, "records": manifest.TotalDocuments()})
+}
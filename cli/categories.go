@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"fynance/models"
+	"fynance/utils"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// category is an income or expense category as the command line shows it.
+// Path is its full name, such as "Transport > Fuel".
+type category struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// listCategories returns the categories of kind, or of both kinds when
+// empty, each followed by its subcategories
+func listCategories(s *session, kind string) ([]category, error) {
+	categories := []category{}
+	for _, k := range []string{"income", "expense"} {
+		if kind != "" && kind != k {
+			continue
+		}
+		tree, err := utils.LoadCategoryTree(s.ctx, k)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range tree.Categories {
+			listed := category{ID: c.ID.Hex(), Type: k, Name: c.Name, Path: c.Path, CreatedAt: c.CreatedAt}
+			if !c.ParentID.IsZero() {
+				listed.ParentID = c.ParentID.Hex()
+			}
+			categories = append(categories, listed)
+		}
+	}
+	return categories, nil
+}
+
+func runCategory(s *session, args []string) error {
+	usage := "category list|add|rename|move|delete [-type income|expense] [NAME]"
+	action, args, err := subcommand(args, usage)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("category "+action, flag.ContinueOnError)
+	kind := flags.String("type", "", "income or expense")
+	parent := flags.String("parent", "", "add: the category to file the new one under")
+	reassign := flags.String("reassign", "", "delete: the category to move the records and rules of the deleted one to")
+	if err := flags.Parse(args); err != nil {
+		return usageError(usage)
+	}
+	if *kind != "" && *kind != "income" && *kind != "expense" {
+		return errors.New("-type must be income or expense")
+	}
+
+	switch action {
+	case "list":
+		categories, err := listCategories(s, *kind)
+		if err != nil {
+			return err
+		}
+		t := table{headers: []string{"ID", "TYPE", "NAME"}}
+		for _, c := range categories {
+			t.add(c.ID, c.Type, c.Path)
+		}
+		return s.print(categories, t)
+
+	case "add":
+		if *kind == "" || flags.NArg() != 1 {
+			return usageError("category add -type income|expense [-parent PARENT] NAME")
+		}
+		return categoryAdd(s, *kind, strings.TrimSpace(flags.Arg(0)), *parent)
+
+	case "rename":
+		if *kind == "" || flags.NArg() != 2 {
+			return usageError("category rename -type income|expense NAME NEW-NAME")
+		}
+		return categoryRename(s, *kind, flags.Arg(0), strings.TrimSpace(flags.Arg(1)))
+
+	case "move":
+		if *kind == "" || flags.NArg() < 1 || flags.NArg() > 2 {
+			return usageError("category move -type income|expense NAME [PARENT]")
+		}
+		return categoryMove(s, *kind, flags.Arg(0), flags.Arg(1))
+
+	case "delete":
+		if *kind == "" || flags.NArg() != 1 {
+			return usageError("category delete -type income|expense [-reassign CATEGORY] NAME")
+		}
+		return categoryDelete(s, *kind, flags.Arg(0), *reassign)
+
+	default:
+		return usageError(usage)
+	}
+}
+
+// findCategory looks up a category of kind by its full name
+func findCategory(s *session, kind, path string) (utils.Category, error) {
+	tree, err := utils.LoadCategoryTree(s.ctx, kind)
+	if err != nil {
+		return utils.Category{}, err
+	}
+	c, ok := tree.FindPath(path)
+	if !ok {
+		return c, fmt.Errorf("no %s category named %q", kind, path)
+	}
+	return c, nil
+}
+
+func categoryAdd(s *session, kind, name, parent string) error {
+	var parentID primitive.ObjectID
+	if parent != "" {
+		c, err := findCategory(s, kind, parent)
+		if err != nil {
+			return err
+		}
+		parentID = c.ID
+	}
+	if err := utils.CheckCategory(s.ctx, kind, primitive.NilObjectID, name, parentID); err != nil {
+		return err
+	}
+
+	parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
+	if err != nil {
+		return err
+	}
+
+	id := primitive.NewObjectID()
+	if kind == "income" {
+		err = utils.AddDetail(models.IncomeDetail{ID: id, IncomeCategory: name, ParentID: parentID, CreatedAt: parsedTime}, nil)
+	} else {
+		err = utils.AddExpenseDetail(models.ExpenseDetail{ID: id, ExpenseCategory: name, ParentID: parentID, CreatedAt: parsedTime}, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.audit("Added " + name)
+	return s.printMessage(fmt.Sprintf("%s category added: %s", kind, name), map[string]any{"id": id.Hex()})
+}
+
+// saveCategory stores a category under its new name and parent. Records
+// filed under it, or under its subcategories, follow.
+func saveCategory(s *session, kind string, c utils.Category, name string, parentID primitive.ObjectID) error {
+	if err := utils.CheckCategory(s.ctx, kind, c.ID, name, parentID); err != nil {
+		return err
+	}
+
+	parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
+	if err != nil {
+		return err
+	}
+	if kind == "income" {
+		return utils.UpdateDetail(models.IncomeDetail{ID: c.ID, IncomeCategory: name, ParentID: parentID, CreatedAt: c.CreatedAt, UpdatedAt: parsedTime}, nil)
+	}
+	return utils.UpdateExpenseDetail(models.ExpenseDetail{ID: c.ID, ExpenseCategory: name, ParentID: parentID, CreatedAt: c.CreatedAt, UpdatedAt: parsedTime}, nil)
+}
+
+func categoryRename(s *session, kind, path, name string) error {
+	c, err := findCategory(s, kind, path)
+	if err != nil {
+		return err
+	}
+	if err := saveCategory(s, kind, c, name, c.ParentID); err != nil {
+		return err
+	}
+
+	s.audit("Edited " + c.Path + " to " + name)
+	return s.printMessage(fmt.Sprintf("%s category renamed: %s to %s", kind, c.Path, name), map[string]any{"id": c.ID.Hex()})
+}
+
+// categoryMove files a category under parent, or at the top when parent is
+// empty
+func categoryMove(s *session, kind, path, parent string) error {
+	c, err := findCategory(s, kind, path)
+	if err != nil {
+		return err
+	}
+	var parentID primitive.ObjectID
+	if parent != "" {
+		p, err := findCategory(s, kind, parent)
+		if err != nil {
+			return err
+		}
+		parentID = p.ID
+	}
+	if err := saveCategory(s, kind, c, c.Name, parentID); err != nil {
+		return err
+	}
+
+	tree, err := utils.LoadCategoryTree(s.ctx, kind)
+	if err != nil {
+		return err
+	}
+	moved, _ := tree.Find(c.ID)
+	s.audit("Edited " + c.Path + " to " + moved.Path)
+	return s.printMessage(fmt.Sprintf("%s category moved: %s to %s", kind, c.Path, moved.Path), map[string]any{"id": c.ID.Hex()})
+}
+
+// categoryDelete deletes a category no records use, moving its records to
+// the reassign category first when one is given
+func categoryDelete(s *session, kind, path, reassign string) error {
+	c, err := findCategory(s, kind, path)
+	if err != nil {
+		return err
+	}
+
+	if reassign != "" {
+		target, err := findCategory(s, kind, reassign)
+		if err != nil {
+			return err
+		}
+		moved, err := utils.ReassignCategory(s.ctx, kind, c.ID, target.ID)
+		if err != nil {
+			return err
+		}
+		s.audit(fmt.Sprintf("moved %d %s records from %s to %s", moved, kind, c.Path, target.Path))
+	}
+
+	if kind == "income" {
+		err = utils.DeleteDetail(c.ID, nil)
+	} else {
+		err = utils.DeleteExpenseDetail(c.ID, nil)
+	}
+	if errors.Is(err, utils.ErrCategoryInUse) {
+		return fmt.Errorf("%w; move its subcategories, or delete it with -reassign CATEGORY to move its records and rules", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.audit("deleted " + kind + " category " + c.Path)
+	return s.printMessage(fmt.Sprintf("%s category deleted: %s", kind, c.Path), map[string]any{"id": c.ID.Hex()})
+}
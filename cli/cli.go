@@ -0,0 +1,205 @@
+// Package cli runs Fynance from the command line, without opening a window.
+// It uses the same data layer as the app and signs in against the users
+// collection before running any command.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"fynance/auth"
+	"fynance/helpers"
+	"fynance/migrations"
+	"fynance/models"
+	"fynance/utils"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/term"
+)
+
+const (
+	uriEnv      = utils.MongoURIEnv
+	userEnv     = "FYNANCE_USER"
+	passwordEnv = "FYNANCE_PASSWORD"
+)
+
+// errUsage is returned by commands called with the wrong arguments
+var errUsage = errors.New("invalid usage")
+
+// usageError reports wrong arguments along with the expected usage
+func usageError(usage string) error {
+	return fmt.Errorf("%w\nusage: fynance %s", errUsage, usage)
+}
+
+// session is what every command runs with
+type session struct {
+	ctx    context.Context
+	user   *models.User
+	format string // "table" or "json"
+}
+
+type command struct {
+	summary string
+	run     func(s *session, args []string) error
+}
+
+var commands = map[string]command{
+	"income":   {"add, list or delete incomes", runIncome},
+	"expense":  {"add, list or delete expenses", runExpense},
+	"category": {"add, list, rename, move or delete income and expense categories", runCategory},
+	"rules":    {"list rules, or preview or apply them to existing records", runRules},
+	"accounts": {"list accounts and their balances, add one or transfer between them", runAccounts},
+	"report":   {"monthly income, expenses and balance for a year", runReport},
+	"import":   {"import incomes or expenses from CSV", runImport},
+	"imports":  {"list import batches or roll one back", runImports},
+	"export":   {"export incomes, expenses or logs to CSV or JSON", runExport},
+	"logs":     {"show or follow the activity log", runLogs},
+	"backup":   {"write a backup archive of the whole database", runBackup},
+	"restore":  {"replace the database contents with a backup archive", runRestore},
+	"serve":    {"serve the REST API until interrupted", runServe},
+	"migrate":  {"apply, or preview with -dry-run, pending database migrations", runMigrate},
+}
+
+var commandOrder = []string{"income", "expense", "category", "rules", "accounts", "report", "import", "imports", "export", "logs", "backup", "restore", "serve", "migrate"}
+
+func printUsage(flags *flag.FlagSet) {
+	fmt.Fprintln(os.Stderr, "usage: fynance [flags] COMMAND [ARGS]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nFlags:")
+	flags.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nThe password is read from %s or asked for on the terminal.\n", passwordEnv)
+}
+
+// Run executes the command line in args and returns the exit code
+func Run(args []string) int {
+	flags := flag.NewFlagSet("fynance", flag.ContinueOnError)
+	uri := flags.String("uri", "", "MongoDB connection URI (default: "+uriEnv+", then the app's saved settings)")
+	username := flags.String("user", os.Getenv(userEnv), "user to sign in as (or "+userEnv+")")
+	format := flags.String("format", "table", "output format: table or json")
+	flags.Usage = func() { printUsage(flags) }
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || flags.Arg(0) == "help" {
+		printUsage(flags)
+		return 2
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "fynance: unknown command %q\n", flags.Arg(0))
+		printUsage(flags)
+		return 2
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintln(os.Stderr, "fynance: -format must be table or json")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// The connection saved by the app, then the environment, then -uri
+	config, err := utils.SavedDBConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fynance: settings:", err)
+		return 1
+	}
+	config = config.WithEnv()
+	if *uri != "" {
+		config.URI = *uri
+	}
+	if err := utils.ConnectWith(config); err != nil {
+		fmt.Fprintln(os.Stderr, "fynance: connect:", err)
+		return 1
+	}
+	defer func() { utils.Client().Disconnect(context.Background()) }()
+	defer waitForWebhooks()
+
+	user, err := login(*username)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fynance:", err)
+		return 1
+	}
+
+	// Only a signed in user changes the database. The migrate command
+	// decides for itself, so it can preview them.
+	if flags.Arg(0) != "migrate" {
+		if _, err := migrations.Run(ctx, false); err != nil {
+			fmt.Fprintln(os.Stderr, "fynance: migrate:", err)
+			return 1
+		}
+	}
+
+	s := &session{ctx: ctx, user: user, format: *format}
+	if err := cmd.run(s, flags.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "fynance:", err)
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// login signs in with the password from the environment or the terminal
+func login(username string) (*models.User, error) {
+	if username == "" {
+		return nil, fmt.Errorf("no user given, use -user or %s", userEnv)
+	}
+
+	password := os.Getenv(passwordEnv)
+	if password == "" {
+		fmt.Fprintf(os.Stderr, "Password for %s: ", username)
+		input, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		password = string(input)
+	}
+
+	user, err := auth.Login(username, password, func(float64) {})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errors.New("invalid username or password")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the data layer attributes notifications to the current user
+	helpers.CurrentUserID = user.ID
+	return user, nil
+}
+
+// audit records a change made from the command line in the activity log
+func (s *session) audit(detail string) {
+	if err := utils.LogEvent(s.user.Username+" "+detail, "SUCCESS"); err != nil {
+		fmt.Fprintln(os.Stderr, "fynance: writing the activity log:", err)
+	}
+}
+
+// subcommand splits args into the action and its arguments
+func subcommand(args []string, usage string) (string, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "", nil, usageError(usage)
+	}
+	return args[0], args[1:], nil
+}
+
+// waitForWebhooks gives webhook deliveries started by the command a moment
+// to finish before the process exits
+func waitForWebhooks() {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	utils.WaitForWebhooks(ctx)
+}
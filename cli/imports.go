@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"fynance/models"
+	"fynance/utils"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func runImports(s *session, args []string) error {
+	usage := "imports list|rollback [flags]"
+	action, args, err := subcommand(args, usage)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "list":
+		return importsList(s, args)
+	case "rollback":
+		return importsRollback(s, args)
+	default:
+		return usageError(usage)
+	}
+}
+
+func importsList(s *session, args []string) error {
+	flags := flag.NewFlagSet("imports list", flag.ContinueOnError)
+	limit := flags.Int64("limit", 50, "maximum number of batches, 0 for all")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return usageError("imports list [-limit N]")
+	}
+
+	batches, err := utils.ListImports(s.ctx, *limit)
+	if err != nil {
+		return err
+	}
+
+	t := table{headers: []string{"ID", "TYPE", "FILE", "USER", "ROWS", "IMPORTED", "SKIPPED", "STATUS", "CREATED"}}
+	for _, batch := range batches {
+		t.add(batch.ID.Hex(), batch.Kind, batch.FileName, batch.Username, strconv.Itoa(batch.Rows),
+			strconv.Itoa(batch.Imported), strconv.Itoa(batch.Skipped), batch.Status, batch.CreatedAt.Format("2006-01-02 15:04"))
+	}
+
+	if batches == nil {
+		batches = []models.ImportBatch{}
+	}
+	return s.print(batches, t)
+}
+
+func importsRollback(s *session, args []string) error {
+	if len(args) != 1 {
+		return usageError("imports rollback ID")
+	}
+	id, err := primitive.ObjectIDFromHex(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid id %q", args[0])
+	}
+
+	batch, removed, err := utils.RollbackImport(s.ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("no import with id %s", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	s.audit(fmt.Sprintf("rolled back import %s of %s, removing %d %s records", batch.ID.Hex(), batch.FileName, removed, batch.Kind))
+	return s.printMessage(fmt.Sprintf("Rolled back import %s: %d %s records removed", batch.ID.Hex(), removed, batch.Kind),
+		map[string]any{"id": batch.ID.Hex(), "count": removed})
+}
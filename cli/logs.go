@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"fynance/models"
+	"fynance/utils"
+	"os"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// logEntry is a log as the command line shows it
+type logEntry struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	Details   string    `json:"details"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func toLogEntries(logs []models.Log) []logEntry {
+	entries := []logEntry{}
+	for _, log := range logs {
+		entries = append(entries, logEntry{log.ID.Hex(), log.Status, log.Details, log.Timestamp})
+	}
+	return entries
+}
+
+func logTable(entries []logEntry) table {
+	t := table{headers: []string{"TIMESTAMP", "STATUS", "DETAILS"}}
+	for _, entry := range entries {
+		t.add(entry.Timestamp.Format("2006-01-02 15:04:05"), entry.Status, entry.Details)
+	}
+	return t
+}
+
+func runLogs(s *session, args []string) error {
+	usage := "logs tail [-n LINES] [-f] [-search TEXT]"
+	action, args, err := subcommand(args, usage)
+	if err != nil {
+		return err
+	}
+	if action != "tail" {
+		return usageError(usage)
+	}
+
+	flags := flag.NewFlagSet("logs tail", flag.ContinueOnError)
+	lines := flags.Int64("n", 20, "number of recent entries to show")
+	follow := flags.Bool("f", false, "keep printing new entries as they are written")
+	interval := flags.Duration("interval", 2*time.Second, "how often to check for new entries with -f")
+	search := flags.String("search", "", "only entries whose details contain this text")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return usageError(usage)
+	}
+
+	filter := utils.ExportFilter{Search: *search}
+	logs, err := utils.ListLogs(s.ctx, filter, *lines)
+	if err != nil {
+		return err
+	}
+	// show the most recent entries in the order they happened
+	slices.Reverse(logs)
+
+	if !*follow {
+		entries := toLogEntries(logs)
+		return s.print(entries, logTable(entries))
+	}
+
+	// When following, every entry is printed on its own, as a JSON line in
+	// JSON mode
+	printEntries := func(entries []logEntry) error {
+		for _, entry := range entries {
+			if s.format == "json" {
+				if err := json.NewEncoder(os.Stdout).Encode(entry); err != nil {
+					return err
+				}
+				continue
+			}
+			fmt.Printf("%s  %-8s %s\n", entry.Timestamp.Format("2006-01-02 15:04:05"), entry.Status, entry.Details)
+		}
+		return nil
+	}
+
+	last := primitive.NewObjectIDFromTimestamp(time.Now())
+	if len(logs) > 0 {
+		last = logs[len(logs)-1].ID
+	}
+	if err := printEntries(toLogEntries(logs)); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil // interrupted
+		case <-ticker.C:
+		}
+
+		logs, err := utils.ListLogsAfter(s.ctx, filter, last)
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if len(logs) == 0 {
+			continue
+		}
+		last = logs[len(logs)-1].ID
+		if err := printEntries(toLogEntries(logs)); err != nil {
+			return err
+		}
+	}
+}
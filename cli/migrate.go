@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"fynance/migrations"
+	"strconv"
+)
+
+// runMigrate applies the pending migrations, or lists what they would change
+func runMigrate(s *session, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only count the documents each migration would change")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return usageError("migrate [-dry-run]")
+	}
+
+	current, err := migrations.Current(s.ctx)
+	if err != nil {
+		return err
+	}
+
+	results, err := migrations.Run(s.ctx, *dryRun)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return s.printMessage(fmt.Sprintf("the database is up to date at schema %d", current),
+			map[string]any{"schema_version": current})
+	}
+
+	type row struct {
+		Version     int    `json:"version"`
+		Description string `json:"description"`
+		Changed     int64  `json:"changed"`
+	}
+	rows := []row{}
+	t := table{headers: []string{"VERSION", "DESCRIPTION", "DOCUMENTS"}}
+	for _, result := range results {
+		rows = append(rows, row{result.Migration.Version, result.Migration.Description, result.Changed})
+		t.add(strconv.Itoa(result.Migration.Version), result.Migration.Description, strconv.FormatInt(result.Changed, 10))
+	}
+
+	if !*dryRun {
+		s.audit(fmt.Sprintf("migrated the database from schema %d to %d", current, migrations.Latest()))
+	}
+	return s.print(map[string]any{
+		"dry_run":    *dryRun,
+		"from":       current,
+		"to":         migrations.Latest(),
+		"migrations": rows,
+	}, t)
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"fynance/helpers"
+	"fynance/models"
+	"fynance/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func runReport(s *session, args []string) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	year := flags.String("year", time.Now().Format("2006"), "year to report on")
+	byTag := flags.Bool("tags", false, "total by tag instead of by month")
+	byCategory := flags.Bool("categories", false, "total by category instead of by month")
+	kind := flags.String("type", "", "with -categories, only income or expense categories")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return usageError("report [-year YEAR] [-tags | -categories [-type income|expense]]")
+	}
+	if _, err := strconv.Atoi(*year); err != nil || len(*year) != 4 {
+		return fmt.Errorf("invalid year %q", *year)
+	}
+	if *kind != "" && *kind != "income" && *kind != "expense" {
+		return errors.New("-type must be income or expense")
+	}
+	if *byTag {
+		return tagReport(s, *year)
+	}
+	if *byCategory {
+		return categoryReport(s, *year, *kind)
+	}
+
+	reports, err := utils.MonthlyReport(s.ctx, *year, helpers.Months)
+	if err != nil {
+		return err
+	}
+
+	t := table{headers: []string{"MONTH", "INCOME", "EXPENSES", "BALANCE"}}
+	var total models.Report
+	for _, report := range reports {
+		total.TotalIncome += report.TotalIncome
+		total.TotalExpense += report.TotalExpense
+		total.Balance += report.Balance
+		t.add(report.Month, formatAmount(report.TotalIncome), formatAmount(report.TotalExpense), formatAmount(report.Balance))
+	}
+	t.add("TOTAL", formatAmount(total.TotalIncome), formatAmount(total.TotalExpense), formatAmount(total.Balance))
+
+	return s.print(map[string]any{
+		"year":          *year,
+		"months":        reports,
+		"total_income":  total.TotalIncome,
+		"total_expense": total.TotalExpense,
+		"balance":       total.Balance,
+	}, t)
+}
+
+// tagReport prints the income and expenses of every tag
+func tagReport(s *session, year string) error {
+	tags, err := utils.TagReport(s.ctx, year)
+	if err != nil {
+		return err
+	}
+
+	t := table{headers: []string{"TAG", "RECORDS", "INCOME", "EXPENSES"}}
+	for _, tag := range tags {
+		t.add(tag.Tag, strconv.Itoa(tag.Count), formatAmount(tag.TotalIncome), formatAmount(tag.TotalExpense))
+	}
+
+	if tags == nil {
+		tags = []models.TagTotal{}
+	}
+	return s.print(map[string]any{"year": year, "tags": tags}, t)
+}
+
+// categoryReport prints what was recorded under every category, with
+// subcategories indented under their parents and rolled up into them
+func categoryReport(s *session, year, kind string) error {
+	categories, err := utils.CategoryReport(s.ctx, kind, year)
+	if err != nil {
+		return err
+	}
+
+	t := table{headers: []string{"TYPE", "CATEGORY", "RECORDS", "TOTAL", "OWN"}}
+	for _, c := range categories {
+		name := c.Category
+		if c.Depth > 0 {
+			name = strings.Repeat("  ", c.Depth) + c.Name
+		}
+		t.add(c.Type, name, strconv.Itoa(c.Count), formatAmount(c.Total), formatAmount(c.Own))
+	}
+
+	if categories == nil {
+		categories = []models.CategoryTotal{}
+	}
+	return s.print(map[string]any{"year": year, "categories": categories}, t)
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"fynance/models"
+	"fynance/utils"
+	"strconv"
+	"strings"
+)
+
+func runRules(s *session, args []string) error {
+	usage := "rules list|preview|apply [-type income|expense] [NAME]"
+	action, args, err := subcommand(args, usage)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("rules "+action, flag.ContinueOnError)
+	kind := flags.String("type", "", "income or expense")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 {
+		return usageError(usage)
+	}
+	if *kind != "" && *kind != "income" && *kind != "expense" {
+		return errors.New("-type must be income or expense")
+	}
+
+	switch action {
+	case "list":
+		rules, err := utils.ListRules(s.ctx, *kind)
+		if err != nil {
+			return err
+		}
+		t := table{headers: []string{"PRIORITY", "TYPE", "NAME", "MATCHES", "SETS", "ACTIVE"}}
+		for _, rule := range rules {
+			t.add(strconv.Itoa(rule.Priority), rule.Kind, rule.Name, describeMatch(rule), describeActions(rule), strconv.FormatBool(rule.Active))
+		}
+		if rules == nil {
+			rules = []models.Rule{}
+		}
+		return s.print(rules, t)
+
+	case "preview", "apply":
+		if *kind == "" {
+			return usageError("rules " + action + " -type income|expense [NAME]")
+		}
+		return rulesApply(s, *kind, flags.Arg(0), action == "preview")
+
+	default:
+		return usageError(usage)
+	}
+}
+
+// rulesApply categorises the existing records of kind with the active rules,
+// or with the rule called name whether active or not. With dryRun the
+// changes are only listed.
+func rulesApply(s *session, kind, name string, dryRun bool) error {
+	var rules utils.RuleSet
+	if name == "" {
+		loaded, err := utils.LoadRules(s.ctx, kind)
+		if err != nil {
+			return err
+		}
+		rules = loaded
+	} else {
+		all, err := utils.ListRules(s.ctx, kind)
+		if err != nil {
+			return err
+		}
+		for _, rule := range all {
+			if strings.EqualFold(rule.Name, name) {
+				rules = utils.CompileRules([]models.Rule{rule})
+			}
+		}
+		if rules.Len() == 0 {
+			return fmt.Errorf("no %s rule named %q", kind, name)
+		}
+	}
+
+	changes, err := utils.PreviewRules(s.ctx, kind, rules)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		t := table{headers: []string{"ID", "PERIOD", "AMOUNT", "RULE", "CATEGORY", "TAGS"}}
+		for _, c := range changes {
+			t.add(c.ID.Hex(), c.Month+" "+c.Year, formatAmount(c.Amount), c.Rule,
+				describeChange(c.Category, c.NewCategory), describeChange(strings.Join(c.Tags, ","), strings.Join(c.NewTags, ",")))
+		}
+		return s.print(changes, t)
+	}
+
+	changed, err := utils.ApplyRuleChanges(s.ctx, kind, changes)
+	if err != nil {
+		return err
+	}
+	s.audit(fmt.Sprintf("categorised %d %s records by rules", changed, kind))
+	return s.printMessage(fmt.Sprintf("Categorised %d %s records", changed, kind), map[string]any{"count": changed})
+}
+
+// describeMatch says which records a rule matches
+func describeMatch(rule models.Rule) string {
+	var conditions []string
+	if rule.Pattern != "" {
+		conditions = append(conditions, "/"+rule.Pattern+"/")
+	}
+	switch {
+	case rule.MinAmount > 0 && rule.MaxAmount > 0:
+		conditions = append(conditions, formatAmount(rule.MinAmount)+"-"+formatAmount(rule.MaxAmount))
+	case rule.MinAmount > 0:
+		conditions = append(conditions, ">= "+formatAmount(rule.MinAmount))
+	case rule.MaxAmount > 0:
+		conditions = append(conditions, "<= "+formatAmount(rule.MaxAmount))
+	}
+	return strings.Join(conditions, " and ")
+}
+
+// describeActions says what a rule sets
+func describeActions(rule models.Rule) string {
+	var actions []string
+	if rule.Category != "" {
+		actions = append(actions, rule.Category)
+	}
+	if len(rule.Tags) > 0 {
+		actions = append(actions, "+"+strings.Join(rule.Tags, ",+"))
+	}
+	return strings.Join(actions, " ")
+}
+
+// describeChange shows a value before and after, or once when unchanged
+func describeChange(before, after string) string {
+	if before == after {
+		return before
+	}
+	return before + " -> " + after
+}
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"fynance/api"
+	"os"
+	"time"
+)
+
+// runServe serves the REST API without the window. Requests authenticate
+// with their own API tokens, the signed in user only starts the server.
+func runServe(s *session, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", api.DefaultAddress, "address to listen on")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return usageError("serve [-addr HOST:PORT]")
+	}
+
+	server, err := api.Start(*addr)
+	if err != nil {
+		return err
+	}
+	s.audit("started the API server on " + server.Addr())
+	fmt.Fprintf(os.Stderr, "serving the API on http://%s/api/v1, press Ctrl+C to stop\n", server.Addr())
+
+	<-s.ctx.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
@@ -0,0 +1,285 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"fynance/helpers"
+	"fynance/models"
+	"fynance/utils"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// record is an income or an expense as the command line shows it
+type record struct {
+	ID        string    `json:"id"`
+	Category  string    `json:"category"`
+	Month     string    `json:"month"`
+	Year      string    `json:"year"`
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+	Payee     string    `json:"payee,omitempty"`
+	Notes     string    `json:"notes,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+}
+
+// ledger holds what differs between incomes and expenses, so both share the
+// same subcommands
+type ledger struct {
+	name       string // "income" or "expense"
+	label      string // "Income" or "Expense", as used in the activity log
+	categories func(ctx context.Context) ([]string, error)
+	add        func(r record, createdBy primitive.ObjectID) error
+	list       func(ctx context.Context, filter utils.ExportFilter, limit int64) ([]record, error)
+	find       func(ctx context.Context, id primitive.ObjectID) (record, error)
+	remove     func(id primitive.ObjectID) error
+	importAll  func(ctx context.Context, source utils.ImportSource, records []record) (models.ImportBatch, error)
+}
+
+var incomeLedger = ledger{
+	name:  "income",
+	label: "Income",
+	categories: func(ctx context.Context) ([]string, error) {
+		return utils.CategoryPaths(ctx, "income")
+	},
+	add: func(r record, createdBy primitive.ObjectID) error {
+		return utils.AddIncome(models.Income{
+			ID:        primitive.NewObjectID(),
+			Category:  r.Category,
+			Month:     r.Month,
+			Year:      r.Year,
+			Amount:    r.Amount,
+			CreatedAt: r.CreatedAt,
+			Payee:     r.Payee,
+			Notes:     r.Notes,
+			Tags:      r.Tags,
+			CreatedBy: createdBy,
+		}, nil)
+	},
+	list: func(ctx context.Context, filter utils.ExportFilter, limit int64) ([]record, error) {
+		incomes, err := utils.ListIncomes(ctx, filter, limit)
+		var records []record
+		for _, income := range incomes {
+			records = append(records, record{income.ID.Hex(), income.Category, income.Month, income.Year, income.Amount, income.CreatedAt, income.Payee, income.Notes, income.Tags})
+		}
+		return records, err
+	},
+	find: func(ctx context.Context, id primitive.ObjectID) (record, error) {
+		income, err := utils.FindIncomeByID(ctx, id)
+		return record{income.ID.Hex(), income.Category, income.Month, income.Year, income.Amount, income.CreatedAt, income.Payee, income.Notes, income.Tags}, err
+	},
+	remove: func(id primitive.ObjectID) error {
+		return utils.DeleteIncome(id, nil)
+	},
+	importAll: func(ctx context.Context, source utils.ImportSource, records []record) (models.ImportBatch, error) {
+		var incomes []models.Income
+		for _, r := range records {
+			incomes = append(incomes, models.Income{ID: primitive.NewObjectID(), Category: r.Category, Month: r.Month, Year: r.Year, Amount: r.Amount,
+				Payee: r.Payee, Notes: r.Notes, Tags: r.Tags})
+		}
+		return utils.ImportIncomes(ctx, source, incomes, nil)
+	},
+}
+
+var expenseLedger = ledger{
+	name:  "expense",
+	label: "Expense",
+	categories: func(ctx context.Context) ([]string, error) {
+		return utils.CategoryPaths(ctx, "expense")
+	},
+	add: func(r record, createdBy primitive.ObjectID) error {
+		return utils.AddExpense(models.Expense{
+			ID:        primitive.NewObjectID(),
+			Category:  r.Category,
+			Month:     r.Month,
+			Year:      r.Year,
+			Amount:    r.Amount,
+			CreatedAt: r.CreatedAt,
+			Payee:     r.Payee,
+			Notes:     r.Notes,
+			Tags:      r.Tags,
+			CreatedBy: createdBy,
+		}, nil)
+	},
+	list: func(ctx context.Context, filter utils.ExportFilter, limit int64) ([]record, error) {
+		expenses, err := utils.ListExpenses(ctx, filter, limit)
+		var records []record
+		for _, expense := range expenses {
+			records = append(records, record{expense.ID.Hex(), expense.Category, expense.Month, expense.Year, expense.Amount, expense.CreatedAt, expense.Payee, expense.Notes, expense.Tags})
+		}
+		return records, err
+	},
+	find: func(ctx context.Context, id primitive.ObjectID) (record, error) {
+		expense, err := utils.FindExpenseByID(ctx, id)
+		return record{expense.ID.Hex(), expense.Category, expense.Month, expense.Year, expense.Amount, expense.CreatedAt, expense.Payee, expense.Notes, expense.Tags}, err
+	},
+	remove: func(id primitive.ObjectID) error {
+		return utils.DeleteExpense(id, nil)
+	},
+	importAll: func(ctx context.Context, source utils.ImportSource, records []record) (models.ImportBatch, error) {
+		var expenses []models.Expense
+		for _, r := range records {
+			expenses = append(expenses, models.Expense{ID: primitive.NewObjectID(), Category: r.Category, Month: r.Month, Year: r.Year, Amount: r.Amount,
+				Payee: r.Payee, Notes: r.Notes, Tags: r.Tags})
+		}
+		return utils.ImportExpenses(ctx, source, expenses, nil)
+	},
+}
+
+func runIncome(s *session, args []string) error {
+	return runLedger(s, incomeLedger, args)
+}
+
+func runExpense(s *session, args []string) error {
+	return runLedger(s, expenseLedger, args)
+}
+
+func runLedger(s *session, l ledger, args []string) error {
+	usage := l.name + " add|list|delete [flags]"
+	action, args, err := subcommand(args, usage)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "add":
+		return ledgerAdd(s, l, args)
+	case "list":
+		return ledgerList(s, l, args)
+	case "delete":
+		return ledgerDelete(s, l, args)
+	default:
+		return usageError(usage)
+	}
+}
+
+// validateRecord applies the same rules as the income and expense forms
+func validateRecord(l ledger, categories []string, r record) error {
+	if err := helpers.ValidateTransaction(r.Category, r.Month, r.Year, r.Amount, categories); err != nil {
+		return fmt.Errorf("%s: %w", l.name, err)
+	}
+	return nil
+}
+
+func ledgerAdd(s *session, l ledger, args []string) error {
+	flags := flag.NewFlagSet(l.name+" add", flag.ContinueOnError)
+	category := flags.String("category", "", "category (required)")
+	month := flags.String("month", helpers.Months[time.Now().Month()-1], "month")
+	year := flags.String("year", time.Now().Format("2006"), "year")
+	amount := flags.Float64("amount", 0, "amount (required)")
+	payee := flags.String("payee", "", "who paid or was paid")
+	notes := flags.String("notes", "", "free text, such as an invoice number")
+	tags := flags.String("tags", "", "comma separated tags")
+	if err := flags.Parse(args); err != nil {
+		return usageError(l.name + " add -category NAME -amount AMOUNT [-month MONTH] [-year YEAR] [-payee NAME] [-notes TEXT] [-tags A,B]")
+	}
+
+	parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
+	if err != nil {
+		return err
+	}
+
+	categories, err := l.categories(s.ctx)
+	if err != nil {
+		return err
+	}
+
+	r := record{Category: *category, Month: *month, Year: *year, Amount: *amount, CreatedAt: parsedTime,
+		Payee: strings.TrimSpace(*payee), Notes: strings.TrimSpace(*notes), Tags: helpers.ParseTags(*tags)}
+	if err := validateRecord(l, categories, r); err != nil {
+		return err
+	}
+	if err := l.add(r, s.user.ID); err != nil {
+		return err
+	}
+
+	s.audit("Added " + l.label + ": " + r.Category)
+	return s.printMessage(fmt.Sprintf("%s added: %s %s %s %s", l.label, r.Category, r.Month, r.Year, formatAmount(r.Amount)), nil)
+}
+
+func ledgerList(s *session, l ledger, args []string) error {
+	flags := flag.NewFlagSet(l.name+" list", flag.ContinueOnError)
+	from := flags.String("from", "", "first date, YYYY-MM-DD")
+	to := flags.String("to", "", "last date, YYYY-MM-DD")
+	category := flags.String("category", "", "only this category")
+	tag := flags.String("tag", "", "only records with this tag")
+	search := flags.String("search", "", "search category, month, payee, notes and tags")
+	limit := flags.Int64("limit", 50, "maximum number of records, 0 for all")
+	if err := flags.Parse(args); err != nil {
+		return usageError(l.name + " list [-from DATE] [-to DATE] [-category NAME] [-tag TAG] [-search TEXT] [-limit N]")
+	}
+
+	filter, err := parseFilter(*from, *to, *category, *search)
+	if err != nil {
+		return err
+	}
+	filter.Tag = *tag
+
+	records, err := l.list(s.ctx, filter, *limit)
+	if err != nil {
+		return err
+	}
+
+	t := table{headers: []string{"ID", "CATEGORY", "PAYEE", "MONTH", "YEAR", "AMOUNT", "TAGS", "CREATED"}}
+	var total float64
+	for _, r := range records {
+		total += r.Amount
+		t.add(r.ID, r.Category, r.Payee, r.Month, r.Year, formatAmount(r.Amount), strings.Join(r.Tags, ","), r.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	t.add("", "", "", "", "TOTAL", formatAmount(total), "", "")
+
+	if records == nil {
+		records = []record{}
+	}
+	return s.print(records, t)
+}
+
+func ledgerDelete(s *session, l ledger, args []string) error {
+	if len(args) != 1 {
+		return usageError(l.name + " delete ID")
+	}
+	id, err := primitive.ObjectIDFromHex(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid id %q", args[0])
+	}
+
+	r, err := l.find(s.ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("no %s with id %s", l.name, args[0])
+	}
+	if err != nil {
+		return err
+	}
+	if err := l.remove(id); err != nil {
+		return err
+	}
+
+	s.audit("deleted " + l.label + " " + r.Category)
+	return s.printMessage(l.label+" deleted: "+r.ID, map[string]any{"id": r.ID})
+}
+
+// parseFilter builds an export filter from YYYY-MM-DD dates and search text
+func parseFilter(from, to, category, search string) (utils.ExportFilter, error) {
+	filter := utils.ExportFilter{Category: category, Search: search}
+
+	var err error
+	if from != "" {
+		if filter.From, err = time.ParseInLocation("2006-01-02", from, time.Local); err != nil {
+			return filter, fmt.Errorf("invalid -from date %q, use YYYY-MM-DD", from)
+		}
+	}
+	if to != "" {
+		if filter.To, err = time.ParseInLocation("2006-01-02", to, time.Local); err != nil {
+			return filter, fmt.Errorf("invalid -to date %q, use YYYY-MM-DD", to)
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return filter, errors.New("-to is before -from")
+	}
+	return filter, nil
+}
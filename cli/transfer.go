@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"fynance/helpers"
+	"fynance/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readRecords parses a Category,Month,Year,Amount CSV with a header row and
+// optional Payee, Notes and Tags columns, the same layout the app's bulk
+// upload and export use. Rows are categorised by rules before they are
+// checked.
+func readRecords(r io.Reader, l ledger, categories []string, rules utils.RuleSet) ([]record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []record
+	for i, row := range rows {
+		if i == 0 {
+			continue // Skip header row
+		}
+		line := i + 1
+		if len(row) < 4 {
+			return nil, fmt.Errorf("line %d: expected Category,Month,Year,Amount", line)
+		}
+
+		amount, err := strconv.ParseFloat(strings.TrimSpace(row[3]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount %q", line, row[3])
+		}
+
+		rec := record{
+			Category: strings.TrimSpace(row[0]),
+			Month:    strings.TrimSpace(row[1]),
+			Year:     strings.TrimSpace(row[2]),
+			Amount:   amount,
+		}
+		// the payee, notes and tags columns are optional
+		if len(row) > 4 {
+			rec.Payee = strings.TrimSpace(row[4])
+		}
+		if len(row) > 5 {
+			rec.Notes = strings.TrimSpace(row[5])
+		}
+		if len(row) > 6 {
+			rec.Tags = helpers.ParseTags(row[6])
+		}
+		if rule, ok := rules.Match(rec.Payee, rec.Notes, rec.Amount); ok {
+			rec.Category, rec.Tags = utils.ApplyRule(rule, rec.Category, rec.Tags)
+		}
+		if err := validateRecord(l, categories, rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func runImport(s *session, args []string) error {
+	usage := "import income|expense [-dry-run] [-force] FILE.csv"
+	kind, args, err := subcommand(args, usage)
+	if err != nil {
+		return err
+	}
+
+	var l ledger
+	switch kind {
+	case "income":
+		l = incomeLedger
+	case "expense":
+		l = expenseLedger
+	default:
+		return usageError(usage)
+	}
+
+	flags := flag.NewFlagSet("import "+kind, flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "check the file without importing it")
+	force := flags.Bool("force", false, "import the file even if it was imported before")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return usageError(usage)
+	}
+	path := flags.Arg(0)
+
+	categories, err := l.categories(s.ctx)
+	if err != nil {
+		return err
+	}
+	rules, err := utils.LoadRules(s.ctx, l.name)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Nothing is imported unless every row is valid
+	records, err := readRecords(file, l, categories, rules)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("%s: no %s records found", path, l.name)
+	}
+
+	if *dryRun {
+		return s.printMessage(fmt.Sprintf("%d %s records are valid, nothing imported", len(records), l.name),
+			map[string]any{"count": len(records)})
+	}
+
+	// the same file imported twice is most likely a mistake
+	hash, err := utils.HashFile(path)
+	if err != nil {
+		return err
+	}
+	previous, found, err := utils.FindImportByHash(s.ctx, l.name, hash)
+	if err != nil {
+		return err
+	}
+	if found && !*force {
+		return fmt.Errorf("%s was already imported as batch %s on %s, use -force to import it again",
+			path, previous.ID.Hex(), previous.CreatedAt.Format("2006-01-02 15:04"))
+	}
+
+	batch, err := l.importAll(s.ctx, utils.ImportSource{
+		FileName: filepath.Base(path),
+		Hash:     hash,
+		Rows:     len(records),
+		UserID:   s.user.ID,
+		Username: s.user.Username,
+	}, records)
+	if err != nil {
+		return err
+	}
+
+	s.audit(fmt.Sprintf("imported %d %s records from %s as batch %s", len(records), l.name, path, batch.ID.Hex()))
+	return s.printMessage(fmt.Sprintf("Imported %d %s records as batch %s", len(records), l.name, batch.ID.Hex()),
+		map[string]any{"count": len(records), "batch_id": batch.ID.Hex()})
+}
+
+func runExport(s *session, args []string) error {
+	usage := "export incomes|expenses|logs [-as csv|json] [-o FILE] [-from DATE] [-to DATE] [-category NAME] [-search TEXT]"
+	what, args, err := subcommand(args, usage)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("export "+what, flag.ContinueOnError)
+	as := flags.String("as", "csv", "file format: csv, or json for logs")
+	output := flags.String("o", "-", "file to write, - for standard output")
+	from := flags.String("from", "", "first date, YYYY-MM-DD")
+	to := flags.String("to", "", "last date, YYYY-MM-DD")
+	category := flags.String("category", "", "only this category")
+	search := flags.String("search", "", "search text")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return usageError(usage)
+	}
+
+	filter, err := parseFilter(*from, *to, *category, *search)
+	if err != nil {
+		return err
+	}
+
+	var export func(w io.Writer) error
+	switch {
+	case what == "incomes" && *as == "csv":
+		export = func(w io.Writer) error { return utils.WriteIncomesCSV(s.ctx, w, filter, nil) }
+	case what == "expenses" && *as == "csv":
+		export = func(w io.Writer) error { return utils.WriteExpensesCSV(s.ctx, w, filter, nil) }
+	case what == "logs" && *as == "csv":
+		export = func(w io.Writer) error { return utils.WriteLogsCSV(s.ctx, w, filter, nil) }
+	case what == "logs" && *as == "json":
+		export = func(w io.Writer) error { return utils.WriteLogsJSON(s.ctx, w, filter, nil) }
+	case what == "incomes" || what == "expenses" || what == "logs":
+		return errors.New("-as must be csv, or json for logs")
+	default:
+		return usageError(usage)
+	}
+
+	if *output == "-" {
+		return export(os.Stdout)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	err = export(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*output)
+		return err
+	}
+
+	s.audit("exported " + what + " to " + *output)
+	// the export itself goes to the file, so only report where it went
+	fmt.Fprintln(os.Stderr, "exported "+what+" to "+*output)
+	return nil
+}
@@ -2,7 +2,11 @@ package helpers
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 func ValidateUsername(username string) error {
@@ -30,3 +34,34 @@ func ValidatePhoneNumber(phone string) error {
 
 	return nil // Phone number is valid
 }
+
+// ValidateTransaction checks an income or expense against its categories,
+// the month names and a four digit year
+func ValidateTransaction(category, month, year string, amount float64, categories []string) error {
+	if !slices.Contains(categories, category) {
+		return fmt.Errorf("unknown category %q", category)
+	}
+	if !slices.Contains(Months, month) {
+		return fmt.Errorf("unknown month %q, use one of %v", month, Months)
+	}
+	if _, err := strconv.Atoi(year); err != nil || len(year) != 4 {
+		return fmt.Errorf("invalid year %q", year)
+	}
+	if amount <= 0 {
+		return errors.New("the amount must be greater than zero")
+	}
+	return nil
+}
+
+// ParseTags splits comma separated tags, trimming and lower-casing them and
+// dropping blanks and repeats
+func ParseTags(text string) []string {
+	var tags []string
+	for _, tag := range strings.Split(text, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,41 @@
+// Package testdb sets up scratch databases for the tests that need a real
+// MongoDB server.
+package testdb
+
+import (
+	"context"
+	"fynance/migrations"
+	"fynance/utils"
+	"os"
+	"testing"
+)
+
+// URIEnv names the server for the tests. They are skipped when it is not set.
+const URIEnv = "FYNANCE_TEST_MONGO_URI"
+
+// Connect points the shared client at the scratch database named database,
+// empty and migrated, and drops it when the test ends
+func Connect(t *testing.T, database string) context.Context {
+	t.Helper()
+	uri := os.Getenv(URIEnv)
+	if uri == "" {
+		t.Skipf("set %s to run tests against MongoDB", URIEnv)
+	}
+
+	config := utils.DefaultDBConfig()
+	config.URI = uri
+	config.Database = database
+	if err := utils.ConnectWith(config); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := utils.GetDatabase().Drop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { utils.GetDatabase().Drop(context.Background()) })
+	if _, err := migrations.Run(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	return ctx
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"fynance/appTheme"
+	"fynance/cli"
 	"fynance/helpers"
 	"fynance/utils"
 	"fynance/views"
+	"os"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -14,30 +18,52 @@ import (
 )
 
 func main() {
+	// Command line actions run without opening a window
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
 	application := app.NewWithID("fynance.com")
 	window := application.NewWindow("Fynance")
-	// connect to DB
-	utils.ConnectDB("mongodb://localhost:27017", window)
-
 	// Placeholder for functions that need to reference each other
-	var showParameters, showIncome, showExpenses, showReport, showContact, showDashboard, showLogin func()
+	var showParameters, showIncome, showExpenses, showAccounts, showReconcile, showImports, showReport, showContact, showDashboard, showLogin func()
 
 	// Load the settings on app startup
 	settings, err := views.LoadSettings()
 	if err != nil {
 		dialog.ShowInformation("Loading settings", "Error loading settings: "+err.Error(), window)
+		settings = views.DefaultSettings()
 	}
 
+	// connect to DB, environment variables override the saved settings
+	dbConfig := settings.Database.WithEnv()
+	utils.ConnectDB(dbConfig, window)
+
+	// Let webhook deliveries in flight finish when the app closes
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		utils.WaitForWebhooks(ctx)
+	}()
+
 	if settings.IsDarkMode {
 		fyne.CurrentApp().Settings().SetTheme(&appTheme.ThemeVariant{Theme: theme.DefaultTheme(), Variant: theme.VariantDark})
 	} else {
 		fyne.CurrentApp().Settings().SetTheme(&appTheme.ThemeVariant{Theme: theme.DefaultTheme(), Variant: theme.VariantLight})
 	}
 
+	// Automatic backups and the local REST API start once the database is
+	// ready, see start below
+	defer views.StopBackupScheduler()
+	defer views.StopAPIServer()
+
+	// The header starts the database monitor, stop it on exit
+	defer views.StopHealthMonitor()
+
 	// Function to show the details view
 	showParameters = func() {
 		sidebar := views.Sidebar(window, showParameters, showIncome,
-			showExpenses, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
+			showExpenses, showAccounts, showReconcile, showImports, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
 		parameters := views.ParametersView(window, helpers.CurrentUserID)
 		window.SetContent(container.NewBorder(nil, nil, sidebar, nil, parameters))
 	}
@@ -45,7 +71,7 @@ func main() {
 	// Function to show the income view
 	showIncome = func() {
 		sidebar := views.Sidebar(window, showParameters, showIncome,
-			showExpenses, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
+			showExpenses, showAccounts, showReconcile, showImports, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
 		income := views.IncomeView(window, helpers.CurrentUserID)
 		window.SetContent(container.NewBorder(nil, nil, sidebar, nil, income))
 	}
@@ -53,15 +79,39 @@ func main() {
 	// Function to show the expenses view
 	showExpenses = func() {
 		sidebar := views.Sidebar(window, showParameters, showIncome,
-			showExpenses, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
+			showExpenses, showAccounts, showReconcile, showImports, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
 		expenses := views.ExpenseView(window, helpers.CurrentUserID)
 		window.SetContent(container.NewBorder(nil, nil, sidebar, nil, expenses))
 	}
 
+	// Function to show the accounts view
+	showAccounts = func() {
+		sidebar := views.Sidebar(window, showParameters, showIncome,
+			showExpenses, showAccounts, showReconcile, showImports, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
+		accounts := views.AccountsView(window, helpers.CurrentUserID)
+		window.SetContent(container.NewBorder(nil, nil, sidebar, nil, accounts))
+	}
+
+	// Function to show the bank reconciliation view
+	showReconcile = func() {
+		sidebar := views.Sidebar(window, showParameters, showIncome,
+			showExpenses, showAccounts, showReconcile, showImports, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
+		reconcile := views.ReconcileView(window, helpers.CurrentUserID)
+		window.SetContent(container.NewBorder(nil, nil, sidebar, nil, reconcile))
+	}
+
+	// Function to show the imports view
+	showImports = func() {
+		sidebar := views.Sidebar(window, showParameters, showIncome,
+			showExpenses, showAccounts, showReconcile, showImports, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
+		imports := views.ImportsView(window, helpers.CurrentUserID)
+		window.SetContent(container.NewBorder(nil, nil, sidebar, nil, imports))
+	}
+
 	// Function to show the report view
 	showReport = func() {
 		sidebar := views.Sidebar(window, showParameters, showIncome,
-			showExpenses, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
+			showExpenses, showAccounts, showReconcile, showImports, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
 		report := views.Report(window)
 		window.SetContent(container.NewBorder(nil, nil, sidebar, nil, report))
 	}
@@ -69,7 +119,7 @@ func main() {
 	// Function to show the contact view
 	showContact = func() {
 		sidebar := views.Sidebar(window, showParameters, showIncome,
-			showExpenses, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
+			showExpenses, showAccounts, showReconcile, showImports, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
 		contact := views.ContactView(window)
 		window.SetContent(container.NewBorder(nil, nil, sidebar, nil, contact))
 	}
@@ -77,7 +127,7 @@ func main() {
 	// Function to show the dashboard view
 	showDashboard = func() {
 		sidebar := views.Sidebar(window, showParameters, showIncome,
-			showExpenses, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
+			showExpenses, showAccounts, showReconcile, showImports, showReport, showContact, showDashboard, showLogin, helpers.CurrentUserID)
 		dashboard := views.Dashboard(window)
 		window.SetContent(container.NewBorder(nil, nil, sidebar, nil, dashboard))
 	}
@@ -87,8 +137,23 @@ func main() {
 		window.SetContent(views.LoginView(window, showDashboard))
 	}
 
-	// Initial view when the application starts
-	showLogin()
+	// Initial view when the application starts: the setup wizard on first
+	// launch or when the database can't be reached, then migrations and login
+	start := func() {
+		if views.MigrateDatabase(window) {
+			views.EnsureIndexes(window)
+			// Automatic backups run in the background while the app is open
+			views.StartBackupScheduler(window)
+			// The local REST API, when enabled in the settings
+			views.StartAPIServer(window)
+			showLogin()
+		}
+	}
+	if err := utils.TestConnection(context.Background(), dbConfig); !settings.DatabaseConfigured || err != nil {
+		window.SetContent(views.DatabaseSetupView(window, dbConfig, err, start))
+	} else {
+		start()
+	}
 	window.Resize(fyne.NewSize(600, 500))
 	window.CenterOnScreen()
 	window.ShowAndRun()
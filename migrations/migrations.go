@@ -0,0 +1,202 @@
+// Package migrations evolves stored documents as the models change. The
+// database records the schema version it is at, and every migration newer
+// than that runs once, in order, when Fynance starts.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fynance/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// collectionName holds the single schema version document
+const collectionName = "schema_version"
+
+const versionID = "schema"
+
+// ErrDatabaseNewer is returned when the database was migrated by a newer
+// build than this one, which may not understand its documents
+var ErrDatabaseNewer = errors.New("the database was written by a newer version of Fynance")
+
+// Step updates every document of a collection matching Filter. The filter
+// must exclude documents already migrated, so running a step twice is
+// harmless and a dry run can count what it would change.
+type Step struct {
+	Collection string
+	Filter     bson.M
+	Update     any // an update document or pipeline
+
+	// Run, when set, makes the change in place of Update, for changes an
+	// update can't express. It returns the number of documents changed.
+	Run func(ctx context.Context) (int64, error)
+}
+
+// Migration brings the database from Version-1 to Version
+type Migration struct {
+	Version     int
+	Description string
+	Steps       []Step
+}
+
+// History records an applied migration in the version document
+type History struct {
+	Version     int       `bson:"version" json:"version"`
+	Description string    `bson:"description" json:"description"`
+	Changed     int64     `bson:"changed" json:"changed"`
+	AppliedAt   time.Time `bson:"applied_at" json:"applied_at"`
+}
+
+type versionDocument struct {
+	ID        string    `bson:"_id"`
+	Version   int       `bson:"version"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	History   []History `bson:"history"`
+}
+
+// Result describes one migration run or, in a dry run, what it would do
+type Result struct {
+	Migration Migration
+	Changed   int64 // documents changed, or that would be
+}
+
+// Latest is the schema version this build writes
+func Latest() int {
+	return registry[len(registry)-1].Version
+}
+
+// Current returns the schema version recorded in the database, 0 for a
+// database that predates versioning
+func Current(ctx context.Context) (int, error) {
+	var doc versionDocument
+	err := utils.GetCollection(collectionName).FindOne(ctx, bson.M{"_id": versionID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return doc.Version, err
+}
+
+// Pending returns the migrations the database has not had yet
+func Pending(ctx context.Context) ([]Migration, error) {
+	current, err := Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current > Latest() {
+		return nil, fmt.Errorf("%w (schema %d, this build knows up to %d)", ErrDatabaseNewer, current, Latest())
+	}
+
+	var pending []Migration
+	for _, migration := range registry {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Run applies the pending migrations in order, recording the version after
+// each one. With dryRun nothing is written and the results count the
+// documents each migration would change.
+func Run(ctx context.Context, dryRun bool) ([]Result, error) {
+	pending, err := Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, migration := range pending {
+		changed, err := apply(ctx, migration, dryRun)
+		results = append(results, Result{Migration: migration, Changed: changed})
+		if err != nil {
+			return results, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		if dryRun {
+			continue
+		}
+
+		if err := record(ctx, migration, changed); err != nil {
+			return results, err
+		}
+		utils.LogEvent(fmt.Sprintf("Migrated the database to schema %d: %s (%d documents)",
+			migration.Version, migration.Description, changed), "SUCCESS")
+	}
+	return results, nil
+}
+
+// Restored sets the recorded version back to that of restored data, when it
+// is older, and migrates it forward again
+func Restored(ctx context.Context, version int) error {
+	current, err := Current(ctx)
+	if err != nil {
+		return err
+	}
+	if version < current {
+		_, err := utils.GetCollection(collectionName).UpdateOne(ctx,
+			bson.M{"_id": versionID},
+			bson.M{"$set": bson.M{"version": version, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = Run(ctx, false)
+	return err
+}
+
+// apply runs the steps of a migration, or counts what they would change
+func apply(ctx context.Context, migration Migration, dryRun bool) (int64, error) {
+	var changed int64
+	for _, step := range migration.Steps {
+		collection := utils.GetCollection(step.Collection)
+		if dryRun {
+			count, err := collection.CountDocuments(ctx, step.Filter)
+			if err != nil {
+				return changed, err
+			}
+			changed += count
+			continue
+		}
+
+		if step.Run != nil {
+			count, err := step.Run(ctx)
+			changed += count
+			if err != nil {
+				return changed, err
+			}
+			continue
+		}
+
+		result, err := collection.UpdateMany(ctx, step.Filter, step.Update)
+		if err != nil {
+			return changed, err
+		}
+		changed += result.ModifiedCount
+	}
+	return changed, nil
+}
+
+// record stores the new version along with the migration's history entry
+func record(ctx context.Context, migration Migration, changed int64) error {
+	now := time.Now()
+	_, err := utils.GetCollection(collectionName).UpdateOne(ctx,
+		bson.M{"_id": versionID},
+		bson.M{
+			"$set": bson.M{"version": migration.Version, "updated_at": now},
+			"$push": bson.M{"history": History{
+				Version:     migration.Version,
+				Description: migration.Description,
+				Changed:     changed,
+				AppliedAt:   now,
+			}},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
@@ -0,0 +1,103 @@
+package migrations
+
+import (
+	"context"
+	"fynance/utils"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// transactionCollections hold incomes and expenses, which share one shape
+var transactionCollections = []string{"income", "expenses"}
+
+// categoryCollections hold income and expense categories
+var categoryCollections = []string{"income_details", "expense_details"}
+
+// registry lists every migration, oldest first. Versions are never reused or
+// reordered; add new migrations at the end.
+var registry = []Migration{
+	{
+		Version:     1,
+		Description: "Start recording the schema version",
+	},
+	{
+		Version:     2,
+		Description: "Store years as text",
+		Steps:       perCollection(transactionCollections, yearsAsText),
+	},
+	{
+		Version:     3,
+		Description: "Fill in missing updated_at from created_at",
+		Steps:       perCollection(slices.Concat(transactionCollections, categoryCollections), fillUpdatedAt),
+	},
+	{
+		Version:     4,
+		Description: "Reference categories by ID",
+		Steps:       []Step{linkCategories("income", "income"), linkCategories("expenses", "expense")},
+	},
+	{
+		Version:     5,
+		Description: "Reference rule categories by ID",
+		Steps:       []Step{linkRuleCategories},
+	},
+}
+
+// perCollection repeats a step for each collection
+func perCollection(collections []string, step func(collection string) Step) []Step {
+	var steps []Step
+	for _, collection := range collections {
+		steps = append(steps, step(collection))
+	}
+	return steps
+}
+
+// yearsAsText converts years imported as numbers, which the year filters
+// and reports never matched
+func yearsAsText(collection string) Step {
+	return Step{
+		Collection: collection,
+		Filter:     bson.M{"year": bson.M{"$type": "number"}},
+		Update: bson.A{bson.M{"$set": bson.M{
+			"year": bson.M{"$toString": bson.M{"$toLong": "$year"}},
+		}}},
+	}
+}
+
+// fillUpdatedAt gives records that were never edited an updated_at, so
+// conflict checks can rely on it
+func fillUpdatedAt(collection string) Step {
+	return Step{
+		Collection: collection,
+		Filter: bson.M{
+			"created_at": bson.M{"$exists": true},
+			"$or": bson.A{
+				bson.M{"updated_at": bson.M{"$exists": false}},
+				bson.M{"updated_at": bson.M{"$lt": time.Unix(0, 0)}}, // the zero time.Time
+			},
+		},
+		Update: bson.A{bson.M{"$set": bson.M{"updated_at": "$created_at"}}},
+	}
+}
+
+// linkCategories gives records the ID of the category they name, so
+// renaming the category no longer orphans them. Records naming a category
+// that doesn't exist keep only the name.
+func linkCategories(collection, kind string) Step {
+	return Step{
+		Collection: collection,
+		Filter:     bson.M{"category_id": bson.M{"$exists": false}},
+		Run: func(ctx context.Context) (int64, error) {
+			return utils.LinkCategories(ctx, kind)
+		},
+	}
+}
+
+// linkRuleCategories gives rules the ID of the category they file records
+// under, so renaming the category carries the rules along
+var linkRuleCategories = Step{
+	Collection: "rules",
+	Filter:     bson.M{"category_id": bson.M{"$exists": false}, "category": bson.M{"$nin": bson.A{"", nil}}},
+	Run:        utils.LinkRuleCategories,
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Account types
+const (
+	AccountBank    = "bank"
+	AccountMobile  = "mobile money"
+	AccountCash    = "cash"
+	AccountSavings = "savings"
+)
+
+// Account is where money sits, such as a bank account, an M-Pesa wallet or
+// cash. Its balance is the opening balance plus the incomes and transfers
+// into it, less the expenses and transfers out of it.
+type Account struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name           string             `bson:"name" json:"name"`
+	Type           string             `bson:"type" json:"type"`
+	OpeningBalance float64            `bson:"opening_balance" json:"opening_balance"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Transfer moves money between two accounts. It is neither income nor
+// expense.
+type Transfer struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FromID    primitive.ObjectID `bson:"from_account" json:"from_account"`
+	ToID      primitive.ObjectID `bson:"to_account" json:"to_account"`
+	Amount    float64            `bson:"amount" json:"amount"`
+	Month     string             `bson:"month" json:"month"`
+	Year      string             `bson:"year" json:"year"`
+	Notes     string             `bson:"notes" json:"notes,omitempty"`
+	CreatedBy primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// AccountBalance is where an account stands
+type AccountBalance struct {
+	Account      Account `json:"account"`
+	Income       float64 `json:"income"`
+	Expenses     float64 `json:"expenses"`
+	TransfersIn  float64 `json:"transfers_in"`
+	TransfersOut float64 `json:"transfers_out"`
+	Balance      float64 `json:"balance"`
+}
+
+// BalancePoint is the movement on an account in one month and its balance
+// at the end of it
+type BalancePoint struct {
+	Month        string  `json:"month"`
+	Year         string  `json:"year"`
+	Income       float64 `json:"income"`
+	Expenses     float64 `json:"expenses"`
+	TransfersIn  float64 `json:"transfers_in"`
+	TransfersOut float64 `json:"transfers_out"`
+	Balance      float64 `json:"balance"`
+}
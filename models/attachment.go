@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attachment is a receipt, invoice or other file kept with an income or
+// expense. It is the GridFS file document; the content is in its chunks.
+type Attachment struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	FileName   string             `bson:"filename" json:"file_name"`
+	Length     int64              `bson:"length" json:"length"`
+	UploadedAt time.Time          `bson:"uploadDate" json:"uploaded_at"`
+	Metadata   AttachmentMetadata `bson:"metadata" json:"metadata"`
+}
+
+// AttachmentMetadata ties an attachment to its record
+type AttachmentMetadata struct {
+	Kind        string             `bson:"kind" json:"kind"` // income or expense
+	RecordID    primitive.ObjectID `bson:"record_id" json:"record_id"`
+	ContentType string             `bson:"content_type" json:"content_type"`
+	SHA256      string             `bson:"sha256" json:"sha256"`
+	Thumbnail   []byte             `bson:"thumbnail,omitempty" json:"-"` // a small PNG, for images
+	UploadedBy  string             `bson:"uploaded_by" json:"uploaded_by"`
+}
+
+// IsImage reports whether the attachment can be previewed as a picture
+func (a Attachment) IsImage() bool {
+	return a.Metadata.ContentType == "image/png" || a.Metadata.ContentType == "image/jpeg" || a.Metadata.ContentType == "image/gif"
+}
@@ -7,13 +7,36 @@ import (
 )
 
 type Expense struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	Category  string             `bson:"category"`
-	Month     string             `bson:"month"`
-	Year      string             `bson:"year"`
-	Amount    float64            `bson:"amount"`
-	CreatedAt time.Time          `bson:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at"`
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Category  string             `bson:"category" json:"category"`
+	Month     string             `bson:"month" json:"month"`
+	Year      string             `bson:"year" json:"year"`
+	Amount    float64            `bson:"amount" json:"amount"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+
+	// optional details: who paid or was paid, free text such as an invoice
+	// number, and labels cutting across categories
+	Payee string   `bson:"payee" json:"payee,omitempty"`
+	Notes string   `bson:"notes" json:"notes,omitempty"`
+	Tags  []string `bson:"tags" json:"tags,omitempty"`
+
+	// the category by reference. Category holds its full name, such as
+	// "Transport > Fuel", and is kept up to date when categories are renamed.
+	CategoryID primitive.ObjectID `bson:"category_id,omitempty" json:"category_id,omitempty"`
+
+	// the account the money went into or came out of, if any
+	AccountID primitive.ObjectID `bson:"account_id,omitempty" json:"account_id,omitempty"`
+
+	// the user who added the record, if known
+	CreatedBy primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+
+	// the CSV import that added the record, if any
+	ImportBatch primitive.ObjectID `bson:"import_batch,omitempty" json:"import_batch,omitempty"`
+
+	// the bank reconciliation the record was confirmed by, if any. A
+	// reconciled record can't be edited or deleted.
+	ReconciliationID primitive.ObjectID `bson:"reconciliation_id,omitempty" json:"reconciliation_id,omitempty"`
 }
 
 // time.Now().Format("2006-01-02 15:04:05")
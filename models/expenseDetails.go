@@ -7,10 +7,13 @@ import (
 )
 
 type ExpenseDetail struct {
-	ID              primitive.ObjectID `bson:"_id,omitempty"`
-	ExpenseCategory string             `bson:"expense_category"`
-	CreatedAt       time.Time          `bson:"created_at"`
-	UpdatedAt       time.Time          `bson:"updated_at"`
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ExpenseCategory string             `bson:"expense_category" json:"expense_category"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+
+	// the category this one sits under, none for a top-level category
+	ParentID primitive.ObjectID `bson:"parent_id,omitempty" json:"parent_id,omitempty"`
 }
 
 // time.Now().Format("2006-01-02 15:04:05")
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Import batch states
+const (
+	ImportRunning    = "importing"
+	ImportComplete   = "complete"
+	ImportFailed     = "failed"
+	ImportRolledBack = "rolled_back"
+)
+
+// ImportBatch records one CSV import. Every income or expense it inserted
+// carries its ID, so the import can be reviewed and rolled back as a unit.
+type ImportBatch struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind         string             `bson:"kind" json:"kind"` // income or expense
+	FileName     string             `bson:"file_name" json:"file_name"`
+	FileHash     string             `bson:"file_hash" json:"file_hash"` // hex SHA-256 of the file
+	UserID       primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Username     string             `bson:"username" json:"username"`
+	Rows         int                `bson:"rows" json:"rows"` // data rows in the file
+	Imported     int                `bson:"imported" json:"imported"`
+	Skipped      int                `bson:"skipped" json:"skipped"`
+	Total        float64            `bson:"total" json:"total"`
+	Status       string             `bson:"status" json:"status"`
+	Error        string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	RolledBackAt time.Time          `bson:"rolled_back_at,omitempty" json:"rolled_back_at,omitempty"`
+}
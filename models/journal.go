@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JournalEntry is a write made while the database was unreachable, kept in
+// the local journal until it is replayed
+type JournalEntry struct {
+	ID         string             `json:"id"`
+	Op         string             `json:"op"` // insert, update or delete
+	Collection string             `json:"collection"`
+	DocID      primitive.ObjectID `json:"doc_id"`
+	Document   json.RawMessage    `json:"document,omitempty"`
+	Since      time.Time          `json:"since"` // last time the database was reachable
+	QueuedAt   time.Time          `json:"queued_at"`
+	Conflict   string             `json:"conflict,omitempty"` // why replaying stopped, if it did
+	Force      bool               `json:"force,omitempty"`    // replay despite a conflict
+}
@@ -7,8 +7,8 @@ import (
 )
 
 type Log struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	Status    string             `bson:"status"`
-	Details   string             `bson:"details,omitempty"`
-	Timestamp time.Time          `bson:"timestamp"`
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Status    string             `bson:"status" json:"status"`
+	Details   string             `bson:"details,omitempty" json:"details,omitempty"`
+	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
 }
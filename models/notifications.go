@@ -4,9 +4,9 @@ import "go.mongodb.org/mongo-driver/bson/primitive"
 
 // Notification struct for storing notification data
 type Notification struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	UserID    primitive.ObjectID `bson:"user_id"`
-	Message   string             `bson:"message"`
-	IsRead    bool               `bson:"is_read"`
-	CreatedAt primitive.DateTime `bson:"created_at"`
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Message   string             `bson:"message" json:"message"`
+	IsRead    bool               `bson:"is_read" json:"is_read"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
 }
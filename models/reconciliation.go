@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reconciliation states
+const (
+	ReconciliationOpen     = "open"
+	ReconciliationComplete = "complete"
+)
+
+// How a statement line was matched to a record
+const (
+	MatchAuto    = "auto"
+	MatchManual  = "manual"
+	MatchCreated = "created"
+)
+
+// StatementLine is one line of a bank statement. Money in is positive and
+// is matched to an income, money out is negative and matched to an expense.
+type StatementLine struct {
+	Date        time.Time `bson:"date" json:"date"`
+	Description string    `bson:"description" json:"description"`
+	Amount      float64   `bson:"amount" json:"amount"`
+
+	// the record the line was matched to, its amount at the time and how
+	// it was matched
+	RecordID     primitive.ObjectID `bson:"record_id,omitempty" json:"record_id,omitempty"`
+	RecordAmount float64            `bson:"record_amount,omitempty" json:"record_amount,omitempty"`
+	MatchedBy    string             `bson:"matched_by,omitempty" json:"matched_by,omitempty"`
+}
+
+// Reconciliation compares a bank statement for a period with the recorded
+// incomes and expenses. Once complete, the records it matched are locked.
+type Reconciliation struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FileName    string             `bson:"file_name" json:"file_name"`
+	AccountID   primitive.ObjectID `bson:"account_id,omitempty" json:"account_id,omitempty"` // only its records are matched
+	From        time.Time          `bson:"from" json:"from"`
+	To          time.Time          `bson:"to" json:"to"`
+	Lines       []StatementLine    `bson:"lines" json:"lines"`
+	Status      string             `bson:"status" json:"status"`
+	CreatedBy   primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+	CompletedAt time.Time          `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
@@ -2,8 +2,29 @@ package models
 
 // MonthlyFinance represents the aggregated financial data
 type Report struct {
-	Month        string  `bson:"month"`
-	TotalIncome  float64 `bson:"total_income"`
-	TotalExpense float64 `bson:"total_expense"`
-	Balance      float64 `bson:"balance"`
+	Month        string  `bson:"month" json:"month"`
+	TotalIncome  float64 `bson:"total_income" json:"total_income"`
+	TotalExpense float64 `bson:"total_expense" json:"total_expense"`
+	Balance      float64 `bson:"balance" json:"balance"`
+}
+
+// TagTotal is the income and expenses carrying a tag
+type TagTotal struct {
+	Tag          string  `bson:"_id" json:"tag"`
+	TotalIncome  float64 `bson:"total_income" json:"total_income"`
+	TotalExpense float64 `bson:"total_expense" json:"total_expense"`
+	Count        int     `bson:"count" json:"count"`
+}
+
+// CategoryTotal is the amount recorded under a category. Total includes its
+// subcategories; Own is what was recorded against the category itself.
+type CategoryTotal struct {
+	ID       string  `json:"id"`
+	Type     string  `json:"type"`
+	Name     string  `json:"name"`     // its own name, such as Fuel
+	Category string  `json:"category"` // the full name, such as "Transport > Fuel"
+	Depth    int     `json:"depth"`
+	Total    float64 `json:"total"`
+	Own      float64 `json:"own"`
+	Count    int     `json:"count"`
 }
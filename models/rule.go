@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Rule files incomes or expenses under a category and tags them. It matches
+// a record when every condition it sets holds: Pattern, a regular expression
+// matched against the payee and notes ignoring case, and the amount range,
+// where a zero bound is open. Rules are tried by ascending Priority and the
+// first match wins.
+type Rule struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	Kind      string             `bson:"kind" json:"kind"` // income or expense
+	Priority  int                `bson:"priority" json:"priority"`
+	Pattern   string             `bson:"pattern" json:"pattern,omitempty"`
+	MinAmount float64            `bson:"min_amount" json:"min_amount,omitempty"`
+	MaxAmount float64            `bson:"max_amount" json:"max_amount,omitempty"`
+	Category  string             `bson:"category" json:"category,omitempty"` // full name, empty to keep the record's
+	Tags      []string           `bson:"tags" json:"tags,omitempty"`         // added to the record's
+	Active    bool               `bson:"active" json:"active"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+
+	// the category by reference. Category is read from the tree through it,
+	// so renaming the category doesn't leave the rule behind.
+	CategoryID primitive.ObjectID `bson:"category_id,omitempty" json:"category_id,omitempty"`
+}
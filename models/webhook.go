@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook receives a signed POST for every event it subscribes to
+type Webhook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	URL       string             `bson:"url" json:"url"`
+	Secret    string             `bson:"secret" json:"-"`
+	Events    []string           `bson:"events" json:"events"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// WebhookDelivery records one attempt to deliver an event
+type WebhookDelivery struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WebhookID  primitive.ObjectID `bson:"webhook_id" json:"webhook_id"`
+	DeliveryID string             `bson:"delivery_id" json:"delivery_id"`
+	Event      string             `bson:"event" json:"event"`
+	URL        string             `bson:"url" json:"url"`
+	Payload    string             `bson:"payload" json:"payload"`
+	Attempt    int                `bson:"attempt" json:"attempt"`
+	StatusCode int                `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string             `bson:"error,omitempty" json:"error,omitempty"`
+	Success    bool               `bson:"success" json:"success"`
+	Duration   time.Duration      `bson:"duration" json:"duration"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
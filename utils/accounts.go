@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fynance/helpers"
+	"fynance/models"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrAccountInUse is returned when deleting an account that records or
+// transfers still refer to
+var ErrAccountInUse = errors.New("the account is still in use")
+
+// AccountTypes lists the kinds of account
+var AccountTypes = []string{models.AccountBank, models.AccountMobile, models.AccountCash, models.AccountSavings}
+
+// ValidateAccount checks the name and type of an account before it is saved
+func ValidateAccount(ctx context.Context, account models.Account) error {
+	if account.Name == "" {
+		return errors.New("the account needs a name")
+	}
+	if !slices.Contains(AccountTypes, account.Type) {
+		return fmt.Errorf("unknown account type %q", account.Type)
+	}
+	accounts, err := ListAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, other := range accounts {
+		if other.ID != account.ID && strings.EqualFold(other.Name, account.Name) {
+			return fmt.Errorf("there is already an account named %q", other.Name)
+		}
+	}
+	return nil
+}
+
+// AddAccount saves a new account
+func AddAccount(ctx context.Context, account models.Account) (models.Account, error) {
+	account.Name = strings.TrimSpace(account.Name)
+	if err := ValidateAccount(ctx, account); err != nil {
+		return account, err
+	}
+	account.ID = primitive.NewObjectID()
+	account.CreatedAt = stamp(time.Now())
+	account.UpdatedAt = account.CreatedAt
+
+	_, err := GetCollection("accounts").InsertOne(ctx, account)
+	return account, err
+}
+
+// UpdateAccount saves the name, type and opening balance of an account
+func UpdateAccount(ctx context.Context, account models.Account) error {
+	account.Name = strings.TrimSpace(account.Name)
+	if err := ValidateAccount(ctx, account); err != nil {
+		return err
+	}
+	_, err := GetCollection("accounts").UpdateOne(ctx, bson.M{"_id": account.ID}, bson.M{"$set": bson.M{
+		"name":            account.Name,
+		"type":            account.Type,
+		"opening_balance": account.OpeningBalance,
+		"updated_at":      stamp(time.Now()),
+	}})
+	return err
+}
+
+// AccountUsage counts the records and transfers referring to an account
+func AccountUsage(ctx context.Context, id primitive.ObjectID) (records, transfers int64, err error) {
+	for _, collection := range []string{"income", "expenses"} {
+		count, err := GetCollection(collection).CountDocuments(ctx, bson.M{"account_id": id})
+		if err != nil {
+			return 0, 0, err
+		}
+		records += count
+	}
+	transfers, err = GetCollection("transfers").CountDocuments(ctx, bson.M{"$or": bson.A{
+		bson.M{"from_account": id},
+		bson.M{"to_account": id},
+	}})
+	return records, transfers, err
+}
+
+// DeleteAccount removes an account no records or transfers refer to
+func DeleteAccount(ctx context.Context, id primitive.ObjectID) error {
+	records, transfers, err := AccountUsage(ctx, id)
+	if err != nil {
+		return err
+	}
+	if records > 0 || transfers > 0 {
+		return fmt.Errorf("%w by %d records and %d transfers", ErrAccountInUse, records, transfers)
+	}
+	_, err = GetCollection("accounts").DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// GetAccount returns the account with id
+func GetAccount(ctx context.Context, id primitive.ObjectID) (models.Account, error) {
+	var account models.Account
+	err := GetCollection("accounts").FindOne(ctx, bson.M{"_id": id}).Decode(&account)
+	return account, err
+}
+
+// ListAccounts returns every account by name
+func ListAccounts(ctx context.Context) ([]models.Account, error) {
+	return findAll[models.Account](ctx, "accounts", bson.M{}, bson.D{{Key: "name", Value: 1}}, 0)
+}
+
+// AddTransfer records money moved between two accounts
+func AddTransfer(ctx context.Context, transfer models.Transfer) (models.Transfer, error) {
+	if transfer.FromID.IsZero() || transfer.ToID.IsZero() {
+		return transfer, errors.New("choose the accounts to transfer from and to")
+	}
+	if transfer.FromID == transfer.ToID {
+		return transfer, errors.New("choose two different accounts")
+	}
+	if transfer.Amount <= 0 {
+		return transfer, errors.New("the amount must be greater than zero")
+	}
+	if !slices.Contains(helpers.Months, transfer.Month) {
+		return transfer, fmt.Errorf("unknown month %q", transfer.Month)
+	}
+	if _, err := strconv.Atoi(transfer.Year); err != nil || len(transfer.Year) != 4 {
+		return transfer, fmt.Errorf("invalid year %q", transfer.Year)
+	}
+	for _, id := range []primitive.ObjectID{transfer.FromID, transfer.ToID} {
+		count, err := GetCollection("accounts").CountDocuments(ctx, bson.M{"_id": id})
+		if err != nil {
+			return transfer, err
+		}
+		if count == 0 {
+			return transfer, errors.New("no such account")
+		}
+	}
+
+	transfer.ID = primitive.NewObjectID()
+	transfer.Notes = strings.TrimSpace(transfer.Notes)
+	transfer.CreatedAt = stamp(time.Now())
+	if _, err := GetCollection("transfers").InsertOne(ctx, transfer); err != nil {
+		return transfer, err
+	}
+	FireWebhook(EventTransferCreated, transfer)
+	return transfer, nil
+}
+
+// DeleteTransfer removes a transfer
+func DeleteTransfer(ctx context.Context, id primitive.ObjectID) error {
+	var deleted models.Transfer
+	err := GetCollection("transfers").FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&deleted)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err == nil {
+		FireWebhook(EventTransferDeleted, deleted)
+	}
+	return err
+}
+
+// ListTransfers returns the transfers in or out of an account, or every
+// transfer when id is nil, newest first
+func ListTransfers(ctx context.Context, id primitive.ObjectID) ([]models.Transfer, error) {
+	filter := bson.M{}
+	if !id.IsZero() {
+		filter["$or"] = bson.A{bson.M{"from_account": id}, bson.M{"to_account": id}}
+	}
+	return findAll[models.Transfer](ctx, "transfers", filter, bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}, 0)
+}
+
+// accountSum is the total of one account in one month, or overall when the
+// month and year are empty
+type accountSum struct {
+	ID struct {
+		Account primitive.ObjectID `bson:"account"`
+		Month   string             `bson:"month"`
+		Year    string             `bson:"year"`
+	} `bson:"_id"`
+	Total float64 `bson:"total"`
+}
+
+// sumByAccount totals the amounts of a collection by the account in field,
+// and by month too when monthly is set. match narrows the documents summed.
+func sumByAccount(ctx context.Context, collection, field string, match bson.M, monthly bool) ([]accountSum, error) {
+	group := bson.M{"account": "$" + field}
+	if monthly {
+		group["month"] = "$month"
+		group["year"] = "$year"
+	}
+	if match == nil {
+		match = bson.M{field: bson.M{"$exists": true}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": group, "total": bson.M{"$sum": "$amount"}}}},
+	}
+
+	cursor, err := GetCollection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var sums []accountSum
+	err = cursor.All(ctx, &sums)
+	return sums, err
+}
+
+// AccountBalances returns where every account stands
+func AccountBalances(ctx context.Context) ([]models.AccountBalance, error) {
+	accounts, err := ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]models.AccountBalance, len(accounts))
+	index := map[primitive.ObjectID]*models.AccountBalance{}
+	for i, account := range accounts {
+		balances[i] = models.AccountBalance{Account: account}
+		index[account.ID] = &balances[i]
+	}
+
+	flows := []struct {
+		collection, field string
+		add               func(*models.AccountBalance, float64)
+	}{
+		{"income", "account_id", func(b *models.AccountBalance, total float64) { b.Income = total }},
+		{"expenses", "account_id", func(b *models.AccountBalance, total float64) { b.Expenses = total }},
+		{"transfers", "to_account", func(b *models.AccountBalance, total float64) { b.TransfersIn = total }},
+		{"transfers", "from_account", func(b *models.AccountBalance, total float64) { b.TransfersOut = total }},
+	}
+	for _, flow := range flows {
+		sums, err := sumByAccount(ctx, flow.collection, flow.field, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, sum := range sums {
+			if balance, ok := index[sum.ID.Account]; ok {
+				flow.add(balance, sum.Total)
+			}
+		}
+	}
+
+	for i := range balances {
+		b := &balances[i]
+		b.Balance = b.Account.OpeningBalance + b.Income - b.Expenses + b.TransfersIn - b.TransfersOut
+	}
+	return balances, nil
+}
+
+// periodKey orders a month and year
+func periodKey(month, year string) int {
+	y, _ := strconv.Atoi(year)
+	return y*12 + slices.Index(helpers.Months, month)
+}
+
+// AccountHistory returns the movement on an account by month, oldest first,
+// each with the balance at the end of that month
+func AccountHistory(ctx context.Context, account models.Account) ([]models.BalancePoint, error) {
+	points := map[int]*models.BalancePoint{}
+	flows := []struct {
+		collection, field string
+		add               func(*models.BalancePoint, float64)
+	}{
+		{"income", "account_id", func(p *models.BalancePoint, total float64) { p.Income += total }},
+		{"expenses", "account_id", func(p *models.BalancePoint, total float64) { p.Expenses += total }},
+		{"transfers", "to_account", func(p *models.BalancePoint, total float64) { p.TransfersIn += total }},
+		{"transfers", "from_account", func(p *models.BalancePoint, total float64) { p.TransfersOut += total }},
+	}
+	for _, flow := range flows {
+		sums, err := sumByAccount(ctx, flow.collection, flow.field, bson.M{flow.field: account.ID}, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, sum := range sums {
+			key := periodKey(sum.ID.Month, sum.ID.Year)
+			point, ok := points[key]
+			if !ok {
+				point = &models.BalancePoint{Month: sum.ID.Month, Year: sum.ID.Year}
+				points[key] = point
+			}
+			flow.add(point, sum.Total)
+		}
+	}
+
+	keys := make([]int, 0, len(points))
+	for key := range points {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	history := make([]models.BalancePoint, 0, len(keys))
+	balance := account.OpeningBalance
+	for _, key := range keys {
+		point := *points[key]
+		balance += point.Income - point.Expenses + point.TransfersIn - point.TransfersOut
+		point.Balance = balance
+		history = append(history, point)
+	}
+	return history, nil
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fynance/models"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// apiTokenPrefix marks Fynance tokens so they are easy to spot in scripts
+const apiTokenPrefix = "fyn_"
+
+var ErrInvalidToken = errors.New("invalid API token")
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken issues a new token for the user. The returned token string
+// is the only copy, the database keeps its hash.
+func CreateAPIToken(ctx context.Context, userID primitive.ObjectID, name string) (string, models.APIToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", models.APIToken{}, err
+	}
+	token := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiToken := models.APIToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    token[:len(apiTokenPrefix)+6],
+		TokenHash: hashAPIToken(token),
+		CreatedAt: time.Now(),
+	}
+
+	if _, err := GetCollection("api_tokens").InsertOne(ctx, apiToken); err != nil {
+		return "", models.APIToken{}, err
+	}
+	return token, apiToken, nil
+}
+
+// ListAPITokens returns the user's tokens, newest first
+func ListAPITokens(ctx context.Context, userID primitive.ObjectID) ([]models.APIToken, error) {
+	return findAll[models.APIToken](ctx, "api_tokens", bson.M{"user_id": userID}, bson.D{{Key: "created_at", Value: -1}}, 0)
+}
+
+// RevokeAPIToken deletes one of the user's tokens
+func RevokeAPIToken(ctx context.Context, userID, tokenID primitive.ObjectID) error {
+	_, err := GetCollection("api_tokens").DeleteOne(ctx, bson.M{"_id": tokenID, "user_id": userID})
+	return err
+}
+
+// UserForAPIToken returns the user a token belongs to and records its use
+func UserForAPIToken(ctx context.Context, token string) (models.User, error) {
+	var user models.User
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return user, ErrInvalidToken
+	}
+
+	var apiToken models.APIToken
+	err := GetCollection("api_tokens").FindOneAndUpdate(ctx,
+		bson.M{"token_hash": hashAPIToken(token)},
+		bson.M{"$set": bson.M{"last_used_at": time.Now()}},
+	).Decode(&apiToken)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return user, ErrInvalidToken
+	}
+	if err != nil {
+		return user, err
+	}
+
+	err = GetCollection("users").FindOne(ctx, bson.M{"_id": apiToken.UserID}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// the user was deleted, the token is no longer valid
+		return user, ErrInvalidToken
+	}
+	return user, err
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"fynance/models"
+	"image"
+	"image/color"
+	_ "image/gif" // decoders for the thumbnails
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxAttachmentSize is the largest file that can be attached
+const MaxAttachmentSize = 20 << 20
+
+// attachmentBucket is the GridFS bucket holding attachments, stored in the
+// attachments.files and attachments.chunks collections
+const attachmentBucket = "attachments"
+
+// thumbnailSize bounds the width and height of the previews shown in lists
+const thumbnailSize = 64
+
+// maxThumbnailPixels is the largest image given a thumbnail. A small file can
+// claim huge dimensions, and decoding it would allocate all of them.
+const maxThumbnailPixels = 50_000_000
+
+var (
+	ErrAttachmentTooLarge = fmt.Errorf("attachments can be at most %d MB", MaxAttachmentSize>>20)
+	ErrAttachmentType     = errors.New("only PNG, JPEG and GIF images and PDF files can be attached")
+)
+
+// attachmentTypes are the content types that can be attached, as sniffed
+// from the file itself rather than trusted from its name
+var attachmentTypes = []string{"image/png", "image/jpeg", "image/gif", "application/pdf"}
+
+func attachmentStore() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(GetDatabase(), options.GridFSBucket().SetName(attachmentBucket))
+}
+
+// AttachFile stores the file read from r with the income or expense recordID
+func AttachFile(ctx context.Context, kind string, recordID primitive.ObjectID, fileName string, r io.Reader, uploadedBy string) (models.Attachment, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxAttachmentSize+1))
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if len(data) > MaxAttachmentSize {
+		return models.Attachment{}, ErrAttachmentTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	if !slices.Contains(attachmentTypes, contentType) {
+		return models.Attachment{}, ErrAttachmentType
+	}
+
+	sum := sha256.Sum256(data)
+	attachment := models.Attachment{
+		ID:         primitive.NewObjectID(),
+		FileName:   fileName,
+		Length:     int64(len(data)),
+		UploadedAt: time.Now(),
+		Metadata: models.AttachmentMetadata{
+			Kind:        kind,
+			RecordID:    recordID,
+			ContentType: contentType,
+			SHA256:      hex.EncodeToString(sum[:]),
+			Thumbnail:   thumbnail(data),
+			UploadedBy:  uploadedBy,
+		},
+	}
+
+	store, err := attachmentStore()
+	if err != nil {
+		return attachment, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		store.SetWriteDeadline(deadline)
+	}
+	err = store.UploadFromStreamWithID(attachment.ID, fileName, bytes.NewReader(data),
+		options.GridFSUpload().SetMetadata(attachment.Metadata))
+	return attachment, err
+}
+
+// ListAttachments returns the attachments of a record, oldest first
+func ListAttachments(ctx context.Context, recordID primitive.ObjectID) ([]models.Attachment, error) {
+	return findAll[models.Attachment](ctx, attachmentBucket+".files", bson.M{"metadata.record_id": recordID},
+		bson.D{{Key: "uploadDate", Value: 1}}, 0)
+}
+
+// AttachmentsOf returns the attachments of each of the records, for showing
+// them in a list
+func AttachmentsOf(ctx context.Context, recordIDs []primitive.ObjectID) (map[primitive.ObjectID][]models.Attachment, error) {
+	byRecord := map[primitive.ObjectID][]models.Attachment{}
+	if len(recordIDs) == 0 {
+		return byRecord, nil
+	}
+	attachments, err := findAll[models.Attachment](ctx, attachmentBucket+".files",
+		bson.M{"metadata.record_id": bson.M{"$in": recordIDs}}, bson.D{{Key: "uploadDate", Value: 1}}, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, attachment := range attachments {
+		byRecord[attachment.Metadata.RecordID] = append(byRecord[attachment.Metadata.RecordID], attachment)
+	}
+	return byRecord, nil
+}
+
+// ReadAttachment returns the content of an attachment
+func ReadAttachment(ctx context.Context, id primitive.ObjectID) ([]byte, error) {
+	store, err := attachmentStore()
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		store.SetReadDeadline(deadline)
+	}
+	var content bytes.Buffer
+	if _, err := store.DownloadToStream(id, &content); err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
+
+// DeleteAttachment removes an attachment and its content
+func DeleteAttachment(ctx context.Context, id primitive.ObjectID) error {
+	store, err := attachmentStore()
+	if err != nil {
+		return err
+	}
+	return store.DeleteContext(ctx, id)
+}
+
+// deleteAttachmentsOf removes the attachments of records that were deleted
+func deleteAttachmentsOf(ctx context.Context, recordIDs []primitive.ObjectID) error {
+	if len(recordIDs) == 0 {
+		return nil
+	}
+	store, err := attachmentStore()
+	if err != nil {
+		return err
+	}
+	cursor, err := store.GetFilesCollection().Find(ctx, bson.M{"metadata.record_id": bson.M{"$in": recordIDs}},
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var files []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &files); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, file := range files {
+		errs = append(errs, store.DeleteContext(ctx, file.ID))
+	}
+	return errors.Join(errs...)
+}
+
+// thumbnail returns a small PNG of an image, or nil for anything else and for
+// images too large to decode
+func thumbnail(data []byte) []byte {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || int64(config.Width)*int64(config.Height) > maxThumbnailPixels {
+		return nil
+	}
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil
+	}
+	scale := min(1, float64(thumbnailSize)/float64(max(width, height)))
+	thumbWidth, thumbHeight := max(1, int(float64(width)*scale)), max(1, int(float64(height)*scale))
+
+	// nearest neighbour is plenty at this size
+	thumb := image.NewNRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+	for y := range thumbHeight {
+		for x := range thumbWidth {
+			c := source.At(bounds.Min.X+x*width/thumbWidth, bounds.Min.Y+y*height/thumbHeight)
+			thumb.Set(x, y, color.NRGBAModel.Convert(c))
+		}
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, thumb); err != nil {
+		return nil
+	}
+	return encoded.Bytes()
+}
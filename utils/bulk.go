@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fynance/helpers"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrBulkUnavailable is returned for bulk changes while the database is
+// unreachable or offline changes are waiting. Bulk changes are not journaled.
+var ErrBulkUnavailable = errors.New("bulk changes need the database online with no offline changes waiting")
+
+// AmountChange says what a bulk edit does to the amounts
+type AmountChange int
+
+const (
+	AmountKeep    AmountChange = iota
+	AmountSet                  // replace with Amount
+	AmountAdd                  // add Amount, which may be negative
+	AmountPercent              // change by Amount percent
+)
+
+// BulkEdit is applied to every selected income or expense. Empty fields are
+// left as they are.
+type BulkEdit struct {
+	Category     string
+	Month        string
+	Year         string
+	AmountChange AmountChange
+	Amount       float64
+}
+
+// IsEmpty reports whether the edit changes nothing
+func (e BulkEdit) IsEmpty() bool {
+	return e.Category == "" && e.Month == "" && e.Year == "" && e.AmountChange == AmountKeep
+}
+
+// Validate checks the edit, with categories the ones it may set
+func (e BulkEdit) Validate(categories []string) error {
+	if e.IsEmpty() {
+		return errors.New("choose something to change")
+	}
+	if e.Category != "" && !slices.Contains(categories, e.Category) {
+		return fmt.Errorf("unknown category %q", e.Category)
+	}
+	if e.Month != "" && !slices.Contains(helpers.Months, e.Month) {
+		return fmt.Errorf("unknown month %q", e.Month)
+	}
+	if _, err := strconv.Atoi(e.Year); e.Year != "" && (err != nil || len(e.Year) != 4) {
+		return fmt.Errorf("invalid year %q", e.Year)
+	}
+	if e.AmountChange == AmountSet && e.Amount <= 0 {
+		return errors.New("the amount must be greater than zero")
+	}
+	if e.AmountChange == AmountPercent && e.Amount <= -100 {
+		return errors.New("the amount can't be reduced by 100% or more")
+	}
+	return nil
+}
+
+// minAmount is the smallest amount the edit leaves at one cent or more.
+// Records below it are skipped rather than taken to zero or below.
+func (e BulkEdit) minAmount() (float64, bool) {
+	switch {
+	case e.AmountChange == AmountAdd && e.Amount < 0:
+		return 0.01 - e.Amount, true
+	case e.AmountChange == AmountPercent && e.Amount < 0:
+		return 0.01 / (1 + e.Amount/100), true
+	}
+	return 0, false
+}
+
+// String describes the edit for notifications and logs
+func (e BulkEdit) String() string {
+	var changes []string
+	if e.Category != "" {
+		changes = append(changes, "category to "+e.Category)
+	}
+	if e.Month != "" || e.Year != "" {
+		changes = append(changes, strings.TrimSpace("period to "+e.Month+" "+e.Year))
+	}
+	amount := strconv.FormatFloat(e.Amount, 'f', -1, 64)
+	switch e.AmountChange {
+	case AmountSet:
+		changes = append(changes, "amount to "+amount)
+	case AmountAdd:
+		changes = append(changes, "amount by "+amount)
+	case AmountPercent:
+		changes = append(changes, "amount by "+amount+"%")
+	}
+	return strings.Join(changes, ", ")
+}
+
+// update is the update pipeline of the edit, with categoryID that of the
+// category it sets. Amounts are rounded to cents and text is set literally,
+// so a category can't be read as a field path.
+func (e BulkEdit) update(now time.Time, categoryID primitive.ObjectID) bson.A {
+	set := bson.M{"updated_at": now}
+	if e.Category != "" {
+		set["category"] = bson.M{"$literal": e.Category}
+		set["category_id"] = categoryID
+		if categoryID.IsZero() {
+			set["category_id"] = "$$REMOVE"
+		}
+	}
+	if e.Month != "" {
+		set["month"] = bson.M{"$literal": e.Month}
+	}
+	if e.Year != "" {
+		set["year"] = bson.M{"$literal": e.Year}
+	}
+	switch e.AmountChange {
+	case AmountSet:
+		set["amount"] = e.Amount
+	case AmountAdd:
+		set["amount"] = bson.M{"$round": bson.A{bson.M{"$add": bson.A{"$amount", e.Amount}}, 2}}
+	case AmountPercent:
+		set["amount"] = bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{"$amount", 1 + e.Amount/100}}, 2}}
+	}
+	return bson.A{bson.M{"$set": set}}
+}
+
+// BulkUpdateIncomes applies edit to the incomes with the given ids in one
+// call, returning how many changed. Reconciled incomes, and those the edit
+// would leave without a positive amount, are skipped.
+func BulkUpdateIncomes(ctx context.Context, ids []primitive.ObjectID, edit BulkEdit) (int64, error) {
+	return bulkUpdate(ctx, "income", "income", ids, edit)
+}
+
+// BulkUpdateExpenses applies edit to the expenses with the given ids in one
+// call, returning how many changed. Reconciled expenses, and those the edit
+// would leave without a positive amount, are skipped.
+func BulkUpdateExpenses(ctx context.Context, ids []primitive.ObjectID, edit BulkEdit) (int64, error) {
+	return bulkUpdate(ctx, "expenses", "expense", ids, edit)
+}
+
+// BulkDeleteIncomes deletes the incomes with the given ids in one call
+func BulkDeleteIncomes(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	return bulkDelete(ctx, "income", "income", ids)
+}
+
+// BulkDeleteExpenses deletes the expenses with the given ids in one call
+func BulkDeleteExpenses(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	return bulkDelete(ctx, "expenses", "expense", ids)
+}
+
+func bulkUpdate(ctx context.Context, collection, kind string, ids []primitive.ObjectID, edit BulkEdit) (int64, error) {
+	if len(ids) == 0 || edit.IsEmpty() {
+		return 0, nil
+	}
+	if err := bulkAvailable(); err != nil {
+		return 0, err
+	}
+
+	var category primitive.ObjectID
+	if edit.Category != "" {
+		category = categoryID(ctx, kind, edit.Category)
+	}
+	filter := unreconciled(bson.M{"_id": bson.M{"$in": ids}})
+	if minimum, ok := edit.minAmount(); ok {
+		filter["amount"] = bson.M{"$gte": minimum}
+	}
+	result, err := GetCollection(collection).UpdateMany(ctx, filter, edit.update(stamp(time.Now()), category))
+	if err != nil {
+		return 0, err
+	}
+	FireWebhook(EventBulkUpdated, map[string]any{
+		"type":   kind,
+		"count":  result.ModifiedCount,
+		"ids":    ids,
+		"change": edit.String(),
+	})
+	return result.ModifiedCount, nil
+}
+
+func bulkDelete(ctx context.Context, collection, kind string, ids []primitive.ObjectID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := bulkAvailable(); err != nil {
+		return 0, err
+	}
+
+	// reconciled records are left, and so are their files
+	ids, err := unreconciledIDs(ctx, collection, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	defer invalidateCount(collection)
+	result, err := GetCollection(collection).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	if err := deleteAttachmentsOf(ctx, ids); err != nil {
+		return result.DeletedCount, err
+	}
+	FireWebhook(EventBulkDeleted, map[string]any{
+		"type":  kind,
+		"count": result.DeletedCount,
+		"ids":   ids,
+	})
+	return result.DeletedCount, nil
+}
+
+// bulkAvailable refuses bulk changes that would race the offline journal
+func bulkAvailable() error {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	if journalEnabled {
+		loadJournal()
+	}
+	if !databaseOnline || len(journal) > 0 {
+		return ErrBulkUnavailable
+	}
+	return nil
+}
@@ -0,0 +1,581 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fynance/models"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CategorySeparator joins the names of a category and the categories above
+// it into its full name
+const CategorySeparator = " > "
+
+// ErrCategoryInUse is returned when deleting a category that records or
+// subcategories still use
+var ErrCategoryInUse = errors.New("the category is still in use")
+
+// ErrCategoryExists is returned for a category named like one of its siblings
+var ErrCategoryExists = errors.New("the category already exists")
+
+// recordCollections hold the records filed under the categories of each
+// kind, income or expense
+var recordCollections = map[string]string{"income": "income", "expense": "expenses"}
+
+// Category is an income or expense category placed in its tree
+type Category struct {
+	ID        primitive.ObjectID
+	Name      string // its own name, such as Fuel
+	Path      string // its full name, such as Transport > Fuel
+	ParentID  primitive.ObjectID
+	Depth     int // 0 for a top-level category
+	CreatedAt time.Time
+}
+
+// CategoryTree holds the categories of one kind, each followed by its
+// subcategories in order of name
+type CategoryTree struct {
+	Kind       string
+	Categories []Category
+	byID       map[primitive.ObjectID]int
+}
+
+// LoadCategoryTree reads the categories of kind, income or expense
+func LoadCategoryTree(ctx context.Context, kind string) (CategoryTree, error) {
+	var categories []Category
+	switch kind {
+	case "income":
+		details, err := ListIncomeCategories(ctx)
+		if err != nil {
+			return CategoryTree{}, err
+		}
+		for _, detail := range details {
+			categories = append(categories, Category{ID: detail.ID, Name: detail.IncomeCategory, ParentID: detail.ParentID, CreatedAt: detail.CreatedAt})
+		}
+	case "expense":
+		details, err := ListExpenseCategories(ctx)
+		if err != nil {
+			return CategoryTree{}, err
+		}
+		for _, detail := range details {
+			categories = append(categories, Category{ID: detail.ID, Name: detail.ExpenseCategory, ParentID: detail.ParentID, CreatedAt: detail.CreatedAt})
+		}
+	default:
+		return CategoryTree{}, fmt.Errorf("unknown category type %q", kind)
+	}
+	return newCategoryTree(kind, categories), nil
+}
+
+// GetCategoryTree reads the categories of kind, showing any error in window
+func GetCategoryTree(kind string, window fyne.Window) CategoryTree {
+	tree, err := LoadCategoryTree(context.TODO(), kind)
+	if err != nil {
+		dialog.ShowError(err, window)
+	}
+	return tree
+}
+
+// CategoryPaths returns the full names of the categories of kind, which
+// records are filed under, in tree order
+func CategoryPaths(ctx context.Context, kind string) ([]string, error) {
+	tree, err := LoadCategoryTree(ctx, kind)
+	return tree.Paths(), err
+}
+
+// newCategoryTree orders categories under their parents. A category whose
+// parent is gone, or that would sit under itself, is placed at the top.
+func newCategoryTree(kind string, categories []Category) CategoryTree {
+	known := map[primitive.ObjectID]bool{}
+	for _, category := range categories {
+		known[category.ID] = true
+	}
+
+	children := map[primitive.ObjectID][]Category{}
+	for _, category := range categories {
+		parent := category.ParentID
+		if !known[parent] {
+			parent = primitive.NilObjectID
+		}
+		children[parent] = append(children[parent], category)
+	}
+	for _, siblings := range children {
+		sort.SliceStable(siblings, func(i, j int) bool {
+			return strings.ToLower(siblings[i].Name) < strings.ToLower(siblings[j].Name)
+		})
+	}
+
+	tree := CategoryTree{Kind: kind, byID: map[primitive.ObjectID]int{}}
+	var place func(parent primitive.ObjectID, path string, depth int)
+	place = func(parent primitive.ObjectID, path string, depth int) {
+		for _, category := range children[parent] {
+			if _, placed := tree.byID[category.ID]; placed {
+				continue
+			}
+			category.Depth = depth
+			category.Path = category.Name
+			if path != "" {
+				category.Path = path + CategorySeparator + category.Name
+			} else {
+				category.ParentID = primitive.NilObjectID
+			}
+			tree.byID[category.ID] = len(tree.Categories)
+			tree.Categories = append(tree.Categories, category)
+			place(category.ID, category.Path, depth+1)
+		}
+	}
+	place(primitive.NilObjectID, "", 0)
+
+	// categories in a loop of parents are never reached from the top
+	for _, category := range categories {
+		if _, placed := tree.byID[category.ID]; !placed {
+			children[primitive.NilObjectID] = []Category{category}
+			place(primitive.NilObjectID, "", 0)
+		}
+	}
+	return tree
+}
+
+// Find returns the category with id
+func (t CategoryTree) Find(id primitive.ObjectID) (Category, bool) {
+	i, ok := t.byID[id]
+	if !ok {
+		return Category{}, false
+	}
+	return t.Categories[i], true
+}
+
+// FindPath returns the category with a full name, ignoring case when no
+// category matches it exactly
+func (t CategoryTree) FindPath(path string) (Category, bool) {
+	path = strings.TrimSpace(path)
+	for _, category := range t.Categories {
+		if category.Path == path {
+			return category, true
+		}
+	}
+	for _, category := range t.Categories {
+		if strings.EqualFold(category.Path, path) {
+			return category, true
+		}
+	}
+	return Category{}, false
+}
+
+// IDOf returns the ID of the category with a full name, or the nil ID when
+// there is none
+func (t CategoryTree) IDOf(path string) primitive.ObjectID {
+	category, _ := t.FindPath(path)
+	return category.ID
+}
+
+// Paths returns the full names of the categories in tree order
+func (t CategoryTree) Paths() []string {
+	var paths []string
+	for _, category := range t.Categories {
+		paths = append(paths, category.Path)
+	}
+	return paths
+}
+
+// Subtree returns the category with id followed by everything under it
+func (t CategoryTree) Subtree(id primitive.ObjectID) []Category {
+	i, ok := t.byID[id]
+	if !ok {
+		return nil
+	}
+	depth := t.Categories[i].Depth
+	end := i + 1
+	for end < len(t.Categories) && t.Categories[end].Depth > depth {
+		end++
+	}
+	return t.Categories[i:end]
+}
+
+// Root returns the top-level category the category with id sits under,
+// which is the category itself when it is at the top
+func (t CategoryTree) Root(id primitive.ObjectID) (Category, bool) {
+	category, ok := t.Find(id)
+	for ok && !category.ParentID.IsZero() {
+		category, ok = t.Find(category.ParentID)
+	}
+	return category, ok
+}
+
+// CheckCategory validates giving the category with id, or a new one when id
+// is nil, the name and parent. Names are unique among siblings and can't
+// contain the separator of full names.
+func CheckCategory(ctx context.Context, kind string, id primitive.ObjectID, name string, parentID primitive.ObjectID) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("the category name cannot be empty")
+	}
+	if strings.Contains(name, ">") {
+		return errors.New("category names cannot contain >")
+	}
+
+	tree, err := LoadCategoryTree(ctx, kind)
+	if err != nil {
+		return err
+	}
+	if !parentID.IsZero() {
+		if _, ok := tree.Find(parentID); !ok {
+			return fmt.Errorf("no such parent %s category", kind)
+		}
+		for _, below := range tree.Subtree(id) {
+			if below.ID == parentID {
+				return errors.New("a category can't sit under itself or one of its subcategories")
+			}
+		}
+	}
+	for _, category := range tree.Categories {
+		if category.ID != id && category.ParentID == parentID && strings.EqualFold(category.Name, name) {
+			return fmt.Errorf("%w: %s", ErrCategoryExists, category.Path)
+		}
+	}
+	return nil
+}
+
+// setUpdate is the update saving doc. Its reference fields are left out
+// when nil, so they are unset explicitly: a category moved to the top loses
+// its parent, and a record renamed to no known category loses its category
+// ID.
+func setUpdate(doc any, refs map[string]primitive.ObjectID) bson.M {
+	update := bson.M{"$set": doc}
+	unset := bson.M{}
+	for field, ref := range refs {
+		if ref.IsZero() {
+			unset[field] = ""
+		}
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
+
+// categoryRecords matches the records filed under a category, including
+// ones from before categories were referenced by ID
+func categoryRecords(category Category) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"category_id": category.ID},
+		bson.M{"category_id": bson.M{"$exists": false}, "category": category.Path},
+	}}
+}
+
+// categoryRules matches the rules of kind filing records under a category,
+// including ones from before rules referenced it by ID
+func categoryRules(kind string, category Category) bson.M {
+	return bson.M{"kind": kind, "$or": bson.A{
+		bson.M{"category_id": category.ID},
+		bson.M{"category_id": bson.M{"$exists": false}, "category": category.Path},
+	}}
+}
+
+// refileCategory brings the full names stored on records and rules up to
+// date after the category with id, and so everything under it, was renamed
+// or moved
+func refileCategory(ctx context.Context, kind string, id primitive.ObjectID) error {
+	tree, err := LoadCategoryTree(ctx, kind)
+	if err != nil {
+		return err
+	}
+	records := GetCollection(recordCollections[kind])
+	rules := GetCollection("rules")
+	for _, category := range tree.Subtree(id) {
+		refile := bson.M{"$set": bson.M{"category": category.Path}}
+		_, err := records.UpdateMany(ctx, bson.M{"category_id": category.ID, "category": bson.M{"$ne": category.Path}}, refile)
+		if err != nil {
+			return err
+		}
+		_, err = rules.UpdateMany(ctx, bson.M{"kind": kind, "category_id": category.ID, "category": bson.M{"$ne": category.Path}}, refile)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// categoryID looks up the category a record names, returning the nil ID for
+// a name matching none
+func categoryID(ctx context.Context, kind, path string) primitive.ObjectID {
+	tree, err := LoadCategoryTree(ctx, kind)
+	if err != nil {
+		return primitive.NilObjectID
+	}
+	return tree.IDOf(path)
+}
+
+// CategoryUsage counts the records filed directly under the category with id
+// and its direct subcategories
+func CategoryUsage(ctx context.Context, kind string, id primitive.ObjectID) (records int64, subcategories int, err error) {
+	tree, err := LoadCategoryTree(ctx, kind)
+	if err != nil {
+		return 0, 0, err
+	}
+	category, ok := tree.Find(id)
+	if !ok {
+		return 0, 0, nil
+	}
+	for _, below := range tree.Subtree(id) {
+		if below.ParentID == id {
+			subcategories++
+		}
+	}
+	records, err = GetCollection(recordCollections[kind]).CountDocuments(ctx, categoryRecords(category))
+	return records, subcategories, err
+}
+
+// checkUnused refuses deleting a category still in use by records, rules or
+// subcategories
+func checkUnused(ctx context.Context, kind string, id primitive.ObjectID) error {
+	records, subcategories, err := CategoryUsage(ctx, kind, id)
+	if err != nil {
+		return err
+	}
+	tree, err := LoadCategoryTree(ctx, kind)
+	if err != nil {
+		return err
+	}
+	var rules int64
+	if category, ok := tree.Find(id); ok {
+		rules, err = GetCollection("rules").CountDocuments(ctx, categoryRules(kind, category))
+		if err != nil {
+			return err
+		}
+	}
+	if records > 0 || rules > 0 || subcategories > 0 {
+		return fmt.Errorf("%w by %d records, %d rules and %d subcategories", ErrCategoryInUse, records, rules, subcategories)
+	}
+	return nil
+}
+
+// ReassignCategory files the records of the category from under the
+// category to instead, so from can be deleted. The rules filing records
+// under from file them under to as well. It returns how many records moved. Like bulk edits, it needs the database online, and it returns
+// ErrReconciled without moving anything while reconciled records are filed
+// under from.
+func ReassignCategory(ctx context.Context, kind string, from, to primitive.ObjectID) (int64, error) {
+	if err := bulkAvailable(); err != nil {
+		return 0, err
+	}
+	tree, err := LoadCategoryTree(ctx, kind)
+	if err != nil {
+		return 0, err
+	}
+	source, ok := tree.Find(from)
+	if !ok {
+		return 0, fmt.Errorf("no such %s category", kind)
+	}
+	target, ok := tree.Find(to)
+	if !ok || to == from {
+		return 0, fmt.Errorf("choose another %s category to move the records to", kind)
+	}
+
+	records := GetCollection(recordCollections[kind])
+	locked, err := records.CountDocuments(ctx, bson.M{"$and": bson.A{
+		categoryRecords(source),
+		bson.M{"reconciliation_id": bson.M{"$exists": true}},
+	}})
+	if err != nil {
+		return 0, err
+	}
+	if locked > 0 {
+		return 0, fmt.Errorf("%w: %d %s records under %s are reconciled, reopen their reconciliations first",
+			ErrReconciled, locked, kind, source.Path)
+	}
+
+	// narrowed as well, in case a reconciliation completes meanwhile
+	result, err := records.UpdateMany(ctx, unreconciled(categoryRecords(source)), bson.M{"$set": bson.M{
+		"category_id": target.ID,
+		"category":    target.Path,
+		"updated_at":  stamp(time.Now()),
+	}})
+	if err != nil {
+		return 0, err
+	}
+	_, err = GetCollection("rules").UpdateMany(ctx, categoryRules(kind, source), bson.M{"$set": bson.M{
+		"category_id": target.ID,
+		"category":    target.Path,
+		"updated_at":  time.Now(),
+	}})
+	if err != nil {
+		return result.ModifiedCount, err
+	}
+	if result.ModifiedCount > 0 {
+		FireWebhook(EventBulkUpdated, map[string]any{
+			"type":   kind,
+			"count":  result.ModifiedCount,
+			"change": "category from " + source.Path + " to " + target.Path,
+		})
+	}
+	return result.ModifiedCount, nil
+}
+
+// LinkCategories sets the category ID of records of kind that only name
+// their category, returning how many it linked. Records naming no category
+// are left as they are.
+func LinkCategories(ctx context.Context, kind string) (int64, error) {
+	tree, err := LoadCategoryTree(ctx, kind)
+	if err != nil {
+		return 0, err
+	}
+	var linked int64
+	records := GetCollection(recordCollections[kind])
+	for _, category := range tree.Categories {
+		result, err := records.UpdateMany(ctx,
+			bson.M{"category_id": bson.M{"$exists": false}, "category": category.Path},
+			bson.M{"$set": bson.M{"category_id": category.ID}},
+		)
+		if err != nil {
+			return linked, err
+		}
+		linked += result.ModifiedCount
+	}
+	return linked, nil
+}
+
+// categorySum is what the records of one category add up to
+type categorySum struct {
+	Key struct {
+		ID       primitive.ObjectID `bson:"id"`
+		Category string             `bson:"category"`
+	} `bson:"_id"`
+	Total float64 `bson:"total"`
+	Count int     `bson:"count"`
+}
+
+// sumByCategory totals the records of kind by category, for year or every
+// year when empty
+func sumByCategory(ctx context.Context, kind, year string) ([]categorySum, error) {
+	match := bson.M{}
+	if year != "" {
+		match["year"] = year
+	}
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{
+			"_id":   bson.M{"id": "$category_id", "category": "$category"},
+			"total": bson.M{"$sum": "$amount"},
+			"count": bson.M{"$sum": 1},
+		}},
+	}
+	cursor, err := GetCollection(recordCollections[kind]).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var sums []categorySum
+	err = cursor.All(ctx, &sums)
+	return sums, err
+}
+
+// topCategories totals the records of kind under each top-level category,
+// so subcategories roll up into it, and keeps the five largest
+func topCategories(ctx context.Context, kind string) (map[string]float64, error) {
+	tree, err := LoadCategoryTree(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := sumByCategory(ctx, kind, "")
+	if err != nil {
+		return nil, err
+	}
+
+	totals := map[string]float64{}
+	for _, sum := range sums {
+		name := sum.Key.Category
+		if root, ok := tree.Root(sum.Key.ID); ok {
+			name = root.Name
+		} else if category, ok := tree.FindPath(name); ok {
+			root, _ := tree.Root(category.ID)
+			name = root.Name
+		}
+		totals[name] += sum.Total
+	}
+
+	names := make([]string, 0, len(totals))
+	for name := range totals {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return totals[names[i]] > totals[names[j]] })
+
+	stats := make(map[string]float64)
+	for _, name := range names[:min(5, len(names))] {
+		stats[name] = math.Round(totals[name]*100) / 100
+	}
+	return stats, nil
+}
+
+// CategoryReport totals the records of year by category, with each
+// category's total including its subcategories. kind is income, expense, or
+// empty for both. Records naming no category are listed after the tree.
+func CategoryReport(ctx context.Context, kind, year string) ([]models.CategoryTotal, error) {
+	kinds := []string{"income", "expense"}
+	if kind != "" {
+		kinds = []string{kind}
+	}
+
+	var report []models.CategoryTotal
+	for _, kind := range kinds {
+		tree, err := LoadCategoryTree(ctx, kind)
+		if err != nil {
+			return nil, err
+		}
+		sums, err := sumByCategory(ctx, kind, year)
+		if err != nil {
+			return nil, err
+		}
+
+		own := map[primitive.ObjectID]categorySum{}
+		var unfiled []categorySum
+		for _, sum := range sums {
+			id := sum.Key.ID
+			if _, ok := tree.Find(id); !ok {
+				id = tree.IDOf(sum.Key.Category)
+			}
+			if id.IsZero() {
+				unfiled = append(unfiled, sum)
+				continue
+			}
+			merged := own[id]
+			merged.Total += sum.Total
+			merged.Count += sum.Count
+			own[id] = merged
+		}
+
+		for _, category := range tree.Categories {
+			total := models.CategoryTotal{
+				ID:       category.ID.Hex(),
+				Type:     kind,
+				Name:     category.Name,
+				Category: category.Path,
+				Depth:    category.Depth,
+				Own:      math.Round(own[category.ID].Total*100) / 100,
+			}
+			for _, below := range tree.Subtree(category.ID) {
+				total.Total += own[below.ID].Total
+				total.Count += own[below.ID].Count
+			}
+			total.Total = math.Round(total.Total*100) / 100
+			if total.Count > 0 {
+				report = append(report, total)
+			}
+		}
+
+		slices.SortFunc(unfiled, func(a, b categorySum) int { return strings.Compare(a.Key.Category, b.Key.Category) })
+		for _, sum := range unfiled {
+			amount := math.Round(sum.Total*100) / 100
+			report = append(report, models.CategoryTotal{Type: kind, Name: sum.Key.Category, Category: sum.Key.Category, Total: amount, Own: amount, Count: sum.Count})
+		}
+	}
+	return report, nil
+}
@@ -2,25 +2,249 @@ package utils
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/dialog"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-var Client *mongo.Client
+// DatabaseName is the MongoDB database used when none is configured
+const DatabaseName = "fynance"
 
-func ConnectDB(uri string, window fyne.Window) {
-	clientOptions := options.Client().ApplyURI(uri)
-	client, err := mongo.Connect(context.Background(), clientOptions)
+// DefaultMongoURI is used when no other connection string is given
+const DefaultMongoURI = "mongodb://localhost:27017"
 
+// Environment variables overriding the saved connection settings
+const (
+	MongoURIEnv       = "FYNANCE_MONGO_URI"
+	DatabaseEnv       = "FYNANCE_DB_NAME"
+	AuthSourceEnv     = "FYNANCE_DB_AUTH_SOURCE"
+	TLSEnv            = "FYNANCE_DB_TLS"
+	CAFileEnv         = "FYNANCE_DB_CA_FILE"
+	ConnectTimeoutEnv = "FYNANCE_DB_TIMEOUT"
+)
+
+// DBConfig describes how to reach the database
+type DBConfig struct {
+	URI            string `json:"uri"`
+	Database       string `json:"database"`
+	AuthSource     string `json:"auth_source,omitempty"` // overrides the URI's authSource
+	TLS            bool   `json:"tls"`
+	CAFile         string `json:"ca_file,omitempty"` // PEM file trusted for TLS, on top of the system roots
+	ConnectTimeout int    `json:"connect_timeout_seconds"`
+}
+
+// DefaultDBConfig is a local server without TLS
+func DefaultDBConfig() DBConfig {
+	return DBConfig{URI: DefaultMongoURI, Database: DatabaseName, ConnectTimeout: 10}
+}
+
+// The shared client and the configuration it was made with. They are
+// replaced together under clientMu and read through Client and
+// CurrentDBConfig.
+var (
+	clientMu     sync.RWMutex
+	sharedClient *mongo.Client
+	dbConfig     = DefaultDBConfig()
+)
+
+// retiredClientGrace is how long a replaced client stays connected, so calls
+// that picked it up just before it was replaced can still finish
+const retiredClientGrace = 30 * time.Second
+
+// Client returns the shared client, nil before the first connection
+func Client() *mongo.Client {
+	clientMu.RLock()
+	defer clientMu.RUnlock()
+	return sharedClient
+}
+
+// CurrentDBConfig returns the configuration the shared client was made with
+func CurrentDBConfig() DBConfig {
+	clientMu.RLock()
+	defer clientMu.RUnlock()
+	return dbConfig
+}
+
+// setClient makes client the shared client. The previous one is disconnected
+// once the grace period is over, and Disconnect waits for the operations
+// still running on it.
+func setClient(client *mongo.Client, config DBConfig) {
+	clientMu.Lock()
+	previous := sharedClient
+	sharedClient, dbConfig = client, config
+	clientMu.Unlock()
+
+	if previous != nil {
+		time.AfterFunc(retiredClientGrace, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			previous.Disconnect(ctx)
+		})
+	}
+}
+
+// WithEnv returns config with the values set in the environment applied
+func (config DBConfig) WithEnv() DBConfig {
+	if value := os.Getenv(MongoURIEnv); value != "" {
+		config.URI = value
+	}
+	if value := os.Getenv(DatabaseEnv); value != "" {
+		config.Database = value
+	}
+	if value := os.Getenv(AuthSourceEnv); value != "" {
+		config.AuthSource = value
+	}
+	if value, err := strconv.ParseBool(os.Getenv(TLSEnv)); err == nil {
+		config.TLS = value
+	}
+	if value := os.Getenv(CAFileEnv); value != "" {
+		config.CAFile = value
+	}
+	if value, err := strconv.Atoi(os.Getenv(ConnectTimeoutEnv)); err == nil && value > 0 {
+		config.ConnectTimeout = value
+	}
+	return config
+}
+
+// Validate checks the configuration before it is used or saved
+func (config DBConfig) Validate() error {
+	if !strings.HasPrefix(config.URI, "mongodb://") && !strings.HasPrefix(config.URI, "mongodb+srv://") {
+		return errors.New("the connection URI must start with mongodb:// or mongodb+srv://")
+	}
+	if strings.TrimSpace(config.Database) == "" || strings.ContainsAny(config.Database, `/\. "$`) {
+		return errors.New("enter a database name without spaces, dots or slashes")
+	}
+	if config.ConnectTimeout < 1 {
+		return errors.New("the timeout must be at least one second")
+	}
+	return nil
+}
+
+// clientOptions turns the configuration into driver options
+func (config DBConfig) clientOptions() (*options.ClientOptions, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(config.ConnectTimeout) * time.Second
+	clientOptions := options.Client().ApplyURI(config.URI).
+		SetConnectTimeout(timeout).
+		SetServerSelectionTimeout(timeout)
+	if err := clientOptions.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.AuthSource != "" && clientOptions.Auth != nil {
+		clientOptions.Auth.AuthSource = config.AuthSource
+	}
+
+	if config.TLS || config.CAFile != "" {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if config.CAFile != "" {
+			pem, err := os.ReadFile(config.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading the CA file: %w", err)
+			}
+			roots, err := x509.SystemCertPool()
+			if err != nil {
+				roots = x509.NewCertPool()
+			}
+			if !roots.AppendCertsFromPEM(pem) {
+				return nil, errors.New("the CA file holds no PEM certificates")
+			}
+			tlsConfig.RootCAs = roots
+		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
+	return clientOptions, nil
+}
+
+func ConnectDB(config DBConfig, window fyne.Window) {
+	if err := ConnectWith(config); err != nil {
+		dialog.ShowInformation("MongoDB Connect", "Failed to connect to MongoDB: "+err.Error(), window)
+	}
+}
+
+// SettingsFile is where the app saves its settings, the database
+// connection among them
+const SettingsFile = "settings.json"
+
+// SavedDBConfig returns the database connection saved in the settings file,
+// the defaults when there is none. Used without the app, which reads the
+// whole file itself.
+func SavedDBConfig() (DBConfig, error) {
+	saved := struct {
+		Database DBConfig `json:"database"`
+	}{Database: DefaultDBConfig()}
+
+	data, err := os.ReadFile(SettingsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return saved.Database, nil
+	}
 	if err != nil {
-		dialog.ShowInformation("MongoDB Connect", "Failed to connect to MongoDB", window)
+		return saved.Database, err
 	}
-	Client = client
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return saved.Database, fmt.Errorf("reading %s: %w", SettingsFile, err)
+	}
+	return saved.Database, nil
+}
+
+// ConnectWith replaces the shared client with one for config
+func ConnectWith(config DBConfig) error {
+	client, err := newClient(config)
+	if err != nil {
+		return err
+	}
+
+	setClient(client, config)
+	return nil
+}
+
+// TestConnection connects with config and pings the server, without touching
+// the shared client
+func TestConnection(ctx context.Context, config DBConfig) error {
+	client, err := newClient(config)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.ConnectTimeout)*time.Second)
+	defer cancel()
+	return client.Ping(ctx, readpref.Primary())
+}
+
+// newClient creates a client for config. The driver connects lazily, so this
+// only fails on invalid settings.
+func newClient(config DBConfig) (*mongo.Client, error) {
+	clientOptions, err := config.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	return mongo.Connect(context.Background(), clientOptions)
+}
+
+// GetDatabase returns the Fynance database handle
+func GetDatabase() *mongo.Database {
+	clientMu.RLock()
+	defer clientMu.RUnlock()
+	return sharedClient.Database(dbConfig.Database)
 }
 
 func GetCollection(collectionName string) *mongo.Collection {
-	return Client.Database("fynance").Collection(collectionName)
+	return GetDatabase().Collection(collectionName)
 }
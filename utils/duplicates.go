@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"context"
+	"fynance/models"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DuplicateOptions says how alike two records of the same category and
+// period must be to count as duplicates
+type DuplicateOptions struct {
+	Tolerance float64       // the most their amounts may differ by
+	Window    time.Duration // the most their creation times may differ by, zero for any
+}
+
+// DefaultDuplicateOptions match amounts to the cent entered within a day
+var DefaultDuplicateOptions = DuplicateOptions{Tolerance: 0.01, Window: 24 * time.Hour}
+
+// DuplicateGroup is a set of records that are likely the same transaction,
+// oldest first
+type DuplicateGroup[T any] struct {
+	Category string
+	Month    string
+	Year     string
+	Items    []T
+}
+
+// FindDuplicateIncomes groups the incomes that are likely entered more than
+// once
+func FindDuplicateIncomes(ctx context.Context, opts DuplicateOptions) ([]DuplicateGroup[models.Income], error) {
+	return findDuplicates(ctx, "income", opts, func(income models.Income) (float64, time.Time) {
+		return income.Amount, income.CreatedAt
+	})
+}
+
+// FindDuplicateExpenses groups the expenses that are likely entered more
+// than once
+func FindDuplicateExpenses(ctx context.Context, opts DuplicateOptions) ([]DuplicateGroup[models.Expense], error) {
+	return findDuplicates(ctx, "expenses", opts, func(expense models.Expense) (float64, time.Time) {
+		return expense.Amount, expense.CreatedAt
+	})
+}
+
+// findDuplicates lets the database gather the records sharing a category and
+// period, then splits those into groups of records alike in amount and
+// creation time
+func findDuplicates[T any](ctx context.Context, collectionName string, opts DuplicateOptions, fields func(T) (float64, time.Time)) ([]DuplicateGroup[T], error) {
+	pipeline := bson.A{
+		bson.M{"$sort": bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		bson.M{"$group": bson.M{
+			"_id":   bson.M{"category": "$category", "month": "$month", "year": "$year"},
+			"docs":  bson.M{"$push": "$$ROOT"},
+			"count": bson.M{"$sum": 1},
+		}},
+		bson.M{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+		bson.M{"$sort": bson.D{{Key: "_id.year", Value: -1}, {Key: "_id.month", Value: 1}, {Key: "_id.category", Value: 1}}},
+	}
+	cursor, err := GetCollection(collectionName).Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var candidates []struct {
+		Key struct {
+			Category string `bson:"category"`
+			Month    string `bson:"month"`
+			Year     string `bson:"year"`
+		} `bson:"_id"`
+		Docs []T `bson:"docs"`
+	}
+	if err := cursor.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
+
+	var groups []DuplicateGroup[T]
+	for _, candidate := range candidates {
+		for _, items := range clusterDuplicates(candidate.Docs, opts, fields) {
+			groups = append(groups, DuplicateGroup[T]{
+				Category: candidate.Key.Category,
+				Month:    candidate.Key.Month,
+				Year:     candidate.Key.Year,
+				Items:    items,
+			})
+		}
+	}
+	return groups, nil
+}
+
+// clusterDuplicates splits items, oldest first, into groups where every
+// record is alike to at least one other, dropping the records alike to none
+func clusterDuplicates[T any](items []T, opts DuplicateOptions, fields func(T) (float64, time.Time)) [][]T {
+	alike := func(a, b T) bool {
+		amountA, createdA := fields(a)
+		amountB, createdB := fields(b)
+		// a little slack so a tolerance of 0.01 matches amounts a cent apart
+		if math.Abs(amountA-amountB) > opts.Tolerance+1e-9 {
+			return false
+		}
+		gap := createdA.Sub(createdB)
+		return opts.Window <= 0 || (gap <= opts.Window && gap >= -opts.Window)
+	}
+
+	grouped := make([]bool, len(items))
+	var groups [][]T
+	for i := range items {
+		if grouped[i] {
+			continue
+		}
+		grouped[i] = true
+		members := []int{i}
+		for next := 0; next < len(members); next++ {
+			for j := range items {
+				if !grouped[j] && alike(items[members[next]], items[j]) {
+					grouped[j] = true
+					members = append(members, j)
+				}
+			}
+		}
+		if len(members) < 2 {
+			continue
+		}
+
+		sort.Ints(members)
+		group := make([]T, len(members))
+		for k, member := range members {
+			group[k] = items[member]
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
@@ -0,0 +1,280 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"fynance/helpers"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// workbookStyles holds the style IDs shared by every sheet of the export
+type workbookStyles struct {
+	header   int
+	currency int
+	date     int
+	total    int
+	totalAmt int
+}
+
+// newWorkbookStyles registers the header, currency, date and total styles
+func newWorkbookStyles(f *excelize.File) (workbookStyles, error) {
+	var styles workbookStyles
+	var err error
+
+	currencyFormat := "#,##0.00;[Red]-#,##0.00"
+	dateFormat := "yyyy-mm-dd hh:mm"
+
+	styles.header, err = f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"D9E1F2"}},
+	})
+	if err != nil {
+		return styles, err
+	}
+
+	styles.currency, err = f.NewStyle(&excelize.Style{CustomNumFmt: &currencyFormat})
+	if err != nil {
+		return styles, err
+	}
+
+	styles.date, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		return styles, err
+	}
+
+	styles.total, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return styles, err
+	}
+
+	styles.totalAmt, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}, CustomNumFmt: &currencyFormat})
+	return styles, err
+}
+
+// newSheetWriter creates a stream writer with a frozen, styled header row
+func newSheetWriter(f *excelize.File, sheet string, styles workbookStyles, headers []string, widths []float64) (*excelize.StreamWriter, error) {
+	if _, err := f.NewSheet(sheet); err != nil {
+		return nil, err
+	}
+
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Panes and column widths must be set before any row is written
+	err = sw.SetPanes(&excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, width := range widths {
+		if err := sw.SetColWidth(i+1, i+1, width); err != nil {
+			return nil, err
+		}
+	}
+
+	row := make([]any, len(headers))
+	for i, header := range headers {
+		row[i] = excelize.Cell{StyleID: styles.header, Value: header}
+	}
+
+	return sw, sw.SetRow("A1", row)
+}
+
+// yearCell stores the year as a number when it parses, otherwise as text
+func yearCell(year string) any {
+	if n, err := strconv.Atoi(year); err == nil {
+		return n
+	}
+	return year
+}
+
+// dateCell leaves the cell empty for records without a timestamp
+func dateCell(t time.Time, style int) any {
+	if t.IsZero() {
+		return nil
+	}
+	return excelize.Cell{StyleID: style, Value: t}
+}
+
+// sumCell totals a column from row 2 down to lastRow, or 0 when the sheet is empty
+func sumCell(column string, lastRow int, style int) excelize.Cell {
+	if lastRow < 2 {
+		return excelize.Cell{StyleID: style, Value: 0}
+	}
+	return excelize.Cell{StyleID: style, Formula: fmt.Sprintf("SUM(%s2:%s%d)", column, column, lastRow)}
+}
+
+// writeTransactionSheet writes incomes or expenses with a SUM row at the bottom
+func writeTransactionSheet(f *excelize.File, sheet string, styles workbookStyles, rows [][]any) error {
+	sw, err := newSheetWriter(f, sheet, styles,
+		[]string{"Category", "Month", "Year", "Amount", "Created At", "Updated At"},
+		[]float64{24, 12, 8, 16, 18, 18})
+	if err != nil {
+		return err
+	}
+
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := sw.SetRow(cell, row); err != nil {
+			return err
+		}
+	}
+
+	// Summary row
+	lastRow := len(rows) + 1
+	cell, _ := excelize.CoordinatesToCellName(1, lastRow+1)
+	err = sw.SetRow(cell, []any{
+		excelize.Cell{StyleID: styles.total, Value: "Total"},
+		nil,
+		nil,
+		sumCell("D", lastRow, styles.totalAmt),
+	})
+	if err != nil {
+		return err
+	}
+
+	return sw.Flush()
+}
+
+// ExportWorkbook writes incomes, expenses, categories and the monthly report
+// of the current year into a single .xlsx workbook. Any query that fails
+// fails the export.
+func ExportWorkbook(w io.Writer) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newWorkbookStyles(f)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	// Incomes sheet
+	incomes, err := ListIncomes(ctx, ExportFilter{}, 0)
+	if err != nil {
+		return err
+	}
+	var incomeRows [][]any
+	for _, income := range incomes {
+		incomeRows = append(incomeRows, []any{
+			income.Category,
+			income.Month,
+			yearCell(income.Year),
+			excelize.Cell{StyleID: styles.currency, Value: income.Amount},
+			dateCell(income.CreatedAt, styles.date),
+			dateCell(income.UpdatedAt, styles.date),
+		})
+	}
+	if err := writeTransactionSheet(f, "Incomes", styles, incomeRows); err != nil {
+		return err
+	}
+
+	// Expenses sheet
+	expenses, err := ListExpenses(ctx, ExportFilter{}, 0)
+	if err != nil {
+		return err
+	}
+	var expenseRows [][]any
+	for _, expense := range expenses {
+		expenseRows = append(expenseRows, []any{
+			expense.Category,
+			expense.Month,
+			yearCell(expense.Year),
+			excelize.Cell{StyleID: styles.currency, Value: expense.Amount},
+			dateCell(expense.CreatedAt, styles.date),
+			dateCell(expense.UpdatedAt, styles.date),
+		})
+	}
+	if err := writeTransactionSheet(f, "Expenses", styles, expenseRows); err != nil {
+		return err
+	}
+
+	// Categories sheet
+	sw, err := newSheetWriter(f, "Categories", styles,
+		[]string{"Type", "Category", "Created At"},
+		[]float64{12, 28, 18})
+	if err != nil {
+		return err
+	}
+	row := 2
+	for _, kind := range []struct{ key, label string }{{"income", "Income"}, {"expense", "Expense"}} {
+		// full names, so subcategories show under their parents
+		tree, err := LoadCategoryTree(ctx, kind.key)
+		if err != nil {
+			return err
+		}
+		for _, category := range tree.Categories {
+			cell, _ := excelize.CoordinatesToCellName(1, row)
+			if err := sw.SetRow(cell, []any{kind.label, category.Path, dateCell(category.CreatedAt, styles.date)}); err != nil {
+				return err
+			}
+			row++
+		}
+	}
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+
+	// Monthly Report sheet, balance and totals are formulas so edits recalculate
+	reports, err := MonthlyReport(ctx, time.Now().Format("2006"), helpers.Months)
+	if err != nil {
+		return err
+	}
+	sw, err = newSheetWriter(f, "Monthly Report", styles,
+		[]string{"Month", "Total Income", "Total Expenses", "Balance"},
+		[]float64{12, 16, 16, 16})
+	if err != nil {
+		return err
+	}
+	for i, report := range reports {
+		r := i + 2
+		cell, _ := excelize.CoordinatesToCellName(1, r)
+		err := sw.SetRow(cell, []any{
+			report.Month,
+			excelize.Cell{StyleID: styles.currency, Value: report.TotalIncome},
+			excelize.Cell{StyleID: styles.currency, Value: report.TotalExpense},
+			excelize.Cell{StyleID: styles.currency, Formula: fmt.Sprintf("B%d-C%d", r, r), Value: report.Balance},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	lastRow := len(reports) + 1
+	cell, _ := excelize.CoordinatesToCellName(1, lastRow+1)
+	err = sw.SetRow(cell, []any{
+		excelize.Cell{StyleID: styles.total, Value: "Total"},
+		sumCell("B", lastRow, styles.totalAmt),
+		sumCell("C", lastRow, styles.totalAmt),
+		sumCell("D", lastRow, styles.totalAmt),
+	})
+	if err != nil {
+		return err
+	}
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+
+	// Drop the default sheet created by excelize
+	if err := f.DeleteSheet("Sheet1"); err != nil {
+		return err
+	}
+	index, err := f.GetSheetIndex("Incomes")
+	if err != nil {
+		return err
+	}
+	f.SetActiveSheet(index)
+
+	return f.Write(w)
+}
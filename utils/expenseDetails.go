@@ -8,11 +8,17 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// AddDetail adds a new Detail to the database.
+// AddExpenseDetail adds a new ExpenseDetail to the database, or to the
+// offline journal while the database can't be reached.
 func AddExpenseDetail(ExpenseDetail models.ExpenseDetail, window fyne.Window) error {
+	return journaled(journalInsert, "expense_details", ExpenseDetail.ID, ExpenseDetail, func() error {
+		return addExpenseDetail(ExpenseDetail)
+	})
+}
+
+func addExpenseDetail(ExpenseDetail models.ExpenseDetail) error {
 	collection := GetCollection("expense_details")
 	_, err := collection.InsertOne(context.TODO(), ExpenseDetail)
 	return err
@@ -50,90 +56,84 @@ func GetExpenseDetailByID(id primitive.ObjectID, window fyne.Window) models.Expe
 	return ExpenseDetail
 }
 
-// UpdateExpenseDetail updates an existing ExpenseDetail in the database.
+// UpdateExpenseDetail updates an existing ExpenseDetail in the database, or
+// journals the change while offline.
 func UpdateExpenseDetail(ExpenseDetail models.ExpenseDetail, window fyne.Window) error {
+	return journaled(journalUpdate, "expense_details", ExpenseDetail.ID, ExpenseDetail, func() error {
+		return updateExpenseDetail(ExpenseDetail)
+	})
+}
+
+func updateExpenseDetail(ExpenseDetail models.ExpenseDetail) error {
 	collection := GetCollection("expense_details")
 	_, err := collection.UpdateOne(
 		context.TODO(),
 		bson.M{"_id": ExpenseDetail.ID},
-		bson.M{"$set": ExpenseDetail},
+		setUpdate(ExpenseDetail, map[string]primitive.ObjectID{"parent_id": ExpenseDetail.ParentID}),
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	// records and rules carry the full name of their category, which a rename or
+	// move changes
+	return refileCategory(context.TODO(), "expense", ExpenseDetail.ID)
 }
 
-// DeleteExpenseDetail deletes a ExpenseDetail from the database.
+// DeleteExpenseDetail deletes a ExpenseDetail from the database, or journals
+// the deletion while offline.
 func DeleteExpenseDetail(id primitive.ObjectID, window fyne.Window) error {
+	return journaled(journalDelete, "expense_details", id, nil, func() error {
+		return deleteExpenseDetail(id)
+	})
+}
+
+func deleteExpenseDetail(id primitive.ObjectID) error {
+	if err := checkUnused(context.TODO(), "expense", id); err != nil {
+		return err
+	}
 	collection := GetCollection("expense_details")
 	_, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
 	return err
 }
 
-// GetExpenseDetailsPaginated fetches ExpenseDetails with pagination from the database
-func GetExpenseDetailsPaginated(page, limit int, w fyne.Window) []models.ExpenseDetail {
-	collection := GetCollection("expense_details")
-
-	skip := (page - 1) * limit
-	findOptions := options.Find()
-	findOptions.SetSkip(int64(skip))
-	findOptions.SetLimit(int64(limit))
+// expenseDetailKeyset pages through expense categories, newest first
+var expenseDetailKeyset = keyset[models.ExpenseDetail]{"expense_details", "created_at"}
 
-	var expense_details []models.ExpenseDetail
-
-	cursor, err := collection.Find(context.TODO(), bson.M{}, findOptions)
+// GetExpenseDetailsPaginated fetches one page of expense categories, newest first by default
+func GetExpenseDetailsPaginated(req PageRequest, w fyne.Window) Page[models.ExpenseDetail] {
+	page, err := expenseDetailKeyset.page(context.TODO(), req)
 	if err != nil {
 		dialog.ShowError(err, w)
-		return expense_details
 	}
-	defer cursor.Close(context.TODO())
-
-	if err = cursor.All(context.TODO(), &expense_details); err != nil {
-		dialog.ShowError(err, w)
-	}
-	// Process Details and update progress
-	count := 0
-	for cursor.Next(context.TODO()) {
-		var expense_detail models.ExpenseDetail
-		if err := cursor.Decode(&expense_detail); err != nil {
-			dialog.ShowError(err, w)
-			continue
-		}
-		expense_details = append(expense_details, expense_detail)
-		count++
-
-	}
-
-	if err := cursor.Err(); err != nil {
-		dialog.ShowError(err, w)
-	}
-
-	return expense_details
+	return page
 }
 
 // CountExpenseDetails returns the total count of ExpenseDetails for a user
 func CountExpenseDetails(w fyne.Window) int64 {
-	collection := GetCollection("expense_details")
-	count, err := collection.CountDocuments(context.TODO(), bson.M{})
+	count, err := CachedCount(context.TODO(), "expense_details")
 	if err != nil {
 		dialog.ShowError(err, w)
 	}
 	return count
 }
 
-// search ExpenseDetails by quering the db
-func SearchExpenseDetails(searchText string, window fyne.Window) []models.ExpenseDetail {
-	collection := GetCollection("expense_details")
+// ExpenseDetailSearchFilter matches expense categories containing searchText, ignoring case
+func ExpenseDetailSearchFilter(searchText string) bson.M {
+	// the text is escaped, so it is searched for as typed
+	searchPattern := literal(searchText)
 
-	// Create a case-insensitive regex pattern for the search
-	searchPattern := bson.M{
-		"$regex":   searchText,
-		"$options": "i", // Case-insensitive
-	}
-
-	filter := bson.M{
+	return bson.M{
 		"$or": []bson.M{
 			{"expense_category": searchPattern},
 		},
 	}
+}
+
+// search ExpenseDetails by quering the db
+func SearchExpenseDetails(searchText string, window fyne.Window) []models.ExpenseDetail {
+	collection := GetCollection("expense_details")
+
+	filter := ExpenseDetailSearchFilter(searchText)
 
 	cursor, err := collection.Find(context.TODO(), filter)
 	if err != nil {
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fynance/models"
 	"time"
 
@@ -10,19 +11,30 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // MonthlyExpense represents the aggregated result
 type MonthlyExpense struct {
 	Month string  `bson:"_id"`
-	Total float64 `bson:"count"`
+	Total float64 `bson:"total"`
 }
 
-// AddExpense adds a new Expense to the database.
+// AddExpense adds a new Expense to the database, or to the offline journal
+// while the database can't be reached.
 func AddExpense(Expense models.Expense, window fyne.Window) error {
+	return journaled(journalInsert, "expenses", Expense.ID, Expense, func() error {
+		return addExpense(Expense)
+	})
+}
+
+func addExpense(Expense models.Expense) error {
+	Expense.CategoryID = categoryID(context.TODO(), "expense", Expense.Category)
 	collection := GetCollection("expenses")
 	_, err := collection.InsertOne(context.TODO(), Expense)
+	if err == nil {
+		FireWebhook(EventExpenseCreated, Expense)
+		checkBudget(Expense.Year, Expense.Month, Expense.Amount)
+	}
 	return err
 }
 
@@ -58,111 +70,92 @@ func GetExpenseByID(id primitive.ObjectID, window fyne.Window) models.Expense {
 	return Expense
 }
 
-// UpdateExpense updates an existing Expense in the database.
+// UpdateExpense updates an existing Expense in the database, or journals the
+// change while offline.
 func UpdateExpense(Expense models.Expense, window fyne.Window) error {
+	return journaled(journalUpdate, "expenses", Expense.ID, Expense, func() error {
+		return updateExpense(Expense)
+	})
+}
+
+func updateExpense(Expense models.Expense) error {
+	Expense.CategoryID = categoryID(context.TODO(), "expense", Expense.Category)
 	collection := GetCollection("expenses")
-	_, err := collection.UpdateOne(
+	var previous models.Expense
+	err := collection.FindOneAndUpdate(
 		context.TODO(),
-		bson.M{"_id": Expense.ID},
-		bson.M{"$set": Expense},
-	)
+		unreconciled(bson.M{"_id": Expense.ID}),
+		setUpdate(Expense, map[string]primitive.ObjectID{"category_id": Expense.CategoryID, "account_id": Expense.AccountID}),
+	).Decode(&previous)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return reconciledError(context.TODO(), "expenses", Expense.ID) // or nothing to update
+	}
+	if err == nil {
+		FireWebhook(EventExpenseUpdated, Expense)
+
+		// how much this change added to the expenses of its month
+		added := Expense.Amount
+		if previous.Year == Expense.Year && previous.Month == Expense.Month {
+			added -= previous.Amount
+		}
+		checkBudget(Expense.Year, Expense.Month, added)
+	}
 	return err
 }
 
-// DeleteExpense deletes a Expense from the database.
+// DeleteExpense deletes a Expense from the database, or journals the
+// deletion while offline.
 func DeleteExpense(id primitive.ObjectID, window fyne.Window) error {
-	collection := GetCollection("expenses")
-	_, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
-	return err
+	return journaled(journalDelete, "expenses", id, nil, func() error {
+		return deleteExpense(id)
+	})
 }
 
-// GetExpensesPaginated fetches Expenses with pagination from the database
-func GetExpensesPaginated(page, limit int, w fyne.Window, updateProgress func(float64)) []models.Expense {
+func deleteExpense(id primitive.ObjectID) error {
 	collection := GetCollection("expenses")
-
-	skip := (page - 1) * limit
-	findOptions := options.Find()
-	findOptions.SetSkip(int64(skip))
-	findOptions.SetLimit(int64(limit))
-
-	var expenses []models.Expense
-
-	cursor, err := collection.Find(context.TODO(), bson.M{}, findOptions)
-	if err != nil {
-		dialog.ShowError(err, w)
-		return expenses
+	var deleted models.Expense
+	err := collection.FindOneAndDelete(context.TODO(), unreconciled(bson.M{"_id": id})).Decode(&deleted)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if err := reconciledError(context.TODO(), "expenses", id); err != nil {
+			return err
+		}
+		// already gone, though a replayed deletion may have left its files
+		return deleteAttachmentsOf(context.TODO(), []primitive.ObjectID{id})
 	}
-	defer cursor.Close(context.TODO())
-
-	if err = cursor.All(context.TODO(), &expenses); err != nil {
-		dialog.ShowError(err, w)
+	if err != nil {
+		return err
 	}
-	// Process todos and update progress
-	count := 0
-	for cursor.Next(context.TODO()) {
-		var expense models.Expense
-		if err := cursor.Decode(&expense); err != nil {
-			dialog.ShowError(err, w)
-			continue
-		}
-		expenses = append(expenses, expense)
-		count++
+	FireWebhook(EventExpenseDeleted, deleted)
+	return deleteAttachmentsOf(context.TODO(), []primitive.ObjectID{id})
+}
 
-		// Update progress dynamically
-		if updateProgress != nil {
-			updateProgress(float64(count) / float64(count))
-		}
-	}
+// expenseKeyset pages through expenses, newest first
+var expenseKeyset = keyset[models.Expense]{"expenses", "created_at"}
 
-	if err := cursor.Err(); err != nil {
+// GetExpensesPaginated fetches one page of expenses, newest first by default
+func GetExpensesPaginated(req PageRequest, w fyne.Window) Page[models.Expense] {
+	page, err := expenseKeyset.page(context.TODO(), req)
+	if err != nil {
 		dialog.ShowError(err, w)
 	}
-
-	return expenses
+	return page
 }
 
 // CountExpenses returns the count count of Expenses for a user
 func CountExpenses(w fyne.Window) int64 {
-	collection := GetCollection("expenses")
-	count, err := collection.CountDocuments(context.TODO(), bson.M{})
+	count, err := CachedCount(context.TODO(), "expenses")
 	if err != nil {
 		dialog.ShowError(err, w)
 	}
 	return count
 }
 
-// search Expenses by quering the db
-func SearchExpenses(searchText string, window fyne.Window) []models.Expense {
-	collection := GetCollection("expenses")
-
-	// Create a case-insensitive regex pattern for the search
-	searchPattern := bson.M{
-		"$regex":   searchText,
-		"$options": "i", // Case-insensitive
-	}
-
-	filter := bson.M{
-		"$or": []bson.M{
-			{"category": searchPattern},
-			{"month": searchPattern},
-		},
-	}
-
-	cursor, err := collection.Find(context.TODO(), filter)
-	if err != nil {
-		dialog.ShowError(err, window)
-		return nil
-	}
-	defer cursor.Close(context.TODO())
-
-	var results []models.Expense
-	if err = cursor.All(context.TODO(), &results); err != nil {
-		dialog.ShowError(err, window)
-		return nil
-	}
-
-	return results
+// ExpenseSearchFilter matches expenses containing searchText, ignoring case
+func ExpenseSearchFilter(searchText string) bson.M {
+	// the text is escaped, so it is searched for as typed
+	searchPattern := literal(searchText)
 
+	return bson.M{"$or": matchAny(transactionSearchFields, searchPattern)}
 }
 
 // count income by month in current year
@@ -177,7 +170,7 @@ func SumExpenseByMonth(month string) (MonthlyExpense, error) {
 		{{Key: "$match", Value: bson.D{{Key: "year", Value: currentYear}, {Key: "month", Value: month}}}}, // Filter by year and month
 		{{Key: "$group", Value: bson.D{
 			{Key: "_id", Value: "$month"},
-			{Key: "count", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+			{Key: "total", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
 		}}},
 	}
 
@@ -240,45 +233,8 @@ func TotalExpenses(w fyne.Window) float64 {
 	return count
 }
 
+// total expenses of the top 5 categories, with subcategories rolled up into
+// the top-level category they sit under
 func GetExpenseStats(ctx context.Context) (map[string]float64, error) {
-	collection := GetCollection("expenses")
-
-	pipeline := []bson.M{
-		{
-			"$group": bson.M{
-				"_id":   "$category",
-				"count": bson.M{"$sum": "$amount"},
-			},
-		},
-		{
-			"$sort": bson.M{
-				"count": -1, // sort by count descending
-			},
-		},
-		{
-			"$limit": 5, // return only top 5 categories
-		},
-	}
-
-	cursor, err := collection.Aggregate(ctx, pipeline)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var results []struct {
-		Category string  `bson:"_id"`
-		Count    float64 `bson:"count"`
-	}
-
-	if err = cursor.All(ctx, &results); err != nil {
-		return nil, err
-	}
-
-	stats := make(map[string]float64)
-	for _, result := range results {
-		stats[result.Category] = result.Count
-	}
-
-	return stats, nil
+	return topCategories(ctx, "expense")
 }
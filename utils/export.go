@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fynance/helpers"
+	"fynance/models"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExportFilter narrows an export down by date range, category, tag and search text.
+// Zero values leave that part of the filter open.
+type ExportFilter struct {
+	From     time.Time
+	To       time.Time
+	Category string
+	Tag      string
+	Search   string
+}
+
+// periods lists every year/month pair touched by the From-To range
+func (f ExportFilter) periods() []bson.M {
+	from := time.Date(f.From.Year(), f.From.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(f.To.Year(), f.To.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var periods []bson.M
+	for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+		periods = append(periods, bson.M{
+			"year":  strconv.Itoa(month.Year()),
+			"month": helpers.Months[month.Month()-1],
+		})
+	}
+	return periods
+}
+
+// transactionFilter builds the query for incomes and expenses. Records only
+// carry a month and a year, so the date range is matched by period.
+func (f ExportFilter) transactionFilter() bson.M {
+	filter := bson.M{}
+
+	if !f.From.IsZero() || !f.To.IsZero() {
+		if f.From.IsZero() {
+			f.From = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+		}
+		if f.To.IsZero() {
+			f.To = time.Now()
+		}
+		filter["$or"] = f.periods()
+	}
+
+	if f.Category != "" {
+		// the category and its subcategories, whose full names start with it
+		filter["category"] = bson.M{"$regex": "^" + regexp.QuoteMeta(f.Category) + "($|" + regexp.QuoteMeta(CategorySeparator) + ")"}
+	}
+
+	if f.Tag != "" {
+		filter["tags"] = f.Tag
+	}
+
+	if f.Search != "" {
+		pattern := bson.M{"$regex": regexp.QuoteMeta(f.Search), "$options": "i"}
+		filter["$and"] = []bson.M{{"$or": matchAny(transactionSearchFields, pattern)}}
+	}
+
+	return filter
+}
+
+// logFilter builds the query for logs, matching the range on the timestamp
+func (f ExportFilter) logFilter() bson.M {
+	filter := bson.M{}
+
+	timestamp := bson.M{}
+	if !f.From.IsZero() {
+		timestamp["$gte"] = f.From
+	}
+	if !f.To.IsZero() {
+		// include the whole of the last day
+		timestamp["$lt"] = f.To.AddDate(0, 0, 1)
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+
+	if f.Search != "" {
+		filter["details"] = bson.M{"$regex": regexp.QuoteMeta(f.Search), "$options": "i"}
+	}
+
+	return filter
+}
+
+// streamCollection decodes the documents matching filter one at a time and
+// hands each to fn, so exports never hold the whole collection in memory.
+// progress receives values between 0 and 1 based on the matching count.
+func streamCollection[T any](ctx context.Context, collectionName string, filter bson.M, sort bson.D,
+	fn func(T) error, progress func(float64)) error {
+	collection := GetCollection(collectionName)
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSort(sort)
+	findOptions.SetBatchSize(1000)
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var done int64
+	step := max(total/100, 1) // report roughly once per percent
+	for cursor.Next(ctx) {
+		var item T
+		if err := cursor.Decode(&item); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+
+		done++
+		if progress != nil && (done%step == 0 || done == total) {
+			progress(float64(done) / float64(total))
+		}
+	}
+
+	if progress != nil && total == 0 {
+		progress(1)
+	}
+
+	return cursor.Err()
+}
+
+// TransactionCSVHeader is the header row of income and expense CSV files.
+// Imports need the first four columns; the others may be left out.
+var TransactionCSVHeader = []string{"Category", "Month", "Year", "Amount", "Payee", "Notes", "Tags"}
+
+// WriteIncomesCSV streams the incomes matching filter to w as CSV
+func WriteIncomesCSV(ctx context.Context, w io.Writer, filter ExportFilter, progress func(float64)) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(TransactionCSVHeader); err != nil {
+		return err
+	}
+
+	err := streamCollection(ctx, "income", filter.transactionFilter(), bson.D{{Key: "created_at", Value: -1}},
+		func(income models.Income) error {
+			return writer.Write([]string{
+				income.Category,
+				income.Month,
+				income.Year,
+				strconv.FormatFloat(income.Amount, 'f', -1, 64),
+				income.Payee,
+				income.Notes,
+				strings.Join(income.Tags, ", "),
+			})
+		}, progress)
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteExpensesCSV streams the expenses matching filter to w as CSV
+func WriteExpensesCSV(ctx context.Context, w io.Writer, filter ExportFilter, progress func(float64)) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(TransactionCSVHeader); err != nil {
+		return err
+	}
+
+	err := streamCollection(ctx, "expenses", filter.transactionFilter(), bson.D{{Key: "created_at", Value: -1}},
+		func(expense models.Expense) error {
+			return writer.Write([]string{
+				expense.Category,
+				expense.Month,
+				expense.Year,
+				strconv.FormatFloat(expense.Amount, 'f', -1, 64),
+				expense.Payee,
+				expense.Notes,
+				strings.Join(expense.Tags, ", "),
+			})
+		}, progress)
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteLogsCSV streams the logs matching filter to w as CSV
+func WriteLogsCSV(ctx context.Context, w io.Writer, filter ExportFilter, progress func(float64)) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write([]string{"ID", "Status", "Details", "Timestamp"}); err != nil {
+		return err
+	}
+
+	err := streamCollection(ctx, "logs", filter.logFilter(), bson.D{{Key: "timestamp", Value: -1}},
+		func(log models.Log) error {
+			return writer.Write([]string{
+				log.ID.Hex(),
+				log.Status,
+				log.Details,
+				log.Timestamp.Format("2006-01-02 15:04:05"),
+			})
+		}, progress)
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteLogsJSON streams the logs matching filter to w as a JSON array
+func WriteLogsJSON(ctx context.Context, w io.Writer, filter ExportFilter, progress func(float64)) error {
+	if _, err := io.WriteString(w, "[\n"); err != nil {
+		return err
+	}
+
+	first := true
+	err := streamCollection(ctx, "logs", filter.logFilter(), bson.D{{Key: "timestamp", Value: -1}},
+		func(log models.Log) error {
+			data, err := json.MarshalIndent(log, "  ", "  ")
+			if err != nil {
+				return err
+			}
+
+			separator := ",\n  "
+			if first {
+				separator = "  "
+				first = false
+			}
+			if _, err := io.WriteString(w, separator); err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		}, progress)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n]\n")
+	return err
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// HealthState is the database connectivity reported by the health monitor
+type HealthState int
+
+const (
+	HealthConnected HealthState = iota // pings answer quickly
+	HealthDegraded                     // pings are slow or have just started failing
+	HealthOffline                      // the database cannot be reached
+)
+
+func (s HealthState) String() string {
+	switch s {
+	case HealthConnected:
+		return "Connected"
+	case HealthDegraded:
+		return "Degraded"
+	default:
+		return "Offline"
+	}
+}
+
+// Health monitor timings. While healthy the database is pinged every
+// healthInterval; after a failure it is retried after healthBackoff,
+// doubling up to healthMaxBackoff, and reconnects once offline.
+var (
+	healthInterval    = 10 * time.Second
+	healthPingTimeout = 3 * time.Second
+	healthSlowPing    = time.Second
+	healthBackoff     = 2 * time.Second
+	healthMaxBackoff  = time.Minute
+)
+
+// failed pings in a row before the database counts as offline
+const healthOfflineAfter = 2
+
+// HealthMonitor pings the database in the background until stopped
+type HealthMonitor struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.Mutex
+	state HealthState
+	err   error
+}
+
+// StartHealthMonitor pings the configured database and calls onChange, from the
+// monitor's goroutine, whenever the state changes. The first check runs
+// straight away and is always reported.
+func StartHealthMonitor(onChange func(state HealthState, err error)) *HealthMonitor {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &HealthMonitor{cancel: cancel, done: make(chan struct{}), state: -1}
+
+	go func() {
+		defer close(m.done)
+
+		failures := 0
+		backoff := healthBackoff
+		for {
+			state, err := m.check(ctx, failures)
+			if ctx.Err() != nil {
+				return
+			}
+
+			wait := healthInterval
+			if err != nil {
+				failures++
+				wait = backoff
+				backoff = min(backoff*2, healthMaxBackoff)
+			} else {
+				failures = 0
+				backoff = healthBackoff
+			}
+
+			setDatabaseOnline(err == nil)
+
+			m.mu.Lock()
+			changed := state != m.state
+			m.state, m.err = state, err
+			m.mu.Unlock()
+			if changed && onChange != nil {
+				onChange(state, err)
+			}
+
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return m
+}
+
+// State returns the last state seen by the monitor and the error behind it
+func (m *HealthMonitor) State() (HealthState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state, m.err
+}
+
+// Stop ends monitoring and waits for a running check to return
+func (m *HealthMonitor) Stop() {
+	m.cancel()
+	<-m.done
+}
+
+// check pings the database, reconnecting once it has been offline
+func (m *HealthMonitor) check(ctx context.Context, failures int) (HealthState, error) {
+	if Client() == nil || failures >= healthOfflineAfter {
+		if err := reconnect(ctx); err != nil {
+			return HealthOffline, err
+		}
+	}
+
+	started := time.Now()
+	err := ping(ctx, Client())
+	switch {
+	case err != nil && failures+1 >= healthOfflineAfter:
+		return HealthOffline, err
+	case err != nil:
+		return HealthDegraded, err
+	case time.Since(started) > healthSlowPing:
+		return HealthDegraded, nil
+	}
+	return HealthConnected, nil
+}
+
+// reconnect replaces the shared client with a new one once it answers a ping
+func reconnect(ctx context.Context) error {
+	config := CurrentDBConfig()
+	client, err := newClient(config)
+	if err != nil {
+		return err
+	}
+	if err := ping(ctx, client); err != nil {
+		client.Disconnect(context.Background())
+		return err
+	}
+
+	setClient(client, config)
+	return nil
+}
+
+func ping(ctx context.Context, client *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+	defer cancel()
+	return client.Ping(ctx, readpref.Primary())
+}
@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"fynance/models"
+	"io"
+	"os"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ImportSource describes where imported records came from, for the batch
+// they are tagged with
+type ImportSource struct {
+	FileName string
+	Hash     string // from HashFile
+	Rows     int    // data rows in the file, including skipped ones
+	Skipped  int    // rows left out as invalid
+	UserID   primitive.ObjectID
+	Username string
+}
+
+// HashFile returns the hex SHA-256 of a file, which tells a re-import of
+// the same file apart from a new one
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ImportIncomes inserts incomes in batches as one import batch, setting
+// their timestamps. The active rules file them under a category first. If
+// the import fails the incomes already inserted are removed again.
+func ImportIncomes(ctx context.Context, source ImportSource, incomes []models.Income, progress func(float64)) (models.ImportBatch, error) {
+	categories, err := LoadCategoryTree(ctx, "income")
+	if err != nil {
+		return models.ImportBatch{}, err
+	}
+	rules, err := LoadRules(ctx, "income")
+	if err != nil {
+		return models.ImportBatch{}, err
+	}
+	return importBatch(ctx, "income", source, incomes, func(income *models.Income, now time.Time, batch primitive.ObjectID) float64 {
+		rules.categorise(income)
+		income.CategoryID = categories.IDOf(income.Category)
+		income.CreatedAt = now
+		income.UpdatedAt = now
+		income.ImportBatch = batch
+		income.CreatedBy = source.UserID
+		return income.Amount
+	}, progress)
+}
+
+// ImportExpenses inserts expenses in batches as one import batch, setting
+// their timestamps. The active rules file them under a category first. If
+// the import fails the expenses already inserted are removed again.
+func ImportExpenses(ctx context.Context, source ImportSource, expenses []models.Expense, progress func(float64)) (models.ImportBatch, error) {
+	categories, err := LoadCategoryTree(ctx, "expense")
+	if err != nil {
+		return models.ImportBatch{}, err
+	}
+	rules, err := LoadRules(ctx, "expense")
+	if err != nil {
+		return models.ImportBatch{}, err
+	}
+	return importBatch(ctx, "expense", source, expenses, func(expense *models.Expense, now time.Time, batch primitive.ObjectID) float64 {
+		record := models.Income(*expense)
+		rules.categorise(&record)
+		*expense = models.Expense(record)
+		expense.CategoryID = categories.IDOf(expense.Category)
+		expense.CreatedAt = now
+		expense.UpdatedAt = now
+		expense.ImportBatch = batch
+		expense.CreatedBy = source.UserID
+		return expense.Amount
+	}, progress)
+}
+
+// importCollection is the collection an import of kind goes to
+func importCollection(kind string) string {
+	if kind == "expense" {
+		return "expenses"
+	}
+	return "income"
+}
+
+// importBatch records the batch, then inserts items tagged with its ID. The
+// batch stays "importing" until every item is in, so an import cut short
+// can still be found and rolled back.
+func importBatch[T any](ctx context.Context, kind string, source ImportSource, items []T, tag func(*T, time.Time, primitive.ObjectID) float64, progress func(float64)) (models.ImportBatch, error) {
+	batch := models.ImportBatch{
+		ID:        primitive.NewObjectID(),
+		Kind:      kind,
+		FileName:  source.FileName,
+		FileHash:  source.Hash,
+		UserID:    source.UserID,
+		Username:  source.Username,
+		Rows:      source.Rows,
+		Skipped:   source.Skipped,
+		Status:    models.ImportRunning,
+		CreatedAt: stamp(time.Now()),
+	}
+	if batch.Rows == 0 {
+		batch.Rows = len(items) + source.Skipped
+	}
+	batches := GetCollection("import_batches")
+	if _, err := batches.InsertOne(ctx, batch); err != nil {
+		return batch, err
+	}
+
+	collection := importCollection(kind)
+	err := insertBatched(ctx, collection, items, func(item *T, now time.Time) {
+		batch.Total += tag(item, now, batch.ID)
+	}, progress)
+	if err != nil {
+		// leave nothing of a failed import behind
+		if _, cleanupErr := GetCollection(collection).DeleteMany(ctx, bson.M{"import_batch": batch.ID}); cleanupErr != nil {
+			err = errors.Join(err, cleanupErr)
+		}
+		batch.Status, batch.Error = models.ImportFailed, err.Error()
+		batches.UpdateByID(ctx, batch.ID, bson.M{"$set": bson.M{"status": batch.Status, "error": batch.Error}})
+		return batch, err
+	}
+
+	batch.Imported, batch.Status = len(items), models.ImportComplete
+	_, err = batches.UpdateByID(ctx, batch.ID, bson.M{"$set": bson.M{
+		"imported": batch.Imported,
+		"total":    batch.Total,
+		"status":   batch.Status,
+	}})
+	if err != nil {
+		return batch, err
+	}
+	FireWebhook(EventImportFinished, map[string]any{
+		"type":      kind,
+		"count":     batch.Imported,
+		"amount":    batch.Total,
+		"batch_id":  batch.ID,
+		"file_name": batch.FileName,
+	})
+	return batch, nil
+}
+
+// FindImportByHash returns the latest import of kind from a file with the
+// given hash that is still in place, and whether there is one
+func FindImportByHash(ctx context.Context, kind, hash string) (models.ImportBatch, bool, error) {
+	var batch models.ImportBatch
+	if hash == "" {
+		return batch, false, nil
+	}
+	filter := bson.M{"kind": kind, "file_hash": hash, "status": models.ImportComplete}
+	err := GetCollection("import_batches").
+		FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})).
+		Decode(&batch)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return batch, false, nil
+	}
+	return batch, err == nil, err
+}
+
+// FindImport returns the import batch with the given ID
+func FindImport(ctx context.Context, id primitive.ObjectID) (models.ImportBatch, error) {
+	var batch models.ImportBatch
+	err := GetCollection("import_batches").FindOne(ctx, bson.M{"_id": id}).Decode(&batch)
+	return batch, err
+}
+
+// importKeyset pages through import batches, newest first
+var importKeyset = keyset[models.ImportBatch]{"import_batches", "created_at"}
+
+// GetImportsPaginated fetches one page of import batches, newest first by
+// default
+func GetImportsPaginated(req PageRequest, w fyne.Window) Page[models.ImportBatch] {
+	page, err := importKeyset.page(context.TODO(), req)
+	if err != nil {
+		dialog.ShowError(err, w)
+	}
+	return page
+}
+
+// ListImports returns the import batches, newest first. A limit of zero
+// returns them all.
+func ListImports(ctx context.Context, limit int64) ([]models.ImportBatch, error) {
+	return findAll[models.ImportBatch](ctx, "import_batches", bson.M{}, bson.D{{Key: "created_at", Value: -1}}, limit)
+}
+
+// RollbackImport deletes exactly the records an import batch inserted,
+// including any edited since, and marks the batch rolled back. It returns
+// the batch and how many records were removed.
+func RollbackImport(ctx context.Context, id primitive.ObjectID) (models.ImportBatch, int64, error) {
+	if err := bulkAvailable(); err != nil {
+		return models.ImportBatch{}, 0, err
+	}
+	batch, err := FindImport(ctx, id)
+	if err != nil {
+		return batch, 0, err
+	}
+	switch batch.Status {
+	case models.ImportRolledBack:
+		return batch, 0, fmt.Errorf("import %s was already rolled back", id.Hex())
+	case models.ImportFailed:
+		return batch, 0, fmt.Errorf("import %s failed and left no records", id.Hex())
+	}
+
+	collection := importCollection(batch.Kind)
+	reconciled, err := GetCollection(collection).CountDocuments(ctx, bson.M{"import_batch": batch.ID, "reconciliation_id": bson.M{"$exists": true}})
+	if err != nil {
+		return batch, 0, err
+	}
+	if reconciled > 0 {
+		return batch, 0, fmt.Errorf("%d records of import %s are reconciled, undo their reconciliation first", reconciled, id.Hex())
+	}
+	defer invalidateCount(collection)
+	// files attached to the records since the import go with them
+	ids, err := GetCollection(collection).Distinct(ctx, "_id", bson.M{"import_batch": batch.ID})
+	if err != nil {
+		return batch, 0, err
+	}
+	result, err := GetCollection(collection).DeleteMany(ctx, bson.M{"import_batch": batch.ID})
+	if err != nil {
+		return batch, 0, err
+	}
+	var recordIDs []primitive.ObjectID
+	for _, id := range ids {
+		if recordID, ok := id.(primitive.ObjectID); ok {
+			recordIDs = append(recordIDs, recordID)
+		}
+	}
+	if err := deleteAttachmentsOf(ctx, recordIDs); err != nil {
+		return batch, result.DeletedCount, err
+	}
+
+	batch.Status, batch.RolledBackAt = models.ImportRolledBack, stamp(time.Now())
+	_, err = GetCollection("import_batches").UpdateByID(ctx, batch.ID, bson.M{"$set": bson.M{
+		"status":         batch.Status,
+		"rolled_back_at": batch.RolledBackAt,
+	}})
+	if err != nil {
+		return batch, result.DeletedCount, err
+	}
+	FireWebhook(EventImportRollback, map[string]any{
+		"type":      batch.Kind,
+		"count":     result.DeletedCount,
+		"batch_id":  batch.ID,
+		"file_name": batch.FileName,
+	})
+	return batch, result.DeletedCount, nil
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"fynance/models"
 	"time"
 
@@ -11,7 +13,6 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // MonthlyIncome represents the aggregated result
@@ -20,10 +21,21 @@ type MonthlyIncome struct {
 	Total float64 `bson:"total"`
 }
 
-// AddIncome adds a new Income to the database.
+// AddIncome adds a new Income to the database, or to the offline journal
+// while the database can't be reached.
 func AddIncome(Income models.Income, window fyne.Window) error {
+	return journaled(journalInsert, "income", Income.ID, Income, func() error {
+		return addIncome(Income)
+	})
+}
+
+func addIncome(Income models.Income) error {
+	Income.CategoryID = categoryID(context.TODO(), "income", Income.Category)
 	collection := GetCollection("income")
 	_, err := collection.InsertOne(context.TODO(), Income)
+	if err == nil {
+		FireWebhook(EventIncomeCreated, Income)
+	}
 	return err
 }
 
@@ -59,112 +71,85 @@ func GetIncomeByID(id primitive.ObjectID, window fyne.Window) models.Income {
 	return Income
 }
 
-// UpdateIncome updates an existing Income in the database.
+// UpdateIncome updates an existing Income in the database, or journals the
+// change while offline.
 func UpdateIncome(Income models.Income, window fyne.Window) error {
+	return journaled(journalUpdate, "income", Income.ID, Income, func() error {
+		return updateIncome(Income)
+	})
+}
+
+func updateIncome(Income models.Income) error {
+	Income.CategoryID = categoryID(context.TODO(), "income", Income.Category)
 	collection := GetCollection("income")
-	_, err := collection.UpdateOne(
+	result, err := collection.UpdateOne(
 		context.TODO(),
-		bson.M{"_id": Income.ID},
-		bson.M{"$set": Income},
+		unreconciled(bson.M{"_id": Income.ID}),
+		setUpdate(Income, map[string]primitive.ObjectID{"category_id": Income.CategoryID, "account_id": Income.AccountID}),
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return reconciledError(context.TODO(), "income", Income.ID)
+	}
+	FireWebhook(EventIncomeUpdated, Income)
+	return nil
 }
 
-// DeleteIncome deletes a Income from the database.
+// DeleteIncome deletes a Income from the database, or journals the deletion
+// while offline.
 func DeleteIncome(id primitive.ObjectID, window fyne.Window) error {
-	collection := GetCollection("income")
-	_, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
-	return err
+	return journaled(journalDelete, "income", id, nil, func() error {
+		return deleteIncome(id)
+	})
 }
 
-// GetIncomesPaginated fetches Incomes with pagination from the database
-func GetIncomesPaginated(page, limit int, w fyne.Window, updateProgress func(float64)) []models.Income {
+func deleteIncome(id primitive.ObjectID) error {
 	collection := GetCollection("income")
-
-	skip := (page - 1) * limit
-	findOptions := options.Find()
-	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
-	findOptions.SetSkip(int64(skip))
-	findOptions.SetLimit(int64(limit))
-
-	var incomes []models.Income
-
-	cursor, err := collection.Find(context.TODO(), bson.M{}, findOptions)
-	if err != nil {
-		dialog.ShowError(err, w)
-		return incomes
+	var deleted models.Income
+	err := collection.FindOneAndDelete(context.TODO(), unreconciled(bson.M{"_id": id})).Decode(&deleted)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if err := reconciledError(context.TODO(), "income", id); err != nil {
+			return err
+		}
+		// already gone, though a replayed deletion may have left its files
+		return deleteAttachmentsOf(context.TODO(), []primitive.ObjectID{id})
 	}
-	defer cursor.Close(context.TODO())
-
-	if err = cursor.All(context.TODO(), &incomes); err != nil {
-		dialog.ShowError(err, w)
+	if err != nil {
+		return err
 	}
-	// Process incomes and update progress
-	count := 0
-	for cursor.Next(context.TODO()) {
-		var income models.Income
-		if err := cursor.Decode(&income); err != nil {
-			dialog.ShowError(err, w)
-			continue
-		}
-		incomes = append(incomes, income)
-		count++
+	FireWebhook(EventIncomeDeleted, deleted)
+	return deleteAttachmentsOf(context.TODO(), []primitive.ObjectID{id})
+}
 
-		// Update progress dynamically
-		if updateProgress != nil {
-			updateProgress(float64(count) / float64(count))
-		}
-	}
+// incomeKeyset pages through incomes, newest first
+var incomeKeyset = keyset[models.Income]{"income", "created_at"}
 
-	if err := cursor.Err(); err != nil {
+// GetIncomesPaginated fetches one page of incomes, newest first by default
+func GetIncomesPaginated(req PageRequest, w fyne.Window) Page[models.Income] {
+	page, err := incomeKeyset.page(context.TODO(), req)
+	if err != nil {
 		dialog.ShowError(err, w)
 	}
-
-	return incomes
+	return page
 }
 
 // CountIncomes returns the total count of Incomes for a user
 func CountIncomes(w fyne.Window) int64 {
-	collection := GetCollection("income")
-	count, err := collection.CountDocuments(context.TODO(), bson.M{})
+	count, err := CachedCount(context.TODO(), "income")
 	if err != nil {
 		dialog.ShowError(err, w)
 	}
 	return count
 }
 
-// search Incomes by quering the db
-func SearchIncomes(searchText string, window fyne.Window) []models.Income {
-	collection := GetCollection("income")
-
-	// Create a case-insensitive regex pattern for the search
-	searchPattern := bson.M{
-		"$regex":   searchText,
-		"$options": "i", // Case-insensitive
-	}
-
-	filter := bson.M{
-		"$or": []bson.M{
-			{"category": searchPattern},
-			{"month": searchPattern},
-		},
-	}
-
-	cursor, err := collection.Find(context.TODO(), filter)
-	if err != nil {
-		dialog.ShowError(err, window)
-		return nil
-	}
-	defer cursor.Close(context.TODO())
-
-	var results []models.Income
-	if err = cursor.All(context.TODO(), &results); err != nil {
-		dialog.ShowError(err, window)
-		return nil
-	}
-
-	return results
+// IncomeSearchFilter matches incomes containing searchText, ignoring case
+func IncomeSearchFilter(searchText string) bson.M {
+	// the text is escaped, so it is searched for as typed
+	searchPattern := literal(searchText)
 
+	return bson.M{"$or": matchAny(transactionSearchFields, searchPattern)}
 }
 
 // total income by month in current year
@@ -242,81 +227,25 @@ func TotalIncome(w fyne.Window) float64 {
 	return total
 }
 
-// total income by category limited to 5
+// total income of the top 5 categories, with subcategories rolled up into
+// the top-level category they sit under
 func GetIncomeStats(ctx context.Context) (map[string]float64, error) {
-	collection := GetCollection("income")
-
-	pipeline := []bson.M{
-		{
-			"$group": bson.M{
-				"_id":   "$category",
-				"count": bson.M{"$sum": "$amount"},
-			},
-		},
-		{
-			"$sort": bson.M{
-				"count": -1, // sort by count descending
-			},
-		},
-		{
-			"$limit": 5, // return only top 5 categories
-		},
-	}
+	return topCategories(ctx, "income")
+}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+// BulkInsertIncome inserts multiple incomes into the database safely, as
+// one import batch that can be rolled back.
+func BulkInsertIncome(source ImportSource, incomes []models.Income, window fyne.Window, progressBar *widget.ProgressBar) error {
+	batch, err := ImportIncomes(context.TODO(), source, incomes, progressBar.SetValue)
 	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var results []struct {
-		Category string  `bson:"_id"`
-		Count    float64 `bson:"count"`
-	}
-
-	if err = cursor.All(ctx, &results); err != nil {
-		return nil, err
-	}
-
-	stats := make(map[string]float64)
-	for _, result := range results {
-		stats[result.Category] = result.Count
+		dialog.ShowError(err, window)
+		return err
 	}
 
-	return stats, nil
-}
-
-// BulkInsertIncome inserts multiple incomes into the database safely.
-func BulkInsertIncome(incomes []models.Income, window fyne.Window, progressBar *widget.ProgressBar) {
-	collection := GetCollection("income")
-	var docs []any
-	totalIncomes := len(incomes)
-	progress := 0
-
-	for i, income := range incomes {
-		parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
-		if err != nil {
-			dialog.ShowError(err, window)
-			return
-		}
-		income.CreatedAt = parsedTime
-		income.UpdatedAt = parsedTime
-		docs = append(docs, income)
-
-		// Update progress bar for each income processed
-		progress = i + 1
-		progressBar.SetValue(float64(progress) / float64(totalIncomes))
-
-		// Flush the documents in smaller batches
-		if len(docs) == 100 || i == totalIncomes-1 {
-			_, err := collection.InsertMany(context.TODO(), docs)
-			if err != nil {
-				dialog.ShowError(err, window)
-				return
-			}
-			docs = nil // Reset docs slice for next batch
-		}
+	message := fmt.Sprintf("%d incomes added successfully!", batch.Imported)
+	if batch.Skipped > 0 {
+		message += fmt.Sprintf(" %d invalid rows were skipped.", batch.Skipped)
 	}
-
-	dialog.ShowInformation("Success", "Incomes added successfully!", window)
+	dialog.ShowInformation("Success", message, window)
+	return nil
 }
@@ -8,11 +8,17 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// AddDetail adds a new Detail to the database.
+// AddDetail adds a new Detail to the database, or to the offline journal
+// while the database can't be reached.
 func AddDetail(Detail models.IncomeDetail, window fyne.Window) error {
+	return journaled(journalInsert, "income_details", Detail.ID, Detail, func() error {
+		return addDetail(Detail)
+	})
+}
+
+func addDetail(Detail models.IncomeDetail) error {
 	collection := GetCollection("income_details")
 	_, err := collection.InsertOne(context.TODO(), Detail)
 	return err
@@ -50,90 +56,84 @@ func GetDetailByID(id primitive.ObjectID, window fyne.Window) models.IncomeDetai
 	return Detail
 }
 
-// UpdateDetail updates an existing Detail in the database.
+// UpdateDetail updates an existing Detail in the database, or journals the
+// change while offline.
 func UpdateDetail(Detail models.IncomeDetail, window fyne.Window) error {
+	return journaled(journalUpdate, "income_details", Detail.ID, Detail, func() error {
+		return updateDetail(Detail)
+	})
+}
+
+func updateDetail(Detail models.IncomeDetail) error {
 	collection := GetCollection("income_details")
 	_, err := collection.UpdateOne(
 		context.TODO(),
 		bson.M{"_id": Detail.ID},
-		bson.M{"$set": Detail},
+		setUpdate(Detail, map[string]primitive.ObjectID{"parent_id": Detail.ParentID}),
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	// records and rules carry the full name of their category, which a rename or
+	// move changes
+	return refileCategory(context.TODO(), "income", Detail.ID)
 }
 
-// DeleteDetail deletes a Detail from the database.
+// DeleteDetail deletes a Detail from the database, or journals the
+// deletion while offline.
 func DeleteDetail(id primitive.ObjectID, window fyne.Window) error {
+	return journaled(journalDelete, "income_details", id, nil, func() error {
+		return deleteDetail(id)
+	})
+}
+
+func deleteDetail(id primitive.ObjectID) error {
+	if err := checkUnused(context.TODO(), "income", id); err != nil {
+		return err
+	}
 	collection := GetCollection("income_details")
 	_, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
 	return err
 }
 
-// GetDetailsPaginated fetches Details with pagination from the database
-func GetDetailsPaginated(page, limit int, w fyne.Window) []models.IncomeDetail {
-	collection := GetCollection("income_details")
-
-	skip := (page - 1) * limit
-	findOptions := options.Find()
-	findOptions.SetSkip(int64(skip))
-	findOptions.SetLimit(int64(limit))
+// detailKeyset pages through income categories, newest first
+var detailKeyset = keyset[models.IncomeDetail]{"income_details", "created_at"}
 
-	var income_details []models.IncomeDetail
-
-	cursor, err := collection.Find(context.TODO(), bson.M{}, findOptions)
+// GetDetailsPaginated fetches one page of income categories, newest first by default
+func GetDetailsPaginated(req PageRequest, w fyne.Window) Page[models.IncomeDetail] {
+	page, err := detailKeyset.page(context.TODO(), req)
 	if err != nil {
 		dialog.ShowError(err, w)
-		return income_details
 	}
-	defer cursor.Close(context.TODO())
-
-	if err = cursor.All(context.TODO(), &income_details); err != nil {
-		dialog.ShowError(err, w)
-	}
-	// Process Details and update progress
-	count := 0
-	for cursor.Next(context.TODO()) {
-		var income_detail models.IncomeDetail
-		if err := cursor.Decode(&income_detail); err != nil {
-			dialog.ShowError(err, w)
-			continue
-		}
-		income_details = append(income_details, income_detail)
-		count++
-
-	}
-
-	if err := cursor.Err(); err != nil {
-		dialog.ShowError(err, w)
-	}
-
-	return income_details
+	return page
 }
 
 // CountDetails returns the total count of Details for a user
 func CountDetails(w fyne.Window) int64 {
-	collection := GetCollection("income_details")
-	count, err := collection.CountDocuments(context.TODO(), bson.M{})
+	count, err := CachedCount(context.TODO(), "income_details")
 	if err != nil {
 		dialog.ShowError(err, w)
 	}
 	return count
 }
 
-// search Details by quering the db
-func SearchDetails(searchText string, window fyne.Window) []models.IncomeDetail {
-	collection := GetCollection("income_details")
+// DetailSearchFilter matches income categories containing searchText, ignoring case
+func DetailSearchFilter(searchText string) bson.M {
+	// the text is escaped, so it is searched for as typed
+	searchPattern := literal(searchText)
 
-	// Create a case-insensitive regex pattern for the search
-	searchPattern := bson.M{
-		"$regex":   searchText,
-		"$options": "i", // Case-insensitive
-	}
-
-	filter := bson.M{
+	return bson.M{
 		"$or": []bson.M{
 			{"income_category": searchPattern},
 		},
 	}
+}
+
+// search Details by quering the db
+func SearchDetails(searchText string, window fyne.Window) []models.IncomeDetail {
+	collection := GetCollection("income_details")
+
+	filter := DetailSearchFilter(searchText)
 
 	cursor, err := collection.Find(context.TODO(), filter)
 	if err != nil {
@@ -0,0 +1,312 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultLogRetention is how long log entries are kept before the TTL index
+// removes them
+const DefaultLogRetention = 365 * 24 * time.Hour
+
+// indexSpec is one index of a collection
+type indexSpec struct {
+	collection string
+	name       string
+	keys       bson.D
+	options    *options.IndexOptions
+}
+
+// indexSpecs declares every index Fynance relies on
+func indexSpecs(logRetention time.Duration) []indexSpec {
+	var specs []indexSpec
+	for _, collection := range []string{"income", "expenses"} {
+		specs = append(specs,
+			indexSpec{collection, "year_month", bson.D{{Key: "year", Value: 1}, {Key: "month", Value: 1}}, nil},
+			indexSpec{collection, "created_at_id", bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}, nil},
+			// the sortable table columns
+			indexSpec{collection, "category_id", bson.D{{Key: "category", Value: 1}, {Key: "_id", Value: 1}}, nil},
+			indexSpec{collection, "amount_id", bson.D{{Key: "amount", Value: 1}, {Key: "_id", Value: 1}}, nil},
+			indexSpec{collection, "search", bson.D{{Key: "category", Value: "text"}, {Key: "month", Value: "text"},
+				{Key: "payee", Value: "text"}, {Key: "notes", Value: "text"}, {Key: "tags", Value: "text"}}, nil},
+			indexSpec{collection, "tags", bson.D{{Key: "tags", Value: 1}}, nil},
+			indexSpec{collection, "import_batch", bson.D{{Key: "import_batch", Value: 1}}, options.Index().SetSparse(true)},
+			indexSpec{collection, "category_ref", bson.D{{Key: "category_id", Value: 1}}, nil},
+			indexSpec{collection, "account_id", bson.D{{Key: "account_id", Value: 1}}, options.Index().SetSparse(true)},
+			indexSpec{collection, "reconciliation_id", bson.D{{Key: "reconciliation_id", Value: 1}}, options.Index().SetSparse(true)},
+			indexSpec{collection, "created_by", bson.D{{Key: "created_by", Value: 1}}, options.Index().SetSparse(true)},
+		)
+	}
+	specs = append(specs,
+		indexSpec{"income_details", "created_at_id", bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}, nil},
+		indexSpec{"income_details", "income_category_id", bson.D{{Key: "income_category", Value: 1}, {Key: "_id", Value: 1}}, nil},
+		indexSpec{"income_details", "search", bson.D{{Key: "income_category", Value: "text"}}, nil},
+		indexSpec{"income_details", "parent_id", bson.D{{Key: "parent_id", Value: 1}}, options.Index().SetSparse(true)},
+		indexSpec{"expense_details", "created_at_id", bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}, nil},
+		indexSpec{"expense_details", "expense_category_id", bson.D{{Key: "expense_category", Value: 1}, {Key: "_id", Value: 1}}, nil},
+		indexSpec{"expense_details", "search", bson.D{{Key: "expense_category", Value: "text"}}, nil},
+		indexSpec{"expense_details", "parent_id", bson.D{{Key: "parent_id", Value: 1}}, options.Index().SetSparse(true)},
+		indexSpec{"notifications", "user_unread", bson.D{{Key: "user_id", Value: 1}, {Key: "is_read", Value: 1}}, nil},
+		indexSpec{"notifications", "user_created_at", bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}, nil},
+		indexSpec{"logs", "timestamp_ttl", bson.D{{Key: "timestamp", Value: 1}},
+			options.Index().SetExpireAfterSeconds(int32(logRetention / time.Second))},
+		indexSpec{"logs", "timestamp_id", bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}, nil},
+		indexSpec{"logs", "status_id", bson.D{{Key: "status", Value: 1}, {Key: "_id", Value: 1}}, nil},
+		indexSpec{"logs", "search", bson.D{{Key: "details", Value: "text"}, {Key: "status", Value: "text"}}, nil},
+		indexSpec{"users", "username", bson.D{{Key: "username", Value: 1}}, nil},
+		indexSpec{"api_tokens", "token_hash", bson.D{{Key: "token_hash", Value: 1}}, options.Index().SetUnique(true)},
+		indexSpec{"api_tokens", "user_id", bson.D{{Key: "user_id", Value: 1}}, nil},
+		indexSpec{"import_batches", "kind_file_hash", bson.D{{Key: "kind", Value: 1}, {Key: "file_hash", Value: 1}}, nil},
+		indexSpec{"import_batches", "created_at_id", bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}, nil},
+		// the GridFS indexes, under the names the driver gives them
+		indexSpec{"attachments.files", "filename_1_uploadDate_1", bson.D{{Key: "filename", Value: 1}, {Key: "uploadDate", Value: 1}}, nil},
+		indexSpec{"attachments.chunks", "files_id_1_n_1", bson.D{{Key: "files_id", Value: 1}, {Key: "n", Value: 1}}, options.Index().SetUnique(true)},
+		indexSpec{"attachments.files", "record_id", bson.D{{Key: "metadata.record_id", Value: 1}}, nil},
+		indexSpec{"accounts", "name", bson.D{{Key: "name", Value: 1}}, nil},
+		indexSpec{"transfers", "from_account", bson.D{{Key: "from_account", Value: 1}}, nil},
+		indexSpec{"transfers", "to_account", bson.D{{Key: "to_account", Value: 1}}, nil},
+		indexSpec{"reconciliations", "created_at", bson.D{{Key: "created_at", Value: -1}}, nil},
+		indexSpec{"saved_searches", "user_name", bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}}, options.Index().SetUnique(true)},
+		indexSpec{"rules", "kind_priority", bson.D{{Key: "kind", Value: 1}, {Key: "priority", Value: 1}}, nil},
+		indexSpec{"webhook_deliveries", "webhook_created_at", bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}}, nil},
+	)
+	return specs
+}
+
+// EnsureIndexes creates the declared indexes that are missing. An index
+// whose definition changed, such as the log retention, is dropped and made
+// again. Every index is attempted; the errors are returned together.
+func EnsureIndexes(ctx context.Context, logRetention time.Duration) error {
+	if logRetention <= 0 {
+		logRetention = DefaultLogRetention
+	}
+
+	var errs []error
+	for _, spec := range indexSpecs(logRetention) {
+		indexOptions := spec.options
+		if indexOptions == nil {
+			indexOptions = options.Index()
+		}
+		model := mongo.IndexModel{Keys: spec.keys, Options: indexOptions.SetName(spec.name)}
+		indexes := GetCollection(spec.collection).Indexes()
+
+		_, err := indexes.CreateOne(ctx, model)
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && (serverErr.HasErrorCode(85) || serverErr.HasErrorCode(86)) {
+			// IndexOptionsConflict or IndexKeySpecsConflict: the definition changed
+			if _, err = indexes.DropOne(ctx, spec.name); err == nil {
+				_, err = indexes.CreateOne(ctx, model)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("index %s.%s: %w", spec.collection, spec.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogRetention returns the retention of the log TTL index in the database,
+// DefaultLogRetention when there is none yet
+func LogRetention(ctx context.Context) (time.Duration, error) {
+	cursor, err := GetCollection("logs").Indexes().List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var indexes []struct {
+		Name        string `bson:"name"`
+		ExpireAfter *int64 `bson:"expireAfterSeconds"`
+	}
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return 0, err
+	}
+	for _, index := range indexes {
+		if index.Name == "timestamp_ttl" && index.ExpireAfter != nil {
+			return time.Duration(*index.ExpireAfter) * time.Second, nil
+		}
+	}
+	return DefaultLogRetention, nil
+}
+
+// MissingIndexes returns the declared indexes the database lacks, as
+// "collection.index"
+func MissingIndexes(ctx context.Context) ([]string, error) {
+	existing := make(map[string][]string)
+	var missing []string
+	for _, spec := range indexSpecs(DefaultLogRetention) {
+		names, listed := existing[spec.collection]
+		if !listed {
+			indexes, err := GetCollection(spec.collection).Indexes().ListSpecifications(ctx)
+			if err != nil {
+				return nil, err
+			}
+			names = []string{}
+			for _, index := range indexes {
+				names = append(names, index.Name)
+			}
+			existing[spec.collection] = names
+		}
+		if !slices.Contains(names, spec.name) {
+			missing = append(missing, spec.collection+"."+spec.name)
+		}
+	}
+	return missing, nil
+}
+
+// IndexUsage is an index and how often it was used since the server started
+type IndexUsage struct {
+	Collection string
+	Name       string
+	Keys       string
+	Ops        int64
+	Since      time.Time
+}
+
+// ListIndexUsage returns the $indexStats of every collection with declared indexes
+func ListIndexUsage(ctx context.Context) ([]IndexUsage, error) {
+	var collections []string
+	for _, spec := range indexSpecs(DefaultLogRetention) {
+		if len(collections) == 0 || collections[len(collections)-1] != spec.collection {
+			collections = append(collections, spec.collection)
+		}
+	}
+
+	var usage []IndexUsage
+	for _, collection := range collections {
+		cursor, err := GetCollection(collection).Aggregate(ctx, mongo.Pipeline{{{Key: "$indexStats", Value: bson.D{}}}})
+		if err != nil {
+			return usage, err
+		}
+		var stats []struct {
+			Name     string `bson:"name"`
+			Key      bson.D `bson:"key"`
+			Accesses struct {
+				Ops   int64     `bson:"ops"`
+				Since time.Time `bson:"since"`
+			} `bson:"accesses"`
+		}
+		err = cursor.All(ctx, &stats)
+		if err != nil {
+			return usage, err
+		}
+		for _, stat := range stats {
+			var keys []string
+			for _, key := range stat.Key {
+				keys = append(keys, fmt.Sprintf("%s:%v", key.Key, key.Value))
+			}
+			usage = append(usage, IndexUsage{collection, stat.Name, strings.Join(keys, ", "), stat.Accesses.Ops, stat.Accesses.Since})
+		}
+	}
+	return usage, nil
+}
+
+// DiagnosticQuery is one of the app's main queries, explained on the
+// diagnostics screen
+type DiagnosticQuery struct {
+	Name       string
+	Collection string
+	Filter     bson.D
+	Sort       bson.D
+	Limit      int64
+}
+
+// DiagnosticQueries returns the queries behind the main screens
+func DiagnosticQueries() []DiagnosticQuery {
+	year := time.Now().Format("2006")
+	recent := bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}
+	return []DiagnosticQuery{
+		{"Latest incomes", "income", bson.D{}, recent, 10},
+		{"Latest expenses", "expenses", bson.D{}, recent, 10},
+		{"Incomes of a month", "income", bson.D{{Key: "year", Value: year}, {Key: "month", Value: "Jan"}}, nil, 0},
+		{"Expenses of a month", "expenses", bson.D{{Key: "year", Value: year}, {Key: "month", Value: "Jan"}}, nil, 0},
+		{"Income search", "income", bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: "salary"}}}}, nil, 0},
+		{"Unread notifications", "notifications", bson.D{{Key: "user_id", Value: nil}, {Key: "is_read", Value: false}}, nil, 0},
+		{"Latest logs", "logs", bson.D{}, bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}, 10},
+	}
+}
+
+// QueryPlan summarises the explain() output of a query
+type QueryPlan struct {
+	Stage        string // COLLSCAN, IXSCAN, TEXT_MATCH...
+	Index        string // the index used, if any
+	Returned     int64
+	KeysExamined int64
+	DocsExamined int64
+	Millis       int64
+}
+
+// UsesIndex reports whether the winning plan read an index
+func (p QueryPlan) UsesIndex() bool {
+	return p.Index != ""
+}
+
+// ExplainQuery runs the query under explain with execution statistics
+func ExplainQuery(ctx context.Context, query DiagnosticQuery) (QueryPlan, error) {
+	find := bson.D{{Key: "find", Value: query.Collection}, {Key: "filter", Value: query.Filter}}
+	if len(query.Sort) > 0 {
+		find = append(find, bson.E{Key: "sort", Value: query.Sort})
+	}
+	if query.Limit > 0 {
+		find = append(find, bson.E{Key: "limit", Value: query.Limit})
+	}
+
+	var result struct {
+		QueryPlanner struct {
+			WinningPlan bson.M `bson:"winningPlan"`
+		} `bson:"queryPlanner"`
+		ExecutionStats struct {
+			Returned     int64 `bson:"nReturned"`
+			KeysExamined int64 `bson:"totalKeysExamined"`
+			DocsExamined int64 `bson:"totalDocsExamined"`
+			Millis       int64 `bson:"executionTimeMillis"`
+		} `bson:"executionStats"`
+	}
+	err := GetDatabase().RunCommand(ctx, bson.D{
+		{Key: "explain", Value: find},
+		{Key: "verbosity", Value: "executionStats"},
+	}).Decode(&result)
+	if err != nil {
+		return QueryPlan{}, err
+	}
+
+	plan := QueryPlan{
+		Returned:     result.ExecutionStats.Returned,
+		KeysExamined: result.ExecutionStats.KeysExamined,
+		DocsExamined: result.ExecutionStats.DocsExamined,
+		Millis:       result.ExecutionStats.Millis,
+	}
+	winning := result.QueryPlanner.WinningPlan
+	if queryPlan, ok := winning["queryPlan"].(bson.M); ok {
+		winning = queryPlan // newer servers wrap the classic plan
+	}
+	plan.Stage, _ = winning["stage"].(string)
+	plan.Index = planIndex(winning)
+	return plan, nil
+}
+
+// planIndex finds the index read by a plan stage or any of its inputs
+func planIndex(stage bson.M) string {
+	if name, ok := stage["indexName"].(string); ok {
+		return name
+	}
+	if input, ok := stage["inputStage"].(bson.M); ok {
+		return planIndex(input)
+	}
+	if inputs, ok := stage["inputStages"].(bson.A); ok {
+		for _, input := range inputs {
+			if input, ok := input.(bson.M); ok {
+				if name := planIndex(input); name != "" {
+					return name
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,415 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fynance/models"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// JournalPath is the local file holding writes made while the database is
+// unreachable. It sits next to settings.json.
+const JournalPath = "journal.json"
+
+// Journal operations
+const (
+	journalInsert = "insert"
+	journalUpdate = "update"
+	journalDelete = "delete"
+)
+
+var (
+	journalMu      sync.Mutex
+	journal        []models.JournalEntry
+	journalLoaded  bool
+	journalEnabled bool      // set once the health monitor runs
+	databaseOnline = true    // last state seen by the health monitor
+	lastOnline     time.Time // last time the database answered
+
+	replayMu sync.Mutex
+)
+
+// OnJournalChange is called with the number of journal entries, pending or in
+// conflict, whenever it changes
+var OnJournalChange func(pending int)
+
+// journalTarget replays the entries of one collection
+type journalTarget struct {
+	insert func(doc json.RawMessage) error
+	update func(doc json.RawMessage) error
+	remove func(id primitive.ObjectID) error
+}
+
+// target decodes journalled documents into T before handing them to the writers
+func target[T any](insert, update func(T) error, remove func(primitive.ObjectID) error) journalTarget {
+	decode := func(write func(T) error) func(json.RawMessage) error {
+		if write == nil {
+			return nil
+		}
+		return func(doc json.RawMessage) error {
+			var value T
+			if err := json.Unmarshal(doc, &value); err != nil {
+				return err
+			}
+			return write(value)
+		}
+	}
+	return journalTarget{insert: decode(insert), update: decode(update), remove: remove}
+}
+
+// journalTargets lists the collections written through the journal
+var journalTargets = map[string]journalTarget{
+	"income":          target(addIncome, updateIncome, deleteIncome),
+	"expenses":        target(addExpense, updateExpense, deleteExpense),
+	"income_details":  target(addDetail, updateDetail, deleteDetail),
+	"expense_details": target(addExpenseDetail, updateExpenseDetail, deleteExpenseDetail),
+	"logs": target(func(log models.Log) error {
+		_, err := GetCollection("logs").InsertOne(context.TODO(), log)
+		return err
+	}, nil, nil),
+	"notifications": target(func(notification models.Notification) error {
+		_, err := GetCollection("notifications").InsertOne(context.TODO(), notification)
+		return err
+	}, nil, nil),
+}
+
+// isConnectionError reports whether err means the database could not be reached
+func isConnectionError(err error) bool {
+	return err != nil && (mongo.IsNetworkError(err) || mongo.IsTimeout(err))
+}
+
+// stamp converts t the way timestamps are stored in this app
+func stamp(t time.Time) time.Time {
+	parsed, err := time.Parse("02-01-2006 15:04:05", t.Format("02-01-2006 15:04:05"))
+	if err != nil {
+		return t
+	}
+	return parsed
+}
+
+// journaled runs write, or records it in the journal instead when the
+// database is offline, when earlier writes are still waiting to be replayed,
+// or when write fails to reach the database
+func journaled(op, collection string, id primitive.ObjectID, doc any, write func() error) error {
+	invalidateCount(collection)
+
+	journalMu.Lock()
+	enabled := journalEnabled && !id.IsZero()
+	if enabled {
+		loadJournal()
+	}
+	queue := enabled && (!databaseOnline || waiting(id))
+	journalMu.Unlock()
+
+	if !enabled {
+		return write()
+	}
+	if !queue {
+		err := write()
+		if !isConnectionError(err) {
+			return err
+		}
+	}
+	return queueWrite(op, collection, id, doc)
+}
+
+// waiting reports whether a write to id has to queue behind the journal:
+// anything is pending, or id has an entry in conflict
+func waiting(id primitive.ObjectID) bool {
+	for _, entry := range journal {
+		if entry.Conflict == "" || entry.DocID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// queueWrite appends a write to the journal
+func queueWrite(op, collection string, id primitive.ObjectID, doc any) error {
+	entry := models.JournalEntry{
+		ID:         primitive.NewObjectID().Hex(),
+		Op:         op,
+		Collection: collection,
+		DocID:      id,
+		QueuedAt:   time.Now(),
+	}
+	if doc != nil {
+		body, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		entry.Document = body
+	}
+
+	journalMu.Lock()
+	// changes on the server after this are conflicts, from when the database
+	// was last reachable or, if it never was this session, from now
+	entry.Since = stamp(lastOnline)
+	if lastOnline.IsZero() {
+		entry.Since = stamp(entry.QueuedAt)
+	}
+	journal = append(journal, entry)
+	err := saveJournal()
+	pending := len(journal)
+	journalMu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("the database is unreachable and the change could not be saved locally: %w", err)
+	}
+	journalChanged(pending)
+	return nil
+}
+
+// loadJournal reads the journal file once. The caller holds journalMu.
+func loadJournal() {
+	if journalLoaded {
+		return
+	}
+	journalLoaded = true
+
+	body, err := os.ReadFile(JournalPath)
+	if err != nil {
+		return
+	}
+	json.Unmarshal(body, &journal)
+}
+
+// saveJournal writes the journal through a temporary file so a crash never
+// leaves it half written. The caller holds journalMu.
+func saveJournal() error {
+	if len(journal) == 0 {
+		err := os.Remove(JournalPath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	body, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(JournalPath+".tmp", body, 0600); err != nil {
+		return err
+	}
+	return os.Rename(JournalPath+".tmp", JournalPath)
+}
+
+func journalChanged(pending int) {
+	if OnJournalChange != nil {
+		OnJournalChange(pending)
+	}
+}
+
+// PendingChanges returns the journal entries, oldest first
+func PendingChanges() []models.JournalEntry {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	loadJournal()
+	return append([]models.JournalEntry(nil), journal...)
+}
+
+// setDatabaseOnline records the state seen by the health monitor and replays
+// the journal once the database is back
+func setDatabaseOnline(online bool) {
+	journalMu.Lock()
+	journalEnabled = true
+	databaseOnline = online
+	if online {
+		lastOnline = time.Now()
+	}
+	loadJournal()
+	pending := len(journal)
+	journalMu.Unlock()
+
+	if online && pending > 0 {
+		go ReplayJournal(context.Background())
+	}
+}
+
+// ReplayJournal applies pending journal entries in order. An entry whose
+// record changed on the server since it was queued is kept as a conflict,
+// along with later entries for the same record, until it is resolved.
+// Replaying stops at the first connection error.
+func ReplayJournal(ctx context.Context) (int, error) {
+	if !replayMu.TryLock() {
+		return 0, nil // already replaying
+	}
+	defer replayMu.Unlock()
+
+	applied := 0
+	skip := map[string]bool{}
+	for ctx.Err() == nil {
+		journalMu.Lock()
+		loadJournal()
+		var entry models.JournalEntry
+		found := false
+		held := map[primitive.ObjectID]bool{}
+		for _, candidate := range journal {
+			if candidate.Conflict != "" || skip[candidate.ID] {
+				held[candidate.DocID] = true
+				continue
+			}
+			if held[candidate.DocID] {
+				continue
+			}
+			entry, found = candidate, true
+			break
+		}
+		journalMu.Unlock()
+		if !found {
+			break
+		}
+
+		conflict, err := replayEntry(ctx, entry)
+		if isConnectionError(err) {
+			return applied, err
+		}
+		if err != nil {
+			conflict = err.Error()
+		}
+
+		journalMu.Lock()
+		for i := range journal {
+			if journal[i].ID != entry.ID {
+				continue
+			}
+			if conflict != "" {
+				journal[i].Conflict = conflict
+				journal[i].Force = false
+			} else {
+				journal = append(journal[:i], journal[i+1:]...)
+				applied++
+			}
+			break
+		}
+		if conflict == "" {
+			// later entries for the record build on this one
+			for i := range journal {
+				if journal[i].DocID == entry.DocID {
+					journal[i].Since = stamp(time.Now())
+				}
+			}
+		}
+		saveErr := saveJournal()
+		pending := len(journal)
+		journalMu.Unlock()
+
+		if saveErr != nil {
+			// keep going, the entry would only be replayed again
+			skip[entry.ID] = true
+		}
+		journalChanged(pending)
+	}
+
+	return applied, ctx.Err()
+}
+
+// replayEntry checks an entry for conflicts and applies it, returning why it
+// conflicts instead when it does
+func replayEntry(ctx context.Context, entry models.JournalEntry) (string, error) {
+	writer, ok := journalTargets[entry.Collection]
+	if !ok {
+		return "unknown collection " + entry.Collection, nil
+	}
+	defer invalidateCount(entry.Collection)
+
+	var current bson.M
+	err := GetCollection(entry.Collection).FindOne(ctx, bson.M{"_id": entry.DocID}).Decode(&current)
+	exists := err == nil
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", err
+	}
+
+	// reconciled records are locked, forced or not
+	if _, locked := current["reconciliation_id"]; exists && locked && entry.Op != journalInsert {
+		return "reconciled on the server after this change was made offline", nil
+	}
+
+	switch entry.Op {
+	case journalInsert:
+		if exists {
+			return "", nil // written before the connection dropped
+		}
+		return "", writer.insert(entry.Document)
+
+	case journalUpdate:
+		if !exists {
+			if entry.Force {
+				return "", writer.insert(entry.Document)
+			}
+			return "deleted on the server after this change was made offline", nil
+		}
+		if !entry.Force && changedSince(current, entry) {
+			return "changed on the server after this change was made offline", nil
+		}
+		return "", writer.update(entry.Document)
+
+	case journalDelete:
+		if !exists {
+			return "", nil
+		}
+		if !entry.Force && changedSince(current, entry) {
+			return "changed on the server after it was deleted offline", nil
+		}
+		return "", writer.remove(entry.DocID)
+	}
+	return "unknown operation " + entry.Op, nil
+}
+
+// changedSince reports whether the stored document was modified after the
+// entry's Since, unless the modification is the entry itself
+func changedSince(current bson.M, entry models.JournalEntry) bool {
+	modified := time.Time{}
+	for _, field := range []string{"created_at", "updated_at"} {
+		if value, ok := current[field].(primitive.DateTime); ok && value.Time().After(modified) {
+			modified = value.Time()
+		}
+	}
+	if !modified.After(entry.Since) {
+		return false
+	}
+
+	var queued struct {
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	if json.Unmarshal(entry.Document, &queued) == nil && !queued.UpdatedAt.IsZero() {
+		return !queued.UpdatedAt.Equal(modified)
+	}
+	return true
+}
+
+// ResolveConflict keeps a conflicting entry, replaying it over the server's
+// version, or discards it
+func ResolveConflict(id string, keep bool) {
+	journalMu.Lock()
+	for i := range journal {
+		if journal[i].ID != id {
+			continue
+		}
+		if keep {
+			journal[i].Conflict = ""
+			journal[i].Force = true
+		} else {
+			journal = append(journal[:i], journal[i+1:]...)
+		}
+		break
+	}
+	saveJournal()
+	pending := len(journal)
+	online := databaseOnline
+	journalMu.Unlock()
+
+	journalChanged(pending)
+	if online {
+		go ReplayJournal(context.Background())
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"fynance/models"
 	"time"
 
@@ -10,11 +11,16 @@ import (
 )
 
 func Logger(details string, status string, window fyne.Window) {
-	parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
+	if err := LogEvent(details, status); err != nil {
+		dialog.ShowError(err, window)
+	}
+}
 
+// LogEvent writes a log entry and returns any error instead of showing it
+func LogEvent(details string, status string) error {
+	parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
 	if err != nil {
-		dialog.ShowError(err, window)
-		return
+		return err
 	}
 	myLog := models.Log{
 		ID:        primitive.NewObjectID(),
@@ -22,5 +28,8 @@ func Logger(details string, status string, window fyne.Window) {
 		Details:   details,
 		Status:    status,
 	}
-	AddLog(myLog, window)
+	return journaled(journalInsert, "logs", myLog.ID, myLog, func() error {
+		_, err := GetCollection("logs").InsertOne(context.TODO(), myLog)
+		return err
+	})
 }
@@ -8,7 +8,6 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // AddLog adds a new todo to the database.
@@ -74,69 +73,34 @@ func DeleteAllLogs(window fyne.Window) {
 	}
 }
 
-// GetLogsPaginated fetches todos with pagination from the database
-func GetLogsPaginated(page, limit int, w fyne.Window) []models.Log {
-	collection := GetCollection("logs")
-
-	skip := (page - 1) * limit
-	findOptions := options.Find()
-	findOptions.SetSkip(int64(skip))
-	findOptions.SetLimit(int64(limit))
-
-	var logs []models.Log
+// logKeyset pages through logs, newest first
+var logKeyset = keyset[models.Log]{"logs", "timestamp"}
 
-	cursor, err := collection.Find(context.TODO(), bson.M{}, findOptions)
+// GetLogsPaginated fetches one page of logs, newest first by default
+func GetLogsPaginated(req PageRequest, w fyne.Window) Page[models.Log] {
+	page, err := logKeyset.page(context.TODO(), req)
 	if err != nil {
 		dialog.ShowError(err, w)
-		return logs
 	}
-	defer cursor.Close(context.TODO())
-
-	if err = cursor.All(context.TODO(), &logs); err != nil {
-		dialog.ShowError(err, w)
-	}
-
-	return logs
+	return page
 }
 
-// search logs by quering the db
-func SearchLogs(searchText string, window fyne.Window) []models.Log {
-	collection := GetCollection("logs")
-
-	// Create a case-insensitive regex pattern for the search
-	searchPattern := bson.M{
-		"$regex":   searchText,
-		"$options": "i", // Case-insensitive
-	}
+// LogSearchFilter matches logs containing searchText, ignoring case
+func LogSearchFilter(searchText string) bson.M {
+	// the text is escaped, so it is searched for as typed
+	searchPattern := literal(searchText)
 
-	filter := bson.M{
+	return bson.M{
 		"$or": []bson.M{
 			{"timestamp": searchPattern},
 			{"details": searchPattern},
 		},
 	}
-
-	cursor, err := collection.Find(context.TODO(), filter)
-	if err != nil {
-		dialog.ShowError(err, window)
-		return nil
-	}
-	defer cursor.Close(context.TODO())
-
-	var results []models.Log
-	if err = cursor.All(context.TODO(), &results); err != nil {
-		dialog.ShowError(err, window)
-		return nil
-	}
-
-	return results
-
 }
 
 // CountLogs returns the total count of logs
 func CountLogs(w fyne.Window) int64 {
-	collection := GetCollection("logs")
-	count, err := collection.CountDocuments(context.TODO(), bson.M{})
+	count, err := CachedCount(context.TODO(), "logs")
 	if err != nil {
 		dialog.ShowError(err, w)
 	}
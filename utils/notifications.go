@@ -18,15 +18,22 @@ import (
 
 // AddNotification adds a new notification to the database
 func AddNotification(notification models.Notification, window fyne.Window) {
+	if err := InsertNotification(context.TODO(), notification); err != nil {
+		dialog.ShowError(err, window)
+	}
+}
+
+// InsertNotification adds a notification and returns any error instead of showing it
+func InsertNotification(ctx context.Context, notification models.Notification) error {
 	collection := GetCollection("notifications")
 
 	notification.ID = primitive.NewObjectID() // Assign a new ObjectID
 	notification.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
 
-	_, err := collection.InsertOne(context.TODO(), notification)
-	if err != nil {
-		dialog.ShowError(err, window)
-	}
+	return journaled(journalInsert, "notifications", notification.ID, notification, func() error {
+		_, err := collection.InsertOne(ctx, notification)
+		return err
+	})
 }
 
 // ClearNotifications clears all notifications for a user
@@ -0,0 +1,270 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalidPageToken is returned for a page token that was not made by a
+// Page of the same sort order
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// PageRequest asks for one page of a list. After and Before are tokens from
+// a previous Page; with neither set the first page is returned, and Last
+// asks for the final one. Without Sort the list is newest first.
+type PageRequest struct {
+	Limit     int
+	After     string // the page following this token
+	Before    string // the page preceding this token
+	Last      bool
+	Filter    bson.M // narrows the list, as a search does
+	Sort      string // the document field the list is ordered by
+	Ascending bool
+}
+
+// Page is one page of a list with the tokens of its neighbours, empty when
+// there is no page in that direction
+type Page[T any] struct {
+	Items []T
+	Next  string
+	Prev  string
+	Total int64 // the size of the whole list, on the first and last pages only
+}
+
+// keyset pages through a collection on (sort field, _id). Every page is
+// read from where the previous one ended instead of skipping the rows
+// before it, which an index on the two fields makes cheap.
+type keyset[T any] struct {
+	collection string
+	field      string // the default order, newest first
+}
+
+// pageToken is the position a page ended at
+type pageToken struct {
+	Field string             `bson:"f"`
+	Value bson.RawValue      `bson:"v"`
+	ID    primitive.ObjectID `bson:"id"`
+}
+
+// page reads the page req asks for
+func (k keyset[T]) page(ctx context.Context, req PageRequest) (Page[T], error) {
+	limit := req.Limit
+	if limit < 1 {
+		limit = 10
+	}
+	field, ascending := req.Sort, req.Ascending
+	if field == "" {
+		field, ascending = k.field, false
+	}
+	later, earlier := "$lt", "$gt"
+	if ascending {
+		later, earlier = earlier, later
+	}
+
+	// forward reads in the list's order, from the start or after a token;
+	// backward reads against it, before a token or from the end, and is
+	// reversed afterwards
+	forward := req.Before == "" && !req.Last
+	conditions := bson.A{}
+	if len(req.Filter) > 0 {
+		conditions = append(conditions, req.Filter)
+	}
+	var total int64
+	switch {
+	case req.After != "":
+		token, err := decodePageToken(req.After, field)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		conditions = append(conditions, beyond(token, later))
+	case req.Before != "":
+		token, err := decodePageToken(req.Before, field)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		conditions = append(conditions, beyond(token, earlier))
+	default:
+		count, err := k.count(ctx, req.Filter)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		total = count
+		// The last page holds the remainder, so paging back from it lines
+		// up with paging forward from the first
+		if remainder := int(total % int64(limit)); req.Last && remainder > 0 {
+			limit = remainder
+		}
+	}
+
+	filter := bson.M{}
+	if len(conditions) == 1 {
+		filter = conditions[0].(bson.M)
+	} else if len(conditions) > 1 {
+		filter = bson.M{"$and": conditions}
+	}
+
+	direction := -1
+	if ascending == forward {
+		direction = 1
+	}
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(limit + 1)) // one more tells whether the list goes on
+
+	cursor, err := GetCollection(k.collection).Find(ctx, filter, findOptions)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	var items []T
+	if err := cursor.All(ctx, &items); err != nil {
+		return Page[T]{}, err
+	}
+
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+	if !forward {
+		slices.Reverse(items)
+	}
+
+	page := Page[T]{Items: items, Total: total}
+	if len(items) == 0 {
+		return page, nil
+	}
+	first, err := encodePageToken(items[0], field)
+	if err != nil {
+		return page, err
+	}
+	last, err := encodePageToken(items[len(items)-1], field)
+	if err != nil {
+		return page, err
+	}
+	if forward {
+		if more {
+			page.Next = last
+		}
+		if req.After != "" {
+			page.Prev = first
+		}
+	} else {
+		if more {
+			page.Prev = first
+		}
+		if req.Before != "" {
+			page.Next = last
+		}
+	}
+	return page, nil
+}
+
+// count is the size of the list, cached when it is the whole collection
+func (k keyset[T]) count(ctx context.Context, filter bson.M) (int64, error) {
+	if len(filter) == 0 {
+		return CachedCount(ctx, k.collection)
+	}
+	return GetCollection(k.collection).CountDocuments(ctx, filter)
+}
+
+// beyond matches the rows past a token in the direction of op
+func beyond(token pageToken, op string) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{token.Field: bson.M{op: token.Value}},
+		bson.M{token.Field: token.Value, "_id": bson.M{op: token.ID}},
+	}}
+}
+
+// encodePageToken records the position of item in a list sorted on field
+func encodePageToken(item any, field string) (string, error) {
+	raw, err := bson.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+	value, err := bson.Raw(raw).LookupErr(strings.Split(field, ".")...)
+	if err != nil {
+		return "", fmt.Errorf("sorting on %s: %w", field, err)
+	}
+	id, ok := bson.Raw(raw).Lookup("_id").ObjectIDOK()
+	if !ok {
+		return "", errors.New("only documents with an ObjectID can be paged")
+	}
+
+	token, err := bson.Marshal(pageToken{Field: field, Value: value, ID: id})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// decodePageToken reads a page token made for a list sorted on field
+func decodePageToken(encoded, field string) (pageToken, error) {
+	var token pageToken
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return token, ErrInvalidPageToken
+	}
+	if err := bson.Unmarshal(raw, &token); err != nil || token.Field != field {
+		return token, ErrInvalidPageToken
+	}
+	return token, nil
+}
+
+// countTTL bounds how stale a cached count gets when another process, such
+// as the API server, writes to the same database
+const countTTL = time.Minute
+
+type cachedCount struct {
+	count int64
+	at    time.Time
+}
+
+var (
+	countsMu sync.Mutex
+	counts   = map[string]cachedCount{}
+)
+
+// CachedCount returns the number of documents in a collection, counting
+// them only when the cached figure is missing or stale. Writes made through
+// this package drop the cached figure.
+func CachedCount(ctx context.Context, collection string) (int64, error) {
+	countsMu.Lock()
+	cached, ok := counts[collection]
+	countsMu.Unlock()
+	if ok && time.Since(cached.at) < countTTL {
+		return cached.count, nil
+	}
+
+	count, err := GetCollection(collection).CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	countsMu.Lock()
+	counts[collection] = cachedCount{count, time.Now()}
+	countsMu.Unlock()
+	return count, nil
+}
+
+// invalidateCount drops the cached count of a collection
+func invalidateCount(collection string) {
+	countsMu.Lock()
+	delete(counts, collection)
+	countsMu.Unlock()
+}
+
+// InvalidateCounts drops every cached count, after changes such as a restore
+// that bypass the usual writers
+func InvalidateCounts() {
+	countsMu.Lock()
+	clear(counts)
+	countsMu.Unlock()
+}
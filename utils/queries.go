@@ -0,0 +1,334 @@
+package utils
+
+import (
+	"context"
+	"fynance/models"
+	"math"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The functions below return errors instead of showing dialogs, so they can
+// be used without a window, e.g. from the command line.
+
+// transactionSearchFields are the income and expense fields a search looks
+// through. Tags are matched as any of the record's tags.
+var transactionSearchFields = []string{"category", "month", "payee", "notes", "tags"}
+
+// matchAny matches documents where any of fields matches condition
+func matchAny(fields []string, condition bson.M) []bson.M {
+	var clauses []bson.M
+	for _, field := range fields {
+		clauses = append(clauses, bson.M{field: condition})
+	}
+	return clauses
+}
+
+// TagFilter matches incomes or expenses carrying tag
+func TagFilter(tag string) bson.M {
+	return bson.M{"tags": tag}
+}
+
+// AndFilters matches documents matching every non-empty filter
+func AndFilters(filters ...bson.M) bson.M {
+	var conditions []bson.M
+	for _, filter := range filters {
+		if len(filter) > 0 {
+			conditions = append(conditions, filter)
+		}
+	}
+	switch len(conditions) {
+	case 0:
+		return nil
+	case 1:
+		return conditions[0]
+	}
+	return bson.M{"$and": conditions}
+}
+
+// ListIncomeTags returns every tag used on an income, sorted
+func ListIncomeTags(ctx context.Context) ([]string, error) {
+	return distinctTags(ctx, "income")
+}
+
+// ListExpenseTags returns every tag used on an expense, sorted
+func ListExpenseTags(ctx context.Context) ([]string, error) {
+	return distinctTags(ctx, "expenses")
+}
+
+func distinctTags(ctx context.Context, collectionName string) ([]string, error) {
+	values, err := GetCollection(collectionName).Distinct(ctx, "tags", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, value := range values {
+		if tag, ok := value.(string); ok && tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	return tags, nil
+}
+
+// TagReport totals the income and expenses of year by tag, largest first.
+// A record with several tags counts towards each of them.
+func TagReport(ctx context.Context, year string) ([]models.TagTotal, error) {
+	byTag := func(amountField string) bson.A {
+		return bson.A{
+			bson.M{"$match": bson.M{"year": year}},
+			bson.M{"$unwind": "$tags"},
+			bson.M{"$project": bson.M{"tags": 1, amountField: "$amount", "count": bson.M{"$literal": 1}}},
+		}
+	}
+
+	pipeline := append(byTag("total_income"),
+		bson.M{"$unionWith": bson.M{"coll": "expenses", "pipeline": byTag("total_expense")}},
+		bson.M{"$group": bson.M{
+			"_id":           "$tags",
+			"total_income":  bson.M{"$sum": "$total_income"},
+			"total_expense": bson.M{"$sum": "$total_expense"},
+			"count":         bson.M{"$sum": "$count"},
+		}},
+		bson.M{"$sort": bson.D{{Key: "total_expense", Value: -1}, {Key: "total_income", Value: -1}, {Key: "_id", Value: 1}}},
+	)
+
+	cursor, err := GetCollection("income").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var totals []models.TagTotal
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	for i := range totals {
+		totals[i].TotalIncome = math.Round(totals[i].TotalIncome*100) / 100
+		totals[i].TotalExpense = math.Round(totals[i].TotalExpense*100) / 100
+	}
+	return totals, nil
+}
+
+// findAll decodes every document matching filter in sort order. A limit of
+// zero returns them all.
+func findAll[T any](ctx context.Context, collectionName string, filter bson.M, sort bson.D, limit int64) ([]T, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(sort)
+	if limit > 0 {
+		findOptions.SetLimit(limit)
+	}
+
+	cursor, err := GetCollection(collectionName).Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []T
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListIncomes returns the incomes matching filter, newest first
+func ListIncomes(ctx context.Context, filter ExportFilter, limit int64) ([]models.Income, error) {
+	return findAll[models.Income](ctx, "income", filter.transactionFilter(), bson.D{{Key: "created_at", Value: -1}}, limit)
+}
+
+// ListExpenses returns the expenses matching filter, newest first
+func ListExpenses(ctx context.Context, filter ExportFilter, limit int64) ([]models.Expense, error) {
+	return findAll[models.Expense](ctx, "expenses", filter.transactionFilter(), bson.D{{Key: "created_at", Value: -1}}, limit)
+}
+
+// PageCursor is where a page of incomes or expenses, newest first, ended
+type PageCursor struct {
+	CreatedAt time.Time
+	ID        primitive.ObjectID
+}
+
+// pageFilter restricts filter to the records following after, newest first.
+// Records created at the same time are ordered by ID.
+func pageFilter(filter bson.M, after *PageCursor) bson.M {
+	if after == nil {
+		return filter
+	}
+	return AndFilters(filter, bson.M{"$or": []bson.M{
+		{"created_at": bson.M{"$lt": after.CreatedAt}},
+		{"created_at": after.CreatedAt, "_id": bson.M{"$lt": after.ID}},
+	}})
+}
+
+// newestFirst orders incomes and expenses for paging
+var newestFirst = bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}
+
+// ListIncomesPage returns up to limit incomes matching filter that follow
+// after, newest first. A nil cursor starts at the newest.
+func ListIncomesPage(ctx context.Context, filter ExportFilter, after *PageCursor, limit int64) ([]models.Income, error) {
+	return findAll[models.Income](ctx, "income", pageFilter(filter.transactionFilter(), after), newestFirst, limit)
+}
+
+// ListExpensesPage returns up to limit expenses matching filter that follow
+// after, newest first. A nil cursor starts at the newest.
+func ListExpensesPage(ctx context.Context, filter ExportFilter, after *PageCursor, limit int64) ([]models.Expense, error) {
+	return findAll[models.Expense](ctx, "expenses", pageFilter(filter.transactionFilter(), after), newestFirst, limit)
+}
+
+// ListLogs returns the logs matching filter, newest first
+func ListLogs(ctx context.Context, filter ExportFilter, limit int64) ([]models.Log, error) {
+	return findAll[models.Log](ctx, "logs", filter.logFilter(), bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}, limit)
+}
+
+// ListLogsAfter returns the logs matching filter written after the given one,
+// oldest first
+func ListLogsAfter(ctx context.Context, filter ExportFilter, after primitive.ObjectID) ([]models.Log, error) {
+	query := filter.logFilter()
+	query["_id"] = bson.M{"$gt": after}
+	return findAll[models.Log](ctx, "logs", query, bson.D{{Key: "_id", Value: 1}}, 0)
+}
+
+// FindIncomeByID returns mongo.ErrNoDocuments when there is no such income
+func FindIncomeByID(ctx context.Context, id primitive.ObjectID) (models.Income, error) {
+	var income models.Income
+	err := GetCollection("income").FindOne(ctx, bson.M{"_id": id}).Decode(&income)
+	return income, err
+}
+
+// FindExpenseByID returns mongo.ErrNoDocuments when there is no such expense
+func FindExpenseByID(ctx context.Context, id primitive.ObjectID) (models.Expense, error) {
+	var expense models.Expense
+	err := GetCollection("expenses").FindOne(ctx, bson.M{"_id": id}).Decode(&expense)
+	return expense, err
+}
+
+// ListIncomeCategories returns every income category by name
+func ListIncomeCategories(ctx context.Context) ([]models.IncomeDetail, error) {
+	return findAll[models.IncomeDetail](ctx, "income_details", bson.M{}, bson.D{{Key: "income_category", Value: 1}}, 0)
+}
+
+// ListExpenseCategories returns every expense category by name
+func ListExpenseCategories(ctx context.Context) ([]models.ExpenseDetail, error) {
+	return findAll[models.ExpenseDetail](ctx, "expense_details", bson.M{}, bson.D{{Key: "expense_category", Value: 1}}, 0)
+}
+
+// MonthlyReport totals income and expenses of each month of year
+func MonthlyReport(ctx context.Context, year string, months []string) ([]models.Report, error) {
+	// Function to get total amount from aggregation
+	getTotal := func(collection *mongo.Collection, month string) (float64, error) {
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.D{{Key: "year", Value: year}, {Key: "month", Value: month}}}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: nil},
+				{Key: "total", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+			}}},
+		}
+
+		cursor, err := collection.Aggregate(ctx, pipeline)
+		if err != nil {
+			return 0, err
+		}
+		defer cursor.Close(ctx)
+
+		var result struct {
+			Total float64 `bson:"total"`
+		}
+		if cursor.Next(ctx) {
+			if err := cursor.Decode(&result); err != nil {
+				return 0, err
+			}
+		}
+		return math.Round(result.Total*100) / 100, cursor.Err()
+	}
+
+	var results []models.Report
+	for _, month := range months {
+		totalIncome, err := getTotal(GetCollection("income"), month)
+		if err != nil {
+			return nil, err
+		}
+		totalExpense, err := getTotal(GetCollection("expenses"), month)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, models.Report{
+			Month:        month,
+			TotalIncome:  totalIncome,
+			TotalExpense: totalExpense,
+			Balance:      totalIncome - totalExpense,
+		})
+	}
+
+	return results, nil
+}
+
+// sumAmounts totals the amount of every record of year in the collection
+func sumAmounts(ctx context.Context, collectionName, year string) (float64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "year", Value: year}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "total", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+		}}},
+	}
+
+	cursor, err := GetCollection(collectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Total float64 `bson:"total"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return 0, err
+		}
+	}
+	return result.Total, cursor.Err()
+}
+
+// SumIncomes returns the total income of year
+func SumIncomes(ctx context.Context, year string) (float64, error) {
+	return sumAmounts(ctx, "income", year)
+}
+
+// SumExpenses returns the total expenses of year
+func SumExpenses(ctx context.Context, year string) (float64, error) {
+	return sumAmounts(ctx, "expenses", year)
+}
+
+// insertBatched stamps and inserts docs in batches of 100, reporting progress
+// between 0 and 1 when progress isn't nil
+func insertBatched[T any](ctx context.Context, collectionName string, items []T, stamp func(*T, time.Time), progress func(float64)) error {
+	collection := GetCollection(collectionName)
+	defer invalidateCount(collectionName)
+	parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
+	if err != nil {
+		return err
+	}
+
+	var docs []any
+	for i := range items {
+		stamp(&items[i], parsedTime)
+		docs = append(docs, items[i])
+
+		if progress != nil {
+			progress(float64(i+1) / float64(len(items)))
+		}
+
+		// Flush the documents in smaller batches
+		if len(docs) == 100 || i == len(items)-1 {
+			if _, err := collection.InsertMany(ctx, docs); err != nil {
+				return err
+			}
+			docs = nil
+		}
+	}
+	return nil
+}
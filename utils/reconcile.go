@@ -0,0 +1,554 @@
+package utils
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"fynance/helpers"
+	"fynance/models"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrReconciled is returned when changing a record a completed bank
+// reconciliation locked
+var ErrReconciled = errors.New("the record is reconciled and can't be changed")
+
+// matchWindow is how far apart in time a statement line and a record from
+// another month may be and still be matched automatically
+const matchWindow = 7 * 24 * time.Hour
+
+// unreconciled narrows filter to the records no reconciliation locked
+func unreconciled(filter bson.M) bson.M {
+	filter["reconciliation_id"] = bson.M{"$exists": false}
+	return filter
+}
+
+// reconciledError returns ErrReconciled when the record with id is locked,
+// for writes that left it alone
+func reconciledError(ctx context.Context, collection string, id primitive.ObjectID) error {
+	count, err := GetCollection(collection).CountDocuments(ctx, bson.M{"_id": id, "reconciliation_id": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrReconciled
+	}
+	return nil
+}
+
+// unreconciledIDs returns the ids of the records no reconciliation locked
+func unreconciledIDs(ctx context.Context, collection string, ids []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	values, err := GetCollection(collection).Distinct(ctx, "_id", unreconciled(bson.M{"_id": bson.M{"$in": ids}}))
+	if err != nil {
+		return nil, err
+	}
+	unlocked := []primitive.ObjectID{}
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			unlocked = append(unlocked, id)
+		}
+	}
+	return unlocked, nil
+}
+
+// statementColumns are the header names bank statements use for each
+// column, matched ignoring case
+var statementColumns = map[string][]string{
+	"date":        {"date", "transaction date", "value date", "posting date", "completion time"},
+	"description": {"description", "details", "narrative", "particulars", "payee", "memo", "reference"},
+	"amount":      {"amount", "transaction amount"},
+	"debit":       {"debit", "withdrawal", "withdrawn", "paid out", "money out"},
+	"credit":      {"credit", "deposit", "paid in", "money in"},
+}
+
+// statementDateLayouts are the date formats read from statements
+var statementDateLayouts = []string{
+	"2006-01-02", "2006-01-02 15:04:05", "2006-01-02 15:04", "02/01/2006", "02-01-2006",
+	"02/01/2006 15:04:05", "2 Jan 2006", "02 Jan 2006", "2-Jan-2006", "Jan 2, 2006",
+}
+
+// parseStatementAmount reads an amount such as "1,250.00", "-40" or "(40.00)"
+func parseStatementAmount(value string) (float64, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ",", "")
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+	value = strings.Trim(value, "()")
+	if value == "" {
+		return 0, nil
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// ParseStatement reads the lines of a bank statement CSV. The first row
+// names the columns: a date, a description and either a signed amount or
+// separate debit and credit columns. Rows without a date, such as totals,
+// are skipped.
+func ParseStatement(r io.Reader) ([]models.StatementLine, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, errors.New("the statement has no lines")
+	}
+
+	columns := map[string]int{}
+	for i, header := range rows[0] {
+		header = strings.ToLower(strings.TrimSpace(header))
+		for column, names := range statementColumns {
+			if _, found := columns[column]; !found && slices.Contains(names, header) {
+				columns[column] = i
+			}
+		}
+	}
+	_, hasAmount := columns["amount"]
+	_, hasDebit := columns["debit"]
+	_, hasCredit := columns["credit"]
+	if _, ok := columns["date"]; !ok || !(hasAmount || hasDebit && hasCredit) {
+		return nil, errors.New("the statement needs a date column and an amount column, or debit and credit columns")
+	}
+
+	cell := func(row []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var lines []models.StatementLine
+	for n, row := range rows[1:] {
+		dateText := cell(row, "date")
+		if dateText == "" {
+			continue
+		}
+		var line models.StatementLine
+		var parsed bool
+		for _, layout := range statementDateLayouts {
+			if date, err := time.ParseInLocation(layout, dateText, time.Local); err == nil {
+				line.Date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+				parsed = true
+				break
+			}
+		}
+		if !parsed {
+			return nil, fmt.Errorf("line %d: invalid date %q", n+2, dateText)
+		}
+
+		line.Description = cell(row, "description")
+		if hasAmount {
+			line.Amount, err = parseStatementAmount(cell(row, "amount"))
+		} else {
+			var debit, credit float64
+			if debit, err = parseStatementAmount(cell(row, "debit")); err == nil {
+				credit, err = parseStatementAmount(cell(row, "credit"))
+			}
+			line.Amount = credit - math.Abs(debit)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+2, err)
+		}
+		if line.Amount == 0 {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("the statement has no lines")
+	}
+	slices.SortStableFunc(lines, func(a, b models.StatementLine) int { return a.Date.Compare(b.Date) })
+	return lines, nil
+}
+
+// LineKind is the kind of record a statement line is matched to: money in
+// is income, money out an expense
+func LineKind(line models.StatementLine) string {
+	if line.Amount > 0 {
+		return "income"
+	}
+	return "expense"
+}
+
+// Book is what the records say for the period of a reconciliation. Expenses
+// are read as models.Income, which has the same fields.
+type Book struct {
+	Incomes  []models.Income
+	Expenses []models.Income
+}
+
+// Records returns the incomes or the expenses of the book
+func (b Book) Records(kind string) []models.Income {
+	if kind == "income" {
+		return b.Incomes
+	}
+	return b.Expenses
+}
+
+// periodMonths matches the records of every month from from to to
+func periodMonths(from, to time.Time) bson.A {
+	var months bson.A
+	for month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.Local); !month.After(to); month = month.AddDate(0, 1, 0) {
+		months = append(months, bson.M{"month": helpers.Months[month.Month()-1], "year": strconv.Itoa(month.Year())})
+	}
+	return months
+}
+
+// LoadBook returns the records a reconciliation can match: those of its
+// period and account, give or take matchWindow, that no other
+// reconciliation locked
+func LoadBook(ctx context.Context, rec models.Reconciliation) (Book, error) {
+	filter := bson.M{
+		"$or": periodMonths(rec.From.Add(-matchWindow), rec.To.Add(matchWindow)),
+		"$and": bson.A{bson.M{"$or": bson.A{
+			bson.M{"reconciliation_id": bson.M{"$exists": false}},
+			bson.M{"reconciliation_id": rec.ID},
+		}}},
+	}
+	if !rec.AccountID.IsZero() {
+		filter["account_id"] = rec.AccountID
+	}
+	sort := bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}
+
+	var book Book
+	var err error
+	if book.Incomes, err = findAll[models.Income](ctx, "income", filter, sort, 0); err != nil {
+		return book, err
+	}
+	book.Expenses, err = findAll[models.Income](ctx, "expenses", filter, sort, 0)
+	return book, err
+}
+
+// Unmatched returns the records of book no line of rec is matched to
+func Unmatched(rec models.Reconciliation, book Book) Book {
+	matched := map[primitive.ObjectID]bool{}
+	for _, line := range rec.Lines {
+		matched[line.RecordID] = true
+	}
+	keep := func(records []models.Income) []models.Income {
+		var unmatched []models.Income
+		for _, record := range records {
+			if !matched[record.ID] {
+				unmatched = append(unmatched, record)
+			}
+		}
+		return unmatched
+	}
+	return Book{Incomes: keep(book.Incomes), Expenses: keep(book.Expenses)}
+}
+
+// lineGap is how far apart a statement line and a record are, and whether
+// they are close enough to be matched automatically: in the same month, or
+// the record entered within matchWindow of the line
+func lineGap(line models.StatementLine, record models.Income) (time.Duration, bool) {
+	gap := record.CreatedAt.Sub(line.Date).Abs()
+	sameMonth := record.Month == helpers.Months[line.Date.Month()-1] && record.Year == strconv.Itoa(line.Date.Year())
+	return gap, sameMonth || gap <= matchWindow
+}
+
+// AutoMatch matches every unmatched line of rec to the unmatched record of
+// book with the same amount closest to it in time, returning how many lines
+// it matched
+func AutoMatch(rec *models.Reconciliation, book Book) int {
+	used := map[primitive.ObjectID]bool{}
+	var open []int
+	for i, line := range rec.Lines {
+		if line.RecordID.IsZero() {
+			open = append(open, i)
+		} else {
+			used[line.RecordID] = true
+		}
+	}
+	slices.SortStableFunc(open, func(a, b int) int { return rec.Lines[a].Date.Compare(rec.Lines[b].Date) })
+
+	matched := 0
+	for _, i := range open {
+		line := &rec.Lines[i]
+		records := book.Records(LineKind(*line))
+		best := -1
+		var bestGap time.Duration
+		for j, record := range records {
+			if used[record.ID] || math.Abs(record.Amount-math.Abs(line.Amount)) >= 0.005 {
+				continue
+			}
+			gap, near := lineGap(*line, record)
+			if near && (best < 0 || gap < bestGap) {
+				best, bestGap = j, gap
+			}
+		}
+		if best >= 0 {
+			record := records[best]
+			line.RecordID, line.RecordAmount, line.MatchedBy = record.ID, record.Amount, models.MatchAuto
+			used[record.ID] = true
+			matched++
+		}
+	}
+	return matched
+}
+
+// MatchLine matches line i of rec to a record of kind, as matchedBy says
+func MatchLine(rec *models.Reconciliation, i int, kind string, record models.Income, matchedBy string) error {
+	if i < 0 || i >= len(rec.Lines) {
+		return errors.New("no such statement line")
+	}
+	if LineKind(rec.Lines[i]) != kind {
+		if kind == "income" {
+			return errors.New("an income can only be matched to money in")
+		}
+		return errors.New("an expense can only be matched to money out")
+	}
+	for j, line := range rec.Lines {
+		if j != i && line.RecordID == record.ID {
+			return fmt.Errorf("the record is already matched to the line of %s", line.Date.Format("2006-01-02"))
+		}
+	}
+	line := &rec.Lines[i]
+	line.RecordID, line.RecordAmount, line.MatchedBy = record.ID, record.Amount, matchedBy
+	return nil
+}
+
+// UnmatchLine clears the match of line i of rec
+func UnmatchLine(rec *models.Reconciliation, i int) {
+	if i >= 0 && i < len(rec.Lines) {
+		rec.Lines[i].RecordID, rec.Lines[i].RecordAmount, rec.Lines[i].MatchedBy = primitive.NilObjectID, 0, ""
+	}
+}
+
+// ReconciliationTotals returns the net of the statement lines, the net of
+// the records matched to them, and the difference between the two, which
+// has to be zero to complete the reconciliation
+func ReconciliationTotals(rec models.Reconciliation) (statement, cleared, difference float64) {
+	for _, line := range rec.Lines {
+		statement += line.Amount
+		if line.RecordID.IsZero() {
+			continue
+		}
+		if line.Amount > 0 {
+			cleared += line.RecordAmount
+		} else {
+			cleared -= line.RecordAmount
+		}
+	}
+	statement = math.Round(statement*100) / 100
+	cleared = math.Round(cleared*100) / 100
+	return statement, cleared, math.Round((statement-cleared)*100) / 100
+}
+
+// StartReconciliation saves a new reconciliation of the lines of rec that
+// fall in its period, matched automatically where possible. It returns the
+// reconciliation and how many lines were matched.
+func StartReconciliation(ctx context.Context, rec models.Reconciliation) (models.Reconciliation, int, error) {
+	if rec.To.Before(rec.From) {
+		return rec, 0, errors.New("the period ends before it starts")
+	}
+	rec.Lines = slices.DeleteFunc(rec.Lines, func(line models.StatementLine) bool {
+		return line.Date.Before(rec.From) || line.Date.After(rec.To)
+	})
+	if len(rec.Lines) == 0 {
+		return rec, 0, errors.New("no statement lines fall in the period")
+	}
+
+	rec.ID = primitive.NewObjectID()
+	rec.Status = models.ReconciliationOpen
+	rec.CreatedAt = stamp(time.Now())
+	rec.UpdatedAt = rec.CreatedAt
+
+	book, err := LoadBook(ctx, rec)
+	if err != nil {
+		return rec, 0, err
+	}
+	matched := AutoMatch(&rec, book)
+
+	_, err = GetCollection("reconciliations").InsertOne(ctx, rec)
+	return rec, matched, err
+}
+
+// SaveReconciliation saves the matches of an open reconciliation
+func SaveReconciliation(ctx context.Context, rec *models.Reconciliation) error {
+	rec.UpdatedAt = stamp(time.Now())
+	result, err := GetCollection("reconciliations").UpdateOne(ctx,
+		bson.M{"_id": rec.ID, "status": models.ReconciliationOpen},
+		bson.M{"$set": bson.M{"lines": rec.Lines, "updated_at": rec.UpdatedAt}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("the reconciliation is complete, reopen it to change it")
+	}
+	return nil
+}
+
+// CreateFromLine adds the income or expense line i of rec is missing from
+// the books, filed under category, and matches the line to it
+func CreateFromLine(ctx context.Context, rec *models.Reconciliation, i int, category string, userID primitive.ObjectID) (models.Income, error) {
+	if i < 0 || i >= len(rec.Lines) {
+		return models.Income{}, errors.New("no such statement line")
+	}
+	if err := bulkAvailable(); err != nil {
+		return models.Income{}, err
+	}
+	line := rec.Lines[i]
+	kind := LineKind(line)
+
+	record := models.Income{
+		ID:        primitive.NewObjectID(),
+		Category:  category,
+		Month:     helpers.Months[line.Date.Month()-1],
+		Year:      strconv.Itoa(line.Date.Year()),
+		Amount:    math.Abs(line.Amount),
+		Payee:     line.Description,
+		Notes:     "Added from the statement " + rec.FileName,
+		AccountID: rec.AccountID,
+		CreatedAt: stamp(time.Now()),
+		CreatedBy: userID,
+	}
+	categories, err := CategoryPaths(ctx, kind)
+	if err != nil {
+		return record, err
+	}
+	if err := helpers.ValidateTransaction(record.Category, record.Month, record.Year, record.Amount, categories); err != nil {
+		return record, err
+	}
+
+	invalidateCount(recordCollections[kind])
+	if kind == "income" {
+		err = addIncome(record)
+	} else {
+		err = addExpense(models.Expense(record))
+	}
+	if err != nil {
+		return record, err
+	}
+	if err := MatchLine(rec, i, kind, record, models.MatchCreated); err != nil {
+		return record, err
+	}
+	return record, SaveReconciliation(ctx, rec)
+}
+
+// unlockRecords frees the records reconciliation id locked
+func unlockRecords(ctx context.Context, id primitive.ObjectID) error {
+	for _, collection := range recordCollections {
+		_, err := GetCollection(collection).UpdateMany(ctx, bson.M{"reconciliation_id": id}, bson.M{"$unset": bson.M{"reconciliation_id": ""}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompleteReconciliation locks the records matched by rec once every line
+// is matched and the difference is zero. A record changed since it was
+// matched fails the whole reconciliation.
+func CompleteReconciliation(ctx context.Context, rec *models.Reconciliation) error {
+	if err := bulkAvailable(); err != nil {
+		return err
+	}
+	if rec.Status != models.ReconciliationOpen {
+		return errors.New("the reconciliation is already complete")
+	}
+	unmatched := 0
+	for _, line := range rec.Lines {
+		if line.RecordID.IsZero() {
+			unmatched++
+		}
+	}
+	if unmatched > 0 {
+		return fmt.Errorf("%d statement lines are still unmatched", unmatched)
+	}
+	if _, _, difference := ReconciliationTotals(*rec); difference != 0 {
+		return fmt.Errorf("the difference is %.2f, it has to be zero", difference)
+	}
+
+	// lock each record as long as it still has the amount it was matched with
+	writes := map[string][]mongo.WriteModel{}
+	for _, line := range rec.Lines {
+		kind := LineKind(line)
+		writes[kind] = append(writes[kind], mongo.NewUpdateOneModel().
+			SetFilter(unreconciled(bson.M{"_id": line.RecordID, "amount": line.RecordAmount})).
+			SetUpdate(bson.M{"$set": bson.M{"reconciliation_id": rec.ID}}))
+	}
+	var locked int64
+	for kind, batch := range writes {
+		result, err := GetCollection(recordCollections[kind]).BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return errors.Join(err, unlockRecords(ctx, rec.ID))
+		}
+		locked += result.ModifiedCount
+	}
+	if locked < int64(len(rec.Lines)) {
+		return errors.Join(
+			errors.New("some matched records were changed, deleted or reconciled since they were matched, match them again"),
+			unlockRecords(ctx, rec.ID))
+	}
+
+	now := stamp(time.Now())
+	_, err := GetCollection("reconciliations").UpdateOne(ctx,
+		bson.M{"_id": rec.ID, "status": models.ReconciliationOpen},
+		bson.M{"$set": bson.M{"status": models.ReconciliationComplete, "completed_at": now, "updated_at": now}})
+	if err != nil {
+		return errors.Join(err, unlockRecords(ctx, rec.ID))
+	}
+	rec.Status, rec.CompletedAt, rec.UpdatedAt = models.ReconciliationComplete, now, now
+
+	statement, _, _ := ReconciliationTotals(*rec)
+	FireWebhook(EventReconciled, map[string]any{
+		"id":        rec.ID,
+		"file_name": rec.FileName,
+		"from":      rec.From,
+		"to":        rec.To,
+		"lines":     len(rec.Lines),
+		"net":       statement,
+	})
+	return nil
+}
+
+// ReopenReconciliation unlocks the records of a complete reconciliation so
+// its matches can be changed
+func ReopenReconciliation(ctx context.Context, id primitive.ObjectID) error {
+	if err := bulkAvailable(); err != nil {
+		return err
+	}
+	if err := unlockRecords(ctx, id); err != nil {
+		return err
+	}
+	_, err := GetCollection("reconciliations").UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"status": models.ReconciliationOpen, "updated_at": stamp(time.Now())},
+		"$unset": bson.M{"completed_at": ""},
+	})
+	return err
+}
+
+// DeleteReconciliation removes a reconciliation, unlocking its records
+func DeleteReconciliation(ctx context.Context, id primitive.ObjectID) error {
+	if err := bulkAvailable(); err != nil {
+		return err
+	}
+	if err := unlockRecords(ctx, id); err != nil {
+		return err
+	}
+	_, err := GetCollection("reconciliations").DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// ListReconciliations returns every reconciliation, newest first
+func ListReconciliations(ctx context.Context) ([]models.Reconciliation, error) {
+	return findAll[models.Reconciliation](ctx, "reconciliations", bson.M{}, bson.D{{Key: "created_at", Value: -1}}, 0)
+}
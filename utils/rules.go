@@ -0,0 +1,357 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fynance/models"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// compileRule compiles the pattern of a rule, which ignores case
+func compileRule(rule models.Rule) (*regexp.Regexp, error) {
+	if rule.Pattern == "" {
+		return nil, nil
+	}
+	pattern, err := regexp.Compile("(?i)" + rule.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return pattern, nil
+}
+
+// ValidateRule checks the conditions and category of a rule before it is
+// saved
+func ValidateRule(ctx context.Context, rule models.Rule) error {
+	if rule.Kind != "income" && rule.Kind != "expense" {
+		return errors.New("the rule type must be income or expense")
+	}
+	if strings.TrimSpace(rule.Name) == "" {
+		return errors.New("the rule needs a name")
+	}
+	if _, err := compileRule(rule); err != nil {
+		return err
+	}
+	if rule.Pattern == "" && rule.MinAmount == 0 && rule.MaxAmount == 0 {
+		return errors.New("set a pattern or an amount range to match")
+	}
+	if rule.MinAmount < 0 || rule.MaxAmount < 0 {
+		return errors.New("amounts can't be negative")
+	}
+	if rule.MaxAmount > 0 && rule.MinAmount > rule.MaxAmount {
+		return errors.New("the minimum amount is above the maximum")
+	}
+	if rule.Category == "" && len(rule.Tags) == 0 {
+		return errors.New("choose a category or tags to set")
+	}
+	if rule.Category != "" {
+		tree, err := LoadCategoryTree(ctx, rule.Kind)
+		if err != nil {
+			return err
+		}
+		if c, ok := tree.FindPath(rule.Category); !ok || c.Path != rule.Category {
+			return fmt.Errorf("unknown %s category %q", rule.Kind, rule.Category)
+		}
+	}
+	return nil
+}
+
+// AddRule saves a new rule
+func AddRule(ctx context.Context, rule models.Rule) (models.Rule, error) {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if err := ValidateRule(ctx, rule); err != nil {
+		return rule, err
+	}
+	rule.ID = primitive.NewObjectID()
+	rule.CategoryID = categoryID(ctx, rule.Kind, rule.Category)
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = rule.CreatedAt
+
+	_, err := GetCollection("rules").InsertOne(ctx, rule)
+	return rule, err
+}
+
+// UpdateRule saves the conditions, actions, priority and active flag of a
+// rule
+func UpdateRule(ctx context.Context, rule models.Rule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if err := ValidateRule(ctx, rule); err != nil {
+		return err
+	}
+	rule.CategoryID = categoryID(ctx, rule.Kind, rule.Category)
+	set := bson.M{
+		"name":       rule.Name,
+		"priority":   rule.Priority,
+		"pattern":    rule.Pattern,
+		"min_amount": rule.MinAmount,
+		"max_amount": rule.MaxAmount,
+		"category":   rule.Category,
+		"tags":       rule.Tags,
+		"active":     rule.Active,
+		"updated_at": time.Now(),
+	}
+	if !rule.CategoryID.IsZero() {
+		set["category_id"] = rule.CategoryID
+	}
+	_, err := GetCollection("rules").UpdateOne(ctx, bson.M{"_id": rule.ID},
+		setUpdate(set, map[string]primitive.ObjectID{"category_id": rule.CategoryID}))
+	return err
+}
+
+// DeleteRule removes a rule
+func DeleteRule(ctx context.Context, id primitive.ObjectID) error {
+	_, err := GetCollection("rules").DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// ListRules returns the rules of kind, or of both kinds when empty, in the
+// order they are tried. Their categories are named as they are in the tree
+// now.
+func ListRules(ctx context.Context, kind string) ([]models.Rule, error) {
+	filter := bson.M{}
+	if kind != "" {
+		filter["kind"] = kind
+	}
+	rules, err := findAll[models.Rule](ctx, "rules", filter, bson.D{{Key: "priority", Value: 1}, {Key: "created_at", Value: 1}}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	trees := make(map[string]CategoryTree)
+	for i, rule := range rules {
+		if rule.CategoryID.IsZero() {
+			continue
+		}
+		tree, loaded := trees[rule.Kind]
+		if !loaded {
+			if tree, err = LoadCategoryTree(ctx, rule.Kind); err != nil {
+				return nil, err
+			}
+			trees[rule.Kind] = tree
+		}
+		if category, ok := tree.Find(rule.CategoryID); ok {
+			rules[i].Category = category.Path
+		}
+	}
+	return rules, nil
+}
+
+// LinkRuleCategories sets the category ID of rules that only name their
+// category, returning how many it linked. Rules naming no category are left
+// as they are.
+func LinkRuleCategories(ctx context.Context) (int64, error) {
+	var linked int64
+	rules := GetCollection("rules")
+	for kind := range recordCollections {
+		tree, err := LoadCategoryTree(ctx, kind)
+		if err != nil {
+			return linked, err
+		}
+		for _, category := range tree.Categories {
+			result, err := rules.UpdateMany(ctx,
+				bson.M{"kind": kind, "category_id": bson.M{"$exists": false}, "category": category.Path},
+				bson.M{"$set": bson.M{"category_id": category.ID}},
+			)
+			if err != nil {
+				return linked, err
+			}
+			linked += result.ModifiedCount
+		}
+	}
+	return linked, nil
+}
+
+// compiledRule is a rule ready to match records
+type compiledRule struct {
+	models.Rule
+	pattern *regexp.Regexp
+}
+
+// matches reports whether every condition of the rule holds
+func (r compiledRule) matches(payee, notes string, amount float64) bool {
+	if r.MinAmount > 0 && amount < r.MinAmount {
+		return false
+	}
+	if r.MaxAmount > 0 && amount > r.MaxAmount {
+		return false
+	}
+	return r.pattern == nil || r.pattern.MatchString(payee) || r.pattern.MatchString(notes)
+}
+
+// RuleSet is a list of rules compiled and in the order they are tried
+type RuleSet struct {
+	rules []compiledRule
+}
+
+// CompileRules prepares rules to be tried in priority order, whether they
+// are active or not. Rules that don't compile are left out.
+func CompileRules(rules []models.Rule) RuleSet {
+	var set RuleSet
+	for _, rule := range rules {
+		pattern, err := compileRule(rule)
+		if err != nil {
+			continue
+		}
+		set.rules = append(set.rules, compiledRule{rule, pattern})
+	}
+	slices.SortStableFunc(set.rules, func(a, b compiledRule) int { return a.Priority - b.Priority })
+	return set
+}
+
+// LoadRules returns the active rules of kind
+func LoadRules(ctx context.Context, kind string) (RuleSet, error) {
+	rules, err := ListRules(ctx, kind)
+	if err != nil {
+		return RuleSet{}, err
+	}
+	rules = slices.DeleteFunc(rules, func(rule models.Rule) bool { return !rule.Active })
+	return CompileRules(rules), nil
+}
+
+// Len is the number of rules in the set
+func (s RuleSet) Len() int {
+	return len(s.rules)
+}
+
+// Match returns the first rule matching a record with payee, notes and amount
+func (s RuleSet) Match(payee, notes string, amount float64) (models.Rule, bool) {
+	for _, rule := range s.rules {
+		if rule.matches(payee, notes, amount) {
+			return rule.Rule, true
+		}
+	}
+	return models.Rule{}, false
+}
+
+// ApplyRule returns the category and tags of a record once rule has set its
+// category and added its tags
+func ApplyRule(rule models.Rule, category string, tags []string) (string, []string) {
+	if rule.Category != "" {
+		category = rule.Category
+	}
+	tags = slices.Clone(tags)
+	for _, tag := range rule.Tags {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return category, tags
+}
+
+// categorise applies the first rule matching income to it
+func (s RuleSet) categorise(income *models.Income) (models.Rule, bool) {
+	rule, ok := s.Match(income.Payee, income.Notes, income.Amount)
+	if ok {
+		income.Category, income.Tags = ApplyRule(rule, income.Category, income.Tags)
+	}
+	return rule, ok
+}
+
+// RuleChange is what the rules would do to one record
+type RuleChange struct {
+	ID          primitive.ObjectID `json:"id"`
+	Rule        string             `json:"rule"`
+	Month       string             `json:"month"`
+	Year        string             `json:"year"`
+	Amount      float64            `json:"amount"`
+	Payee       string             `json:"payee,omitempty"`
+	Category    string             `json:"category"`
+	NewCategory string             `json:"new_category"`
+	Tags        []string           `json:"tags,omitempty"`
+	NewTags     []string           `json:"new_tags,omitempty"`
+}
+
+// PreviewRules returns the changes rules would make to the existing records
+// of kind, newest first. Records the first matching rule leaves as they are,
+// and reconciled ones, aren't listed.
+func PreviewRules(ctx context.Context, kind string, rules RuleSet) ([]RuleChange, error) {
+	changes := []RuleChange{}
+	if rules.Len() == 0 {
+		return changes, nil
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	cursor, err := GetCollection(recordCollections[kind]).Find(ctx, unreconciled(bson.M{}), findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var record models.Income
+		if err := cursor.Decode(&record); err != nil {
+			return nil, err
+		}
+		changed := record
+		rule, ok := rules.categorise(&changed)
+		if !ok || (changed.Category == record.Category && slices.Equal(changed.Tags, record.Tags)) {
+			continue
+		}
+		changes = append(changes, RuleChange{
+			ID:          record.ID,
+			Rule:        rule.Name,
+			Month:       record.Month,
+			Year:        record.Year,
+			Amount:      record.Amount,
+			Payee:       record.Payee,
+			Category:    record.Category,
+			NewCategory: changed.Category,
+			Tags:        record.Tags,
+			NewTags:     changed.Tags,
+		})
+	}
+	return changes, cursor.Err()
+}
+
+// ApplyRuleChanges saves previewed changes to records of kind, returning how
+// many changed. A record whose category changed since the preview is left
+// alone.
+func ApplyRuleChanges(ctx context.Context, kind string, changes []RuleChange) (int64, error) {
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	if err := bulkAvailable(); err != nil {
+		return 0, err
+	}
+	tree, err := LoadCategoryTree(ctx, kind)
+	if err != nil {
+		return 0, err
+	}
+
+	now := stamp(time.Now())
+	var writes []mongo.WriteModel
+	var ids []primitive.ObjectID
+	for _, change := range changes {
+		categoryID := tree.IDOf(change.NewCategory)
+		set := bson.M{"category": change.NewCategory, "tags": change.NewTags, "updated_at": now}
+		if !categoryID.IsZero() {
+			set["category_id"] = categoryID
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(unreconciled(bson.M{"_id": change.ID, "category": change.Category})).
+			SetUpdate(setUpdate(set, map[string]primitive.ObjectID{"category_id": categoryID})))
+		ids = append(ids, change.ID)
+	}
+
+	result, err := GetCollection(recordCollections[kind]).BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, err
+	}
+	if result.ModifiedCount > 0 {
+		FireWebhook(EventBulkUpdated, map[string]any{
+			"type":   kind,
+			"count":  result.ModifiedCount,
+			"ids":    ids,
+			"change": "categorised by rules",
+		})
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,65 @@
+package utils_test
+
+import (
+	"errors"
+	"fynance/internal/testdb"
+	"fynance/models"
+	"fynance/utils"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRulesFollowCategoryRename(t *testing.T) {
+	ctx := testdb.Connect(t, "fynance_utils_test")
+
+	transport := models.ExpenseDetail{ID: primitive.NewObjectID(), ExpenseCategory: "Transport", CreatedAt: time.Now()}
+	fuel := models.ExpenseDetail{ID: primitive.NewObjectID(), ExpenseCategory: "Fuel", ParentID: transport.ID, CreatedAt: time.Now()}
+	for _, detail := range []models.ExpenseDetail{transport, fuel} {
+		if err := utils.AddExpenseDetail(detail, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rule, err := utils.AddRule(ctx, models.Rule{Name: "Petrol", Kind: "expense", Pattern: "shell", Category: "Transport > Fuel", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.CategoryID != fuel.ID {
+		t.Fatalf("the rule refers to category %s, want %s", rule.CategoryID.Hex(), fuel.ID.Hex())
+	}
+
+	transport.ExpenseCategory = "Travel"
+	if err := utils.UpdateExpenseDetail(transport, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stored name is refiled, and matches are filed under the new one
+	var stored models.Rule
+	if err := utils.GetCollection("rules").FindOne(ctx, bson.M{"_id": rule.ID}).Decode(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Category != "Travel > Fuel" {
+		t.Errorf("stored category %q, want %q", stored.Category, "Travel > Fuel")
+	}
+	rules, err := utils.LoadRules(ctx, "expense")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched, ok := rules.Match("Shell Westlands", "", 40)
+	if !ok || matched.Category != "Travel > Fuel" {
+		t.Fatalf("matched %v with category %q, want %q", ok, matched.Category, "Travel > Fuel")
+	}
+
+	// the category can't go while a rule files records under it
+	if err := utils.DeleteExpenseDetail(fuel.ID, nil); !errors.Is(err, utils.ErrCategoryInUse) {
+		t.Fatalf("got %v, want ErrCategoryInUse", err)
+	}
+	if err := utils.DeleteRule(ctx, rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.DeleteExpenseDetail(fuel.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+}
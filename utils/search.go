@@ -0,0 +1,385 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fynance/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AmountBound limits amounts from one side
+type AmountBound struct {
+	Value     float64
+	Inclusive bool
+}
+
+// Query is a search typed into a search bar, such as
+//
+//	fuel amount>=50 from:2026-01 to:2026-03 category:"Transport > Fuel" tag:work by:alice
+//
+// Words that aren't filters are matched literally, ignoring case, and each
+// has to appear in the record. Filters of the same kind widen the search,
+// except tags, which all have to be present. Empty parts leave it open.
+type Query struct {
+	Words      []string
+	MinAmount  *AmountBound
+	MaxAmount  *AmountBound
+	From       time.Time // the first day, matched by month for incomes and expenses
+	To         time.Time // the last day
+	Categories []string  // full names, including their subcategories
+	Tags       []string
+	CreatedBy  []string // usernames
+	Statuses   []string // log statuses
+}
+
+// tokenize splits text on spaces, keeping double-quoted parts together and
+// dropping the quotes
+func tokenize(text string) []string {
+	var tokens []string
+	var token strings.Builder
+	quoted, started := false, false
+	for _, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if started {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				started = false
+			}
+		default:
+			token.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+// parseDate reads a YYYY-MM-DD day or a YYYY-MM month, which stands for its
+// first day, or its last when end is set
+func parseDate(value string, end bool) (time.Time, error) {
+	if day, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return day, nil
+	}
+	month, err := time.ParseInLocation("2006-01", value, time.Local)
+	if err != nil {
+		return month, fmt.Errorf("invalid date %q, use YYYY-MM-DD or YYYY-MM", value)
+	}
+	if end {
+		return month.AddDate(0, 1, -1), nil
+	}
+	return month, nil
+}
+
+// parseAmount reads the amount of a filter
+func parseAmount(value string) (float64, error) {
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	return amount, nil
+}
+
+// parseAmountFilter reads the rest of an amount filter after "amount": a
+// comparison such as ">=50", or ":50" for an exact amount and ":50..100"
+// for a range
+func (q *Query) parseAmountFilter(rest string) error {
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if value, ok := strings.CutPrefix(rest, op); ok {
+			amount, err := parseAmount(value)
+			if err != nil {
+				return err
+			}
+			bound := &AmountBound{amount, len(op) == 2}
+			if op[0] == '>' {
+				q.MinAmount = bound
+			} else {
+				q.MaxAmount = bound
+			}
+			return nil
+		}
+	}
+
+	value, ok := strings.CutPrefix(rest, ":")
+	if !ok {
+		return fmt.Errorf("invalid amount filter %q", "amount"+rest)
+	}
+	low, high, isRange := strings.Cut(value, "..")
+	if !isRange {
+		high = low
+	}
+	if low != "" {
+		amount, err := parseAmount(low)
+		if err != nil {
+			return err
+		}
+		q.MinAmount = &AmountBound{amount, true}
+	}
+	if high != "" {
+		amount, err := parseAmount(high)
+		if err != nil {
+			return err
+		}
+		q.MaxAmount = &AmountBound{amount, true}
+	}
+	return nil
+}
+
+// ParseQuery reads a search bar query. Filters are written as key:value,
+// with double quotes around values that have spaces: amount (>=, <=, >, <,
+// :N or :N..M), from and to (YYYY-MM-DD or YYYY-MM), category, tag, by for
+// the user who added a record, and status for logs.
+func ParseQuery(text string) (Query, error) {
+	var q Query
+	for _, token := range tokenize(text) {
+		if rest, ok := strings.CutPrefix(strings.ToLower(token), "amount"); ok && rest != "" && strings.ContainsAny(rest[:1], ":<>") {
+			if err := q.parseAmountFilter(token[len("amount"):]); err != nil {
+				return q, err
+			}
+			continue
+		}
+
+		key, value, isFilter := strings.Cut(token, ":")
+		if !isFilter || value == "" {
+			q.Words = append(q.Words, token)
+			continue
+		}
+		var err error
+		switch strings.ToLower(key) {
+		case "from":
+			q.From, err = parseDate(value, false)
+		case "to":
+			q.To, err = parseDate(value, true)
+		case "category":
+			q.Categories = append(q.Categories, value)
+		case "tag":
+			q.Tags = append(q.Tags, strings.ToLower(value))
+		case "by":
+			q.CreatedBy = append(q.CreatedBy, value)
+		case "status":
+			q.Statuses = append(q.Statuses, strings.ToUpper(value))
+		default:
+			// text that happens to hold a colon, such as "ref:1234"
+			q.Words = append(q.Words, token)
+		}
+		if err != nil {
+			return q, err
+		}
+	}
+
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return q, errors.New("the from date is after the to date")
+	}
+	if q.MinAmount != nil && q.MaxAmount != nil && q.MaxAmount.Value < q.MinAmount.Value {
+		return q, errors.New("the lowest amount is above the highest")
+	}
+	return q, nil
+}
+
+// quoteTerm quotes a query value holding spaces
+func quoteTerm(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// String writes the query back as search bar text, so ParseQuery reads the
+// same query from it
+func (q Query) String() string {
+	var terms []string
+	for _, word := range q.Words {
+		terms = append(terms, quoteTerm(word))
+	}
+	formatAmount := func(op string, bound *AmountBound) {
+		if bound == nil {
+			return
+		}
+		if bound.Inclusive {
+			op += "="
+		}
+		terms = append(terms, "amount"+op+strconv.FormatFloat(bound.Value, 'f', -1, 64))
+	}
+	formatAmount(">", q.MinAmount)
+	formatAmount("<", q.MaxAmount)
+	if !q.From.IsZero() {
+		terms = append(terms, "from:"+q.From.Format("2006-01-02"))
+	}
+	if !q.To.IsZero() {
+		terms = append(terms, "to:"+q.To.Format("2006-01-02"))
+	}
+	for _, category := range q.Categories {
+		terms = append(terms, "category:"+quoteTerm(category))
+	}
+	for _, tag := range q.Tags {
+		terms = append(terms, "tag:"+quoteTerm(tag))
+	}
+	for _, username := range q.CreatedBy {
+		terms = append(terms, "by:"+quoteTerm(username))
+	}
+	for _, status := range q.Statuses {
+		terms = append(terms, "status:"+quoteTerm(status))
+	}
+	return strings.Join(terms, " ")
+}
+
+// literal matches text anywhere in a field, ignoring case. The text is
+// escaped, so "(" or "." are searched for as they are.
+func literal(text string) bson.M {
+	return bson.M{"$regex": regexp.QuoteMeta(text), "$options": "i"}
+}
+
+// userIDs returns the IDs of the users with the given usernames
+func userIDs(ctx context.Context, usernames []string) ([]primitive.ObjectID, error) {
+	var ids []primitive.ObjectID
+	for _, username := range usernames {
+		var user models.User
+		err := GetCollection("users").FindOne(ctx, bson.M{"username": username}).Decode(&user)
+		if err != nil {
+			return nil, fmt.Errorf("no user named %q", username)
+		}
+		ids = append(ids, user.ID)
+	}
+	return ids, nil
+}
+
+// TransactionFilter builds the query for incomes or expenses
+func (q Query) TransactionFilter(ctx context.Context) (bson.M, error) {
+	if len(q.Statuses) > 0 {
+		return nil, errors.New("only logs can be searched by status")
+	}
+
+	var conditions []bson.M
+	for _, word := range q.Words {
+		conditions = append(conditions, bson.M{"$or": matchAny(transactionSearchFields, literal(word))})
+	}
+
+	amount := bson.M{}
+	if bound := q.MinAmount; bound != nil {
+		op := "$gt"
+		if bound.Inclusive {
+			op = "$gte"
+		}
+		amount[op] = bound.Value
+	}
+	if bound := q.MaxAmount; bound != nil {
+		op := "$lt"
+		if bound.Inclusive {
+			op = "$lte"
+		}
+		amount[op] = bound.Value
+	}
+	if len(amount) > 0 {
+		conditions = append(conditions, bson.M{"amount": amount})
+	}
+
+	if !q.From.IsZero() || !q.To.IsZero() {
+		conditions = append(conditions, ExportFilter{From: q.From, To: q.To}.transactionFilter())
+	}
+
+	if len(q.Categories) > 0 {
+		// the categories and their subcategories, whose full names start with them
+		var patterns bson.A
+		for _, category := range q.Categories {
+			patterns = append(patterns, primitive.Regex{
+				Pattern: "^" + regexp.QuoteMeta(category) + "($|" + regexp.QuoteMeta(CategorySeparator) + ")",
+			})
+		}
+		conditions = append(conditions, bson.M{"category": bson.M{"$in": patterns}})
+	}
+
+	if len(q.Tags) > 0 {
+		conditions = append(conditions, bson.M{"tags": bson.M{"$all": q.Tags}})
+	}
+
+	if len(q.CreatedBy) > 0 {
+		ids, err := userIDs(ctx, q.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, bson.M{"created_by": bson.M{"$in": ids}})
+	}
+
+	return AndFilters(conditions...), nil
+}
+
+// LogFilter builds the query for logs. Log entries start with the username
+// of whoever acted, which is what by matches.
+func (q Query) LogFilter() (bson.M, error) {
+	if q.MinAmount != nil || q.MaxAmount != nil || len(q.Categories) > 0 || len(q.Tags) > 0 {
+		return nil, errors.New("logs can't be searched by amount, category or tag")
+	}
+
+	var conditions []bson.M
+	for _, word := range q.Words {
+		conditions = append(conditions, bson.M{"details": literal(word)})
+	}
+
+	if !q.From.IsZero() || !q.To.IsZero() {
+		conditions = append(conditions, ExportFilter{From: q.From, To: q.To}.logFilter())
+	}
+
+	if len(q.CreatedBy) > 0 {
+		var patterns bson.A
+		for _, username := range q.CreatedBy {
+			patterns = append(patterns, primitive.Regex{Pattern: "^" + regexp.QuoteMeta(username) + `\b`, Options: "i"})
+		}
+		conditions = append(conditions, bson.M{"details": bson.M{"$in": patterns}})
+	}
+
+	if len(q.Statuses) > 0 {
+		conditions = append(conditions, bson.M{"status": bson.M{"$in": q.Statuses}})
+	}
+
+	return AndFilters(conditions...), nil
+}
+
+// SaveSearch saves query under name for a user, replacing the query of a
+// search of theirs with the same name
+func SaveSearch(ctx context.Context, userID primitive.ObjectID, name, query string) (models.SavedSearch, error) {
+	search := models.SavedSearch{UserID: userID, Name: strings.TrimSpace(name), Query: strings.TrimSpace(query)}
+	if search.Name == "" {
+		return search, errors.New("the search needs a name")
+	}
+	if search.Query == "" {
+		return search, errors.New("there is no search to save")
+	}
+	if _, err := ParseQuery(search.Query); err != nil {
+		return search, err
+	}
+
+	now := time.Now()
+	err := GetCollection("saved_searches").FindOneAndUpdate(ctx,
+		bson.M{"user_id": userID, "name": search.Name},
+		bson.M{
+			"$set":         bson.M{"query": search.Query, "updated_at": now},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "created_at": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&search)
+	return search, err
+}
+
+// ListSavedSearches returns the saved searches of a user by name
+func ListSavedSearches(ctx context.Context, userID primitive.ObjectID) ([]models.SavedSearch, error) {
+	return findAll[models.SavedSearch](ctx, "saved_searches", bson.M{"user_id": userID}, bson.D{{Key: "name", Value: 1}}, 0)
+}
+
+// DeleteSavedSearch removes a saved search
+func DeleteSavedSearch(ctx context.Context, id primitive.ObjectID) error {
+	_, err := GetCollection("saved_searches").DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fynance/models"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook events
+const (
+	EventIncomeCreated   = "income.created"
+	EventIncomeUpdated   = "income.updated"
+	EventIncomeDeleted   = "income.deleted"
+	EventExpenseCreated  = "expense.created"
+	EventExpenseUpdated  = "expense.updated"
+	EventExpenseDeleted  = "expense.deleted"
+	EventBudgetExceeded  = "budget.exceeded"
+	EventImportFinished  = "import.finished"
+	EventImportRollback  = "import.rolled_back"
+	EventBulkUpdated     = "bulk.updated"
+	EventBulkDeleted     = "bulk.deleted"
+	EventTransferCreated = "transfer.created"
+	EventTransferDeleted = "transfer.deleted"
+	EventReconciled      = "reconciliation.completed"
+	EventPing            = "ping"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to
+var WebhookEvents = []string{
+	EventIncomeCreated, EventIncomeUpdated, EventIncomeDeleted,
+	EventExpenseCreated, EventExpenseUpdated, EventExpenseDeleted,
+	EventBudgetExceeded, EventImportFinished, EventImportRollback,
+	EventBulkUpdated, EventBulkDeleted, EventTransferCreated, EventTransferDeleted,
+	EventReconciled,
+}
+
+// Webhook request headers. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the webhook secret, so receivers can
+// reject replays with an old timestamp.
+const (
+	WebhookSignatureHeader = "X-Fynance-Signature"
+	WebhookTimestampHeader = "X-Fynance-Timestamp"
+	WebhookEventHeader     = "X-Fynance-Event"
+	WebhookDeliveryHeader  = "X-Fynance-Delivery"
+)
+
+// Delivery retries: up to webhookAttempts tries, waiting webhookBackoff,
+// then twice as long after every failure
+var (
+	webhookAttempts = 5
+	webhookBackoff  = 2 * time.Second
+	webhookClient   = &http.Client{Timeout: 10 * time.Second}
+)
+
+// deliveries in flight, so short-lived processes can wait for them
+var webhookDeliveries sync.WaitGroup
+
+// WebhookPayload is the JSON body POSTed to webhooks
+type WebhookPayload struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// SignWebhook returns the signature header value for body sent at timestamp
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateWebhook checks the URL and events before a webhook is saved
+func ValidateWebhook(webhook models.Webhook) error {
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("the webhook URL must start with http:// or https://")
+	}
+	if len(webhook.Events) == 0 {
+		return errors.New("choose at least one event")
+	}
+	for _, event := range webhook.Events {
+		if !slices.Contains(WebhookEvents, event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+// AddWebhook saves a new webhook with a freshly generated secret
+func AddWebhook(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	if err := ValidateWebhook(webhook); err != nil {
+		return webhook, err
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return webhook, err
+	}
+	webhook.ID = primitive.NewObjectID()
+	webhook.Secret = "whsec_" + hex.EncodeToString(secret)
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = webhook.CreatedAt
+
+	_, err := GetCollection("webhooks").InsertOne(ctx, webhook)
+	return webhook, err
+}
+
+// UpdateWebhook saves the URL, events and active flag of a webhook
+func UpdateWebhook(ctx context.Context, webhook models.Webhook) error {
+	if err := ValidateWebhook(webhook); err != nil {
+		return err
+	}
+	_, err := GetCollection("webhooks").UpdateOne(ctx, bson.M{"_id": webhook.ID}, bson.M{"$set": bson.M{
+		"url":        webhook.URL,
+		"events":     webhook.Events,
+		"active":     webhook.Active,
+		"updated_at": time.Now(),
+	}})
+	return err
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func DeleteWebhook(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := GetCollection("webhooks").DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return err
+	}
+	_, err := GetCollection("webhook_deliveries").DeleteMany(ctx, bson.M{"webhook_id": id})
+	return err
+}
+
+// ListWebhooks returns every webhook, oldest first
+func ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	return findAll[models.Webhook](ctx, "webhooks", bson.M{}, bson.D{{Key: "created_at", Value: 1}}, 0)
+}
+
+// ListWebhookDeliveries returns the latest delivery attempts of a webhook
+func ListWebhookDeliveries(ctx context.Context, webhookID primitive.ObjectID, limit int64) ([]models.WebhookDelivery, error) {
+	return findAll[models.WebhookDelivery](ctx, "webhook_deliveries", bson.M{"webhook_id": webhookID},
+		bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}, limit)
+}
+
+// FireWebhook delivers event to every active webhook subscribed to it, in
+// the background. Failures only show up in the delivery log.
+func FireWebhook(event string, data any) {
+	webhooks, err := findAll[models.Webhook](context.Background(), "webhooks",
+		bson.M{"active": true, "events": event}, bson.D{{Key: "_id", Value: 1}}, 0)
+	if err != nil || len(webhooks) == 0 {
+		return
+	}
+
+	payload := WebhookPayload{
+		ID:        primitive.NewObjectID().Hex(),
+		Event:     event,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	for _, webhook := range webhooks {
+		webhookDeliveries.Add(1)
+		go func() {
+			defer webhookDeliveries.Done()
+			deliverWebhook(context.Background(), webhook, payload, body)
+		}()
+	}
+}
+
+// PingWebhook sends a test event and waits for the outcome of the first attempt
+func PingWebhook(ctx context.Context, webhook models.Webhook) models.WebhookDelivery {
+	payload := WebhookPayload{
+		ID:        primitive.NewObjectID().Hex(),
+		Event:     EventPing,
+		CreatedAt: time.Now(),
+		Data:      map[string]string{"message": "Webhook test from Fynance"},
+	}
+	body, _ := json.Marshal(payload)
+	return attemptWebhook(ctx, webhook, payload, body, 1)
+}
+
+// WaitForWebhooks waits until pending deliveries, retries included, are
+// done or ctx ends
+func WaitForWebhooks(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		webhookDeliveries.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// deliverWebhook POSTs body, retrying with exponential back-off until a 2xx
+// response or the last attempt
+func deliverWebhook(ctx context.Context, webhook models.Webhook, payload WebhookPayload, body []byte) {
+	wait := webhookBackoff
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if attemptWebhook(ctx, webhook, payload, body, attempt).Success {
+			return
+		}
+		if attempt == webhookAttempts {
+			return
+		}
+
+		select {
+		case <-time.After(wait):
+			wait *= 2
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// attemptWebhook makes one delivery attempt and records it in the delivery log
+func attemptWebhook(ctx context.Context, webhook models.Webhook, payload WebhookPayload, body []byte, attempt int) models.WebhookDelivery {
+	delivery := models.WebhookDelivery{
+		ID:         primitive.NewObjectID(),
+		WebhookID:  webhook.ID,
+		DeliveryID: payload.ID,
+		Event:      payload.Event,
+		URL:        webhook.URL,
+		Payload:    string(body),
+		Attempt:    attempt,
+		CreatedAt:  time.Now(),
+	}
+
+	timestamp := strconv.FormatInt(delivery.CreatedAt.Unix(), 10)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err == nil {
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("User-Agent", "Fynance-Webhook/1")
+		request.Header.Set(WebhookEventHeader, payload.Event)
+		request.Header.Set(WebhookDeliveryHeader, payload.ID)
+		request.Header.Set(WebhookTimestampHeader, timestamp)
+		request.Header.Set(WebhookSignatureHeader, SignWebhook(webhook.Secret, timestamp, body))
+
+		var response *http.Response
+		response, err = webhookClient.Do(request)
+		if err == nil {
+			io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+			response.Body.Close()
+			delivery.StatusCode = response.StatusCode
+			delivery.Success = response.StatusCode >= 200 && response.StatusCode < 300
+			if !delivery.Success {
+				err = errors.New(response.Status)
+			}
+		}
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	delivery.Duration = time.Since(delivery.CreatedAt)
+
+	GetCollection("webhook_deliveries").InsertOne(context.Background(), delivery)
+	return delivery
+}
+
+// checkBudget fires budget.exceeded when a change that raised the expenses
+// of a month by added pushes them above that month's income
+func checkBudget(year, month string, added float64) {
+	if added <= 0 {
+		return
+	}
+
+	ctx := context.Background()
+	reports, err := MonthlyReport(ctx, year, []string{month})
+	if err != nil || len(reports) == 0 {
+		return
+	}
+	report := reports[0]
+
+	if report.TotalExpense > report.TotalIncome && report.TotalExpense-added <= report.TotalIncome {
+		FireWebhook(EventBudgetExceeded, map[string]any{
+			"year":          year,
+			"month":         month,
+			"total_income":  report.TotalIncome,
+			"total_expense": report.TotalExpense,
+			"over_by":       report.TotalExpense - report.TotalIncome,
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// FastWebhookRetries shortens the delivery back-off for the test
+func FastWebhookRetries(t *testing.T, attempts int, backoff time.Duration) {
+	previousAttempts, previousBackoff := webhookAttempts, webhookBackoff
+	webhookAttempts, webhookBackoff = attempts, backoff
+	t.Cleanup(func() { webhookAttempts, webhookBackoff = previousAttempts, previousBackoff })
+}
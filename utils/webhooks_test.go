@@ -0,0 +1,196 @@
+package utils_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fynance/internal/testdb"
+	"fynance/models"
+	"fynance/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receivedWebhook is one request seen by a test receiver
+type receivedWebhook struct {
+	at     time.Time
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver answers webhook requests with the statuses in turn,
+// repeating the last one, and keeps what it received
+type webhookReceiver struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	received []receivedWebhook
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		receiver.received = append(receiver.received, receivedWebhook{at: time.Now(), header: r.Header.Clone(), body: body})
+		w.WriteHeader(receiver.statuses[min(len(receiver.received), len(receiver.statuses))-1])
+	}))
+	t.Cleanup(receiver.server.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) requests() []receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedWebhook(nil), r.received...)
+}
+
+// fireAndWait fires event and waits for every delivery, retries included
+func fireAndWait(t *testing.T, event string, data any) {
+	t.Helper()
+	utils.FireWebhook(event, data)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	utils.WaitForWebhooks(ctx)
+	if ctx.Err() != nil {
+		t.Fatal("webhook deliveries did not finish")
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	ctx := testdb.Connect(t, "fynance_utils_test")
+	const backoff = 50 * time.Millisecond
+	utils.FastWebhookRetries(t, 5, backoff)
+
+	// two server errors, then success on the third attempt
+	receiver := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	webhook, err := utils.AddWebhook(ctx, models.Webhook{URL: receiver.server.URL, Events: []string{utils.EventIncomeCreated}, Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fireAndWait(t, utils.EventIncomeCreated, map[string]any{"category": "Salary", "amount": 1200})
+
+	requests := receiver.requests()
+	if len(requests) != 3 {
+		t.Fatalf("received %d requests, want 3", len(requests))
+	}
+	for i, request := range requests {
+		timestamp := request.header.Get(utils.WebhookTimestampHeader)
+		mac := hmac.New(sha256.New, []byte(webhook.Secret))
+		mac.Write([]byte(timestamp + "." + string(request.body)))
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if got := request.header.Get(utils.WebhookSignatureHeader); got != want {
+			t.Errorf("attempt %d: signature %q, want %q", i+1, got, want)
+		}
+		if request.header.Get(utils.WebhookEventHeader) != utils.EventIncomeCreated {
+			t.Errorf("attempt %d: event header %q", i+1, request.header.Get(utils.WebhookEventHeader))
+		}
+		if request.header.Get(utils.WebhookDeliveryHeader) != requests[0].header.Get(utils.WebhookDeliveryHeader) {
+			t.Errorf("attempt %d: the delivery ID changed between retries", i+1)
+		}
+
+		var payload utils.WebhookPayload
+		if err := json.Unmarshal(request.body, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Event != utils.EventIncomeCreated || payload.ID != request.header.Get(utils.WebhookDeliveryHeader) {
+			t.Errorf("attempt %d: payload %+v", i+1, payload)
+		}
+	}
+
+	// the wait doubles after every failure
+	if gap := requests[1].at.Sub(requests[0].at); gap < backoff {
+		t.Errorf("first retry after %v, want at least %v", gap, backoff)
+	}
+	if gap := requests[2].at.Sub(requests[1].at); gap < 2*backoff {
+		t.Errorf("second retry after %v, want at least %v", gap, 2*backoff)
+	}
+
+	deliveries, err := utils.ListWebhookDeliveries(ctx, webhook.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 3 {
+		t.Fatalf("%d deliveries logged, want 3", len(deliveries))
+	}
+	// newest first
+	for i, delivery := range deliveries {
+		attempt := 3 - i
+		wantStatus := []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}[attempt-1]
+		if delivery.Attempt != attempt || delivery.StatusCode != wantStatus || delivery.Success != (attempt == 3) {
+			t.Errorf("delivery %d: attempt %d status %d success %v", i, delivery.Attempt, delivery.StatusCode, delivery.Success)
+		}
+		if delivery.Event != utils.EventIncomeCreated || delivery.URL != webhook.URL || delivery.DeliveryID != requests[0].header.Get(utils.WebhookDeliveryHeader) {
+			t.Errorf("delivery %d: %+v", i, delivery)
+		}
+		if delivery.Success == (delivery.Error != "") {
+			t.Errorf("delivery %d: success %v with error %q", i, delivery.Success, delivery.Error)
+		}
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	ctx := testdb.Connect(t, "fynance_utils_test")
+	utils.FastWebhookRetries(t, 3, 10*time.Millisecond)
+
+	receiver := newWebhookReceiver(t, http.StatusServiceUnavailable)
+	webhook, err := utils.AddWebhook(ctx, models.Webhook{URL: receiver.server.URL, Events: []string{utils.EventExpenseDeleted}, Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fireAndWait(t, utils.EventExpenseDeleted, map[string]any{"category": "Rent"})
+
+	if got := len(receiver.requests()); got != 3 {
+		t.Fatalf("received %d requests, want 3", got)
+	}
+	deliveries, err := utils.ListWebhookDeliveries(ctx, webhook.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 3 {
+		t.Fatalf("%d deliveries logged, want 3", len(deliveries))
+	}
+	for _, delivery := range deliveries {
+		if delivery.Success || delivery.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("attempt %d: success %v status %d", delivery.Attempt, delivery.Success, delivery.StatusCode)
+		}
+	}
+}
+
+func TestWebhookSubscriptions(t *testing.T) {
+	ctx := testdb.Connect(t, "fynance_utils_test")
+	utils.FastWebhookRetries(t, 1, time.Millisecond)
+
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	inactive, err := utils.AddWebhook(ctx, models.Webhook{URL: receiver.server.URL, Events: []string{utils.EventIncomeCreated}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.AddWebhook(ctx, models.Webhook{URL: receiver.server.URL, Events: []string{utils.EventExpenseCreated}, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// neither the inactive webhook nor the one on another event receives it
+	fireAndWait(t, utils.EventIncomeCreated, map[string]any{"category": "Salary"})
+	if got := len(receiver.requests()); got != 0 {
+		t.Fatalf("received %d requests, want none", got)
+	}
+
+	inactive.Active = true
+	if err := utils.UpdateWebhook(ctx, inactive); err != nil {
+		t.Fatal(err)
+	}
+	fireAndWait(t, utils.EventIncomeCreated, map[string]any{"category": "Salary"})
+	if got := len(receiver.requests()); got != 1 {
+		t.Fatalf("received %d requests, want 1", got)
+	}
+}
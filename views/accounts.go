@@ -0,0 +1,399 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fynance/helpers"
+	"fynance/models"
+	"fynance/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// noAccount is the account choice of a record not tied to any account
+const noAccount = "(none)"
+
+// accountSelect is a choice of account for a record, starting on the account
+// with id. The returned func gives the ID of the account chosen.
+func accountSelect(window fyne.Window, id primitive.ObjectID) (*widget.Select, func() primitive.ObjectID) {
+	accounts, err := utils.ListAccounts(context.Background())
+	if err != nil {
+		dialog.ShowError(err, window)
+	}
+
+	names := []string{noAccount}
+	ids := map[string]primitive.ObjectID{}
+	for _, account := range accounts {
+		names = append(names, account.Name)
+		ids[account.Name] = account.ID
+	}
+
+	choice := widget.NewSelect(names, nil)
+	choice.SetSelected(noAccount)
+	for _, account := range accounts {
+		if account.ID == id {
+			choice.SetSelected(account.Name)
+		}
+	}
+	return choice, func() primitive.ObjectID { return ids[choice.Selected] }
+}
+
+// AccountsView lists the accounts with where each stands, with actions to
+// add, edit and delete them, move money between them and follow the balance
+// of one month by month
+func AccountsView(window fyne.Window, userID primitive.ObjectID) fyne.CanvasObject {
+	header := Header(window)
+	footer := Footer(window)
+
+	accountList := container.NewVBox()
+	history := container.NewStack(widget.NewLabel("Choose an account to see its balance over time"))
+
+	var refreshAccounts func()
+	var showHistory func(account models.Account)
+
+	// notify logs a change to the accounts and tells the user about it
+	notify := func(detail string) {
+		user := utils.GetUserByID(userID, window)
+		utils.AddNotification(models.Notification{
+			UserID:  user.ID,
+			Message: detail,
+			IsRead:  false,
+		}, window)
+		updateNotificationCount(window)
+		utils.Logger(user.Username+" "+detail, "SUCCESS", window)
+	}
+
+	// selected is the account whose history is shown
+	var selected *models.Account
+
+	refreshAccounts = func() {
+		balances, err := utils.AccountBalances(context.Background())
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		accountList.Objects = nil
+		if len(balances) == 0 {
+			accountList.Add(widget.NewLabel("No accounts yet"))
+		}
+		total := 0.0
+		for _, balance := range balances {
+			account := balance.Account
+			total += balance.Balance
+
+			label := widget.NewLabel(fmt.Sprintf("%s (%s)\nBalance %s", account.Name, account.Type, formatBalance(balance.Balance)))
+			label.Wrapping = fyne.TextWrapWord
+
+			actions := container.NewHBox(
+				widget.NewButtonWithIcon("", theme.HistoryIcon(), func() {
+					showHistory(account)
+				}),
+				widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), func() {
+					showAccountForm(window, &account, func(saved models.Account) {
+						notify("edited account " + saved.Name)
+						refreshAccounts()
+					})
+				}),
+				widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+					dialog.ShowConfirm("Delete Account", "Delete the account "+account.Name+"?", func(ok bool) {
+						if !ok {
+							return
+						}
+						if err := utils.DeleteAccount(context.Background(), account.ID); err != nil {
+							if errors.Is(err, utils.ErrAccountInUse) {
+								err = fmt.Errorf("%w. Move its records to another account first", err)
+							}
+							dialog.ShowError(err, window)
+							return
+						}
+						notify("deleted account " + account.Name)
+						if selected != nil && selected.ID == account.ID {
+							selected = nil
+							history.Objects = []fyne.CanvasObject{widget.NewLabel("Choose an account to see its balance over time")}
+							history.Refresh()
+						}
+						refreshAccounts()
+					}, window)
+				}),
+			)
+			accountList.Add(container.NewBorder(nil, nil, nil, actions, label))
+		}
+		if len(balances) > 0 {
+			accountList.Add(widget.NewLabelWithStyle("Total "+formatBalance(total), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		}
+		accountList.Refresh()
+
+		if selected != nil {
+			for _, balance := range balances {
+				if balance.Account.ID == selected.ID {
+					showHistory(balance.Account)
+				}
+			}
+		}
+	}
+
+	showHistory = func(account models.Account) {
+		selected = &account
+		ctx := context.Background()
+		points, err := utils.AccountHistory(ctx, account)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		transfers, err := utils.ListTransfers(ctx, account.ID)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		accounts, err := utils.ListAccounts(ctx)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		names := map[primitive.ObjectID]string{}
+		for _, a := range accounts {
+			names[a.ID] = a.Name
+		}
+
+		months := widget.NewList(
+			func() int {
+				return len(points) + 1
+			},
+			func() fyne.CanvasObject {
+				return container.NewGridWithColumns(5, widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""))
+			},
+			func(id widget.ListItemID, obj fyne.CanvasObject) {
+				row := obj.(*fyne.Container)
+				texts := []string{"Opening", "", "", "", formatBalance(account.OpeningBalance)}
+				if id > 0 {
+					point := points[id-1]
+					texts = []string{
+						point.Month + " " + point.Year,
+						"+" + formatBalance(point.Income),
+						"-" + formatBalance(point.Expenses),
+						formatBalance(point.TransfersIn - point.TransfersOut),
+						formatBalance(point.Balance),
+					}
+				}
+				for i, text := range texts {
+					row.Objects[i].(*widget.Label).SetText(text)
+				}
+			},
+		)
+		monthsHeader := container.NewGridWithColumns(5,
+			widget.NewLabelWithStyle("Month", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabelWithStyle("Income", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabelWithStyle("Expenses", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabelWithStyle("Transfers", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			widget.NewLabelWithStyle("Balance", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		)
+
+		transferList := widget.NewList(
+			func() int {
+				return len(transfers)
+			},
+			func() fyne.CanvasObject {
+				return container.NewBorder(nil, nil, nil, widget.NewButtonWithIcon("", theme.DeleteIcon(), nil), widget.NewLabel(""))
+			},
+			func(id widget.ListItemID, obj fyne.CanvasObject) {
+				transfer := transfers[id]
+				text := fmt.Sprintf("%s %s  %s → %s  %s", transfer.Month, transfer.Year,
+					names[transfer.FromID], names[transfer.ToID], formatBalance(transfer.Amount))
+				if transfer.Notes != "" {
+					text += "  " + transfer.Notes
+				}
+				row := obj.(*fyne.Container)
+				row.Objects[0].(*widget.Label).SetText(text)
+				row.Objects[1].(*widget.Button).OnTapped = func() {
+					dialog.ShowConfirm("Delete Transfer", "Delete this transfer? Both balances change back.", func(ok bool) {
+						if !ok {
+							return
+						}
+						if err := utils.DeleteTransfer(context.Background(), transfer.ID); err != nil {
+							dialog.ShowError(err, window)
+							return
+						}
+						notify(fmt.Sprintf("deleted the transfer of %s from %s to %s",
+							formatBalance(transfer.Amount), names[transfer.FromID], names[transfer.ToID]))
+						refreshAccounts()
+					}, window)
+				}
+			},
+		)
+
+		title := widget.NewLabelWithStyle(account.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+		monthsPanel := container.NewBorder(monthsHeader, nil, nil, nil, months)
+		transfersPanel := container.NewBorder(widget.NewLabel("Transfers"), nil, nil, nil, transferList)
+		split := container.NewVSplit(monthsPanel, transfersPanel)
+		split.Offset = 0.6
+
+		history.Objects = []fyne.CanvasObject{container.NewBorder(title, nil, nil, nil, split)}
+		history.Refresh()
+	}
+
+	refreshAccounts()
+
+	addButton := widget.NewButtonWithIcon("Add Account", theme.ContentAddIcon(), func() {
+		showAccountForm(window, &models.Account{Type: models.AccountBank}, func(saved models.Account) {
+			notify("added account " + saved.Name)
+			refreshAccounts()
+		})
+	})
+	transferButton := widget.NewButtonWithIcon("Transfer", theme.MailForwardIcon(), func() {
+		showTransferForm(window, userID, func(transfer models.Transfer, from, to string) {
+			notify(fmt.Sprintf("transferred %s from %s to %s", formatBalance(transfer.Amount), from, to))
+			refreshAccounts()
+		})
+	})
+
+	help := widget.NewLabel("Transfers move money between accounts without counting as income or expenses. " +
+		"Records not tied to an account don't change any balance.")
+	help.Wrapping = fyne.TextWrapWord
+
+	toolbar := container.NewHBox(addButton, transferButton)
+	split := container.NewHSplit(container.NewVScroll(accountList), history)
+	split.Offset = 0.35
+
+	content := container.NewBorder(container.NewVBox(toolbar, help), nil, nil, nil, split)
+	return container.NewBorder(header, footer, nil, nil, content)
+}
+
+// formatBalance shows an amount of money with two decimals
+func formatBalance(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// showAccountForm adds an account, or edits it when it has an ID
+func showAccountForm(window fyne.Window, account *models.Account, onSaved func(models.Account)) {
+	isEdit := !account.ID.IsZero()
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("e.g. M-Pesa")
+	nameEntry.SetText(account.Name)
+
+	typeSelect := widget.NewSelect(utils.AccountTypes, nil)
+	typeSelect.SetSelected(account.Type)
+
+	openingEntry := widget.NewEntry()
+	openingEntry.SetText(strconv.FormatFloat(account.OpeningBalance, 'f', -1, 64))
+
+	title := "Add Account"
+	if isEdit {
+		title = "Edit Account"
+	}
+
+	form := dialog.NewForm(title, "Save", "Cancel", []*widget.FormItem{
+		{Text: "Name", Widget: nameEntry},
+		{Text: "Type", Widget: typeSelect},
+		{Text: "Opening Balance", Widget: openingEntry, HintText: "What the account held before the first record"},
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+
+		edited := *account
+		edited.Name = strings.TrimSpace(nameEntry.Text)
+		edited.Type = typeSelect.Selected
+		opening, err := strconv.ParseFloat(strings.TrimSpace(openingEntry.Text), 64)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("invalid opening balance %q", openingEntry.Text), window)
+			return
+		}
+		edited.OpeningBalance = opening
+
+		if isEdit {
+			if err := utils.UpdateAccount(context.Background(), edited); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+		} else {
+			if edited, err = utils.AddAccount(context.Background(), edited); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+		}
+		onSaved(edited)
+	}, window)
+	form.Resize(fyne.NewSize(460, 0))
+	form.Show()
+}
+
+// showTransferForm records money moved between two accounts. onSaved is
+// given the transfer and the names of the accounts.
+func showTransferForm(window fyne.Window, userID primitive.ObjectID, onSaved func(models.Transfer, string, string)) {
+	accounts, err := utils.ListAccounts(context.Background())
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+	if len(accounts) < 2 {
+		dialog.ShowInformation("Transfer", "Add at least two accounts to transfer between.", window)
+		return
+	}
+
+	var names []string
+	ids := map[string]primitive.ObjectID{}
+	for _, account := range accounts {
+		names = append(names, account.Name)
+		ids[account.Name] = account.ID
+	}
+
+	fromSelect := widget.NewSelect(names, nil)
+	toSelect := widget.NewSelect(names, nil)
+
+	amountEntry := widget.NewEntry()
+
+	now := time.Now()
+	monthSelect := widget.NewSelect(helpers.Months, nil)
+	monthSelect.SetSelected(helpers.Months[now.Month()-1])
+	yearEntry := widget.NewEntry()
+	yearEntry.SetText(strconv.Itoa(now.Year()))
+
+	notesEntry := widget.NewEntry()
+	notesEntry.SetPlaceHolder("Optional")
+
+	form := dialog.NewForm("Transfer", "Save", "Cancel", []*widget.FormItem{
+		{Text: "From", Widget: fromSelect},
+		{Text: "To", Widget: toSelect},
+		{Text: "Amount", Widget: amountEntry},
+		{Text: "Month", Widget: monthSelect},
+		{Text: "Year", Widget: yearEntry},
+		{Text: "Notes", Widget: notesEntry},
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+
+		amount, err := strconv.ParseFloat(strings.TrimSpace(amountEntry.Text), 64)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("invalid amount %q", amountEntry.Text), window)
+			return
+		}
+		transfer, err := utils.AddTransfer(context.Background(), models.Transfer{
+			FromID:    ids[fromSelect.Selected],
+			ToID:      ids[toSelect.Selected],
+			Amount:    amount,
+			Month:     monthSelect.Selected,
+			Year:      strings.TrimSpace(yearEntry.Text),
+			Notes:     notesEntry.Text,
+			CreatedBy: userID,
+		})
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		onSaved(transfer, fromSelect.Selected, toSelect.Selected)
+	}, window)
+	form.Resize(fyne.NewSize(460, 0))
+	form.Show()
+}
@@ -0,0 +1,185 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fynance/api"
+	"fynance/helpers"
+	"fynance/utils"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// the running API server, nil when the API is off
+var apiServer *api.Server
+
+// StartAPIServer (re)starts the REST API from the saved settings
+func StartAPIServer(window fyne.Window) {
+	StopAPIServer()
+
+	settings, err := LoadSettings()
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+	if !settings.APIEnabled {
+		return
+	}
+
+	apiServer, err = api.Start(settings.APIAddress)
+	if err != nil {
+		utils.Logger("API server failed to start: "+err.Error(), "ERROR", window)
+		dialog.ShowError(errors.New("the API server could not start: "+err.Error()), window)
+		return
+	}
+	utils.Logger("API server listening on "+apiServer.Addr(), "SUCCESS", window)
+}
+
+// StopAPIServer stops the REST API, letting running requests finish
+func StopAPIServer() {
+	if apiServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		apiServer.Shutdown(ctx)
+		apiServer = nil
+	}
+}
+
+// showAPIDialog configures the API server and manages the current user's tokens
+func showAPIDialog(window fyne.Window) {
+	settings, err := LoadSettings()
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+
+	enabledCheck := widget.NewCheck("Serve the API", nil)
+	enabledCheck.SetChecked(settings.APIEnabled)
+
+	addressEntry := widget.NewEntry()
+	addressEntry.SetText(settings.APIAddress)
+
+	status := "Stopped"
+	if apiServer != nil {
+		status = "Listening on http://" + apiServer.Addr() + "/api/v1"
+	}
+
+	// The current user's tokens, refreshed after every change
+	tokenList := container.NewVBox()
+	var refreshTokens func()
+	refreshTokens = func() {
+		tokens, err := utils.ListAPITokens(context.Background(), helpers.CurrentUserID)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		tokenList.Objects = nil
+		if len(tokens) == 0 {
+			tokenList.Add(widget.NewLabel("No tokens yet"))
+		}
+		for _, token := range tokens {
+			lastUsed := "never used"
+			if !token.LastUsedAt.IsZero() {
+				lastUsed = "last used " + token.LastUsedAt.Local().Format("2006-01-02 15:04")
+			}
+			revoke := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+				dialog.ShowConfirm("Revoke Token", "Clients using \""+token.Name+"\" will lose access. Continue?", func(ok bool) {
+					if !ok {
+						return
+					}
+					if err := utils.RevokeAPIToken(context.Background(), helpers.CurrentUserID, token.ID); err != nil {
+						dialog.ShowError(err, window)
+						return
+					}
+					utils.Logger("Revoked API token "+token.Name, "SUCCESS", window)
+					refreshTokens()
+				}, window)
+			})
+			label := widget.NewLabel(token.Name + "  (" + token.Prefix + "…, " + lastUsed + ")")
+			tokenList.Add(container.NewBorder(nil, nil, nil, revoke, label))
+		}
+		tokenList.Refresh()
+	}
+	refreshTokens()
+
+	newTokenButton := widget.NewButtonWithIcon("New Token", theme.ContentAddIcon(), func() {
+		nameEntry := widget.NewEntry()
+		nameEntry.SetPlaceHolder("e.g. Telegram bot")
+		form := helpers.NewFixedWidthCenter(container.NewVBox(widget.NewForm(
+			&widget.FormItem{Text: "Name", Widget: nameEntry},
+		)), 300)
+
+		dialog.ShowCustomConfirm("New API Token", "Create", "Cancel", container.NewCenter(form), func(ok bool) {
+			name := strings.TrimSpace(nameEntry.Text)
+			if !ok {
+				return
+			}
+			if name == "" {
+				dialog.ShowError(errors.New("give the token a name"), window)
+				return
+			}
+
+			token, _, err := utils.CreateAPIToken(context.Background(), helpers.CurrentUserID, name)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			utils.Logger("Created API token "+name, "SUCCESS", window)
+			refreshTokens()
+			showNewToken(window, token)
+		}, window)
+	})
+
+	content := container.NewVBox(
+		widget.NewForm(
+			&widget.FormItem{Text: "", Widget: enabledCheck},
+			&widget.FormItem{Text: "Address", Widget: addressEntry},
+			&widget.FormItem{Text: "Status", Widget: widget.NewLabel(status)},
+		),
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Your API Tokens", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		tokenList,
+		newTokenButton,
+	)
+
+	dialog.ShowCustomConfirm("API Access", "Save", "Close", helpers.NewFixedWidthCenter(content, 450), func(ok bool) {
+		if !ok {
+			return
+		}
+		if strings.TrimSpace(addressEntry.Text) == "" {
+			dialog.ShowError(errors.New("enter an address such as "+api.DefaultAddress), window)
+			return
+		}
+
+		settings.APIEnabled = enabledCheck.Checked
+		settings.APIAddress = strings.TrimSpace(addressEntry.Text)
+		if err := SaveSettings(settings); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		StartAPIServer(window)
+	}, window)
+}
+
+// showNewToken shows a freshly created token, the only time it can be seen
+func showNewToken(window fyne.Window, token string) {
+	tokenEntry := widget.NewEntry()
+	tokenEntry.SetText(token)
+
+	copyButton := widget.NewButtonWithIcon("Copy", theme.ContentCopyIcon(), func() {
+		window.Clipboard().SetContent(token)
+	})
+
+	content := container.NewVBox(
+		widget.NewLabel("Copy this token now, it will not be shown again.\nSend it as: Authorization: Bearer <token>"),
+		container.NewBorder(nil, nil, nil, copyButton, tokenEntry),
+	)
+	dialog.ShowCustom("API Token Created", "Done", helpers.NewFixedWidthCenter(content, 450), window)
+}
@@ -0,0 +1,320 @@
+package views
+
+import (
+	"context"
+	"fmt"
+	"fynance/helpers"
+	"fynance/models"
+	"fynance/utils"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// attachmentIndex remembers the attachments of the rows a table loaded, so
+// every row can show its files without a query of its own
+type attachmentIndex struct {
+	mu         sync.Mutex
+	byRecord   map[primitive.ObjectID][]models.Attachment
+	thumbnails map[primitive.ObjectID]fyne.Resource
+}
+
+func newAttachmentIndex() *attachmentIndex {
+	return &attachmentIndex{
+		byRecord:   map[primitive.ObjectID][]models.Attachment{},
+		thumbnails: map[primitive.ObjectID]fyne.Resource{},
+	}
+}
+
+// load reads the attachments of a page of records
+func (a *attachmentIndex) load(ids []primitive.ObjectID) {
+	byRecord, err := utils.AttachmentsOf(context.Background(), ids)
+	if err != nil {
+		return // the rows just show no files
+	}
+	a.mu.Lock()
+	for _, id := range ids {
+		a.byRecord[id] = byRecord[id]
+	}
+	a.mu.Unlock()
+}
+
+// icon is the preview of a record's first image, a file icon when it only
+// has other files, or nil when it has none
+func (a *attachmentIndex) icon(id primitive.ObjectID) fyne.Resource {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	attachments := a.byRecord[id]
+	for _, attachment := range attachments {
+		if len(attachment.Metadata.Thumbnail) == 0 {
+			continue
+		}
+		if resource, ok := a.thumbnails[attachment.ID]; ok {
+			return resource
+		}
+		resource := fyne.NewStaticResource(attachment.ID.Hex()+".png", attachment.Metadata.Thumbnail)
+		a.thumbnails[attachment.ID] = resource
+		return resource
+	}
+	if len(attachments) > 0 {
+		return theme.FileIcon()
+	}
+	return nil
+}
+
+func (a *attachmentIndex) count(id primitive.ObjectID) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.byRecord[id])
+}
+
+// attachmentColumn shows a preview and the number of files of each row
+func attachmentColumn[T any](index *attachmentIndex, id func(T) primitive.ObjectID) tableColumn[T] {
+	return tableColumn[T]{
+		Title: "Files",
+		Width: 90,
+		Text: func(item T) string {
+			if count := index.count(id(item)); count > 0 {
+				return strconv.Itoa(count)
+			}
+			return ""
+		},
+		Icon: func(item T) fyne.Resource { return index.icon(id(item)) },
+	}
+}
+
+// withAttachments wraps a table's fetch so the attachments of every page are
+// loaded with it
+func withAttachments[T any](index *attachmentIndex, id func(T) primitive.ObjectID, fetch func(utils.PageRequest) utils.Page[T]) func(utils.PageRequest) utils.Page[T] {
+	return func(req utils.PageRequest) utils.Page[T] {
+		page := fetch(req)
+		var ids []primitive.ObjectID
+		for _, item := range page.Items {
+			ids = append(ids, id(item))
+		}
+		index.load(ids)
+		return page
+	}
+}
+
+// formatSize shows a file size in B, KB or MB
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.0f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+// showAttachments lists the files of a record with a preview of the selected
+// one, and lets files be added, saved, opened and deleted. kind is Income or
+// Expense; onChanged is called after a file is added or deleted.
+func showAttachments(window fyne.Window, kind string, recordID primitive.ObjectID, title string, onChanged func()) {
+	var attachments []models.Attachment
+	selected := -1
+
+	preview := canvas.NewImageFromResource(nil)
+	preview.FillMode = canvas.ImageFillContain
+	preview.SetMinSize(fyne.NewSize(360, 360))
+	previewText := widget.NewLabel("Select a file to preview it.")
+	previewText.Wrapping = fyne.TextWrapWord
+	previewText.Alignment = fyne.TextAlignCenter
+
+	list := widget.NewList(
+		func() int { return len(attachments) },
+		func() fyne.CanvasObject {
+			name := widget.NewLabel("")
+			name.Truncation = fyne.TextTruncateEllipsis
+			return container.NewBorder(nil, nil, widget.NewIcon(theme.FileIcon()), widget.NewLabel(""), name)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			attachment := attachments[id]
+			row := obj.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(attachment.FileName)
+			icon := theme.FileApplicationIcon()
+			if attachment.IsImage() {
+				icon = theme.FileImageIcon()
+			}
+			row.Objects[1].(*widget.Icon).SetResource(icon)
+			row.Objects[2].(*widget.Label).SetText(formatSize(attachment.Length))
+		},
+	)
+
+	var saveButton, openButton, deleteButton *widget.Button
+	showSelected := func() {
+		preview.Resource = nil
+		preview.Refresh()
+		if selected < 0 || selected >= len(attachments) {
+			previewText.SetText("Select a file to preview it.")
+			previewText.Show()
+			saveButton.Disable()
+			openButton.Disable()
+			deleteButton.Disable()
+			return
+		}
+		saveButton.Enable()
+		openButton.Enable()
+		deleteButton.Enable()
+
+		attachment := attachments[selected]
+		if !attachment.IsImage() {
+			previewText.SetText(attachment.FileName + "\n\nNo preview for this kind of file; open it instead.")
+			previewText.Show()
+			return
+		}
+		previewText.SetText("Loading…")
+		go func() {
+			content, err := utils.ReadAttachment(context.Background(), attachment.ID)
+			if err != nil {
+				previewText.SetText("Couldn't load the file: " + err.Error())
+				return
+			}
+			previewText.Hide()
+			preview.Resource = fyne.NewStaticResource(attachment.FileName, content)
+			preview.Refresh()
+		}()
+	}
+	list.OnSelected = func(id widget.ListItemID) {
+		selected = id
+		showSelected()
+	}
+
+	reload := func() {
+		go func() {
+			loaded, err := utils.ListAttachments(context.Background(), recordID)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			attachments = loaded
+			selected = -1
+			list.UnselectAll()
+			list.Refresh()
+			showSelected()
+		}()
+	}
+
+	addButton := widget.NewButtonWithIcon("Add File", theme.ContentAddIcon(), func() {
+		openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if reader == nil {
+				return
+			}
+
+			progress := dialog.NewCustomWithoutButtons("Attaching File", widget.NewProgressBarInfinite(), window)
+			progress.Show()
+			go func() {
+				defer reader.Close()
+				user := utils.GetUserByID(helpers.CurrentUserID, window)
+				attachment, err := utils.AttachFile(context.Background(), strings.ToLower(kind), recordID, reader.URI().Name(), reader, user.Username)
+				progress.Hide()
+				if err != nil {
+					dialog.ShowError(err, window)
+					return
+				}
+				utils.Logger(user.Username+" attached "+attachment.FileName+" to "+kind+" "+title, "SUCCESS", window)
+				reload()
+				onChanged()
+			}()
+		}, window)
+		openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".jpg", ".jpeg", ".gif", ".pdf"}))
+		openDialog.Show()
+	})
+
+	saveButton = widget.NewButtonWithIcon("Save As", theme.DocumentSaveIcon(), func() {
+		attachment := attachments[selected]
+		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if writer == nil {
+				return
+			}
+			go func() {
+				defer writer.Close()
+				content, err := utils.ReadAttachment(context.Background(), attachment.ID)
+				if err == nil {
+					_, err = writer.Write(content)
+				}
+				if err != nil {
+					dialog.ShowError(err, window)
+				}
+			}()
+		}, window)
+		saveDialog.SetFileName(attachment.FileName)
+		saveDialog.Show()
+	})
+
+	openButton = widget.NewButtonWithIcon("Open", theme.MediaPlayIcon(), func() {
+		attachment := attachments[selected]
+		go func() {
+			content, err := utils.ReadAttachment(context.Background(), attachment.ID)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			// hand a copy to the system viewer
+			path := filepath.Join(os.TempDir(), "fynance-"+attachment.ID.Hex()+"-"+filepath.Base(attachment.FileName))
+			if err := os.WriteFile(path, content, 0o600); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			fileURL, err := url.Parse(storage.NewFileURI(path).String())
+			if err == nil {
+				err = fyne.CurrentApp().OpenURL(fileURL)
+			}
+			if err != nil {
+				dialog.ShowError(err, window)
+			}
+		}()
+	})
+
+	deleteButton = widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), func() {
+		attachment := attachments[selected]
+		dialog.ShowConfirm("Delete Attachment", "Delete "+attachment.FileName+"? This can't be undone.", func(ok bool) {
+			if !ok {
+				return
+			}
+			go func() {
+				if err := utils.DeleteAttachment(context.Background(), attachment.ID); err != nil {
+					dialog.ShowError(err, window)
+					return
+				}
+				user := utils.GetUserByID(helpers.CurrentUserID, window)
+				utils.Logger(user.Username+" deleted attachment "+attachment.FileName+" of "+kind+" "+title, "SUCCESS", window)
+				reload()
+				onChanged()
+			}()
+		}, window)
+	})
+	deleteButton.Importance = widget.DangerImportance
+
+	buttons := container.NewGridWithColumns(4, addButton, saveButton, openButton, deleteButton)
+	previewArea := container.NewStack(preview, container.NewCenter(previewText))
+	split := container.NewHSplit(list, previewArea)
+	split.Offset = 0.35
+
+	viewer := dialog.NewCustom("Attachments: "+title, "Close", container.NewBorder(nil, buttons, nil, nil, split), window)
+	viewer.Resize(fyne.NewSize(820, 520))
+	showSelected()
+	viewer.Show()
+	reload()
+}
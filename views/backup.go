@@ -0,0 +1,211 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fynance/backup"
+	"fynance/helpers"
+	"fynance/models"
+	"fynance/utils"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showBackupDialog asks for an optional passphrase and where to save a full
+// database backup, then writes it
+func showBackupDialog(window fyne.Window) {
+	passphraseEntry := widget.NewPasswordEntry()
+	passphraseEntry.SetPlaceHolder("Leave empty for no encryption")
+
+	confirmEntry := widget.NewPasswordEntry()
+	confirmEntry.SetPlaceHolder("Repeat passphrase")
+
+	form := helpers.NewFixedWidthCenter(container.NewVBox(widget.NewForm(
+		&widget.FormItem{Text: "Passphrase", Widget: passphraseEntry},
+		&widget.FormItem{Text: "Confirm", Widget: confirmEntry},
+	)), 400)
+
+	dialog.ShowCustomConfirm("Create Backup", "Choose File", "Cancel", container.NewCenter(form), func(ok bool) {
+		if !ok {
+			return
+		}
+		if passphraseEntry.Text != confirmEntry.Text {
+			dialog.ShowError(errors.New("the passphrases do not match"), window)
+			return
+		}
+		saveBackup(window, passphraseEntry.Text)
+	}, window)
+}
+
+// saveBackup asks for the destination and writes the backup behind a progress dialog
+func saveBackup(window fyne.Window, passphrase string) {
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if writer == nil {
+			return // user cancelled
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		progress := widget.NewProgressBar()
+		progressDialog := dialog.NewCustom("Creating Backup", "Cancel", progress, window)
+		progressDialog.SetOnClosed(cancel)
+		progressDialog.Show()
+
+		go func() {
+			manifest, err := backup.Create(ctx, writer, passphrase, progress.SetValue)
+			closeErr := writer.Close()
+			if err == nil {
+				err = closeErr
+			}
+			progressDialog.Hide()
+
+			if err != nil {
+				storage.Delete(writer.URI())
+				utils.Logger("Backup failed: "+err.Error(), "ERROR", window)
+				dialog.ShowError(err, window)
+				return
+			}
+
+			utils.Logger(fmt.Sprintf("Backup of %d records saved to %s", manifest.TotalDocuments(), writer.URI().Name()), "SUCCESS", window)
+			dialog.ShowInformation("Backup Complete", "Backup saved to "+writer.URI().Path(), window)
+		}()
+	}, window)
+	saveDialog.SetFileName(backup.FileName(time.Now()))
+	saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{backup.FileExtension}))
+	saveDialog.Show()
+}
+
+// showRestoreDialog picks an archive, asking for its passphrase when it is
+// encrypted, before confirming the restore
+func showRestoreDialog(window fyne.Window) {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if reader == nil {
+			return // user cancelled
+		}
+		uri := reader.URI()
+
+		_, err = backup.Inspect(reader, "")
+		reader.Close()
+		if errors.Is(err, backup.ErrPassphraseRequired) {
+			askRestorePassphrase(window, uri)
+			return
+		}
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		confirmRestore(window, uri, "")
+	}, window)
+	openDialog.SetFilter(storage.NewExtensionFileFilter([]string{backup.FileExtension}))
+	openDialog.Show()
+}
+
+// askRestorePassphrase prompts for the passphrase of an encrypted archive
+func askRestorePassphrase(window fyne.Window, uri fyne.URI) {
+	passphraseEntry := widget.NewPasswordEntry()
+	form := helpers.NewFixedWidthCenter(container.NewVBox(widget.NewForm(
+		&widget.FormItem{Text: "Passphrase", Widget: passphraseEntry},
+	)), 400)
+
+	dialog.ShowCustomConfirm("Encrypted Backup", "Open", "Cancel", container.NewCenter(form), func(ok bool) {
+		if ok {
+			confirmRestore(window, uri, passphraseEntry.Text)
+		}
+	}, window)
+}
+
+// confirmRestore shows what restoring the archive would replace and restores
+// it once confirmed
+func confirmRestore(window fyne.Window, uri fyne.URI, passphrase string) {
+	reader, err := storage.Reader(uri)
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+	manifest, err := backup.Inspect(reader, passphrase)
+	reader.Close()
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+
+	current, err := backup.CurrentCounts(context.Background())
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+
+	// Summary of what will be replaced
+	summary := container.NewGridWithColumns(3,
+		widget.NewLabelWithStyle("Collection", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Current", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("In Backup", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+	)
+	for _, collection := range manifest.Collections {
+		summary.Add(widget.NewLabel(collection.Name))
+		summary.Add(widget.NewLabel(fmt.Sprint(current[collection.Name])))
+		summary.Add(widget.NewLabel(fmt.Sprint(collection.Count)))
+	}
+
+	warning := widget.NewLabel(fmt.Sprintf("Backup taken %s.\nAll current data in these collections will be replaced.",
+		manifest.CreatedAt.Local().Format("2006-01-02 15:04:05")))
+
+	content := container.NewVBox(warning, summary)
+	dialog.ShowCustomConfirm("Restore Backup", "Restore", "Cancel", content, func(ok bool) {
+		if ok {
+			runRestore(window, uri, passphrase)
+		}
+	}, window)
+}
+
+// runRestore restores the archive at uri behind a progress dialog
+func runRestore(window fyne.Window, uri fyne.URI, passphrase string) {
+	reader, err := storage.Reader(uri)
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := widget.NewProgressBar()
+	progressDialog := dialog.NewCustom("Restoring Backup", "Cancel", progress, window)
+	progressDialog.SetOnClosed(cancel)
+	progressDialog.Show()
+
+	go func() {
+		defer reader.Close()
+
+		manifest, err := backup.Restore(ctx, reader, passphrase, progress.SetValue)
+		progressDialog.Hide()
+
+		if err != nil {
+			utils.Logger("Restore failed: "+err.Error(), "ERROR", window)
+			dialog.ShowError(fmt.Errorf("restore failed, no data was changed: %w", err), window)
+			return
+		}
+
+		detail := fmt.Sprintf("Restored %d records from %s", manifest.TotalDocuments(), uri.Name())
+		utils.Logger(detail, "SUCCESS", window)
+		utils.AddNotification(models.Notification{
+			UserID:  helpers.CurrentUserID,
+			Message: detail,
+			IsRead:  false,
+		}, window)
+		updateNotificationCount(window)
+
+		dialog.ShowInformation("Restore Complete", detail+".\nPlease log in again if your account changed.", window)
+	}()
+}
@@ -0,0 +1,201 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	"fynance/backup"
+	"fynance/models"
+	"fynance/utils"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// the running backup scheduler, nil when automatic backups are off
+var backupScheduler *backup.Scheduler
+
+// backupIntervals are the choices offered for the time between backups
+var backupIntervals = map[string]int{
+	"Every 6 hours":  6,
+	"Every 12 hours": 12,
+	"Daily":          24,
+	"Weekly":         24 * 7,
+}
+
+// StartBackupScheduler (re)starts automatic backups from the saved settings
+func StartBackupScheduler(window fyne.Window) {
+	StopBackupScheduler()
+
+	settings, err := LoadSettings()
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+	if !settings.BackupEnabled {
+		return
+	}
+
+	schedule := backup.Schedule{
+		Interval:   time.Duration(settings.BackupInterval) * time.Hour,
+		Dir:        settings.BackupDir,
+		Keep:       settings.BackupKeep,
+		AlertAfter: time.Duration(settings.BackupAlertDays) * 24 * time.Hour,
+	}
+	scheduler, err := backup.StartScheduler(schedule, func(result backup.Result) {
+		reportBackupResult(result, settings.BackupAlertDays, window)
+	})
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+	backupScheduler = scheduler
+}
+
+// StopBackupScheduler stops automatic backups, waiting for a running one
+func StopBackupScheduler() {
+	if backupScheduler != nil {
+		backupScheduler.Stop()
+		backupScheduler = nil
+	}
+}
+
+// reportBackupResult logs a scheduler run and notifies every user when a
+// backup failed or is overdue
+func reportBackupResult(result backup.Result, alertDays int, window fyne.Window) {
+	var messages []string
+
+	switch {
+	case result.Err != nil && result.Path != "":
+		// the archive was written, only rotation failed
+		utils.Logger("Automatic backup saved to "+filepath.Base(result.Path)+", removing old backups failed: "+result.Err.Error(), "ERROR", window)
+		messages = append(messages, "Old automatic backups could not be removed: "+result.Err.Error())
+	case result.Err != nil:
+		utils.Logger("Automatic backup failed: "+result.Err.Error(), "ERROR", window)
+		messages = append(messages, "Automatic backup failed: "+result.Err.Error())
+	case result.Path != "":
+		detail := "Automatic backup saved to " + filepath.Base(result.Path)
+		if len(result.Removed) > 0 {
+			detail += fmt.Sprintf(", %d old backup(s) removed", len(result.Removed))
+		}
+		utils.Logger(detail, "SUCCESS", window)
+	}
+
+	if result.Overdue {
+		if result.LastSuccess.IsZero() {
+			messages = append(messages, fmt.Sprintf("No automatic backup has succeeded in the last %d day(s)", alertDays))
+		} else {
+			messages = append(messages, "No automatic backup has succeeded since "+result.LastSuccess.Format("2006-01-02 15:04"))
+		}
+	}
+
+	if len(messages) == 0 {
+		return
+	}
+
+	// Backups cover everyone's data, so every user is told
+	for _, user := range utils.GetAllUsers(window) {
+		for _, message := range messages {
+			utils.AddNotification(models.Notification{
+				UserID:  user.ID,
+				Message: message,
+				IsRead:  false,
+			}, window)
+		}
+	}
+
+	// the header only exists once someone has logged in
+	if notificationCountLabel != nil {
+		updateNotificationCount(window)
+	}
+}
+
+// showBackupScheduleDialog edits the automatic backup settings and restarts
+// the scheduler with them
+func showBackupScheduleDialog(window fyne.Window) {
+	settings, err := LoadSettings()
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+
+	enabledCheck := widget.NewCheck("Back up automatically", nil)
+	enabledCheck.SetChecked(settings.BackupEnabled)
+
+	intervalOptions := []string{"Every 6 hours", "Every 12 hours", "Daily", "Weekly"}
+	intervalSelect := widget.NewSelect(intervalOptions, nil)
+	intervalSelect.SetSelected("Daily")
+	for label, hours := range backupIntervals {
+		if hours == settings.BackupInterval {
+			intervalSelect.SetSelected(label)
+		}
+	}
+
+	dirEntry := widget.NewEntry()
+	dirEntry.SetText(settings.BackupDir)
+	browseButton := widget.NewButton("Browse", func() {
+		dialog.ShowFolderOpen(func(folder fyne.ListableURI, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if folder != nil {
+				dirEntry.SetText(folder.Path())
+			}
+		}, window)
+	})
+
+	keepEntry := widget.NewEntry()
+	keepEntry.SetText(strconv.Itoa(settings.BackupKeep))
+
+	alertEntry := widget.NewEntry()
+	alertEntry.SetText(strconv.Itoa(settings.BackupAlertDays))
+	alertEntry.SetPlaceHolder("0 to never alert")
+
+	form := widget.NewForm(
+		&widget.FormItem{Text: "", Widget: enabledCheck},
+		&widget.FormItem{Text: "Frequency", Widget: intervalSelect},
+		&widget.FormItem{Text: "Folder", Widget: container.NewBorder(nil, nil, nil, browseButton, dirEntry)},
+		&widget.FormItem{Text: "Copies to Keep", Widget: keepEntry},
+		&widget.FormItem{Text: "Alert After (days)", Widget: alertEntry},
+	)
+
+	content := container.NewVBox(form, widget.NewLabel("Set "+backup.PassphraseEnv+" to encrypt automatic backups."))
+
+	dialog.ShowCustomConfirm("Automatic Backups", "Save", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+
+		keep, err := strconv.Atoi(keepEntry.Text)
+		if err != nil || keep < 1 {
+			dialog.ShowError(errors.New("copies to keep must be a whole number of at least 1"), window)
+			return
+		}
+		alertDays, err := strconv.Atoi(alertEntry.Text)
+		if err != nil || alertDays < 0 {
+			dialog.ShowError(errors.New("alert after must be a whole number of days"), window)
+			return
+		}
+		if dirEntry.Text == "" {
+			dialog.ShowError(errors.New("choose a folder for the backups"), window)
+			return
+		}
+
+		settings.BackupEnabled = enabledCheck.Checked
+		settings.BackupInterval = backupIntervals[intervalSelect.Selected]
+		settings.BackupDir = dirEntry.Text
+		settings.BackupKeep = keep
+		settings.BackupAlertDays = alertDays
+
+		if err := SaveSettings(settings); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		StartBackupScheduler(window)
+	}, window)
+}
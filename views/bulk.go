@@ -0,0 +1,177 @@
+package views
+
+import (
+	"context"
+	"fmt"
+	"fynance/helpers"
+	"fynance/models"
+	"fynance/utils"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const unchanged = "Unchanged"
+
+// amountChanges are the choices of the bulk edit form, in AmountChange order
+var amountChanges = []string{unchanged, "Set to", "Add", "Change by %"}
+
+// bulkTarget is what the bulk actions change: incomes or expenses
+type bulkTarget struct {
+	kind       string // Income or Expense
+	categories func() []string
+	update     func(context.Context, []primitive.ObjectID, utils.BulkEdit) (int64, error)
+	remove     func(context.Context, []primitive.ObjectID) (int64, error)
+}
+
+// bulkActions are the buttons acting on the rows selected in a table. They
+// are enabled while rows are selected.
+func bulkActions[T any](window fyne.Window, table *dataTable[T], target bulkTarget) fyne.CanvasObject {
+	editButton := widget.NewButton("Edit Selected", func() {
+		ids := table.SelectedIDs()
+		showBulkEditDialog(window, target, len(ids), func(edit utils.BulkEdit) {
+			runBulk(window, target.kind, "updated", edit.String(), len(ids), func(ctx context.Context) (int64, error) {
+				return target.update(ctx, ids, edit)
+			}, table.Reload)
+		})
+	})
+	deleteButton := widget.NewButton("Delete Selected", func() {
+		ids := table.SelectedIDs()
+		message := fmt.Sprintf("Delete the %d selected %s records? This can't be undone.", len(ids), strings.ToLower(target.kind))
+		dialog.ShowConfirm("Delete Selected", message, func(ok bool) {
+			if !ok {
+				return
+			}
+			runBulk(window, target.kind, "deleted", "", len(ids), func(ctx context.Context) (int64, error) {
+				return target.remove(ctx, ids)
+			}, table.Reload)
+		}, window)
+	})
+	deleteButton.Importance = widget.DangerImportance
+
+	showSelection := func(selected int) {
+		if selected == 0 {
+			editButton.SetText("Edit Selected")
+			deleteButton.SetText("Delete Selected")
+			editButton.Disable()
+			deleteButton.Disable()
+			return
+		}
+		editButton.SetText(fmt.Sprintf("Edit %d Selected", selected))
+		deleteButton.SetText(fmt.Sprintf("Delete %d Selected", selected))
+		editButton.Enable()
+		deleteButton.Enable()
+	}
+	showSelection(0)
+	table.OnSelectionChanged = showSelection
+
+	return container.NewGridWithColumns(2, editButton, deleteButton)
+}
+
+// runBulk runs a bulk change of the selected records in the background, then
+// sends one notification and log entry summing it up
+func runBulk(window fyne.Window, kind, verb, change string, selected int, run func(context.Context) (int64, error), onDone func()) {
+	progress := dialog.NewCustomWithoutButtons("Updating "+kind+" Records", widget.NewProgressBarInfinite(), window)
+	progress.Show()
+
+	go func() {
+		count, err := run(context.Background())
+		progress.Hide()
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		user := utils.GetUserByID(helpers.CurrentUserID, window)
+		detail := fmt.Sprintf("%s %s %d %s records", user.Username, verb, count, strings.ToLower(kind))
+		if change != "" {
+			detail += ": " + change
+		}
+		utils.AddNotification(models.Notification{
+			UserID:  user.ID,
+			Message: detail,
+			IsRead:  false,
+		}, window)
+		updateNotificationCount(window)
+		utils.Logger(detail, "SUCCESS", window)
+
+		onDone()
+		message := fmt.Sprintf("%d %s records %s.", count, strings.ToLower(kind), verb)
+		if skipped := int64(selected) - count; skipped > 0 {
+			message += fmt.Sprintf("\n%d were left as they were, such as reconciled records "+
+				"or amounts that would drop to zero or below.", skipped)
+		}
+		dialog.ShowInformation("Success", message, window)
+	}()
+}
+
+// showBulkEditDialog asks what to change on the selected records
+func showBulkEditDialog(window fyne.Window, target bulkTarget, count int, onConfirm func(utils.BulkEdit)) {
+	categories := target.categories()
+	category := widget.NewSelect(append([]string{unchanged}, categories...), nil)
+	category.SetSelected(unchanged)
+
+	month := widget.NewSelect(append([]string{unchanged}, helpers.Months...), nil)
+	month.SetSelected(unchanged)
+
+	year := widget.NewEntry()
+	year.SetPlaceHolder(unchanged)
+
+	amount := widget.NewEntry()
+	amount.SetPlaceHolder("0")
+	amount.Disable()
+	amountChange := widget.NewSelect(amountChanges, func(choice string) {
+		if choice == unchanged {
+			amount.Disable()
+		} else {
+			amount.Enable()
+		}
+	})
+	amountChange.SetSelected(unchanged)
+
+	form := widget.NewForm(
+		widget.NewFormItem("Category", category),
+		widget.NewFormItem("Month", month),
+		widget.NewFormItem("Year", year),
+		widget.NewFormItem("Amount", container.NewGridWithColumns(2, amountChange, amount)),
+	)
+
+	title := fmt.Sprintf("Edit %d %s Records", count, target.kind)
+	editDialog := dialog.NewCustomConfirm(title, "Apply", "Cancel", form, func(ok bool) {
+		if !ok {
+			return
+		}
+
+		edit := utils.BulkEdit{
+			Year:         strings.TrimSpace(year.Text),
+			AmountChange: utils.AmountChange(slices.Index(amountChanges, amountChange.Selected)),
+		}
+		if category.Selected != unchanged {
+			edit.Category = category.Selected
+		}
+		if month.Selected != unchanged {
+			edit.Month = month.Selected
+		}
+		if edit.AmountChange != utils.AmountKeep {
+			value, err := strconv.ParseFloat(strings.TrimSpace(amount.Text), 64)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("invalid amount %q", amount.Text), window)
+				return
+			}
+			edit.Amount = value
+		}
+		if err := edit.Validate(categories); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		onConfirm(edit)
+	}, window)
+	editDialog.Resize(fyne.NewSize(460, 0))
+	editDialog.Show()
+}
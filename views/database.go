@@ -0,0 +1,226 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fynance/utils"
+	"os"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// databaseForm holds the connection settings entries shared by the setup
+// wizard and the settings dialog
+type databaseForm struct {
+	uri        *widget.Entry
+	database   *widget.Entry
+	authSource *widget.Entry
+	tls        *widget.Check
+	caFile     *widget.Entry
+	timeout    *widget.Entry
+	status     *widget.Label
+	form       *widget.Form
+}
+
+func newDatabaseForm(window fyne.Window, config utils.DBConfig) *databaseForm {
+	f := &databaseForm{
+		uri:        widget.NewEntry(),
+		database:   widget.NewEntry(),
+		authSource: widget.NewEntry(),
+		tls:        widget.NewCheck("Use TLS", nil),
+		caFile:     widget.NewEntry(),
+		timeout:    widget.NewEntry(),
+		status:     widget.NewLabel(""),
+	}
+	f.uri.SetText(config.URI)
+	f.uri.SetPlaceHolder(utils.DefaultMongoURI)
+	f.database.SetText(config.Database)
+	f.authSource.SetText(config.AuthSource)
+	f.authSource.SetPlaceHolder("from the URI, usually admin")
+	f.tls.SetChecked(config.TLS)
+	f.caFile.SetText(config.CAFile)
+	f.caFile.SetPlaceHolder("system certificates")
+	f.timeout.SetText(strconv.Itoa(config.ConnectTimeout))
+	f.status.Wrapping = fyne.TextWrapWord
+
+	browseButton := widget.NewButton("Browse", func() {
+		dialog.ShowFileOpen(func(file fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if file != nil {
+				f.caFile.SetText(file.URI().Path())
+				file.Close()
+			}
+		}, window)
+	})
+
+	f.form = widget.NewForm(
+		&widget.FormItem{Text: "Connection URI", Widget: f.uri},
+		&widget.FormItem{Text: "Database", Widget: f.database},
+		&widget.FormItem{Text: "Auth Source", Widget: f.authSource},
+		&widget.FormItem{Text: "", Widget: f.tls},
+		&widget.FormItem{Text: "CA File", Widget: container.NewBorder(nil, nil, nil, browseButton, f.caFile)},
+		&widget.FormItem{Text: "Timeout (seconds)", Widget: f.timeout},
+	)
+	return f
+}
+
+// config reads the entries into a validated configuration
+func (f *databaseForm) config() (utils.DBConfig, error) {
+	timeout, err := strconv.Atoi(strings.TrimSpace(f.timeout.Text))
+	if err != nil {
+		return utils.DBConfig{}, errors.New("the timeout must be a whole number of seconds")
+	}
+
+	config := utils.DBConfig{
+		URI:            strings.TrimSpace(f.uri.Text),
+		Database:       strings.TrimSpace(f.database.Text),
+		AuthSource:     strings.TrimSpace(f.authSource.Text),
+		TLS:            f.tls.Checked,
+		CAFile:         strings.TrimSpace(f.caFile.Text),
+		ConnectTimeout: timeout,
+	}
+	return config, config.Validate()
+}
+
+// test pings the server with the entered settings in the background,
+// showing the outcome in the status label and passing it to onResult
+func (f *databaseForm) test(onResult func(config utils.DBConfig, err error)) {
+	config, err := f.config()
+	if err != nil {
+		f.status.SetText("✘ " + err.Error())
+		if onResult != nil {
+			onResult(config, err)
+		}
+		return
+	}
+
+	f.status.SetText("Connecting…")
+	go func() {
+		err := utils.TestConnection(context.Background(), config)
+		if err != nil {
+			f.status.SetText("✘ Could not connect: " + err.Error())
+		} else {
+			f.status.SetText("✔ Connected")
+		}
+		if onResult != nil {
+			onResult(config, err)
+		}
+	}()
+}
+
+// envNotice warns that environment variables take precedence over the form
+func envNotice() fyne.CanvasObject {
+	var names []string
+	for _, name := range []string{utils.MongoURIEnv, utils.DatabaseEnv, utils.AuthSourceEnv, utils.TLSEnv, utils.CAFileEnv, utils.ConnectTimeoutEnv} {
+		if os.Getenv(name) != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return widget.NewLabel("")
+	}
+	label := widget.NewLabel("Set in the environment, overriding these settings when Fynance starts: " + strings.Join(names, ", "))
+	label.Wrapping = fyne.TextWrapWord
+	label.Importance = widget.WarningImportance
+	return label
+}
+
+// saveDatabaseConfig stores tested connection settings and reconnects with them
+func saveDatabaseConfig(config utils.DBConfig) error {
+	settings, err := LoadSettings()
+	if err != nil {
+		return err
+	}
+	settings.Database = config
+	settings.DatabaseConfigured = true
+	if err := SaveSettings(settings); err != nil {
+		return err
+	}
+	return utils.ConnectWith(config)
+}
+
+// DatabaseSetupView is the first-run wizard, also shown when the saved
+// connection fails. It only continues to onDone once the connection works.
+func DatabaseSetupView(window fyne.Window, config utils.DBConfig, failure error, onDone func()) fyne.CanvasObject {
+	f := newDatabaseForm(window, config)
+
+	intro := "Welcome to Fynance. Tell it where your MongoDB database is; the connection is tested before it is saved."
+	if failure != nil {
+		intro = "Fynance could not reach the database: " + failure.Error() + "\nCheck the connection settings below."
+	}
+	introLabel := widget.NewLabel(intro)
+	introLabel.Wrapping = fyne.TextWrapWord
+
+	var saveButton *widget.Button
+	saveButton = widget.NewButton("Save and Continue", func() {
+		saveButton.Disable()
+		f.test(func(config utils.DBConfig, err error) {
+			if err == nil {
+				err = saveDatabaseConfig(config)
+			}
+			if err != nil {
+				f.status.SetText("✘ " + err.Error())
+				saveButton.Enable()
+				return
+			}
+			onDone()
+		})
+	})
+	saveButton.Importance = widget.HighImportance
+
+	testButton := widget.NewButton("Test Connection", func() {
+		f.test(nil)
+	})
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle("Database Setup", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		introLabel,
+		f.form,
+		envNotice(),
+		f.status,
+		container.NewGridWithColumns(2, testButton, saveButton),
+	)
+	return container.NewCenter(container.NewGridWrap(fyne.NewSize(560, 480), content))
+}
+
+// showDatabaseDialog edits the connection settings from the settings dialog
+func showDatabaseDialog(window fyne.Window) {
+	settings, err := LoadSettings()
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+
+	f := newDatabaseForm(window, settings.Database)
+	testButton := widget.NewButton("Test Connection", func() {
+		f.test(nil)
+	})
+	content := container.NewVBox(f.form, envNotice(), f.status, testButton)
+
+	databaseDialog := dialog.NewCustomConfirm("Database Connection", "Save", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		f.test(func(config utils.DBConfig, err error) {
+			if err == nil {
+				err = saveDatabaseConfig(config)
+			}
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			utils.Logger("Changed the database connection to "+config.Database, "SUCCESS", window)
+			dialog.ShowInformation("Database Connection", "Connected. Sign in again if you switched to another database.", window)
+		})
+	}, window)
+	databaseDialog.Resize(fyne.NewSize(560, 0))
+	databaseDialog.Show()
+}
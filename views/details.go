@@ -1,17 +1,167 @@
 package views
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"fynance/helpers"
+	"fynance/utils"
+	"slices"
+	"sync"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// noParent is the parent choice of a top-level category
+const noParent = "(none)"
+
 func ParametersView(window fyne.Window, userID primitive.ObjectID) fyne.CanvasObject {
 	header := Header(window)
 	footer := Footer(window)
 	content := container.NewAppTabs(
 		container.NewTabItem("Income", IncomeDetailsView(window, userID)),
 		container.NewTabItem("Expenses", ExpenseDetailsView(window, userID)),
+		container.NewTabItem("Rules", RulesView(window)),
 	)
 	return container.NewBorder(header, footer, nil, nil, content)
 }
+
+// categoryPaths remembers the category tree a table of categories was
+// loaded with, so every row can show its full name
+type categoryPaths struct {
+	mu   sync.Mutex
+	tree utils.CategoryTree
+}
+
+// withTree wraps a table's fetch so the tree is read again with every page
+func withTree[T any](paths *categoryPaths, kind string, window fyne.Window, fetch func(utils.PageRequest) utils.Page[T]) func(utils.PageRequest) utils.Page[T] {
+	return func(req utils.PageRequest) utils.Page[T] {
+		tree := utils.GetCategoryTree(kind, window)
+		paths.mu.Lock()
+		paths.tree = tree
+		paths.mu.Unlock()
+		return fetch(req)
+	}
+}
+
+// path is the full name of the category with id, or name when the tree
+// doesn't know it yet
+func (p *categoryPaths) path(id primitive.ObjectID, name string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if category, ok := p.tree.Find(id); ok {
+		return category.Path
+	}
+	return name
+}
+
+// parentSelect offers the categories the category with id can sit under:
+// any but itself and its subcategories. id is nil for a new category.
+func parentSelect(kind string, id, parentID primitive.ObjectID, window fyne.Window) (*widget.Select, func() primitive.ObjectID) {
+	tree := utils.GetCategoryTree(kind, window)
+	below := tree.Subtree(id)
+
+	choices := []string{noParent}
+	for _, category := range tree.Categories {
+		if !slices.ContainsFunc(below, func(c utils.Category) bool { return c.ID == category.ID }) {
+			choices = append(choices, category.Path)
+		}
+	}
+
+	parent := widget.NewSelect(choices, nil)
+	parent.SetSelected(noParent)
+	if category, ok := tree.Find(parentID); ok {
+		parent.SetSelected(category.Path)
+	}
+	return parent, func() primitive.ObjectID {
+		if parent.Selected == noParent {
+			return primitive.NilObjectID
+		}
+		return tree.IDOf(parent.Selected)
+	}
+}
+
+// deleteCategory deletes the category with id once the user confirms, then
+// calls onDeleted. A category with records offers to move them to another
+// category first; one with subcategories has to have them moved or deleted
+// before it can go.
+func deleteCategory(window fyne.Window, kind string, id primitive.ObjectID, remove func(primitive.ObjectID, fyne.Window) error, onDeleted func()) {
+	finish := func() {
+		if err := remove(id, window); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		onDeleted()
+	}
+
+	go func() {
+		records, subcategories, err := utils.CategoryUsage(context.Background(), kind, id)
+		switch {
+		case err != nil, records == 0 && subcategories == 0:
+			// while offline the deletion is journaled and checked when it is replayed
+			dialog.ShowConfirm("Delete Category", "Are you sure you want to delete this category?", func(ok bool) {
+				if ok {
+					finish()
+				}
+			}, window)
+
+		case subcategories > 0:
+			dialog.ShowInformation("Delete Category",
+				fmt.Sprintf("This category has %d subcategories. Move or delete them first.", subcategories), window)
+
+		default:
+			showReassign(window, kind, id, records, finish)
+		}
+	}()
+}
+
+// showReassign asks for the category to move the records of a category to
+// before it is deleted, then moves them and calls finish
+func showReassign(window fyne.Window, kind string, id primitive.ObjectID, records int64, finish func()) {
+	tree := utils.GetCategoryTree(kind, window)
+	category, _ := tree.Find(id)
+	var choices []string
+	for _, other := range tree.Categories {
+		if other.ID != id {
+			choices = append(choices, other.Path)
+		}
+	}
+	if len(choices) == 0 {
+		dialog.ShowInformation("Delete Category",
+			fmt.Sprintf("%s is used by %d records and there is no other category to move them to.", category.Path, records), window)
+		return
+	}
+
+	target := widget.NewSelect(choices, nil)
+	message := widget.NewLabel(fmt.Sprintf("%s is used by %d records. Choose the category to move them to before it is deleted.", category.Path, records))
+	message.Wrapping = fyne.TextWrapWord
+
+	form := dialog.NewForm("Delete Category", "Move and Delete", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("", message),
+		widget.NewFormItem("Move Records To", target),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		if target.Selected == "" {
+			dialog.ShowError(errors.New("choose the category to move the records to"), window)
+			return
+		}
+		go func() {
+			moved, err := utils.ReassignCategory(context.Background(), kind, id, tree.IDOf(target.Selected))
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			user := utils.GetUserByID(helpers.CurrentUserID, window)
+			utils.Logger(fmt.Sprintf("%s moved %d %s records from %s to %s", user.Username, moved, kind, category.Path, target.Selected), "SUCCESS", window)
+			finish()
+		}()
+	}, window)
+	form.Resize(fyne.NewSize(480, 0))
+	form.Show()
+}
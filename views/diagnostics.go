@@ -0,0 +1,139 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fynance/utils"
+	"strconv"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// EnsureIndexes creates the database indexes in the background, logging
+// any that fail
+func EnsureIndexes(window fyne.Window) {
+	settings, err := LoadSettings()
+	if err != nil {
+		settings = DefaultSettings()
+	}
+	retention := time.Duration(settings.LogRetentionDays) * 24 * time.Hour
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		if err := utils.EnsureIndexes(ctx, retention); err != nil {
+			utils.LogEvent("Creating database indexes failed: "+err.Error(), "ERROR")
+		}
+	}()
+}
+
+// showDiagnosticsDialog explains the main queries and lists index usage
+func showDiagnosticsDialog(window fyne.Window) {
+	queryTable := container.NewVBox(widget.NewLabel("Explaining queries…"))
+	indexTable := container.NewVBox(widget.NewLabel("Loading index statistics…"))
+
+	rebuildButton := widget.NewButton("Create Missing Indexes", func() {
+		EnsureIndexes(window)
+		dialog.ShowInformation("Indexes", "Indexes are being created in the background.", window)
+	})
+
+	// the log TTL index follows the retention setting
+	retentionEntry := widget.NewEntry()
+	if settings, err := LoadSettings(); err == nil {
+		retentionEntry.SetText(strconv.Itoa(settings.LogRetentionDays))
+	}
+	retentionButton := widget.NewButton("Save", func() {
+		days, err := strconv.Atoi(retentionEntry.Text)
+		if err != nil || days < 1 {
+			dialog.ShowError(errors.New("keep logs for a whole number of days, at least 1"), window)
+			return
+		}
+		settings, err := LoadSettings()
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		settings.LogRetentionDays = days
+		if err := SaveSettings(settings); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		EnsureIndexes(window)
+		utils.Logger(fmt.Sprintf("Logs are now kept for %d days", days), "SUCCESS", window)
+	})
+	retentionRow := container.NewBorder(nil, nil, widget.NewLabel("Keep logs for (days)"), retentionButton, retentionEntry)
+
+	load := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		rows := []fyne.CanvasObject{container.NewGridWithColumns(5,
+			bold("Query"), bold("Plan"), bold("Index"), bold("Examined / Returned"), bold("Time"))}
+		for _, query := range utils.DiagnosticQueries() {
+			plan, err := utils.ExplainQuery(ctx, query)
+			if err != nil {
+				rows = append(rows, container.NewGridWithColumns(2, widget.NewLabel(query.Name), widget.NewLabel(err.Error())))
+				continue
+			}
+
+			stage := widget.NewLabel(plan.Stage)
+			index := plan.Index
+			if plan.UsesIndex() {
+				stage.Importance = widget.SuccessImportance
+			} else {
+				stage.Importance = widget.WarningImportance
+				index = "none"
+			}
+			rows = append(rows, container.NewGridWithColumns(5,
+				widget.NewLabel(query.Name),
+				stage,
+				widget.NewLabel(index),
+				widget.NewLabel(fmt.Sprintf("%d docs, %d keys / %d", plan.DocsExamined, plan.KeysExamined, plan.Returned)),
+				widget.NewLabel(strconv.FormatInt(plan.Millis, 10)+" ms"),
+			))
+		}
+		queryTable.Objects = rows
+		queryTable.Refresh()
+
+		usage, err := utils.ListIndexUsage(ctx)
+		if err != nil {
+			indexTable.Objects = []fyne.CanvasObject{widget.NewLabel("Index statistics unavailable: " + err.Error())}
+			indexTable.Refresh()
+			return
+		}
+		rows = []fyne.CanvasObject{container.NewGridWithColumns(4, bold("Collection"), bold("Index"), bold("Keys"), bold("Uses"))}
+		for _, index := range usage {
+			rows = append(rows, container.NewGridWithColumns(4,
+				widget.NewLabel(index.Collection),
+				widget.NewLabel(index.Name),
+				widget.NewLabel(index.Keys),
+				widget.NewLabel(fmt.Sprintf("%d since %s", index.Ops, index.Since.Local().Format("2006-01-02"))),
+			))
+		}
+		indexTable.Objects = rows
+		indexTable.Refresh()
+	}
+	go load()
+
+	content := container.NewVScroll(container.NewVBox(
+		widget.NewLabelWithStyle("Main Queries", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		queryTable,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Indexes", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		indexTable,
+		retentionRow,
+		rebuildButton,
+	))
+	diagnosticsDialog := dialog.NewCustom("Database Diagnostics", "Close", content, window)
+	diagnosticsDialog.Resize(fyne.NewSize(900, 550))
+	diagnosticsDialog.Show()
+}
+
+func bold(text string) *widget.Label {
+	return widget.NewLabelWithStyle(text, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+}
@@ -0,0 +1,180 @@
+package views
+
+import (
+	"context"
+	"fmt"
+	"fynance/models"
+	"fynance/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// duplicateWindows are the choices of how close together duplicates were
+// created, zero for any time
+var duplicateWindows = []struct {
+	label  string
+	window time.Duration
+}{
+	{"Within an hour", time.Hour},
+	{"Within a day", 24 * time.Hour},
+	{"Within a week", 7 * 24 * time.Hour},
+	{"Within 30 days", 30 * 24 * time.Hour},
+	{"Any time", 0},
+}
+
+// duplicateTarget is what the duplicate review looks through: incomes or
+// expenses
+type duplicateTarget[T any] struct {
+	kind   string // Income or Expense
+	find   func(context.Context, utils.DuplicateOptions) ([]utils.DuplicateGroup[T], error)
+	row    func(T) transactionRow
+	id     func(T) primitive.ObjectID
+	batch  func(T) primitive.ObjectID // the import that added the record
+	remove func(primitive.ObjectID, fyne.Window) error
+}
+
+// showDuplicateReview finds groups of likely duplicates and lets the user pick
+// the record to keep in each. The others are deleted the same way as from
+// the list, one by one, and onChanged is called after each merge.
+func showDuplicateReview[T any](window fyne.Window, userID primitive.ObjectID, target duplicateTarget[T], onChanged func()) {
+	tolerance := widget.NewEntry()
+	tolerance.SetText(strconv.FormatFloat(utils.DefaultDuplicateOptions.Tolerance, 'f', 2, 64))
+
+	var windowLabels []string
+	for _, choice := range duplicateWindows {
+		windowLabels = append(windowLabels, choice.label)
+	}
+	created := widget.NewSelect(windowLabels, nil)
+	created.SetSelected(duplicateWindows[1].label)
+
+	results := container.NewVBox()
+	status := widget.NewLabel("")
+
+	var find func()
+	find = func() {
+		opts := utils.DuplicateOptions{}
+		value, err := strconv.ParseFloat(strings.TrimSpace(tolerance.Text), 64)
+		if err != nil || value < 0 {
+			dialog.ShowError(fmt.Errorf("invalid amount tolerance %q", tolerance.Text), window)
+			return
+		}
+		opts.Tolerance = value
+		for _, choice := range duplicateWindows {
+			if choice.label == created.Selected {
+				opts.Window = choice.window
+			}
+		}
+
+		results.RemoveAll()
+		status.SetText("Looking for duplicates…")
+		go func() {
+			groups, err := target.find(context.Background(), opts)
+			if err != nil {
+				status.SetText("")
+				dialog.ShowError(err, window)
+				return
+			}
+
+			for _, group := range groups {
+				results.Add(duplicateCard(window, userID, target, group, func(card fyne.CanvasObject) {
+					results.Remove(card)
+					status.SetText(fmt.Sprintf("%d groups of likely duplicates left", len(results.Objects)))
+					onChanged()
+				}))
+			}
+			if len(groups) == 0 {
+				status.SetText("No likely duplicates found.")
+			} else {
+				status.SetText(fmt.Sprintf("%d groups of likely duplicates. Pick the record to keep in each.", len(groups)))
+			}
+		}()
+	}
+
+	findButton := widget.NewButton("Find Duplicates", find)
+	findButton.Importance = widget.HighImportance
+	options := widget.NewForm(
+		widget.NewFormItem("Amounts Within", tolerance),
+		widget.NewFormItem("Created", created),
+	)
+
+	top := container.NewVBox(options, findButton, status)
+	content := container.NewBorder(top, nil, nil, nil, container.NewVScroll(results))
+
+	review := dialog.NewCustom("Duplicate "+target.kind+" Records", "Close", content, window)
+	review.Resize(fyne.NewSize(720, 560))
+	review.Show()
+	find()
+}
+
+// duplicateCard shows one group with a choice of the record to keep. onMerged
+// is given the card once the other records are deleted.
+func duplicateCard[T any](window fyne.Window, userID primitive.ObjectID, target duplicateTarget[T], group utils.DuplicateGroup[T], onMerged func(fyne.CanvasObject)) fyne.CanvasObject {
+	var choices []string
+	for i, item := range group.Items {
+		row := target.row(item)
+		source := "entered by hand"
+		if !target.batch(item).IsZero() {
+			source = "imported"
+		}
+		choices = append(choices, fmt.Sprintf("%d. %s · added %s · %s", i+1,
+			strconv.FormatFloat(row.Amount, 'f', -1, 64), row.CreatedAt.Format("2006-01-02 15:04"), source))
+	}
+	keep := widget.NewRadioGroup(choices, nil)
+	keep.Required = true
+	keep.SetSelected(choices[0])
+
+	var card *widget.Card
+	mergeButton := widget.NewButton(fmt.Sprintf("Keep Selected, Delete %d Others", len(group.Items)-1), func() {
+		kept := 0
+		for i, choice := range choices {
+			if choice == keep.Selected {
+				kept = i
+			}
+		}
+		message := fmt.Sprintf("Keep record %d and delete the other %d %s records?", kept+1, len(group.Items)-1, strings.ToLower(target.kind))
+		dialog.ShowConfirm("Merge Duplicates", message, func(ok bool) {
+			if !ok {
+				return
+			}
+			go func() {
+				user := utils.GetUserByID(userID, window)
+				deleted := 0
+				for i, item := range group.Items {
+					if i == kept {
+						continue
+					}
+					if err := target.remove(target.id(item), window); err != nil {
+						dialog.ShowError(err, window)
+						break
+					}
+					deleted++
+					detail := user.Username + " deleted " + target.kind + " " + group.Category + " as a duplicate of " + target.id(group.Items[kept]).Hex()
+					utils.Logger(detail, "SUCCESS", window)
+				}
+				if deleted == 0 {
+					return
+				}
+
+				utils.AddNotification(models.Notification{
+					UserID:  user.ID,
+					Message: fmt.Sprintf("%s merged %d duplicate %s records of %s", user.Username, deleted+1, strings.ToLower(target.kind), group.Category),
+					IsRead:  false,
+				}, window)
+				updateNotificationCount(window)
+				onMerged(card)
+			}()
+		}, window)
+	})
+
+	title := group.Category
+	subtitle := strings.TrimSpace(group.Month+" "+group.Year) + fmt.Sprintf(" · %d records", len(group.Items))
+	card = widget.NewCard(title, subtitle, container.NewVBox(keep, mergeButton))
+	return card
+}
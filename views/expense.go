@@ -1,14 +1,11 @@
 package views
 
 import (
-	"encoding/csv"
-	"fmt"
 	"fynance/helpers"
 	"fynance/models"
 	"fynance/utils"
-	"math"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -17,316 +14,161 @@ import (
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var expenseList *widget.List
-
 func ExpenseView(window fyne.Window, userID primitive.ObjectID) fyne.CanvasObject {
 	// Load the settings on app startup
 	settings, err := LoadSettings()
 	if err != nil {
 		dialog.ShowInformation("User Settings", "Error loading settings", window)
+		settings = DefaultSettings()
 	}
 
-	pageSize, err := strconv.Atoi(settings.PageSize) // Number of expenses per page
-
-	if err != nil {
-		dialog.ShowError(err, window)
-	}
-
-	var expenses []models.Expense
-	var currentPage int = 1
-	var totalExpenses int64 = 0
-	var pageLabel *widget.Label
-	var prevButton, nextButton *widget.Button
-	var searchResults []models.Expense
-	var searchEntry *widget.Entry
-	var noResultsLabel *widget.Label
+	var expenseTable *dataTable[models.Expense]
 
 	header := Header(window)
 	footer := Footer(window)
 
-	// Update visibility of no results label
-	updateNoResultsLabel := func() {
-		if len(expenses) == 0 {
-			noResultsLabel.Show()
-		} else {
-			noResultsLabel.Hide()
+	var reloadTags func()
+	updateExpenseList := func() {
+		expenseTable.Reload()
+		reloadTags()
+	}
+
+	editExpense := func(expense models.Expense) {
+		if isReconciled(window, "Expense", expense.ReconciliationID) {
+			return
 		}
+		showExpenseForm(window, &expense, userID, updateExpenseList)
 	}
 
-	// Load expenses for the specified page
-	loadExpenses := func(page int) {
-		// Show progress bar dialog
-		progress := widget.NewProgressBar()
-		progress.SetValue(0)
-		progressDialog := dialog.NewCustom("Loading Expenses", "Cancel", progress, window)
-		progressDialog.Show()
-		// Check if search is active
-		go func() {
-			if searchEntry.Text != "" {
-				// Use filtered expenses when a search query is active
-				expenses = searchResults
-				totalExpenses = int64(len(expenses))
-			} else {
-				// Use all expenses for normal pagination
-				expenses = utils.GetExpensesPaginated(page, pageSize, window, func(progressValue float64) {
-					progress.SetValue(progressValue)
-				})
-				totalExpenses = utils.CountExpenses(window)
-			}
+	//delete expense button
+	deleteExpense := func(expense models.Expense) {
+		if isReconciled(window, "Expense", expense.ReconciliationID) {
+			return
+		}
+		dialog.ShowConfirm("Delete Expense", "Are you sure you want to delete this expense?",
+			func(ok bool) {
+				if ok {
+					err := utils.DeleteExpense(expense.ID, window)
+
+					if err != nil {
+						dialog.ShowError(err, window)
+					} else {
+						// Create a new notification
+						// fetch user by ID
+						var user = utils.GetUserByID(userID, window)
+						newNotification := models.Notification{
+							UserID:  user.ID,
+							Message: user.Username + " deleted Expense " + expense.Category,
+							IsRead:  false,
+						}
 
-			expenseList.Refresh()
+						utils.AddNotification(newNotification, window)
 
-			// Enable or disable pagination buttons based on the current page and total pages
-			totalPages := int(math.Ceil(float64(totalExpenses) / float64(pageSize)))
+						//utils.PlayNotificationSound(window)
 
-			// Update page label
-			pageLabel.SetText(fmt.Sprintf("Page %d of %d", currentPage, totalPages))
+						updateNotificationCount(window)
 
-			updateNoResultsLabel()
+						detail := user.Username + " deleted Expense " + expense.Category
+						utils.Logger(detail, "SUCCESS", window)
+						updateExpenseList()
+						dialog.ShowInformation("Success", "Expense deleted successfully!", window)
+					}
 
-			prevButton.Disable()
-			nextButton.Disable()
-			if currentPage > 1 {
-				prevButton.Enable()
-			}
-			if currentPage < totalPages {
-				nextButton.Enable()
-			}
-			progress.SetValue(1.0) // Complete progress
-			progressDialog.Hide()
-		}()
+				}
+			}, window)
 	}
 
-	updateExpenseList := func() {
-		loadExpenses(currentPage)
-		updateNoResultsLabel()
+	// receipts and invoices kept with the expenses
+	attachments := newAttachmentIndex()
+	expenseID := func(expense models.Expense) primitive.ObjectID { return expense.ID }
+	showExpenseAttachments := func(expense models.Expense) {
+		showAttachments(window, "Expense", expense.ID, expense.Category+" "+expense.Month+" "+expense.Year, updateExpenseList)
 	}
 
-	// Header Row with Titles
-	titleRow := container.NewGridWithColumns(5,
-		widget.NewLabelWithStyle("Category", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		widget.NewLabelWithStyle("Month", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		widget.NewLabelWithStyle("Year", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		widget.NewLabelWithStyle("Amount", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		widget.NewLabelWithStyle("Actions", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-	)
-
-	// Create the expenses list
-	expenseList = widget.NewList(
-		func() int {
-			return len(expenses)
-		},
-		func() fyne.CanvasObject {
-			// category label
-			categoryLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{})
-			categoryLabel.Truncation = fyne.TextTruncation(fyne.TextTruncateEllipsis)
-
-			// month  label
-			monthLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{})
-			monthLabel.Truncation = fyne.TextTruncation(fyne.TextTruncateEllipsis)
-
-			// year label
-			yearLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{})
-
-			// amount label
-			amountLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{})
-			amountLabel.Truncation = fyne.TextTruncation(fyne.TextTruncateEllipsis)
-
-			editButton := widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), nil)
-			deleteButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), nil)
-
-			row := container.NewGridWithColumns(5,
-				categoryLabel,
-				monthLabel,
-				yearLabel,
-				amountLabel,
-				container.NewHBox(editButton, deleteButton),
-			)
-			return row
-		},
-		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			expense := expenses[id]
-			row := obj.(*fyne.Container)
-
-			// Retrieve the components in the row
-			categoryLabel := row.Objects[0].(*widget.Label)
-			monthLabel := row.Objects[1].(*widget.Label)
-			yearLabel := row.Objects[2].(*widget.Label)
-			amountLabel := row.Objects[3].(*widget.Label)
-
-			editButton := row.Objects[4].(*fyne.Container).Objects[0].(*widget.Button)
-			deleteButton := row.Objects[4].(*fyne.Container).Objects[1].(*widget.Button)
-
-			categoryLabel.SetText(expense.Category)
-			monthLabel.SetText(expense.Month)
-			yearLabel.SetText(expense.Year)
-
-			// amount to string
-			//amount_string := strconv.Itoa(int(expense.Amount))
-			amount_string := strconv.FormatFloat(expense.Amount, 'f', -1, 64)
-			amountLabel.SetText(amount_string)
-
-			editButton.OnTapped = func() {
-				showExpenseForm(window, &expense, userID, updateExpenseList)
-			}
-
-			//delete expense button
-			deleteButton.OnTapped = func() {
-				dialog.ShowConfirm("Delete Expense", "Are you sure you want to delete this expense?",
-					func(ok bool) {
-						if ok {
-							err = utils.DeleteExpense(expense.ID, window)
-
-							if err != nil {
-								dialog.ShowError(err, window)
-							} else {
-								// Create a new notification
-								// fetch user by ID
-								var user = utils.GetUserByID(userID, window)
-								newNotification := models.Notification{
-									UserID:  user.ID,
-									Message: user.Username + " deleted Expense " + expense.Category,
-									IsRead:  false,
-								}
-
-								utils.AddNotification(newNotification, window)
-
-								//utils.PlayNotificationSound(window)
-
-								updateNotificationCount(window)
-
-								detail := user.Username + " deleted Expense " + expense.Category
-								utils.Logger(detail, "SUCCESS", window)
-								updateExpenseList()
-								dialog.ShowInformation("Success", "Expense deleted successfully!", window)
-							}
-
-						}
-					}, window)
-			}
+	// Create the expenses table
+	expenseTable = newDataTable(
+		append(transactionColumns(func(expense models.Expense) transactionRow {
+			return transactionRow{expense.Category, expense.Month, expense.Year, expense.Amount, expense.CreatedAt, expense.Payee, expense.Tags}
+		}), attachmentColumn(attachments, expenseID)),
+		[]tableAction[models.Expense]{
+			{Icon: theme.MailAttachmentIcon(), Tapped: showExpenseAttachments},
+			{Icon: theme.DocumentCreateIcon(), Tapped: editExpense},
+			{Icon: theme.DeleteIcon(), Tapped: deleteExpense},
 		},
+		int(settings.PageSize),
+		expenseID,
+		withAttachments(attachments, expenseID, func(req utils.PageRequest) utils.Page[models.Expense] {
+			return utils.GetExpensesPaginated(req, window)
+		}),
 	)
 
-	// Pagination controls
-	pagination := container.NewHBox()
-	prevButton = widget.NewButton("Prev", func() {
-		if currentPage > 1 {
-			currentPage--
-			updateExpenseList()
-		}
-	})
-	nextButton = widget.NewButton("Next", func() {
-		if int(math.Ceil(float64(totalExpenses)/float64(pageSize))) > currentPage {
-			currentPage++
-			updateExpenseList()
-		}
-	})
-
-	// Initialize page label
-	pageLabel = widget.NewLabel(fmt.Sprintf("Page %d of %d", currentPage, int(math.Ceil(float64(totalExpenses)/float64(pageSize)))))
-
-	// Add buttons and label to the pagination container
-	pagination.Add(prevButton)
-	pagination.Add(pageLabel)
-	pagination.Add(nextButton)
-
-	// Center the pagination controls
-	pagination = container.NewCenter(pagination)
-
 	addExpenseButton := widget.NewButton("Add Expense", func() {
 		showExpenseForm(window, nil, userID, updateExpenseList)
 	})
 
 	// Search functionality
-	searchEntry = widget.NewEntry()
-	searchEntry.SetPlaceHolder("Search by category/month...")
-	searchButton := widget.NewButtonWithIcon("", theme.SearchIcon(), func() {
-		searchText := searchEntry.Text
-		if searchText != "" {
-			searchResults = utils.SearchExpenses(searchText, window)
-			updateNoResultsLabel()
-			currentPage = 1 // Reset to first page of search results
-			updateExpenseList()
-		} else {
-			// If search is cleared, reset the pagination and expense list
-			searchResults = nil
-			currentPage = 1
-			updateExpenseList()
-		}
+	var searchFilter, tagFilter bson.M
+	applyFilters := func() {
+		expenseTable.SetFilter(utils.AndFilters(searchFilter, tagFilter))
+	}
+	search := searchBar(window, transactionSearch(func() []string {
+		return utils.GetCategoryTree("expense", window).Paths()
+	}), func(filter bson.M) {
+		searchFilter = filter
+		applyFilters()
 	})
 
-	// enter key to search expenses
-	searchEntry.OnSubmitted = func(s string) {
-		searchButton.OnTapped()
-	}
+	// narrow the list down to one tag
+	var tags *widget.Select
+	tags, reloadTags = tagSelect(window, utils.ListExpenseTags, func(tag string) {
+		tagFilter = nil
+		if tag != "" {
+			tagFilter = utils.TagFilter(tag)
+		}
+		applyFilters()
+	})
 
 	// Define functions for exporting data
 	exportToCSV := widget.NewButton("export to csv", func() {
-		expenses := utils.GetAllExpenses(window)
-
-		if len(expenses) != 0 {
-			// Create progress dialog
-			progress := widget.NewProgressBar()
-			progressDialog := dialog.NewCustom("Exporting Expenses", "Cancel", progress, window)
-			progressDialog.Show()
-
-			go func() {
-				file, err := os.Create("expenses.csv")
-				if err != nil {
-					dialog.ShowError(err, window)
-					return
-				}
-				defer file.Close()
-
-				writer := csv.NewWriter(file)
-				defer writer.Flush()
-
-				// Write header
-				writer.Write([]string{"Category", "Month", "Year", "Amount"})
-
-				// Write expense data
-				for i, expense := range expenses {
-					amount_string := strconv.Itoa(int(expense.Amount))
-					writer.Write([]string{
-						expense.Category,
-						expense.Month,
-						expense.Year,
-						amount_string,
-					})
-
-					// Update progress
-					progress.SetValue(float64(i+1) / float64(len(expenses)))
-				}
-
-				// Close progress dialog after exporting
-				progressDialog.Hide()
-				dialog.ShowInformation("Export Successful", "Expenses have been exported to expenses.csv", window)
-			}()
-		} else {
-			dialog.ShowInformation("Export Failed", "No data to export", window)
-		}
+		categories := utils.GetCategoryTree("expense", window).Paths()
+		showExportDialog(window, "Exporting Expenses", "expenses.csv", ".csv", categories, utils.WriteExpensesCSV)
 	})
 
-	// the search entry and bulk upload button
-	searchContainer := container.New(layout.NewGridLayout(2), searchEntry, searchButton)
-
-	// No results label
-	noResultsLabel = widget.NewLabel("No results found")
-	noResultsLabel.Hide() // Hide by default
+	// review likely duplicates and keep one of each
+	duplicatesButton := widget.NewButton("Find Duplicates", func() {
+		showDuplicateReview(window, userID, duplicateTarget[models.Expense]{
+			kind: "Expense",
+			find: utils.FindDuplicateExpenses,
+			row: func(expense models.Expense) transactionRow {
+				return transactionRow{expense.Category, expense.Month, expense.Year, expense.Amount, expense.CreatedAt, expense.Payee, expense.Tags}
+			},
+			id:     func(expense models.Expense) primitive.ObjectID { return expense.ID },
+			batch:  func(expense models.Expense) primitive.ObjectID { return expense.ImportBatch },
+			remove: utils.DeleteExpense,
+		}, updateExpenseList)
+	})
 
-	// Load the initial set of expenses
-	updateExpenseList()
+	// the search bar and tag filter
+	searchContainer := container.NewBorder(nil, nil, nil, tags, search)
 
 	// grid for the add expense and export expenses button
-	exportButtonContainer := container.New(layout.NewGridLayout(2), addExpenseButton, exportToCSV)
+	exportButtonContainer := container.New(layout.NewGridLayout(3), addExpenseButton, duplicatesButton, exportToCSV)
 
-	// Define the container for the list with pagination controls
-	listContainer := container.NewBorder(titleRow, nil, nil, nil, expenseList, noResultsLabel)
+	// actions on the selected expenses
+	selectionActions := bulkActions(window, expenseTable, bulkTarget{
+		kind: "Expense",
+		categories: func() []string {
+			return utils.GetCategoryTree("expense", window).Paths()
+		},
+		update: utils.BulkUpdateExpenses,
+		remove: utils.BulkDeleteExpenses,
+	})
 
-	listWrapper := container.NewBorder(exportButtonContainer, pagination, nil, nil, listContainer)
+	listWrapper := container.NewBorder(container.NewVBox(exportButtonContainer, selectionActions), nil, nil, nil, expenseTable.Widget())
 
 	// Return the final container with all elements
 	return container.NewBorder(header, footer, nil, nil, container.NewBorder(searchContainer, nil, nil, nil, listWrapper))
@@ -343,14 +185,8 @@ func showExpenseForm(window fyne.Window, existing *models.Expense, UserID primit
 	if isEdit {
 		expense = *existing
 	}
-	// get the expense categories
-	expense_categories := utils.GetAllExpenseDetails(window)
-
-	var expenseCategories []string
-	for _, category := range expense_categories {
-		// display available expense categories
-		expenseCategories = append(expenseCategories, category.ExpenseCategory)
-	}
+	// get the expense categories by their full names, such as "Transport > Fuel"
+	expenseCategories := utils.GetCategoryTree("expense", window).Paths()
 
 	// Initialize form fields
 	category := widget.NewSelect(expenseCategories, func(s string) {
@@ -374,6 +210,22 @@ func showExpenseForm(window fyne.Window, existing *models.Expense, UserID primit
 	amount := widget.NewEntry()
 	amount.SetText(string_amount)
 
+	payee := widget.NewEntry()
+	payee.SetPlaceHolder("Optional")
+	payee.SetText(expense.Payee)
+
+	notes := widget.NewMultiLineEntry()
+	notes.SetPlaceHolder("Optional, e.g. an invoice number")
+	notes.SetText(expense.Notes)
+	notes.SetMinRowsVisible(3)
+
+	// the account the money went out of
+	account, accountID := accountSelect(window, expense.AccountID)
+
+	tags := widget.NewEntry()
+	tags.SetPlaceHolder("Comma separated, e.g. travel, client-x")
+	tags.SetText(strings.Join(expense.Tags, ", "))
+
 	// Create form
 	form := &widget.Form{
 		Items: []*widget.FormItem{
@@ -381,6 +233,10 @@ func showExpenseForm(window fyne.Window, existing *models.Expense, UserID primit
 			{Text: "Month", Widget: month},
 			{Text: "Year", Widget: year},
 			{Text: "Amount", Widget: amount},
+			{Text: "Payee", Widget: payee},
+			{Text: "Notes", Widget: notes},
+			{Text: "Account", Widget: account},
+			{Text: "Tags", Widget: tags},
 		},
 		OnSubmit: func() {
 			expense.Category = category.Selected
@@ -390,6 +246,10 @@ func showExpenseForm(window fyne.Window, existing *models.Expense, UserID primit
 			amount_float64, _ := strconv.ParseFloat(amount.Text, 64)
 
 			expense.Amount = amount_float64
+			expense.Payee = strings.TrimSpace(payee.Text)
+			expense.Notes = strings.TrimSpace(notes.Text)
+			expense.Tags = helpers.ParseTags(tags.Text)
+			expense.AccountID = accountID()
 
 			if expense.Month == "" || expense.Year == "" || expense.Category == "" || amount.Text == "" {
 				dialog.ShowInformation("Expense", "All fields are required", window)
@@ -438,6 +298,7 @@ func showExpenseForm(window fyne.Window, existing *models.Expense, UserID primit
 					return
 				}
 				expense.CreatedAt = parsedTime
+				expense.CreatedBy = helpers.CurrentUserID
 
 				err = utils.AddExpense(expense, window)
 
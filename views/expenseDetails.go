@@ -1,12 +1,11 @@
 package views
 
 import (
-	"fmt"
+	"context"
 	"fynance/helpers"
 	"fynance/models"
 	"fynance/utils"
-	"math"
-	"strconv"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -18,204 +17,86 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var expenseDetailList *widget.List
-
 func ExpenseDetailsView(window fyne.Window, userID primitive.ObjectID) fyne.CanvasObject {
 	// Load the settings on app startup
 	settings, err := LoadSettings()
 	if err != nil {
 		dialog.ShowInformation("User Settings", "Error loading settings", window)
+		settings = DefaultSettings()
 	}
 
-	pageSize, err := strconv.Atoi(settings.PageSize) // Number of details per page
+	var detailTable *dataTable[models.ExpenseDetail]
+	paths := &categoryPaths{}
 
-	if err != nil {
-		dialog.ShowError(err, window)
+	updateExpenseDetailList := func() {
+		detailTable.Reload()
 	}
 
-	var expense_details []models.ExpenseDetail
-	var currentPage int = 1
-	var totalExpenseDetails int64 = 0
-	var pageLabel *widget.Label
-	var prevButton, nextButton *widget.Button
-	var searchResults []models.ExpenseDetail
-	var searchEntry *widget.Entry
-	var noResultsLabel *widget.Label
-
-	// Update visibility of no results label
-	updateNoResultsLabel := func() {
-		if len(expense_details) == 0 {
-			noResultsLabel.Show()
-		} else {
-			noResultsLabel.Hide()
-		}
+	editDetail := func(detail models.ExpenseDetail) {
+		showExpenseDetailForm(window, &detail, userID, updateExpenseDetailList)
 	}
 
-	// Load expense_details for the specified page
-	loadExpenseDetails := func(page int) {
-		// Check if search is active
-		go func() {
-			if searchEntry.Text != "" {
-				// Use filtered expense_details when a search query is active
-				expense_details = searchResults
-				totalExpenseDetails = int64(len(expense_details))
-			} else {
-				// Use all expense_details for normal pagination
-				expense_details = utils.GetExpenseDetailsPaginated(page, pageSize, window)
-
-				totalExpenseDetails = utils.CountExpenseDetails(window)
+	//delete detail button
+	deleteDetail := func(detail models.ExpenseDetail) {
+		path := paths.path(detail.ID, detail.ExpenseCategory)
+		deleteCategory(window, "expense", detail.ID, utils.DeleteExpenseDetail, func() {
+			// Create a new notification
+			// fetch user by ID
+			var user = utils.GetUserByID(userID, window)
+			newNotification := models.Notification{
+				UserID:  user.ID,
+				Message: user.Username + " Deleted " + path,
+				IsRead:  false,
 			}
 
-			expenseDetailList.Refresh()
-
-			// Enable or disable pagination buttons based on the current page and total pages
-			totalPages := int(math.Ceil(float64(totalExpenseDetails) / float64(pageSize)))
-
-			// Update page label
-			pageLabel.SetText(fmt.Sprintf("Page %d of %d", currentPage, totalPages))
+			utils.AddNotification(newNotification, window)
 
-			updateNoResultsLabel()
+			//utils.PlayNotificationSound(window)
 
-			prevButton.Disable()
-			nextButton.Disable()
-			if currentPage > 1 {
-				prevButton.Enable()
-			}
-			if currentPage < totalPages {
-				nextButton.Enable()
-			}
-		}()
-	}
+			updateNotificationCount(window)
 
-	updateExpenseDetailList := func() {
-		loadExpenseDetails(currentPage)
-		updateNoResultsLabel()
+			detail := user.Username + " Deleted " + path
+			utils.Logger(detail, "SUCCESS", window)
+			updateExpenseDetailList()
+			dialog.ShowInformation("Success", "expense Detail deleted successfully!", window)
+		})
 	}
 
-	// Header Row with Titles
-	titleRow := container.NewGridWithColumns(2,
-		widget.NewLabelWithStyle("Categories", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		widget.NewLabelWithStyle("Actions", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-	)
-
-	// Create the expense_details list
-	expenseDetailList = widget.NewList(
-		func() int {
-			return len(expense_details)
+	// Create the details table
+	detailTable = newDataTable(
+		[]tableColumn[models.ExpenseDetail]{
+			{Title: "Category", Field: "expense_category", Width: 300, Text: func(detail models.ExpenseDetail) string {
+				return paths.path(detail.ID, detail.ExpenseCategory)
+			}},
+			{Title: "Added", Field: "created_at", Width: 170, Text: func(detail models.ExpenseDetail) string {
+				return detail.CreatedAt.Format("2006-01-02 15:04")
+			}},
 		},
-		func() fyne.CanvasObject {
-
-			// expense category label
-			expenseCategLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{})
-			expenseCategLabel.Truncation = fyne.TextTruncation(fyne.TextTruncateEllipsis)
-
-			editButton := widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), nil)
-			deleteButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), nil)
-
-			row := container.NewGridWithColumns(2,
-				expenseCategLabel,
-				container.NewHBox(editButton, deleteButton),
-			)
-			return row
-		},
-		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			expense_detail := expense_details[id]
-			row := obj.(*fyne.Container)
-
-			// Retrieve the components in the row
-			expenseCategLabel := row.Objects[0].(*widget.Label)
-
-			editButton := row.Objects[1].(*fyne.Container).Objects[0].(*widget.Button)
-			deleteButton := row.Objects[1].(*fyne.Container).Objects[1].(*widget.Button)
-
-			expenseCategLabel.SetText(expense_detail.ExpenseCategory)
-
-			editButton.OnTapped = func() {
-				showExpenseDetailForm(window, &expense_detail, userID, updateExpenseDetailList)
-			}
-
-			//delete detail button
-			deleteButton.OnTapped = func() {
-				dialog.ShowConfirm("Delete expense Detail", "Are you sure you want to delete this detail?",
-					func(ok bool) {
-						if ok {
-							err = utils.DeleteExpenseDetail(expense_detail.ID, window)
-
-							if err != nil {
-								dialog.ShowError(err, window)
-							} else {
-								// Create a new notification
-								// fetch user by ID
-								var user = utils.GetUserByID(userID, window)
-								newNotification := models.Notification{
-									UserID:  user.ID,
-									Message: user.Username + " Deleted " + expense_detail.ExpenseCategory,
-									IsRead:  false,
-								}
-
-								utils.AddNotification(newNotification, window)
-
-								//utils.PlayNotificationSound(window)
-
-								updateNotificationCount(window)
-
-								detail := user.Username + " Deleted " + expense_detail.ExpenseCategory
-								utils.Logger(detail, "SUCCESS", window)
-								updateExpenseDetailList()
-								dialog.ShowInformation("Success", "expense Detail deleted successfully!", window)
-							}
-
-						}
-					}, window)
-			}
+		[]tableAction[models.ExpenseDetail]{
+			{Icon: theme.DocumentCreateIcon(), Tapped: editDetail},
+			{Icon: theme.DeleteIcon(), Tapped: deleteDetail},
 		},
+		int(settings.PageSize),
+		func(detail models.ExpenseDetail) primitive.ObjectID { return detail.ID },
+		withTree(paths, "expense", window, func(req utils.PageRequest) utils.Page[models.ExpenseDetail] {
+			return utils.GetExpenseDetailsPaginated(req, window)
+		}),
 	)
 
-	// Pagination controls
-	pagination := container.NewHBox()
-	prevButton = widget.NewButton("Prev", func() {
-		if currentPage > 1 {
-			currentPage--
-			updateExpenseDetailList()
-		}
-	})
-	nextButton = widget.NewButton("Next", func() {
-		if int(math.Ceil(float64(totalExpenseDetails)/float64(pageSize))) > currentPage {
-			currentPage++
-			updateExpenseDetailList()
-		}
-	})
-
-	// Initialize page label
-	pageLabel = widget.NewLabel(fmt.Sprintf("Page %d of %d", currentPage, int(math.Ceil(float64(totalExpenseDetails)/float64(pageSize)))))
-
-	// Add buttons and label to the pagination container
-	pagination.Add(prevButton)
-	pagination.Add(pageLabel)
-	pagination.Add(nextButton)
-
-	// Center the pagination controls
-	pagination = container.NewCenter(pagination)
-
 	addDetailButton := widget.NewButton("Add Category", func() {
 		showExpenseDetailForm(window, nil, userID, updateExpenseDetailList)
 	})
 
 	// Search functionality
-	searchEntry = widget.NewEntry()
+	searchEntry := widget.NewEntry()
 	searchEntry.SetPlaceHolder("Search Category")
 	searchButton := widget.NewButtonWithIcon("", theme.SearchIcon(), func() {
 		searchText := searchEntry.Text
 		if searchText != "" {
-			searchResults = utils.SearchExpenseDetails(searchText, window)
-			updateNoResultsLabel()
-			currentPage = 1 // Reset to first page of search results
-			updateExpenseDetailList()
+			detailTable.SetFilter(utils.ExpenseDetailSearchFilter(searchText))
 		} else {
-			// If search is cleared, reset the pagination and detail list
-			searchResults = nil
-			currentPage = 1
-			updateExpenseDetailList()
+			// If search is cleared, show every detail again
+			detailTable.SetFilter(nil)
 		}
 	})
 
@@ -227,20 +108,10 @@ func ExpenseDetailsView(window fyne.Window, userID primitive.ObjectID) fyne.Canv
 	// the search entry and bulk upload button
 	searchContainer := container.New(layout.NewGridLayout(2), searchEntry, searchButton)
 
-	// No results label
-	noResultsLabel = widget.NewLabel("No results found")
-	noResultsLabel.Hide() // Hide by default
-
-	// Load the initial set of expense_details
-	updateExpenseDetailList()
-
-	// grid for the add detail and export expense_details button
+	// grid for the add detail and export details button
 	exportButtonContainer := container.New(layout.NewGridLayout(1), addDetailButton)
 
-	// Define the container for the list with pagination controls
-	listContainer := container.NewBorder(titleRow, nil, nil, nil, expenseDetailList, noResultsLabel)
-
-	listWrapper := container.NewBorder(exportButtonContainer, pagination, nil, nil, listContainer)
+	listWrapper := container.NewBorder(exportButtonContainer, nil, nil, nil, detailTable.Widget())
 
 	// Return the final container with all elements
 	return container.NewBorder(nil, nil, nil, nil, container.NewBorder(searchContainer, nil, nil, nil, listWrapper))
@@ -262,19 +133,26 @@ func showExpenseDetailForm(window fyne.Window, existing *models.ExpenseDetail, U
 	expenseCategory := widget.NewEntry()
 	expenseCategory.SetPlaceHolder("eg Rent")
 	expenseCategory.SetText(expense_detaill.ExpenseCategory)
+	parent, parentID := parentSelect("expense", expense_detaill.ID, expense_detaill.ParentID, window)
 
 	// Create form
 	form := &widget.Form{
 		Items: []*widget.FormItem{
 			{Text: "Expense Category", Widget: expenseCategory},
+			{Text: "Parent", Widget: parent},
 		},
 		OnSubmit: func() {
-			expense_detaill.ExpenseCategory = expenseCategory.Text
+			expense_detaill.ExpenseCategory = strings.TrimSpace(expenseCategory.Text)
+			expense_detaill.ParentID = parentID()
 
-			if expenseCategory.Text == "" {
+			if expense_detaill.ExpenseCategory == "" {
 				dialog.ShowInformation("expense Detail", "All fields are required", window)
 				return
 			}
+			if err := utils.CheckCategory(context.TODO(), "expense", expense_detaill.ID, expense_detaill.ExpenseCategory, expense_detaill.ParentID); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
 
 			if isEdit {
 				parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
@@ -0,0 +1,134 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fynance/helpers"
+	"fynance/utils"
+	"io"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// exportFunc writes the filtered records to w, reporting progress from 0 to 1
+type exportFunc func(ctx context.Context, w io.Writer, filter utils.ExportFilter, progress func(float64)) error
+
+// parseExportDate accepts an empty string or a YYYY-MM-DD date
+func parseExportDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("dates must be in the format YYYY-MM-DD")
+	}
+	return date, nil
+}
+
+// showExportDialog asks for an export filter, then a destination file, then
+// streams the export while showing its progress. Pass nil categories to hide
+// the category filter.
+func showExportDialog(window fyne.Window, title, fileName, extension string, categories []string, export exportFunc) {
+	fromEntry := widget.NewEntry()
+	fromEntry.SetPlaceHolder("YYYY-MM-DD")
+
+	toEntry := widget.NewEntry()
+	toEntry.SetPlaceHolder("YYYY-MM-DD")
+
+	searchEntry := widget.NewEntry()
+	searchEntry.SetPlaceHolder("Optional")
+
+	categorySelect := widget.NewSelect(append([]string{"All"}, categories...), nil)
+	categorySelect.SetSelected("All")
+
+	formItems := []*widget.FormItem{
+		{Text: "From", Widget: fromEntry},
+		{Text: "To", Widget: toEntry},
+	}
+	if categories != nil {
+		formItems = append(formItems, &widget.FormItem{Text: "Category", Widget: categorySelect})
+	}
+	formItems = append(formItems, &widget.FormItem{Text: "Search", Widget: searchEntry})
+
+	form := helpers.NewFixedWidthCenter(container.NewVBox(widget.NewForm(formItems...)), 400)
+
+	dialog.ShowCustomConfirm(title, "Choose File", "Cancel", container.NewCenter(form), func(ok bool) {
+		if !ok {
+			return
+		}
+
+		from, err := parseExportDate(fromEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		to, err := parseExportDate(toEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if !from.IsZero() && !to.IsZero() && to.Before(from) {
+			dialog.ShowError(errors.New("the end date is before the start date"), window)
+			return
+		}
+
+		filter := utils.ExportFilter{From: from, To: to, Search: searchEntry.Text}
+		if categorySelect.Selected != "All" {
+			filter.Category = categorySelect.Selected
+		}
+
+		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if writer == nil {
+				return // user cancelled
+			}
+			runExport(window, title, writer, filter, export)
+		}, window)
+		saveDialog.SetFileName(fileName)
+		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{extension}))
+		saveDialog.Show()
+	}, window)
+}
+
+// runExport streams the export into writer behind a cancellable progress dialog
+func runExport(window fyne.Window, title string, writer fyne.URIWriteCloser, filter utils.ExportFilter, export exportFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	progress := widget.NewProgressBar()
+	progressDialog := dialog.NewCustom(title, "Cancel", progress, window)
+	progressDialog.SetOnClosed(cancel)
+	progressDialog.Show()
+
+	go func() {
+		err := export(ctx, writer, filter, progress.SetValue)
+		closeErr := writer.Close()
+		if err == nil {
+			err = closeErr
+		}
+		progressDialog.Hide()
+
+		if err != nil {
+			// Don't leave a half written file behind
+			storage.Delete(writer.URI())
+			if errors.Is(err, context.Canceled) {
+				dialog.ShowInformation(title, "Export cancelled", window)
+				return
+			}
+			dialog.ShowError(err, window)
+			return
+		}
+
+		utils.Logger(title+" to "+writer.URI().Name(), "SUCCESS", window)
+		dialog.ShowInformation("Export Successful", "Exported to "+writer.URI().Path(), window)
+	}()
+}
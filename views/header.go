@@ -2,7 +2,7 @@ package views
 
 import (
 	"fynance/models"
-	"net/http"
+	"fynance/utils"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -21,42 +21,22 @@ var (
 	timeLabel              *widget.Label
 )
 
+// statusLabel shows the database state published by the health monitor
 var statusLabel *widget.Label
 
-// Check if app is online
-func isOnline() bool {
-	_, err := http.Get("https://www.google.com")
-	return err == nil
-}
-
-// Function to display the status label and hide it after 5 seconds
-func showStatus(status string, window fyne.Window) {
-	statusLabel.SetText(status)
-	statusLabel.Show()
-	go func() {
-		time.Sleep(5 * time.Second)
-		window.Canvas().Refresh(statusLabel)
-		statusLabel.Hide()
-	}()
-}
-
-// Function to monitor network status
-func monitorNetworkStatus(window fyne.Window) {
-	for {
-		if isOnline() {
-			showStatus("Online", window)
-		} else {
-			showStatus("Offline", window)
-		}
-		time.Sleep(5 * time.Second) // Check every 5 seconds
-	}
-}
-
 func Header(window fyne.Window) *fyne.Container {
 	statusLabel = widget.NewLabel("")
-	statusLabel.Hide() // Initially hidden
+	showHealth()
+
+	// Changes made offline and not yet synced
+	pendingButton = widget.NewButtonWithIcon("", theme.UploadIcon(), func() {
+		showJournalDialog(window)
+	})
+	pendingButton.Importance = widget.LowImportance
+	showPendingChanges(len(utils.PendingChanges()))
 
-	go monitorNetworkStatus(window) // Start monitoring network status
+	// The monitor outlives the header, it is only started once per session
+	StartHealthMonitor()
 
 	// Notification icon button with initial count
 	notificationCountLabel = widget.NewLabel("0")
@@ -101,6 +81,7 @@ func Header(window fyne.Window) *fyne.Container {
 	header := container.NewHBox(
 		widget.NewLabel("Fynance"),
 		statusLabel,
+		pendingButton,
 		layout.NewSpacer(),
 		timeLabel,
 		darkModeIcon,
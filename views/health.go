@@ -0,0 +1,74 @@
+package views
+
+import (
+	"fynance/utils"
+	"sync"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+var (
+	healthMonitor *utils.HealthMonitor
+	healthMu      sync.Mutex
+	healthState   = utils.HealthOffline
+	healthKnown   bool // false until the first check has run
+)
+
+// StartHealthMonitor starts the app-wide database monitor unless it is
+// already running. State changes are shown in the header.
+func StartHealthMonitor() {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	if healthMonitor != nil {
+		return
+	}
+
+	utils.OnJournalChange = func(pending int) {
+		showPendingChanges(pending)
+	}
+	healthMonitor = utils.StartHealthMonitor(func(state utils.HealthState, err error) {
+		healthMu.Lock()
+		healthState, healthKnown = state, true
+		healthMu.Unlock()
+		showHealth()
+	})
+}
+
+// StopHealthMonitor stops the monitor, on logout and when the app exits
+func StopHealthMonitor() {
+	healthMu.Lock()
+	monitor := healthMonitor
+	healthMonitor = nil
+	healthMu.Unlock()
+
+	if monitor != nil {
+		monitor.Stop()
+	}
+}
+
+// showHealth puts the last known database state in the header
+func showHealth() {
+	healthMu.Lock()
+	state, known := healthState, healthKnown
+	healthMu.Unlock()
+
+	label := statusLabel
+	if label == nil {
+		return
+	}
+	if !known {
+		label.Importance = widget.MediumImportance
+		label.SetText("Checking database…")
+		return
+	}
+
+	switch state {
+	case utils.HealthConnected:
+		label.Importance = widget.SuccessImportance
+	case utils.HealthDegraded:
+		label.Importance = widget.WarningImportance
+	default:
+		label.Importance = widget.DangerImportance
+	}
+	label.SetText("● " + state.String())
+}
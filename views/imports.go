@@ -0,0 +1,136 @@
+package views
+
+import (
+	"context"
+	"fmt"
+	"fynance/models"
+	"fynance/utils"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// importStatuses are the batch states as the Imports view shows them
+var importStatuses = map[string]string{
+	models.ImportRunning:    "Importing",
+	models.ImportComplete:   "Complete",
+	models.ImportFailed:     "Failed",
+	models.ImportRolledBack: "Rolled Back",
+}
+
+// ImportsView lists the CSV imports, each of which can be reviewed and
+// rolled back as a unit
+func ImportsView(window fyne.Window, userID primitive.ObjectID) fyne.CanvasObject {
+	// Load the settings on app startup
+	settings, err := LoadSettings()
+	if err != nil {
+		dialog.ShowInformation("User Settings", "Error loading settings", window)
+		settings = DefaultSettings()
+	}
+
+	var importTable *dataTable[models.ImportBatch]
+
+	header := Header(window)
+	footer := Footer(window)
+
+	rollback := func(batch models.ImportBatch) {
+		if batch.Status == models.ImportRolledBack || batch.Status == models.ImportFailed {
+			dialog.ShowInformation("Rollback", "This import left no records to remove.", window)
+			return
+		}
+		message := fmt.Sprintf("Remove the %d %s records imported from %s on %s? Records edited since are removed too. This can't be undone.",
+			batch.Imported, batch.Kind, batch.FileName, batch.CreatedAt.Format("2006-01-02 15:04"))
+		dialog.ShowConfirm("Roll Back Import", message, func(ok bool) {
+			if !ok {
+				return
+			}
+			progress := dialog.NewCustomWithoutButtons("Rolling Back Import", widget.NewProgressBarInfinite(), window)
+			progress.Show()
+
+			go func() {
+				_, removed, err := utils.RollbackImport(context.Background(), batch.ID)
+				progress.Hide()
+				if err != nil {
+					dialog.ShowError(err, window)
+					return
+				}
+
+				user := utils.GetUserByID(userID, window)
+				detail := fmt.Sprintf("%s rolled back the import of %s, removing %d %s records", user.Username, batch.FileName, removed, batch.Kind)
+				utils.AddNotification(models.Notification{
+					UserID:  user.ID,
+					Message: detail,
+					IsRead:  false,
+				}, window)
+				updateNotificationCount(window)
+				utils.Logger(detail, "SUCCESS", window)
+
+				importTable.Reload()
+				dialog.ShowInformation("Success", fmt.Sprintf("%d %s records removed.", removed, batch.Kind), window)
+			}()
+		}, window)
+	}
+
+	// Create the imports table
+	importTable = newDataTable(
+		[]tableColumn[models.ImportBatch]{
+			{Title: "File", Width: 220, Text: func(batch models.ImportBatch) string { return batch.FileName }},
+			{Title: "Type", Field: "kind", Width: 90, Text: func(batch models.ImportBatch) string { return batch.Kind }},
+			{Title: "User", Width: 120, Text: func(batch models.ImportBatch) string { return batch.Username }},
+			{Title: "Rows", Width: 70, Text: func(batch models.ImportBatch) string { return strconv.Itoa(batch.Rows) }},
+			{Title: "Imported", Width: 90, Text: func(batch models.ImportBatch) string { return strconv.Itoa(batch.Imported) }},
+			{Title: "Skipped", Width: 80, Text: func(batch models.ImportBatch) string { return strconv.Itoa(batch.Skipped) }},
+			{Title: "Status", Field: "status", Width: 110, Text: func(batch models.ImportBatch) string { return importStatuses[batch.Status] }},
+			{Title: "Imported At", Field: "created_at", Width: 170, Text: func(batch models.ImportBatch) string {
+				return batch.CreatedAt.Format("2006-01-02 15:04")
+			}},
+		},
+		[]tableAction[models.ImportBatch]{
+			{Icon: theme.InfoIcon(), Tapped: func(batch models.ImportBatch) { showImportDetails(window, batch) }},
+			{Icon: theme.ContentUndoIcon(), Tapped: rollback},
+		},
+		int(settings.PageSize),
+		func(batch models.ImportBatch) primitive.ObjectID { return batch.ID },
+		func(req utils.PageRequest) utils.Page[models.ImportBatch] {
+			return utils.GetImportsPaginated(req, window)
+		},
+	)
+
+	refreshButton := widget.NewButtonWithIcon("Refresh", theme.ViewRefreshIcon(), importTable.Reload)
+
+	// Return the final container with all elements
+	listWrapper := container.NewBorder(refreshButton, nil, nil, nil, importTable.Widget())
+	return container.NewBorder(header, footer, nil, nil, listWrapper)
+}
+
+// showImportDetails shows everything recorded about an import batch
+func showImportDetails(window fyne.Window, batch models.ImportBatch) {
+	form := widget.NewForm(
+		widget.NewFormItem("Batch", widget.NewLabel(batch.ID.Hex())),
+		widget.NewFormItem("File", widget.NewLabel(batch.FileName)),
+		widget.NewFormItem("SHA-256", widget.NewLabel(batch.FileHash)),
+		widget.NewFormItem("Type", widget.NewLabel(batch.Kind)),
+		widget.NewFormItem("Imported By", widget.NewLabel(batch.Username)),
+		widget.NewFormItem("Imported At", widget.NewLabel(batch.CreatedAt.Format("2006-01-02 15:04:05"))),
+		widget.NewFormItem("Rows", widget.NewLabel(fmt.Sprintf("%d in the file, %d imported, %d skipped", batch.Rows, batch.Imported, batch.Skipped))),
+		widget.NewFormItem("Total", widget.NewLabel(strconv.FormatFloat(batch.Total, 'f', 2, 64))),
+		widget.NewFormItem("Status", widget.NewLabel(importStatuses[batch.Status])),
+	)
+	if batch.Status == models.ImportRolledBack {
+		form.Append("Rolled Back At", widget.NewLabel(batch.RolledBackAt.Format("2006-01-02 15:04:05")))
+	}
+	if batch.Error != "" {
+		errorLabel := widget.NewLabel(batch.Error)
+		errorLabel.Wrapping = fyne.TextWrapWord
+		form.Append("Error", errorLabel)
+	}
+
+	details := dialog.NewCustom("Import Details", "Close", form, window)
+	details.Resize(fyne.NewSize(560, 0))
+	details.Show()
+}
@@ -1,13 +1,13 @@
 package views
 
 import (
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"fynance/helpers"
 	"fynance/models"
 	"fynance/utils"
-	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -20,225 +20,98 @@ import (
 	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var incomeList *widget.List
-
 func IncomeView(window fyne.Window, userID primitive.ObjectID) fyne.CanvasObject {
 	// Load the settings on app startup
 	settings, err := LoadSettings()
 	if err != nil {
 		dialog.ShowInformation("User Settings", "Error loading settings", window)
+		settings = DefaultSettings()
 	}
 
-	pageSize, err := strconv.Atoi(settings.PageSize) // Number of incomes per page
-
-	if err != nil {
-		dialog.ShowError(err, window)
-	}
-
-	var incomes []models.Income
-	var currentPage int = 1
-	var totalIncomes int64 = 0
-	var pageLabel *widget.Label
-	var prevButton, nextButton *widget.Button
-	var searchResults []models.Income
-	var searchEntry *widget.Entry
-	var noResultsLabel *widget.Label
+	var incomeTable *dataTable[models.Income]
 
 	header := Header(window)
 	footer := Footer(window)
 
-	// Update visibility of no results label
-	updateNoResultsLabel := func() {
-		if len(incomes) == 0 {
-			noResultsLabel.Show()
-		} else {
-			noResultsLabel.Hide()
+	var reloadTags func()
+	updateIncomeList := func() {
+		incomeTable.Reload()
+		reloadTags()
+	}
+
+	editIncome := func(income models.Income) {
+		if isReconciled(window, "Income", income.ReconciliationID) {
+			return
 		}
+		showIncomeForm(window, &income, userID, updateIncomeList)
 	}
 
-	// Load incomes for the specified page
-	loadIncomes := func(page int) {
-		// Show progress bar dialog
-		progress := widget.NewProgressBar()
-		progress.SetValue(0)
-		progressDialog := dialog.NewCustom("Loading Incomes", "Cancel", progress, window)
-		progressDialog.Show()
-		// Check if search is active
-		go func() {
-			if searchEntry.Text != "" {
-				// Use filtered incomes when a search query is active
-				incomes = searchResults
-				totalIncomes = int64(len(incomes))
-			} else {
-				// Use all incomes for normal pagination
-				incomes = utils.GetIncomesPaginated(page, pageSize, window, func(progressValue float64) {
-					progress.SetValue(progressValue)
-				})
-				totalIncomes = utils.CountIncomes(window)
-			}
+	//delete income button
+	deleteIncome := func(income models.Income) {
+		if isReconciled(window, "Income", income.ReconciliationID) {
+			return
+		}
+		dialog.ShowConfirm("Delete Income", "Are you sure you want to delete this income?",
+			func(ok bool) {
+				if ok {
+					err := utils.DeleteIncome(income.ID, window)
+
+					if err != nil {
+						dialog.ShowError(err, window)
+					} else {
+						// Create a new notification
+						// fetch user by ID
+						var user = utils.GetUserByID(userID, window)
+						newNotification := models.Notification{
+							UserID:  user.ID,
+							Message: user.Username + " deleted Income " + income.Category,
+							IsRead:  false,
+						}
 
-			incomeList.Refresh()
+						utils.AddNotification(newNotification, window)
 
-			// Enable or disable pagination buttons based on the current page and total pages
-			totalPages := int(math.Ceil(float64(totalIncomes) / float64(pageSize)))
+						//utils.PlayNotificationSound(window)
 
-			// Update page label
-			pageLabel.SetText(fmt.Sprintf("Page %d of %d", currentPage, totalPages))
+						updateNotificationCount(window)
 
-			updateNoResultsLabel()
+						detail := user.Username + " deleted Income " + income.Category
+						utils.Logger(detail, "SUCCESS", window)
+						updateIncomeList()
+						dialog.ShowInformation("Success", "Income deleted successfully!", window)
+					}
 
-			prevButton.Disable()
-			nextButton.Disable()
-			if currentPage > 1 {
-				prevButton.Enable()
-			}
-			if currentPage < totalPages {
-				nextButton.Enable()
-			}
-			progress.SetValue(1.0) // Complete progress
-			progressDialog.Hide()
-		}()
+				}
+			}, window)
 	}
 
-	updateIncomeList := func() {
-		loadIncomes(currentPage)
-		updateNoResultsLabel()
+	// receipts and invoices kept with the incomes
+	attachments := newAttachmentIndex()
+	incomeID := func(income models.Income) primitive.ObjectID { return income.ID }
+	showIncomeAttachments := func(income models.Income) {
+		showAttachments(window, "Income", income.ID, income.Category+" "+income.Month+" "+income.Year, updateIncomeList)
 	}
 
-	// Header Row with Titles
-	titleRow := container.NewGridWithColumns(5,
-		widget.NewLabelWithStyle("Category", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		widget.NewLabelWithStyle("Month", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		widget.NewLabelWithStyle("Year", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		widget.NewLabelWithStyle("Amount", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		widget.NewLabelWithStyle("Actions", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-	)
-
-	// Create the incomes list
-	incomeList = widget.NewList(
-		func() int {
-			return len(incomes)
-		},
-		func() fyne.CanvasObject {
-			// category label
-			categoryLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{})
-			categoryLabel.Truncation = fyne.TextTruncation(fyne.TextTruncateEllipsis)
-
-			// month  label
-			monthLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{})
-			monthLabel.Truncation = fyne.TextTruncation(fyne.TextTruncateEllipsis)
-
-			// year label
-			yearLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{})
-
-			// amount label
-			amountLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{})
-			amountLabel.Truncation = fyne.TextTruncation(fyne.TextTruncateEllipsis)
-
-			editButton := widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), nil)
-			deleteButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), nil)
-
-			row := container.NewGridWithColumns(5,
-				categoryLabel,
-				monthLabel,
-				yearLabel,
-				amountLabel,
-				container.NewHBox(editButton, deleteButton),
-			)
-			return row
-		},
-		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			income := incomes[id]
-			row := obj.(*fyne.Container)
-
-			// Retrieve the components in the row
-			categoryLabel := row.Objects[0].(*widget.Label)
-			monthLabel := row.Objects[1].(*widget.Label)
-			yearLabel := row.Objects[2].(*widget.Label)
-			amountLabel := row.Objects[3].(*widget.Label)
-
-			editButton := row.Objects[4].(*fyne.Container).Objects[0].(*widget.Button)
-			deleteButton := row.Objects[4].(*fyne.Container).Objects[1].(*widget.Button)
-
-			categoryLabel.SetText(income.Category)
-			monthLabel.SetText(income.Month)
-			yearLabel.SetText(income.Year)
-
-			// amount to string
-			//amount_string := strconv.Itoa(int(income.Amount))
-			amount_string := strconv.FormatFloat(income.Amount, 'f', -1, 64)
-			amountLabel.SetText(amount_string)
-
-			editButton.OnTapped = func() {
-				showIncomeForm(window, &income, userID, updateIncomeList)
-			}
-
-			//delete income button
-			deleteButton.OnTapped = func() {
-				dialog.ShowConfirm("Delete Income", "Are you sure you want to delete this income?",
-					func(ok bool) {
-						if ok {
-							err = utils.DeleteIncome(income.ID, window)
-
-							if err != nil {
-								dialog.ShowError(err, window)
-							} else {
-								// Create a new notification
-								// fetch user by ID
-								var user = utils.GetUserByID(userID, window)
-								newNotification := models.Notification{
-									UserID:  user.ID,
-									Message: user.Username + " deleted Income " + income.Category,
-									IsRead:  false,
-								}
-
-								utils.AddNotification(newNotification, window)
-
-								//utils.PlayNotificationSound(window)
-
-								updateNotificationCount(window)
-
-								detail := user.Username + " deleted Income " + income.Category
-								utils.Logger(detail, "SUCCESS", window)
-								updateIncomeList()
-								dialog.ShowInformation("Success", "Income deleted successfully!", window)
-							}
-
-						}
-					}, window)
-			}
+	// Create the incomes table
+	incomeTable = newDataTable(
+		append(transactionColumns(func(income models.Income) transactionRow {
+			return transactionRow{income.Category, income.Month, income.Year, income.Amount, income.CreatedAt, income.Payee, income.Tags}
+		}), attachmentColumn(attachments, incomeID)),
+		[]tableAction[models.Income]{
+			{Icon: theme.MailAttachmentIcon(), Tapped: showIncomeAttachments},
+			{Icon: theme.DocumentCreateIcon(), Tapped: editIncome},
+			{Icon: theme.DeleteIcon(), Tapped: deleteIncome},
 		},
+		int(settings.PageSize),
+		incomeID,
+		withAttachments(attachments, incomeID, func(req utils.PageRequest) utils.Page[models.Income] {
+			return utils.GetIncomesPaginated(req, window)
+		}),
 	)
 
-	// Pagination controls
-	pagination := container.NewHBox()
-	prevButton = widget.NewButton("Prev", func() {
-		if currentPage > 1 {
-			currentPage--
-			updateIncomeList()
-		}
-	})
-	nextButton = widget.NewButton("Next", func() {
-		if int(math.Ceil(float64(totalIncomes)/float64(pageSize))) > currentPage {
-			currentPage++
-			updateIncomeList()
-		}
-	})
-
-	// Initialize page label
-	pageLabel = widget.NewLabel(fmt.Sprintf("Page %d of %d", currentPage, int(math.Ceil(float64(totalIncomes)/float64(pageSize)))))
-
-	// Add buttons and label to the pagination container
-	pagination.Add(prevButton)
-	pagination.Add(pageLabel)
-	pagination.Add(nextButton)
-
-	// Center the pagination controls
-	pagination = container.NewCenter(pagination)
-
 	addIncomeButton := widget.NewButton("Add Income", func() {
 		showIncomeForm(window, nil, userID, updateIncomeList)
 	})
@@ -262,125 +135,138 @@ func IncomeView(window fyne.Window, userID primitive.ObjectID) fyne.CanvasObject
 					return
 				}
 
-				incomes, parseErr := parseIncomeCSV(reader.URI().Path(), window)
+				path := reader.URI().Path()
+				incomes, skipped, parseErr := parseIncomeCSV(path)
 				if parseErr != nil {
 					dialog.ShowError(parseErr, window)
 					return
 				}
+				if len(incomes) == 0 {
+					dialog.ShowInformation("No Incomes Imported", "No valid incomes were found in the CSV file.", window)
+					return
+				}
 
-				if len(incomes) > 0 {
+				hash, err := utils.HashFile(path)
+				if err != nil {
+					dialog.ShowError(err, window)
+					return
+				}
+				user := utils.GetUserByID(userID, window)
+				source := utils.ImportSource{
+					FileName: reader.URI().Name(),
+					Hash:     hash,
+					Rows:     len(incomes) + skipped,
+					Skipped:  skipped,
+					UserID:   user.ID,
+					Username: user.Username,
+				}
+
+				startImport := func() {
 					progressBar := widget.NewProgressBar()
-					progressDialog := dialog.NewCustom("Bulk Upload Progress", "Cancel", progressBar, window)
+					progressDialog := dialog.NewCustomWithoutButtons("Bulk Upload Progress", progressBar, window)
 					progressDialog.Show()
 
 					go func() {
-						utils.BulkInsertIncome(incomes, window, progressBar)
-						updateIncomeList() // Refresh list after bulk upload
+						err := utils.BulkInsertIncome(source, incomes, window, progressBar)
 						progressDialog.Hide()
+						updateIncomeList() // Refresh list after bulk upload
+						if err != nil {
+							return
+						}
 
 						// Update notifications
+						detail := fmt.Sprintf("%s imported %d incomes from %s", user.Username, len(incomes), source.FileName)
 						utils.AddNotification(models.Notification{
-							UserID:  userID,
+							UserID:  user.ID,
 							Message: fmt.Sprintf("Bulk Upload: %d Incomes Uploaded", len(incomes)),
 							IsRead:  false,
 						}, window)
+						updateNotificationCount(window)
+						utils.Logger(detail, "SUCCESS", window)
 					}()
-				} else {
-					dialog.ShowInformation("No Incomes Imported", "No valid incomes were found in the CSV file.", window)
 				}
 
+				// warn before importing the same file twice
+				previous, found, err := utils.FindImportByHash(context.Background(), "income", hash)
+				if err != nil {
+					dialog.ShowError(err, window)
+					return
+				}
+				if found {
+					message := fmt.Sprintf("This file was already imported as %s by %s on %s (%d incomes).\nImport it again anyway?",
+						previous.FileName, previous.Username, previous.CreatedAt.Format("2006-01-02 15:04"), previous.Imported)
+					dialog.ShowConfirm("File Already Imported", message, func(ok bool) {
+						if ok {
+							startImport()
+						}
+					}, window)
+					return
+				}
+				startImport()
 			}, window)
 		openFileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".csv"}))
 		openFileDialog.Show()
 	})
 
 	// Search functionality
-	searchEntry = widget.NewEntry()
-	searchEntry.SetPlaceHolder("Search by category/month...")
-	searchButton := widget.NewButtonWithIcon("", theme.SearchIcon(), func() {
-		searchText := searchEntry.Text
-		if searchText != "" {
-			searchResults = utils.SearchIncomes(searchText, window)
-			updateNoResultsLabel()
-			currentPage = 1 // Reset to first page of search results
-			updateIncomeList()
-		} else {
-			// If search is cleared, reset the pagination and income list
-			searchResults = nil
-			currentPage = 1
-			updateIncomeList()
-		}
+	var searchFilter, tagFilter bson.M
+	applyFilters := func() {
+		incomeTable.SetFilter(utils.AndFilters(searchFilter, tagFilter))
+	}
+	search := searchBar(window, transactionSearch(func() []string {
+		return utils.GetCategoryTree("income", window).Paths()
+	}), func(filter bson.M) {
+		searchFilter = filter
+		applyFilters()
 	})
 
-	// enter key to search income
-	searchEntry.OnSubmitted = func(s string) {
-		searchButton.OnTapped()
-	}
+	// narrow the list down to one tag
+	var tags *widget.Select
+	tags, reloadTags = tagSelect(window, utils.ListIncomeTags, func(tag string) {
+		tagFilter = nil
+		if tag != "" {
+			tagFilter = utils.TagFilter(tag)
+		}
+		applyFilters()
+	})
 
 	// Define functions for exporting data
 	exportToCSV := widget.NewButton("export to csv", func() {
-		incomes := utils.GetAllIncomes(window)
-
-		if len(incomes) != 0 {
-			// Create progress dialog
-			progress := widget.NewProgressBar()
-			progressDialog := dialog.NewCustom("Exporting Incomes", "Cancel", progress, window)
-			progressDialog.Show()
-
-			go func() {
-				file, err := os.Create("incomes.csv")
-				if err != nil {
-					dialog.ShowError(err, window)
-					return
-				}
-				defer file.Close()
-
-				writer := csv.NewWriter(file)
-				defer writer.Flush()
-
-				// Write header
-				writer.Write([]string{"Category", "Month", "Year", "Amount"})
-
-				// Write income data
-				for i, income := range incomes {
-					amount_string := strconv.Itoa(int(income.Amount))
-					writer.Write([]string{
-						income.Category,
-						income.Month,
-						income.Year,
-						amount_string,
-					})
-
-					// Update progress
-					progress.SetValue(float64(i+1) / float64(len(incomes)))
-				}
-
-				// Close progress dialog after exporting
-				progressDialog.Hide()
-				dialog.ShowInformation("Export Successful", "Incomes have been exported to incomes.csv", window)
-			}()
-		} else {
-			dialog.ShowInformation("Export Failed", "No data to export", window)
-		}
+		categories := utils.GetCategoryTree("income", window).Paths()
+		showExportDialog(window, "Exporting Incomes", "incomes.csv", ".csv", categories, utils.WriteIncomesCSV)
 	})
 
-	// the search entry and bulk upload button
-	searchContainer := container.New(layout.NewGridLayout(2), searchEntry, searchButton)
-
-	// No results label
-	noResultsLabel = widget.NewLabel("No results found")
-	noResultsLabel.Hide() // Hide by default
+	// review likely duplicates and keep one of each
+	duplicatesButton := widget.NewButton("Find Duplicates", func() {
+		showDuplicateReview(window, userID, duplicateTarget[models.Income]{
+			kind: "Income",
+			find: utils.FindDuplicateIncomes,
+			row: func(income models.Income) transactionRow {
+				return transactionRow{income.Category, income.Month, income.Year, income.Amount, income.CreatedAt, income.Payee, income.Tags}
+			},
+			id:     func(income models.Income) primitive.ObjectID { return income.ID },
+			batch:  func(income models.Income) primitive.ObjectID { return income.ImportBatch },
+			remove: utils.DeleteIncome,
+		}, updateIncomeList)
+	})
 
-	// Load the initial set of incomes
-	updateIncomeList()
+	// the search bar and tag filter
+	searchContainer := container.NewBorder(nil, nil, nil, tags, search)
 
 	// grid for the add income and export incomes button
-	exportButtonContainer := container.New(layout.NewGridLayout(3), addIncomeButton, bulkUploadButton, exportToCSV)
+	exportButtonContainer := container.New(layout.NewGridLayout(4), addIncomeButton, bulkUploadButton, duplicatesButton, exportToCSV)
 
-	// Define the container for the list with pagination controls
-	listContainer := container.NewBorder(titleRow, nil, nil, nil, incomeList, noResultsLabel)
+	// actions on the selected incomes
+	selectionActions := bulkActions(window, incomeTable, bulkTarget{
+		kind: "Income",
+		categories: func() []string {
+			return utils.GetCategoryTree("income", window).Paths()
+		},
+		update: utils.BulkUpdateIncomes,
+		remove: utils.BulkDeleteIncomes,
+	})
 
-	listWrapper := container.NewBorder(exportButtonContainer, pagination, nil, nil, listContainer)
+	listWrapper := container.NewBorder(container.NewVBox(exportButtonContainer, selectionActions), nil, nil, nil, incomeTable.Widget())
 
 	// Return the final container with all elements
 	return container.NewBorder(header, footer, nil, nil, container.NewBorder(searchContainer, nil, nil, nil, listWrapper))
@@ -397,14 +283,8 @@ func showIncomeForm(window fyne.Window, existing *models.Income, UserID primitiv
 	if isEdit {
 		income = *existing
 	}
-	// get the income categories
-	income_categories := utils.GetAllDetails(window)
-
-	var incomeCategories []string
-	for _, category := range income_categories {
-		// display available income categories
-		incomeCategories = append(incomeCategories, category.IncomeCategory)
-	}
+	// get the income categories by their full names, such as "Transport > Fuel"
+	incomeCategories := utils.GetCategoryTree("income", window).Paths()
 
 	// Initialize form fields
 	category := widget.NewSelect(incomeCategories, func(s string) {
@@ -428,6 +308,22 @@ func showIncomeForm(window fyne.Window, existing *models.Income, UserID primitiv
 	amount := widget.NewEntry()
 	amount.SetText(string_amount)
 
+	payee := widget.NewEntry()
+	payee.SetPlaceHolder("Optional")
+	payee.SetText(income.Payee)
+
+	notes := widget.NewMultiLineEntry()
+	notes.SetPlaceHolder("Optional, e.g. an invoice number")
+	notes.SetText(income.Notes)
+	notes.SetMinRowsVisible(3)
+
+	// the account the money went into
+	account, accountID := accountSelect(window, income.AccountID)
+
+	tags := widget.NewEntry()
+	tags.SetPlaceHolder("Comma separated, e.g. travel, client-x")
+	tags.SetText(strings.Join(income.Tags, ", "))
+
 	// Create form
 	form := &widget.Form{
 		Items: []*widget.FormItem{
@@ -435,6 +331,10 @@ func showIncomeForm(window fyne.Window, existing *models.Income, UserID primitiv
 			{Text: "Month", Widget: month},
 			{Text: "Year", Widget: year},
 			{Text: "Amount", Widget: amount},
+			{Text: "Payee", Widget: payee},
+			{Text: "Notes", Widget: notes},
+			{Text: "Account", Widget: account},
+			{Text: "Tags", Widget: tags},
 		},
 		OnSubmit: func() {
 			income.Category = category.Selected
@@ -444,6 +344,10 @@ func showIncomeForm(window fyne.Window, existing *models.Income, UserID primitiv
 			amount_float64, _ := strconv.ParseFloat(amount.Text, 64)
 
 			income.Amount = amount_float64
+			income.Payee = strings.TrimSpace(payee.Text)
+			income.Notes = strings.TrimSpace(notes.Text)
+			income.Tags = helpers.ParseTags(tags.Text)
+			income.AccountID = accountID()
 
 			if income.Month == "" || income.Year == "" || income.Category == "" || amount.Text == "" {
 				dialog.ShowInformation("Income", "All fields are required", window)
@@ -492,6 +396,7 @@ func showIncomeForm(window fyne.Window, existing *models.Income, UserID primitiv
 					return
 				}
 				income.CreatedAt = parsedTime
+				income.CreatedBy = helpers.CurrentUserID
 
 				err = utils.AddIncome(income, window)
 
@@ -535,32 +440,41 @@ func showIncomeForm(window fyne.Window, existing *models.Income, UserID primitiv
 	dialog.ShowCustom("Income Form", "Cancel", formSave, window)
 }
 
-// Function to parse CSV and return a slice of incomes
-func parseIncomeCSV(filePath string, window fyne.Window) ([]models.Income, error) {
+// parseIncomeCSV reads a Category,Month,Year,Amount CSV with a header row,
+// optionally followed by Payee, Notes and Tags columns.
+// Rows that are too short or have an invalid amount are skipped and counted.
+func parseIncomeCSV(filePath string) ([]models.Income, int, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		dialog.ShowError(err, window)
+		return nil, 0, err
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
 	records, err := reader.ReadAll()
 	if err != nil {
-		dialog.ShowError(err, window)
+		return nil, 0, err
 	}
 
 	var incomes []models.Income
+	skipped := 0
 	for i, record := range records {
 		if i == 0 {
 			continue // Skip header row
 		}
 
 		if len(record) < 4 {
+			skipped++
 			continue // Skip rows with insufficient columns
 		}
 
 		// convert amount from string to float
-		amount_float, _ := strconv.ParseFloat(record[3], 64)
+		amount_float, err := strconv.ParseFloat(strings.TrimSpace(record[3]), 64)
+		if err != nil {
+			skipped++
+			continue
+		}
 
 		income := models.Income{
 			ID:       primitive.NewObjectID(), // Generate a new unique ObjectID for each Incomes
@@ -569,8 +483,18 @@ func parseIncomeCSV(filePath string, window fyne.Window) ([]models.Income, error
 			Year:     record[2],
 			Amount:   amount_float,
 		}
+		// the payee, notes and tags columns are optional
+		if len(record) > 4 {
+			income.Payee = strings.TrimSpace(record[4])
+		}
+		if len(record) > 5 {
+			income.Notes = strings.TrimSpace(record[5])
+		}
+		if len(record) > 6 {
+			income.Tags = helpers.ParseTags(record[6])
+		}
 		incomes = append(incomes, income)
 	}
 
-	return incomes, nil
+	return incomes, skipped, nil
 }
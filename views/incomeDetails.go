@@ -1,12 +1,11 @@
 package views
 
 import (
-	"fmt"
+	"context"
 	"fynance/helpers"
 	"fynance/models"
 	"fynance/utils"
-	"math"
-	"strconv"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -18,204 +17,86 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var incomeDetailList *widget.List
-
 func IncomeDetailsView(window fyne.Window, userID primitive.ObjectID) fyne.CanvasObject {
 	// Load the settings on app startup
 	settings, err := LoadSettings()
 	if err != nil {
 		dialog.ShowInformation("User Settings", "Error loading settings", window)
+		settings = DefaultSettings()
 	}
 
-	pageSize, err := strconv.Atoi(settings.PageSize) // Number of details per page
+	var detailTable *dataTable[models.IncomeDetail]
+	paths := &categoryPaths{}
 
-	if err != nil {
-		dialog.ShowError(err, window)
+	updateDetailList := func() {
+		detailTable.Reload()
 	}
 
-	var details []models.IncomeDetail
-	var currentPage int = 1
-	var totalDetails int64 = 0
-	var pageLabel *widget.Label
-	var prevButton, nextButton *widget.Button
-	var searchResults []models.IncomeDetail
-	var searchEntry *widget.Entry
-	var noResultsLabel *widget.Label
-
-	// Update visibility of no results label
-	updateNoResultsLabel := func() {
-		if len(details) == 0 {
-			noResultsLabel.Show()
-		} else {
-			noResultsLabel.Hide()
-		}
+	editDetail := func(detail models.IncomeDetail) {
+		showDetailForm(window, &detail, userID, updateDetailList)
 	}
 
-	// Load details for the specified page
-	loadIncomeDetails := func(page int) {
-		// Check if search is active
-		go func() {
-			if searchEntry.Text != "" {
-				// Use filtered details when a search query is active
-				details = searchResults
-				totalDetails = int64(len(details))
-			} else {
-				// Use all details for normal pagination
-				details = utils.GetDetailsPaginated(page, pageSize, window)
-
-				totalDetails = utils.CountDetails(window)
+	//delete detail button
+	deleteDetail := func(detail models.IncomeDetail) {
+		path := paths.path(detail.ID, detail.IncomeCategory)
+		deleteCategory(window, "income", detail.ID, utils.DeleteDetail, func() {
+			// Create a new notification
+			// fetch user by ID
+			var user = utils.GetUserByID(userID, window)
+			newNotification := models.Notification{
+				UserID:  user.ID,
+				Message: user.Username + " Deleted " + path,
+				IsRead:  false,
 			}
 
-			incomeDetailList.Refresh()
-
-			// Enable or disable pagination buttons based on the current page and total pages
-			totalPages := int(math.Ceil(float64(totalDetails) / float64(pageSize)))
-
-			// Update page label
-			pageLabel.SetText(fmt.Sprintf("Page %d of %d", currentPage, totalPages))
+			utils.AddNotification(newNotification, window)
 
-			updateNoResultsLabel()
+			////utils.PlayNotificationSound(window)
 
-			prevButton.Disable()
-			nextButton.Disable()
-			if currentPage > 1 {
-				prevButton.Enable()
-			}
-			if currentPage < totalPages {
-				nextButton.Enable()
-			}
-		}()
-	}
+			updateNotificationCount(window)
 
-	updateDetailList := func() {
-		loadIncomeDetails(currentPage)
-		updateNoResultsLabel()
+			detail := user.Username + " Deleted " + path
+			utils.Logger(detail, "SUCCESS", window)
+			updateDetailList()
+			dialog.ShowInformation("Success", "Income Detail deleted successfully!", window)
+		})
 	}
 
-	// Header Row with Titles
-	titleRow := container.NewGridWithColumns(2,
-		widget.NewLabelWithStyle("Categories", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		widget.NewLabelWithStyle("Actions", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-	)
-
-	// Create the details list
-	incomeDetailList = widget.NewList(
-		func() int {
-			return len(details)
+	// Create the details table
+	detailTable = newDataTable(
+		[]tableColumn[models.IncomeDetail]{
+			{Title: "Category", Field: "income_category", Width: 300, Text: func(detail models.IncomeDetail) string {
+				return paths.path(detail.ID, detail.IncomeCategory)
+			}},
+			{Title: "Added", Field: "created_at", Width: 170, Text: func(detail models.IncomeDetail) string {
+				return detail.CreatedAt.Format("2006-01-02 15:04")
+			}},
 		},
-		func() fyne.CanvasObject {
-
-			// income category label
-			incomeCategLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{})
-			incomeCategLabel.Truncation = fyne.TextTruncation(fyne.TextTruncateEllipsis)
-
-			editButton := widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), nil)
-			deleteButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), nil)
-
-			row := container.NewGridWithColumns(2,
-				incomeCategLabel,
-				container.NewHBox(editButton, deleteButton),
-			)
-			return row
-		},
-		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			detail := details[id]
-			row := obj.(*fyne.Container)
-
-			// Retrieve the components in the row
-			incomeCategLabel := row.Objects[0].(*widget.Label)
-
-			editButton := row.Objects[1].(*fyne.Container).Objects[0].(*widget.Button)
-			deleteButton := row.Objects[1].(*fyne.Container).Objects[1].(*widget.Button)
-
-			incomeCategLabel.SetText(detail.IncomeCategory)
-
-			editButton.OnTapped = func() {
-				showDetailForm(window, &detail, userID, updateDetailList)
-			}
-
-			//delete detail button
-			deleteButton.OnTapped = func() {
-				dialog.ShowConfirm("Delete Income Detail", "Are you sure you want to delete this detail?",
-					func(ok bool) {
-						if ok {
-							err = utils.DeleteDetail(detail.ID, window)
-
-							if err != nil {
-								dialog.ShowError(err, window)
-							} else {
-								// Create a new notification
-								// fetch user by ID
-								var user = utils.GetUserByID(userID, window)
-								newNotification := models.Notification{
-									UserID:  user.ID,
-									Message: user.Username + " Deleted " + detail.IncomeCategory,
-									IsRead:  false,
-								}
-
-								utils.AddNotification(newNotification, window)
-
-								////utils.PlayNotificationSound(window)
-
-								updateNotificationCount(window)
-
-								detail := user.Username + " Deleted " + detail.IncomeCategory
-								utils.Logger(detail, "SUCCESS", window)
-								updateDetailList()
-								dialog.ShowInformation("Success", "Income Detail deleted successfully!", window)
-							}
-
-						}
-					}, window)
-			}
+		[]tableAction[models.IncomeDetail]{
+			{Icon: theme.DocumentCreateIcon(), Tapped: editDetail},
+			{Icon: theme.DeleteIcon(), Tapped: deleteDetail},
 		},
+		int(settings.PageSize),
+		func(detail models.IncomeDetail) primitive.ObjectID { return detail.ID },
+		withTree(paths, "income", window, func(req utils.PageRequest) utils.Page[models.IncomeDetail] {
+			return utils.GetDetailsPaginated(req, window)
+		}),
 	)
 
-	// Pagination controls
-	pagination := container.NewHBox()
-	prevButton = widget.NewButton("Prev", func() {
-		if currentPage > 1 {
-			currentPage--
-			updateDetailList()
-		}
-	})
-	nextButton = widget.NewButton("Next", func() {
-		if int(math.Ceil(float64(totalDetails)/float64(pageSize))) > currentPage {
-			currentPage++
-			updateDetailList()
-		}
-	})
-
-	// Initialize page label
-	pageLabel = widget.NewLabel(fmt.Sprintf("Page %d of %d", currentPage, int(math.Ceil(float64(totalDetails)/float64(pageSize)))))
-
-	// Add buttons and label to the pagination container
-	pagination.Add(prevButton)
-	pagination.Add(pageLabel)
-	pagination.Add(nextButton)
-
-	// Center the pagination controls
-	pagination = container.NewCenter(pagination)
-
 	addDetailButton := widget.NewButton("Add Category", func() {
 		showDetailForm(window, nil, userID, updateDetailList)
 	})
 
 	// Search functionality
-	searchEntry = widget.NewEntry()
+	searchEntry := widget.NewEntry()
 	searchEntry.SetPlaceHolder("Search Category")
 	searchButton := widget.NewButtonWithIcon("", theme.SearchIcon(), func() {
 		searchText := searchEntry.Text
 		if searchText != "" {
-			searchResults = utils.SearchDetails(searchText, window)
-			updateNoResultsLabel()
-			currentPage = 1 // Reset to first page of search results
-			updateDetailList()
+			detailTable.SetFilter(utils.DetailSearchFilter(searchText))
 		} else {
-			// If search is cleared, reset the pagination and detail list
-			searchResults = nil
-			currentPage = 1
-			updateDetailList()
+			// If search is cleared, show every detail again
+			detailTable.SetFilter(nil)
 		}
 	})
 
@@ -227,20 +108,10 @@ func IncomeDetailsView(window fyne.Window, userID primitive.ObjectID) fyne.Canva
 	// the search entry and bulk upload button
 	searchContainer := container.New(layout.NewGridLayout(2), searchEntry, searchButton)
 
-	// No results label
-	noResultsLabel = widget.NewLabel("No results found")
-	noResultsLabel.Hide() // Hide by default
-
-	// Load the initial set of details
-	updateDetailList()
-
 	// grid for the add detail and export details button
 	exportButtonContainer := container.New(layout.NewGridLayout(1), addDetailButton)
 
-	// Define the container for the list with pagination controls
-	listContainer := container.NewBorder(titleRow, nil, nil, nil, incomeDetailList, noResultsLabel)
-
-	listWrapper := container.NewBorder(exportButtonContainer, pagination, nil, nil, listContainer)
+	listWrapper := container.NewBorder(exportButtonContainer, nil, nil, nil, detailTable.Widget())
 
 	// Return the final container with all elements
 	return container.NewBorder(nil, nil, nil, nil, container.NewBorder(searchContainer, nil, nil, nil, listWrapper))
@@ -262,19 +133,26 @@ func showDetailForm(window fyne.Window, existing *models.IncomeDetail, UserID pr
 	incomeCategory := widget.NewEntry()
 	incomeCategory.SetPlaceHolder("eg Dividends")
 	incomeCategory.SetText(detail.IncomeCategory)
+	parent, parentID := parentSelect("income", detail.ID, detail.ParentID, window)
 
 	// Create form
 	form := &widget.Form{
 		Items: []*widget.FormItem{
 			{Text: "Income Category", Widget: incomeCategory},
+			{Text: "Parent", Widget: parent},
 		},
 		OnSubmit: func() {
-			detail.IncomeCategory = incomeCategory.Text
+			detail.IncomeCategory = strings.TrimSpace(incomeCategory.Text)
+			detail.ParentID = parentID()
 
-			if incomeCategory.Text == "" {
+			if detail.IncomeCategory == "" {
 				dialog.ShowInformation("Income Detail", "All fields are required", window)
 				return
 			}
+			if err := utils.CheckCategory(context.TODO(), "income", detail.ID, detail.IncomeCategory, detail.ParentID); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
 
 			if isEdit {
 				parsedTime, err := time.Parse("02-01-2006 15:04:05", time.Now().Format("02-01-2006 15:04:05"))
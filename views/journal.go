@@ -0,0 +1,97 @@
+package views
+
+import (
+	"encoding/json"
+	"fmt"
+	"fynance/models"
+	"fynance/utils"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// pendingButton shows how many offline changes wait to be synced
+var pendingButton *widget.Button
+
+// showPendingChanges updates the header with the number of journal entries
+func showPendingChanges(pending int) {
+	button := pendingButton
+	if button == nil {
+		return
+	}
+	if pending == 0 {
+		button.Hide()
+		return
+	}
+	button.SetText(fmt.Sprintf("%d pending", pending))
+	button.Show()
+}
+
+// describeEntry names the record a journal entry changes
+func describeEntry(entry models.JournalEntry) string {
+	var fields map[string]any
+	json.Unmarshal(entry.Document, &fields)
+
+	name := ""
+	for _, key := range []string{"category", "income_category", "expense_category", "details", "message"} {
+		if value, ok := fields[key].(string); ok && value != "" {
+			name = value
+			break
+		}
+	}
+	if name == "" {
+		name = entry.DocID.Hex()
+	}
+	return entry.Op + " " + entry.Collection + ": " + name
+}
+
+// showJournalDialog lists the changes made offline, letting the user keep or
+// discard the ones that conflict with the server
+func showJournalDialog(window fyne.Window) {
+	entries := container.NewVBox()
+
+	var refresh func()
+	refresh = func() {
+		entries.Objects = nil
+		pending := utils.PendingChanges()
+		if len(pending) == 0 {
+			entries.Add(widget.NewLabel("Everything is synced"))
+		}
+		for _, entry := range pending {
+			label := widget.NewLabel(entry.QueuedAt.Format("2006-01-02 15:04:05") + "  " + describeEntry(entry))
+			if entry.Conflict == "" {
+				entries.Add(label)
+				continue
+			}
+
+			reason := widget.NewLabel("Conflict: " + entry.Conflict)
+			reason.Importance = widget.DangerImportance
+			keep := widget.NewButton("Keep Mine", func() {
+				utils.ResolveConflict(entry.ID, true)
+				refresh()
+			})
+			discard := widget.NewButton("Discard", func() {
+				dialog.ShowConfirm("Discard Change", "Drop this offline change and keep the server's version?", func(ok bool) {
+					if ok {
+						utils.ResolveConflict(entry.ID, false)
+						refresh()
+					}
+				}, window)
+			})
+			entries.Add(container.NewBorder(nil, nil, nil, container.NewHBox(keep, discard),
+				container.NewVBox(label, reason)))
+		}
+		entries.Refresh()
+	}
+	refresh()
+
+	help := widget.NewLabel("Changes made while the database was offline are synced in order once it is back.\n" +
+		"A change to a record that was also changed on the server waits for you to decide.")
+
+	content := container.NewBorder(help, nil, nil, nil, container.NewVScroll(entries))
+	journalDialog := dialog.NewCustom("Pending Changes", "Close", content, window)
+	journalDialog.Resize(fyne.NewSize(650, 400))
+	journalDialog.Show()
+}
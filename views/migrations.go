@@ -0,0 +1,42 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fynance/migrations"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// MigrateDatabase brings the database up to this build's schema before
+// anyone logs in. It returns false, leaving an explanation in the window,
+// when the app must not continue.
+func MigrateDatabase(window fyne.Window) bool {
+	_, err := migrations.Run(context.Background(), false)
+	if err == nil {
+		return true
+	}
+
+	message := "Fynance could not update the database: " + err.Error()
+	if errors.Is(err, migrations.ErrDatabaseNewer) {
+		message = fmt.Sprintf("This database was last opened by a newer version of Fynance (%v).\n"+
+			"Update Fynance to keep using it; nothing was changed.", err)
+	}
+
+	label := widget.NewLabel(message)
+	label.Wrapping = fyne.TextWrapWord
+	closeButton := widget.NewButton("Quit", func() {
+		fyne.CurrentApp().Quit()
+	})
+	databaseButton := widget.NewButton("Database Settings", func() {
+		showDatabaseDialog(window)
+	})
+	window.SetContent(container.NewCenter(container.NewGridWrap(fyne.NewSize(520, 200),
+		container.NewVBox(label, container.NewGridWithColumns(2, databaseButton, closeButton)))))
+	dialog.ShowError(err, window)
+	return false
+}
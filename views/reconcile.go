@@ -0,0 +1,489 @@
+package views
+
+import (
+	"context"
+	"fmt"
+	"fynance/models"
+	"fynance/utils"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// matchLabels say how each statement line was matched
+var matchLabels = map[string]string{
+	models.MatchAuto:    "matched",
+	models.MatchManual:  "matched by hand",
+	models.MatchCreated: "added",
+}
+
+// isReconciled tells the user a record is locked by a reconciliation, and
+// reports whether it is
+func isReconciled(window fyne.Window, label string, reconciliationID primitive.ObjectID) bool {
+	if reconciliationID.IsZero() {
+		return false
+	}
+	dialog.ShowInformation(label, "This "+strings.ToLower(label)+" is reconciled with a bank statement and can't be changed. "+
+		"Reopen its reconciliation under Reconcile to change it.", window)
+	return true
+}
+
+// reconciliationName describes a reconciliation in the list to choose from
+func reconciliationName(rec models.Reconciliation) string {
+	status := "open"
+	if rec.Status == models.ReconciliationComplete {
+		status = "complete"
+	}
+	return fmt.Sprintf("%s, %s to %s (%s)", rec.FileName, rec.From.Format("2006-01-02"), rec.To.Format("2006-01-02"), status)
+}
+
+// bookRow is an unmatched income or expense as the reconciliation lists it
+type bookRow struct {
+	kind   string
+	record models.Income
+}
+
+// ReconcileView compares a bank statement with the recorded incomes and
+// expenses. Lines are matched automatically by amount and date, by hand, or
+// to records added from them, and completing the reconciliation once the
+// difference is zero locks the matched records.
+func ReconcileView(window fyne.Window, userID primitive.ObjectID) fyne.CanvasObject {
+	header := Header(window)
+	footer := Footer(window)
+
+	var (
+		reconciliations []models.Reconciliation
+		rec             *models.Reconciliation
+		book            utils.Book
+		records         map[primitive.ObjectID]models.Income // every record of the book by ID
+		unmatched       []bookRow
+		selectedLine    = -1
+		selectedRecord  = -1
+	)
+
+	// notify logs a reconciliation step and tells the user about it
+	notify := func(detail string) {
+		user := utils.GetUserByID(userID, window)
+		utils.AddNotification(models.Notification{
+			UserID:  user.ID,
+			Message: detail,
+			IsRead:  false,
+		}, window)
+		updateNotificationCount(window)
+		utils.Logger(user.Username+" "+detail, "SUCCESS", window)
+	}
+
+	totalsLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
+	lineList := widget.NewList(
+		func() int {
+			if rec == nil {
+				return 0
+			}
+			return len(rec.Lines)
+		},
+		func() fyne.CanvasObject {
+			return container.NewGridWithColumns(4, widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			line := rec.Lines[id]
+			status := "unmatched"
+			if !line.RecordID.IsZero() {
+				status = matchLabels[line.MatchedBy]
+				if record, ok := records[line.RecordID]; ok {
+					status += ": " + record.Category
+				}
+			}
+			row := obj.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(line.Date.Format("2006-01-02"))
+			row.Objects[1].(*widget.Label).SetText(line.Description)
+			row.Objects[2].(*widget.Label).SetText(formatBalance(line.Amount))
+			row.Objects[3].(*widget.Label).SetText(status)
+		},
+	)
+	lineList.OnSelected = func(id widget.ListItemID) { selectedLine = id }
+
+	recordList := widget.NewList(
+		func() int {
+			return len(unmatched)
+		},
+		func() fyne.CanvasObject {
+			return container.NewGridWithColumns(4, widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			row := unmatched[id]
+			amount := row.record.Amount
+			if row.kind == "expense" {
+				amount = -amount
+			}
+			objects := obj.(*fyne.Container).Objects
+			objects[0].(*widget.Label).SetText(row.record.Month + " " + row.record.Year)
+			objects[1].(*widget.Label).SetText(row.record.Category)
+			objects[2].(*widget.Label).SetText(row.record.Payee)
+			objects[3].(*widget.Label).SetText(formatBalance(amount))
+		},
+	)
+	recordList.OnSelected = func(id widget.ListItemID) { selectedRecord = id }
+
+	var matchButton, unmatchButton, createButton, autoButton, completeButton, reopenButton, deleteButton *widget.Button
+	var refresh func()
+
+	// refresh shows the matches of rec, and what is left on either side
+	refresh = func() {
+		records = map[primitive.ObjectID]models.Income{}
+		for _, kind := range []string{"income", "expense"} {
+			for _, record := range book.Records(kind) {
+				records[record.ID] = record
+			}
+		}
+		unmatched = nil
+		selectedLine, selectedRecord = -1, -1
+		lineList.UnselectAll()
+		recordList.UnselectAll()
+
+		editable := []*widget.Button{matchButton, unmatchButton, createButton, autoButton, completeButton}
+		if rec == nil {
+			totalsLabel.SetText("Load a statement, or choose one to carry on with")
+			for _, button := range append(editable, reopenButton, deleteButton) {
+				button.Disable()
+			}
+			lineList.Refresh()
+			recordList.Refresh()
+			return
+		}
+
+		left := utils.Unmatched(*rec, book)
+		for _, kind := range []string{"income", "expense"} {
+			for _, record := range left.Records(kind) {
+				unmatched = append(unmatched, bookRow{kind, record})
+			}
+		}
+
+		statement, cleared, difference := utils.ReconciliationTotals(*rec)
+		totalsLabel.SetText(fmt.Sprintf("Statement %s    Matched %s    Difference %s",
+			formatBalance(statement), formatBalance(cleared), formatBalance(difference)))
+
+		complete := rec.Status == models.ReconciliationComplete
+		for _, button := range editable {
+			if complete {
+				button.Disable()
+			} else {
+				button.Enable()
+			}
+		}
+		if complete {
+			reopenButton.Enable()
+		} else {
+			reopenButton.Disable()
+		}
+		deleteButton.Enable()
+		lineList.Refresh()
+		recordList.Refresh()
+	}
+
+	reconciliationSelect := widget.NewSelect(nil, nil)
+	reconciliationSelect.PlaceHolder = "Reconciliations"
+
+	// open shows a reconciliation with the records it can match
+	open := func(chosen models.Reconciliation) {
+		loaded, err := utils.LoadBook(context.Background(), chosen)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		rec, book = &chosen, loaded
+		refresh()
+	}
+
+	reloadReconciliations := func() {
+		var err error
+		reconciliations, err = utils.ListReconciliations(context.Background())
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		var names []string
+		for _, r := range reconciliations {
+			names = append(names, reconciliationName(r))
+		}
+		reconciliationSelect.OnChanged = nil
+		reconciliationSelect.Options = names
+		reconciliationSelect.ClearSelected()
+		if rec != nil {
+			for _, r := range reconciliations {
+				if r.ID == rec.ID {
+					reconciliationSelect.SetSelected(reconciliationName(r))
+				}
+			}
+		}
+		reconciliationSelect.OnChanged = func(name string) {
+			for _, r := range reconciliations {
+				if reconciliationName(r) == name {
+					open(r)
+				}
+			}
+		}
+		reconciliationSelect.Refresh()
+	}
+
+	// save keeps the matches of rec
+	save := func() {
+		if err := utils.SaveReconciliation(context.Background(), rec); err != nil {
+			dialog.ShowError(err, window)
+		}
+		refresh()
+	}
+
+	matchButton = widget.NewButtonWithIcon("Match", theme.ConfirmIcon(), func() {
+		if selectedLine < 0 || selectedRecord < 0 {
+			dialog.ShowInformation("Match", "Choose a statement line and the record to match it to.", window)
+			return
+		}
+		row := unmatched[selectedRecord]
+		line := rec.Lines[selectedLine]
+		match := func() {
+			if err := utils.MatchLine(rec, selectedLine, row.kind, row.record, models.MatchManual); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			save()
+		}
+		if math.Abs(row.record.Amount-math.Abs(line.Amount)) >= 0.005 {
+			message := fmt.Sprintf("The line is for %s but the record for %s, so the difference won't reach zero. Match them anyway?",
+				formatBalance(math.Abs(line.Amount)), formatBalance(row.record.Amount))
+			dialog.ShowConfirm("Match", message, func(ok bool) {
+				if ok {
+					match()
+				}
+			}, window)
+			return
+		}
+		match()
+	})
+
+	unmatchButton = widget.NewButtonWithIcon("Unmatch", theme.ContentUndoIcon(), func() {
+		if selectedLine < 0 || rec.Lines[selectedLine].RecordID.IsZero() {
+			dialog.ShowInformation("Unmatch", "Choose a matched statement line.", window)
+			return
+		}
+		utils.UnmatchLine(rec, selectedLine)
+		save()
+	})
+
+	createButton = widget.NewButtonWithIcon("Add Record", theme.ContentAddIcon(), func() {
+		if selectedLine < 0 || !rec.Lines[selectedLine].RecordID.IsZero() {
+			dialog.ShowInformation("Add Record", "Choose an unmatched statement line to add to the records.", window)
+			return
+		}
+		showCreateFromLine(window, rec, selectedLine, userID, func(record models.Income, kind string) {
+			if kind == "income" {
+				book.Incomes = append(book.Incomes, record)
+			} else {
+				book.Expenses = append(book.Expenses, record)
+			}
+			notify(fmt.Sprintf("added %s %s from the statement %s", kind, record.Category, rec.FileName))
+			refresh()
+		})
+	})
+
+	autoButton = widget.NewButtonWithIcon("Auto Match", theme.ViewRefreshIcon(), func() {
+		matched := utils.AutoMatch(rec, book)
+		save()
+		dialog.ShowInformation("Auto Match", fmt.Sprintf("%d more lines matched.", matched), window)
+	})
+
+	completeButton = widget.NewButtonWithIcon("Complete", theme.DocumentSaveIcon(), func() {
+		message := fmt.Sprintf("Lock the %d matched records? They can't be edited or deleted until the reconciliation is reopened.", len(rec.Lines))
+		dialog.ShowConfirm("Complete Reconciliation", message, func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := utils.CompleteReconciliation(context.Background(), rec); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			notify("reconciled the statement " + rec.FileName)
+			reloadReconciliations()
+			refresh()
+			dialog.ShowInformation("Reconciled", "The books match the statement.", window)
+		}, window)
+	})
+
+	reopenButton = widget.NewButtonWithIcon("Reopen", theme.ContentRedoIcon(), func() {
+		dialog.ShowConfirm("Reopen Reconciliation", "Unlock the records of "+rec.FileName+" so the matches can be changed?", func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := utils.ReopenReconciliation(context.Background(), rec.ID); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			notify("reopened the reconciliation of " + rec.FileName)
+			rec.Status, rec.CompletedAt = models.ReconciliationOpen, time.Time{}
+			reloadReconciliations()
+			refresh()
+		}, window)
+	})
+
+	deleteButton = widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		dialog.ShowConfirm("Delete Reconciliation", "Delete the reconciliation of "+rec.FileName+"? Its records are unlocked and stay as they are.", func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := utils.DeleteReconciliation(context.Background(), rec.ID); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			notify("deleted the reconciliation of " + rec.FileName)
+			rec, book = nil, utils.Book{}
+			reloadReconciliations()
+			refresh()
+		}, window)
+	})
+
+	newButton := widget.NewButtonWithIcon("Load Statement", theme.FolderOpenIcon(), func() {
+		openFileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if reader == nil {
+				return
+			}
+			defer reader.Close()
+
+			file, err := os.Open(reader.URI().Path())
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			defer file.Close()
+			lines, err := utils.ParseStatement(file)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			showStatementForm(window, reader.URI().Name(), lines, userID, func(started models.Reconciliation, matched int) {
+				notify(fmt.Sprintf("started reconciling the statement %s", started.FileName))
+				rec = &started
+				reloadReconciliations()
+				open(started)
+				dialog.ShowInformation("Statement Loaded",
+					fmt.Sprintf("%d of %d lines matched automatically.", matched, len(started.Lines)), window)
+			})
+		}, window)
+		openFileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".csv"}))
+		openFileDialog.Show()
+	})
+
+	reloadReconciliations()
+	refresh()
+
+	heading := func(text string) fyne.CanvasObject {
+		return widget.NewLabelWithStyle(text, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	}
+	split := container.NewHSplit(
+		container.NewBorder(heading("Statement"), nil, nil, nil, lineList),
+		container.NewBorder(heading("Unmatched Records"), nil, nil, nil, recordList),
+	)
+	split.Offset = 0.55
+
+	toolbar := container.NewBorder(nil, nil, newButton, container.NewHBox(reopenButton, deleteButton), reconciliationSelect)
+	actions := container.NewHBox(matchButton, unmatchButton, createButton, autoButton, completeButton)
+	content := container.NewBorder(toolbar, container.NewBorder(nil, nil, nil, actions, totalsLabel), nil, nil, split)
+	return container.NewBorder(header, footer, nil, nil, content)
+}
+
+// showStatementForm asks for the account and period of a statement, then
+// starts reconciling its lines
+func showStatementForm(window fyne.Window, fileName string, lines []models.StatementLine, userID primitive.ObjectID, onStarted func(models.Reconciliation, int)) {
+	account, accountID := accountSelect(window, primitive.NilObjectID)
+
+	fromEntry := widget.NewEntry()
+	fromEntry.SetText(lines[0].Date.Format("2006-01-02"))
+	toEntry := widget.NewEntry()
+	toEntry.SetText(lines[len(lines)-1].Date.Format("2006-01-02"))
+
+	form := dialog.NewForm("Reconcile "+fileName, "Start", "Cancel", []*widget.FormItem{
+		{Text: "Account", Widget: account, HintText: "Only its records are matched, or every record with (none)"},
+		{Text: "From", Widget: fromEntry, HintText: "YYYY-MM-DD"},
+		{Text: "To", Widget: toEntry, HintText: "YYYY-MM-DD"},
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		from, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(fromEntry.Text), time.Local)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("invalid date %q", fromEntry.Text), window)
+			return
+		}
+		to, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(toEntry.Text), time.Local)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("invalid date %q", toEntry.Text), window)
+			return
+		}
+
+		started, matched, err := utils.StartReconciliation(context.Background(), models.Reconciliation{
+			FileName:  fileName,
+			AccountID: accountID(),
+			From:      from,
+			To:        to,
+			Lines:     lines,
+			CreatedBy: userID,
+		})
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		onStarted(started, matched)
+	}, window)
+	form.Resize(fyne.NewSize(480, 0))
+	form.Show()
+}
+
+// showCreateFromLine adds the income or expense a statement line is missing
+// from the records, with the category the rules suggest
+func showCreateFromLine(window fyne.Window, rec *models.Reconciliation, i int, userID primitive.ObjectID, onCreated func(models.Income, string)) {
+	line := rec.Lines[i]
+	kind := utils.LineKind(line)
+
+	categorySelect := widget.NewSelect(utils.GetCategoryTree(kind, window).Paths(), nil)
+	if rules, err := utils.LoadRules(context.Background(), kind); err == nil {
+		if rule, ok := rules.Match(line.Description, "", math.Abs(line.Amount)); ok && rule.Category != "" {
+			categorySelect.SetSelected(rule.Category)
+		}
+	}
+
+	details := widget.NewLabel(fmt.Sprintf("%s  %s  %s", line.Date.Format("2006-01-02"), line.Description, formatBalance(line.Amount)))
+	details.Wrapping = fyne.TextWrapWord
+
+	title := "Add Income"
+	if kind == "expense" {
+		title = "Add Expense"
+	}
+	form := dialog.NewForm(title, "Add", "Cancel", []*widget.FormItem{
+		{Text: "Line", Widget: details},
+		{Text: "Category", Widget: categorySelect},
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		record, err := utils.CreateFromLine(context.Background(), rec, i, categorySelect.Selected, userID)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		onCreated(record, kind)
+	}, window)
+	form.Resize(fyne.NewSize(480, 0))
+	form.Show()
+}
@@ -1,13 +1,18 @@
 package views
 
 import (
+	"context"
 	"fynance/helpers"
 	"fynance/models"
 	"fynance/utils"
 	"strconv"
+	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/widget"
 )
 
@@ -15,7 +20,10 @@ var reportList *widget.List
 
 func Report(window fyne.Window) fyne.CanvasObject {
 	var reports []models.Report
+	var tagTotals []models.TagTotal
+	var categoryTotals []models.CategoryTotal
 	var noResultsLabel *widget.Label
+	var tagList, categoryList *widget.List
 
 	header := Header(window)
 	footer := Footer(window)
@@ -37,6 +45,20 @@ func Report(window fyne.Window) fyne.CanvasObject {
 
 			reportList.Refresh()
 
+			totals, err := utils.TagReport(context.Background(), time.Now().Format("2006"))
+			if err != nil {
+				dialog.ShowError(err, window)
+			}
+			tagTotals = totals
+			tagList.Refresh()
+
+			categories, err := utils.CategoryReport(context.Background(), "", time.Now().Format("2006"))
+			if err != nil {
+				dialog.ShowError(err, window)
+			}
+			categoryTotals = categories
+			categoryList.Refresh()
+
 			updateNoResultsLabel()
 		}()
 	}
@@ -107,13 +129,111 @@ func Report(window fyne.Window) fyne.CanvasObject {
 		},
 	)
 
+	// Totals by tag, for the tags that cut across categories
+	tagTitleRow := container.NewGridWithColumns(4,
+		widget.NewLabelWithStyle("Tag", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Records", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Total Income", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Total Expenses", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+	)
+	tagList = widget.NewList(
+		func() int {
+			return len(tagTotals)
+		},
+		func() fyne.CanvasObject {
+			tagLabel := widget.NewLabel("")
+			tagLabel.Truncation = fyne.TextTruncateEllipsis
+			return container.NewGridWithColumns(4, tagLabel, widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			total := tagTotals[id]
+			row := obj.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(total.Tag)
+			row.Objects[1].(*widget.Label).SetText(strconv.Itoa(total.Count))
+			row.Objects[2].(*widget.Label).SetText(strconv.FormatFloat(total.TotalIncome, 'f', -1, 64))
+			row.Objects[3].(*widget.Label).SetText(strconv.FormatFloat(total.TotalExpense, 'f', -1, 64))
+		},
+	)
+	tagHeading := widget.NewLabelWithStyle("Totals by Tag", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	tagContainer := container.NewBorder(container.NewVBox(tagHeading, tagTitleRow), nil, nil, nil, tagList)
+
+	// Totals by category, subcategories indented under and rolled up into
+	// their parents
+	categoryTitleRow := container.NewGridWithColumns(4,
+		widget.NewLabelWithStyle("Category", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Type", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Records", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Total", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+	)
+	categoryList = widget.NewList(
+		func() int {
+			return len(categoryTotals)
+		},
+		func() fyne.CanvasObject {
+			categoryLabel := widget.NewLabel("")
+			categoryLabel.Truncation = fyne.TextTruncateEllipsis
+			return container.NewGridWithColumns(4, categoryLabel, widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			total := categoryTotals[id]
+			name := total.Category
+			if total.Depth > 0 {
+				name = strings.Repeat("    ", total.Depth) + total.Name
+			}
+			row := obj.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(name)
+			row.Objects[1].(*widget.Label).SetText(total.Type)
+			row.Objects[2].(*widget.Label).SetText(strconv.Itoa(total.Count))
+			row.Objects[3].(*widget.Label).SetText(strconv.FormatFloat(total.Total, 'f', -1, 64))
+		},
+	)
+	categoryHeading := widget.NewLabelWithStyle("Totals by Category", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	categoryContainer := container.NewBorder(container.NewVBox(categoryHeading, categoryTitleRow), nil, nil, nil, categoryList)
+
 	// No results label
 	noResultsLabel = widget.NewLabel("No results found")
 	noResultsLabel.Hide() // Hide by default
 
+	// Export the whole workbook (incomes, expenses, categories and this report)
+	exportToExcel := widget.NewButton("export to excel", func() {
+		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if writer == nil {
+				return // user cancelled
+			}
+
+			progress := widget.NewProgressBarInfinite()
+			progressDialog := dialog.NewCustomWithoutButtons("Exporting Workbook", progress, window)
+			progressDialog.Show()
+
+			go func() {
+				defer writer.Close()
+
+				err := utils.ExportWorkbook(writer)
+				progressDialog.Hide()
+				if err != nil {
+					dialog.ShowError(err, window)
+					return
+				}
+
+				utils.Logger("Exported workbook to "+writer.URI().Name(), "SUCCESS", window)
+				dialog.ShowInformation("Export Successful", "Workbook saved to "+writer.URI().Path(), window)
+			}()
+		}, window)
+		saveDialog.SetFileName("fynance-" + time.Now().Format("2006") + ".xlsx")
+		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".xlsx"}))
+		saveDialog.Show()
+	})
+
 	updateReportList()
 
 	listContainer := container.NewBorder(titleRow, nil, nil, nil, reportList, noResultsLabel)
 
-	return container.NewBorder(header, footer, nil, nil, listContainer)
+	reportSplit := container.NewVSplit(listContainer, container.NewHSplit(categoryContainer, tagContainer))
+	reportSplit.Offset = 0.65
+
+	return container.NewBorder(header, footer, nil, nil, container.NewBorder(exportToExcel, nil, nil, nil, reportSplit))
 }
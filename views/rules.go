@@ -0,0 +1,305 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"fynance/helpers"
+	"fynance/models"
+	"fynance/utils"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// keepCategory is the category choice of a rule that only adds tags
+const keepCategory = "(keep)"
+
+// ruleKinds maps the rule type choices to rule kinds
+var ruleKinds = map[string]string{"Income": "income", "Expense": "expense"}
+
+// ruleLabel is the type choice of a rule kind
+func ruleLabel(kind string) string {
+	for label, k := range ruleKinds {
+		if k == kind {
+			return label
+		}
+	}
+	return kind
+}
+
+// RulesView lists the categorisation rules of a type in the order they are
+// tried, with actions to add, edit, preview and delete them and to apply all
+// of them to the existing records
+func RulesView(window fyne.Window) fyne.CanvasObject {
+	kindSelect := widget.NewSelect([]string{"Income", "Expense"}, nil)
+	ruleList := container.NewVBox()
+
+	var refreshRules func()
+	refreshRules = func() {
+		kind := ruleKinds[kindSelect.Selected]
+		rules, err := utils.ListRules(context.Background(), kind)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		ruleList.Objects = nil
+		if len(rules) == 0 {
+			ruleList.Add(widget.NewLabel("No " + strings.ToLower(kindSelect.Selected) + " rules yet"))
+		}
+		for _, rule := range rules {
+			status := ""
+			if !rule.Active {
+				status = " (paused)"
+			}
+			label := widget.NewLabel(fmt.Sprintf("%d. %s%s\n%s", rule.Priority, rule.Name, status, describeRule(rule)))
+			label.Wrapping = fyne.TextWrapWord
+
+			actions := container.NewHBox(
+				widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), func() {
+					showRuleForm(window, &rule, refreshRules)
+				}),
+				widget.NewButtonWithIcon("", theme.VisibilityIcon(), func() {
+					showRulePreview(window, kindSelect.Selected, rule.Name, utils.CompileRules([]models.Rule{rule}))
+				}),
+				widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+					dialog.ShowConfirm("Delete Rule", "Delete the rule "+rule.Name+"? Records it already changed stay as they are.", func(ok bool) {
+						if !ok {
+							return
+						}
+						if err := utils.DeleteRule(context.Background(), rule.ID); err != nil {
+							dialog.ShowError(err, window)
+							return
+						}
+						utils.Logger("Deleted rule "+rule.Name, "SUCCESS", window)
+						refreshRules()
+					}, window)
+				}),
+			)
+			ruleList.Add(container.NewBorder(nil, nil, nil, actions, label))
+		}
+		ruleList.Refresh()
+	}
+	kindSelect.OnChanged = func(string) { refreshRules() }
+	kindSelect.SetSelected("Income")
+
+	addButton := widget.NewButtonWithIcon("Add Rule", theme.ContentAddIcon(), func() {
+		showRuleForm(window, &models.Rule{Kind: ruleKinds[kindSelect.Selected], Active: true}, refreshRules)
+	})
+	applyButton := widget.NewButtonWithIcon("Apply All Rules", theme.ConfirmIcon(), func() {
+		rules, err := utils.LoadRules(context.Background(), ruleKinds[kindSelect.Selected])
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		showRulePreview(window, kindSelect.Selected, "", rules)
+	})
+
+	help := widget.NewLabel("Imported records are categorised by the first active rule they match, lowest number first. " +
+		"Patterns are regular expressions matched against the payee and notes, ignoring case.")
+	help.Wrapping = fyne.TextWrapWord
+
+	toolbar := container.NewBorder(nil, nil, kindSelect, container.NewHBox(addButton, applyButton))
+	return container.NewBorder(container.NewVBox(toolbar, help), nil, nil, nil, container.NewVScroll(ruleList))
+}
+
+// describeRule says which records a rule matches and what it sets
+func describeRule(rule models.Rule) string {
+	var conditions []string
+	if rule.Pattern != "" {
+		conditions = append(conditions, "payee or notes match /"+rule.Pattern+"/")
+	}
+	switch {
+	case rule.MinAmount > 0 && rule.MaxAmount > 0:
+		conditions = append(conditions, fmt.Sprintf("amount from %.2f to %.2f", rule.MinAmount, rule.MaxAmount))
+	case rule.MinAmount > 0:
+		conditions = append(conditions, fmt.Sprintf("amount at least %.2f", rule.MinAmount))
+	case rule.MaxAmount > 0:
+		conditions = append(conditions, fmt.Sprintf("amount at most %.2f", rule.MaxAmount))
+	}
+
+	var actions []string
+	if rule.Category != "" {
+		actions = append(actions, "file under "+rule.Category)
+	}
+	if len(rule.Tags) > 0 {
+		actions = append(actions, "tag "+strings.Join(rule.Tags, ", "))
+	}
+	return "If " + strings.Join(conditions, " and ") + ": " + strings.Join(actions, ", ")
+}
+
+// showRuleForm adds a rule, or edits it when it has an ID
+func showRuleForm(window fyne.Window, rule *models.Rule, onSaved func()) {
+	isEdit := !rule.ID.IsZero()
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(rule.Name)
+
+	priorityEntry := widget.NewEntry()
+	priorityEntry.SetText(strconv.Itoa(rule.Priority))
+
+	patternEntry := widget.NewEntry()
+	patternEntry.SetPlaceHolder("e.g. shell|total")
+	patternEntry.SetText(rule.Pattern)
+
+	amountText := func(amount float64) string {
+		if amount == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(amount, 'f', -1, 64)
+	}
+	minEntry := widget.NewEntry()
+	minEntry.SetPlaceHolder("any")
+	minEntry.SetText(amountText(rule.MinAmount))
+	maxEntry := widget.NewEntry()
+	maxEntry.SetPlaceHolder("any")
+	maxEntry.SetText(amountText(rule.MaxAmount))
+
+	categorySelect := widget.NewSelect(append([]string{keepCategory}, utils.GetCategoryTree(rule.Kind, window).Paths()...), nil)
+	categorySelect.SetSelected(keepCategory)
+	if rule.Category != "" {
+		categorySelect.SetSelected(rule.Category)
+	}
+
+	tagsEntry := widget.NewEntry()
+	tagsEntry.SetPlaceHolder("comma separated")
+	tagsEntry.SetText(strings.Join(rule.Tags, ", "))
+
+	activeCheck := widget.NewCheck("Active", nil)
+	activeCheck.SetChecked(rule.Active)
+
+	items := []*widget.FormItem{
+		{Text: "Name", Widget: nameEntry},
+		{Text: "Priority", Widget: priorityEntry, HintText: "Lower numbers are tried first"},
+		{Text: "Payee or Notes", Widget: patternEntry, HintText: "Regular expression, leave empty to match any"},
+		{Text: "Amount From", Widget: minEntry},
+		{Text: "Amount To", Widget: maxEntry},
+		{Text: "Set Category", Widget: categorySelect},
+		{Text: "Add Tags", Widget: tagsEntry},
+		{Text: "", Widget: activeCheck},
+	}
+
+	title := "Add " + ruleLabel(rule.Kind) + " Rule"
+	if isEdit {
+		title = "Edit " + ruleLabel(rule.Kind) + " Rule"
+	}
+
+	form := dialog.NewForm(title, "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+
+		edited := *rule
+		edited.Name = strings.TrimSpace(nameEntry.Text)
+		edited.Pattern = strings.TrimSpace(patternEntry.Text)
+		edited.Tags = helpers.ParseTags(tagsEntry.Text)
+		edited.Active = activeCheck.Checked
+		edited.Category = ""
+		if categorySelect.Selected != keepCategory {
+			edited.Category = categorySelect.Selected
+		}
+
+		var err error
+		if edited.Priority, err = strconv.Atoi(strings.TrimSpace(priorityEntry.Text)); err != nil {
+			dialog.ShowError(errors.New("the priority must be a whole number"), window)
+			return
+		}
+		for _, bound := range []struct {
+			entry  *widget.Entry
+			amount *float64
+		}{{minEntry, &edited.MinAmount}, {maxEntry, &edited.MaxAmount}} {
+			*bound.amount = 0
+			if text := strings.TrimSpace(bound.entry.Text); text != "" {
+				if *bound.amount, err = strconv.ParseFloat(text, 64); err != nil {
+					dialog.ShowError(fmt.Errorf("invalid amount %q", text), window)
+					return
+				}
+			}
+		}
+
+		if isEdit {
+			if err := utils.UpdateRule(context.Background(), edited); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			utils.Logger("Edited rule "+edited.Name, "SUCCESS", window)
+		} else {
+			created, err := utils.AddRule(context.Background(), edited)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			utils.Logger("Added rule "+created.Name, "SUCCESS", window)
+		}
+		onSaved()
+	}, window)
+	form.Resize(fyne.NewSize(520, 0))
+	form.Show()
+}
+
+// showRulePreview lists the records of kind that rules would change, and
+// applies the changes when confirmed. name is that of the rule previewed, or
+// empty for all active rules.
+func showRulePreview(window fyne.Window, kind, name string, rules utils.RuleSet) {
+	progress := dialog.NewCustomWithoutButtons("Previewing Rules", widget.NewProgressBarInfinite(), window)
+	progress.Show()
+
+	go func() {
+		changes, err := utils.PreviewRules(context.Background(), ruleKinds[kind], rules)
+		progress.Hide()
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if len(changes) == 0 {
+			dialog.ShowInformation("Preview", "No "+strings.ToLower(kind)+" records would change.", window)
+			return
+		}
+
+		list := widget.NewList(
+			func() int {
+				return len(changes)
+			},
+			func() fyne.CanvasObject {
+				return container.NewGridWithColumns(4, widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""))
+			},
+			func(id widget.ListItemID, obj fyne.CanvasObject) {
+				change := changes[id]
+				category := change.NewCategory
+				if category != change.Category {
+					category = change.Category + " → " + category
+				}
+				row := obj.(*fyne.Container)
+				row.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s %s  %.2f", change.Month, change.Year, change.Amount))
+				row.Objects[1].(*widget.Label).SetText(change.Payee)
+				row.Objects[2].(*widget.Label).SetText(category)
+				row.Objects[3].(*widget.Label).SetText(strings.Join(change.NewTags, ", "))
+			},
+		)
+
+		heading := fmt.Sprintf("%d %s records would change.", len(changes), strings.ToLower(kind))
+		change := "categorised by rules"
+		if name != "" {
+			heading = fmt.Sprintf("%s would change %d %s records.", name, len(changes), strings.ToLower(kind))
+			change = "categorised by rule " + name
+		}
+		content := container.NewBorder(widget.NewLabel(heading), nil, nil, nil, list)
+
+		previewDialog := dialog.NewCustomConfirm("Preview", "Apply", "Close", content, func(ok bool) {
+			if !ok {
+				return
+			}
+			runBulk(window, kind, "changed", change, len(changes), func(ctx context.Context) (int64, error) {
+				return utils.ApplyRuleChanges(ctx, ruleKinds[kind], changes)
+			}, func() {})
+		}, window)
+		previewDialog.Resize(fyne.NewSize(760, 460))
+		previewDialog.Show()
+	}()
+}
@@ -0,0 +1,298 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fynance/helpers"
+	"fynance/models"
+	"fynance/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// logStatuses are the statuses log entries are written with
+var logStatuses = []string{"SUCCESS", "ERROR"}
+
+// searchTarget says how a list is searched
+type searchTarget struct {
+	filter     func(utils.Query) (bson.M, error)
+	categories func() []string // nil for lists without categories, such as logs
+	logs       bool
+}
+
+// transactionSearch searches incomes or expenses, with categories the ones
+// of their kind
+func transactionSearch(categories func() []string) searchTarget {
+	return searchTarget{
+		filter: func(q utils.Query) (bson.M, error) {
+			return q.TransactionFilter(context.Background())
+		},
+		categories: categories,
+	}
+}
+
+// logSearch searches the activity log
+var logSearch = searchTarget{
+	filter: func(q utils.Query) (bson.M, error) { return q.LogFilter() },
+	logs:   true,
+}
+
+// searchBar is the query bar of a list: an entry for the query, a form to
+// build its filters and the user's saved searches, which work in every list
+// whose filters they use. onSearch is given the filter of the query, nil
+// once the bar is cleared.
+func searchBar(window fyne.Window, target searchTarget, onSearch func(bson.M)) fyne.CanvasObject {
+	entry := widget.NewEntry()
+	if target.logs {
+		entry.SetPlaceHolder("Search, e.g. imported from:2026-01 status:ERROR by:admin")
+	} else {
+		entry.SetPlaceHolder("Search, e.g. fuel amount>=50 from:2026-01 tag:work")
+	}
+
+	search := func() {
+		if strings.TrimSpace(entry.Text) == "" {
+			onSearch(nil)
+			return
+		}
+		q, err := utils.ParseQuery(entry.Text)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		filter, err := target.filter(q)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		onSearch(filter)
+	}
+	entry.OnSubmitted = func(string) { search() }
+
+	searchButton := widget.NewButtonWithIcon("", theme.SearchIcon(), search)
+	filtersButton := widget.NewButtonWithIcon("Filters", theme.ListIcon(), func() {
+		showSearchFilters(window, target, entry, search)
+	})
+
+	// the saved searches of the signed in user
+	var saved []models.SavedSearch
+	savedSelect := widget.NewSelect(nil, func(name string) {
+		for _, s := range saved {
+			if s.Name == name {
+				entry.SetText(s.Query)
+				search()
+			}
+		}
+	})
+	savedSelect.PlaceHolder = "Saved Searches"
+	reloadSaved := func() {
+		go func() {
+			searches, err := utils.ListSavedSearches(context.Background(), helpers.CurrentUserID)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			saved = searches
+			var names []string
+			for _, s := range searches {
+				names = append(names, s.Name)
+			}
+			savedSelect.Options = names
+			savedSelect.Refresh()
+		}()
+	}
+	reloadSaved()
+
+	saveButton := widget.NewButtonWithIcon("", theme.DocumentSaveIcon(), func() {
+		nameEntry := widget.NewEntry()
+		nameEntry.SetText(savedSelect.Selected)
+		dialog.ShowForm("Save Search", "Save", "Cancel", []*widget.FormItem{
+			widget.NewFormItem("Name", nameEntry),
+		}, func(ok bool) {
+			if !ok {
+				return
+			}
+			s, err := utils.SaveSearch(context.Background(), helpers.CurrentUserID, nameEntry.Text, entry.Text)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			saved = append(saved, s)
+			savedSelect.Selected = s.Name
+			reloadSaved()
+		}, window)
+	})
+
+	deleteButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		name := savedSelect.Selected
+		if name == "" {
+			dialog.ShowInformation("Saved Searches", "Choose the saved search to delete first.", window)
+			return
+		}
+		dialog.ShowConfirm("Delete Saved Search", "Delete the saved search "+name+"?", func(ok bool) {
+			if !ok {
+				return
+			}
+			for _, s := range saved {
+				if s.Name == name {
+					if err := utils.DeleteSavedSearch(context.Background(), s.ID); err != nil {
+						dialog.ShowError(err, window)
+						return
+					}
+				}
+			}
+			savedSelect.ClearSelected()
+			reloadSaved()
+		}, window)
+	})
+
+	actions := container.NewHBox(searchButton, filtersButton, savedSelect, saveButton, deleteButton)
+	return container.NewBorder(nil, nil, nil, actions, entry)
+}
+
+// splitList reads a comma separated list
+func splitList(text string) []string {
+	var values []string
+	for _, value := range strings.Split(text, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// showSearchFilters edits the filters of the query in entry with a form,
+// keeping its words, then runs search
+func showSearchFilters(window fyne.Window, target searchTarget, entry *widget.Entry, search func()) {
+	q, err := utils.ParseQuery(entry.Text)
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+
+	boundText := func(bound *utils.AmountBound) string {
+		if bound == nil {
+			return ""
+		}
+		return strconv.FormatFloat(bound.Value, 'f', -1, 64)
+	}
+	dateText := func(date time.Time) string {
+		if date.IsZero() {
+			return ""
+		}
+		return date.Format("2006-01-02")
+	}
+
+	minEntry := widget.NewEntry()
+	minEntry.SetPlaceHolder("any")
+	minEntry.SetText(boundText(q.MinAmount))
+	maxEntry := widget.NewEntry()
+	maxEntry.SetPlaceHolder("any")
+	maxEntry.SetText(boundText(q.MaxAmount))
+
+	fromEntry := widget.NewEntry()
+	fromEntry.SetPlaceHolder("YYYY-MM-DD or YYYY-MM")
+	fromEntry.SetText(dateText(q.From))
+	toEntry := widget.NewEntry()
+	toEntry.SetPlaceHolder("YYYY-MM-DD or YYYY-MM")
+	toEntry.SetText(dateText(q.To))
+
+	tagsEntry := widget.NewEntry()
+	tagsEntry.SetPlaceHolder("all of them, comma separated")
+	tagsEntry.SetText(strings.Join(q.Tags, ", "))
+
+	usersEntry := widget.NewEntry()
+	usersEntry.SetPlaceHolder("usernames, comma separated")
+	usersEntry.SetText(strings.Join(q.CreatedBy, ", "))
+
+	statusGroup := widget.NewCheckGroup(logStatuses, nil)
+	statusGroup.Horizontal = true
+	statusGroup.SetSelected(q.Statuses)
+
+	var categoryGroup *widget.CheckGroup
+	var items []*widget.FormItem
+	if !target.logs {
+		categoryGroup = widget.NewCheckGroup(target.categories(), nil)
+		categoryGroup.SetSelected(q.Categories)
+		categoryScroll := container.NewVScroll(categoryGroup)
+		categoryScroll.SetMinSize(fyne.NewSize(0, 140))
+
+		items = append(items,
+			widget.NewFormItem("Amount From", minEntry),
+			widget.NewFormItem("Amount To", maxEntry),
+			widget.NewFormItem("From", fromEntry),
+			widget.NewFormItem("To", toEntry),
+			widget.NewFormItem("Categories", categoryScroll),
+			widget.NewFormItem("Tags", tagsEntry),
+		)
+	} else {
+		items = append(items,
+			widget.NewFormItem("From", fromEntry),
+			widget.NewFormItem("To", toEntry),
+		)
+	}
+	items = append(items, widget.NewFormItem("Added By", usersEntry))
+	if target.logs {
+		items = append(items, widget.NewFormItem("Status", statusGroup))
+	}
+
+	form := dialog.NewForm("Search Filters", "Search", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+
+		built := utils.Query{Words: q.Words, CreatedBy: splitList(usersEntry.Text)}
+		readBound := func(text string, previous *utils.AmountBound) (*utils.AmountBound, error) {
+			text = strings.TrimSpace(text)
+			if text == "" {
+				return nil, nil
+			}
+			amount, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, errors.New("invalid amount " + strconv.Quote(text))
+			}
+			if previous != nil && previous.Value == amount {
+				return previous, nil // keep a strict bound typed into the bar
+			}
+			return &utils.AmountBound{Value: amount, Inclusive: true}, nil
+		}
+		if built.MinAmount, err = readBound(minEntry.Text, q.MinAmount); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if built.MaxAmount, err = readBound(maxEntry.Text, q.MaxAmount); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if !target.logs {
+			built.Categories = categoryGroup.Selected
+			built.Tags = helpers.ParseTags(tagsEntry.Text)
+		} else {
+			built.Statuses = statusGroup.Selected
+		}
+
+		// the dates are checked by reading the query back
+		text := built.String()
+		if from := strings.TrimSpace(fromEntry.Text); from != "" {
+			text += " from:" + from
+		}
+		if to := strings.TrimSpace(toEntry.Text); to != "" {
+			text += " to:" + to
+		}
+		if _, err := utils.ParseQuery(text); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		entry.SetText(strings.TrimSpace(text))
+		search()
+	}, window)
+	form.Resize(fyne.NewSize(520, 0))
+	form.Show()
+}
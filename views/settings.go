@@ -3,11 +3,14 @@ package views
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"fynance/api"
 	"fynance/auth"
 	"fynance/helpers"
 	"fynance/models"
 	"fynance/utils"
 	"os"
+	"strconv"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -21,18 +24,76 @@ import (
 
 // Struct to hold app settings
 type AppSettings struct {
-	IsDarkMode bool   `json:"is_dark_mode"`
-	PageSize   string `json:"page_size"`
+	IsDarkMode bool       `json:"is_dark_mode"`
+	PageSize   settingInt `json:"page_size"` // rows the tables load at a time
+
+	// automatic backups
+	BackupEnabled   bool   `json:"backup_enabled"`
+	BackupInterval  int    `json:"backup_interval_hours"`
+	BackupDir       string `json:"backup_dir"`
+	BackupKeep      int    `json:"backup_keep"`
+	BackupAlertDays int    `json:"backup_alert_days"`
+
+	// local REST API
+	APIEnabled bool   `json:"api_enabled"`
+	APIAddress string `json:"api_address"`
+
+	// days log entries are kept before the database removes them
+	LogRetentionDays int `json:"log_retention_days"`
+
+	// database connection, FYNANCE_* environment variables take precedence
+	Database           utils.DBConfig `json:"database"`
+	DatabaseConfigured bool           `json:"database_configured"`
 }
 
-const settingsFilePath = "settings.json"
+const settingsFilePath = utils.SettingsFile
+
+const defaultPageSize = 50
+
+// settingInt is a number in the settings file that older versions wrote as
+// a string
+type settingInt int
+
+func (n *settingInt) UnmarshalJSON(data []byte) error {
+	var value int
+	if err := json.Unmarshal(data, &value); err == nil {
+		*n = settingInt(value)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		return fmt.Errorf("%q is not a whole number", text)
+	}
+	*n = settingInt(value)
+	return nil
+}
+
+// DefaultSettings are used when there is no settings file, and for any
+// setting missing from an older one
+func DefaultSettings() *AppSettings {
+	return &AppSettings{
+		IsDarkMode:       false,
+		PageSize:         defaultPageSize,
+		BackupInterval:   24,
+		BackupDir:        "backups",
+		BackupKeep:       7,
+		BackupAlertDays:  3,
+		APIAddress:       api.DefaultAddress,
+		Database:         utils.DefaultDBConfig(),
+		LogRetentionDays: 365,
+	}
+}
 
 // LoadSettings loads the app settings from a JSON file
 func LoadSettings() (*AppSettings, error) {
 	// Check if the settings file exists
 	if _, err := os.Stat(settingsFilePath); os.IsNotExist(err) {
 		// If it doesn't exist, return default settings
-		return &AppSettings{IsDarkMode: false, PageSize: "10"}, nil
+		return DefaultSettings(), nil
 	}
 
 	// Read the settings file
@@ -41,13 +102,13 @@ func LoadSettings() (*AppSettings, error) {
 		return nil, err
 	}
 
-	// Unmarshal the JSON data into the AppSettings struct
-	var settings AppSettings
-	err = json.Unmarshal(fileBytes, &settings)
+	// Unmarshal the JSON data over the defaults
+	settings := DefaultSettings()
+	err = json.Unmarshal(fileBytes, settings)
 	if err != nil {
 		return nil, err
 	}
-	return &settings, nil
+	return settings, nil
 }
 
 // SaveSettings saves the app settings to a JSON file
@@ -58,8 +119,9 @@ func SaveSettings(settings *AppSettings) error {
 		return err
 	}
 
-	// Write the JSON data to the settings file
-	err = os.WriteFile(settingsFilePath, fileBytes, 0644)
+	// Write the JSON data to the settings file, private as the connection
+	// URI can hold a password
+	err = os.WriteFile(settingsFilePath, fileBytes, 0600)
 	if err != nil {
 		return err
 	}
@@ -92,30 +154,43 @@ func toggleTheme(window fyne.Window) {
 	applyTheme()
 
 	// Save the current theme setting
-	settings := &AppSettings{PageSize: saved_settings.PageSize, IsDarkMode: isDarkMode}
-	err = SaveSettings(settings)
+	saved_settings.IsDarkMode = isDarkMode
+	err = SaveSettings(saved_settings)
 	if err != nil {
 		dialog.ShowInformation("User Settings", "Error saving settings", window)
 	}
 }
 
 // FUNCTION TO TOGGLE THE PAGE SIZE
-func updatePageSize(pageSize string, window fyne.Window) {
+func updatePageSize(pageSize int, window fyne.Window) {
 	// load settings
 	saved_settings, err := LoadSettings()
 	if err != nil {
 		dialog.ShowInformation("Loading settings", "Error loading settings: "+err.Error(), window)
 	}
-	// Save the current theme setting
-	settings := &AppSettings{IsDarkMode: saved_settings.IsDarkMode, PageSize: pageSize}
+	// Save the current page size
+	saved_settings.PageSize = settingInt(pageSize)
 
-	err = SaveSettings(settings)
+	err = SaveSettings(saved_settings)
 	if err != nil {
 		dialog.ShowInformation("User Settings:Page size", "Error updating page size: "+err.Error(), window)
 	}
 
 }
 
+// pageSizeSelect picks how many rows the tables load at a time
+func pageSizeSelect(window fyne.Window) *widget.Select {
+	pageSizes := widget.NewSelect([]string{"25", "50", "100", "200"}, nil)
+	if settings, err := LoadSettings(); err == nil {
+		pageSizes.SetSelected(strconv.Itoa(int(settings.PageSize)))
+	}
+	pageSizes.OnChanged = func(value string) {
+		pageSize, _ := strconv.Atoi(value)
+		updatePageSize(pageSize, window)
+	}
+	return pageSizes
+}
+
 // showSettings displays the settings view with user details and update options
 func showSettings(window fyne.Window) {
 	var user models.User
@@ -166,10 +241,37 @@ func showSettings(window fyne.Window) {
 			),
 			container.NewGridWithColumns(1,
 				container.NewVBox(
-					widget.NewLabel("Items Per Page"),
-					widget.NewSelect([]string{"5", "10", "20", "30"}, func(value string) {
-						updatePageSize(value, window)
-					})),
+					widget.NewLabel("Rows Loaded at a Time"),
+					pageSizeSelect(window)),
+			),
+			container.NewGridWithColumns(2,
+				widget.NewButton("Backup", func() {
+					showBackupDialog(window)
+				}),
+				widget.NewButton("Restore", func() {
+					showRestoreDialog(window)
+				}),
+			),
+			container.NewGridWithColumns(2,
+				widget.NewButton("Automatic Backups", func() {
+					showBackupScheduleDialog(window)
+				}),
+				widget.NewButton("API Access", func() {
+					showAPIDialog(window)
+				}),
+			),
+			container.NewGridWithColumns(2,
+				widget.NewButton("Webhooks", func() {
+					showWebhooksDialog(window)
+				}),
+				widget.NewButton("Database", func() {
+					showDatabaseDialog(window)
+				}),
+			),
+			container.NewGridWithColumns(1,
+				widget.NewButton("Diagnostics", func() {
+					showDiagnosticsDialog(window)
+				}),
 			),
 		),
 	)
@@ -12,7 +12,7 @@ import (
 )
 
 func Sidebar(window fyne.Window, showParameters, showIncome,
-	showExpenses, showReport, showContact, showDashboard,
+	showExpenses, showAccounts, showReconcile, showImports, showReport, showContact, showDashboard,
 	showLogin func(), userID primitive.ObjectID) *fyne.Container {
 
 	// Define buttons with their labels and actions
@@ -24,6 +24,9 @@ func Sidebar(window fyne.Window, showParameters, showIncome,
 		{"Parameters", showParameters},
 		{"Income", showIncome},
 		{"Expenses", showExpenses},
+		{"Accounts", showAccounts},
+		{"Reconcile", showReconcile},
+		{"Imports", showImports},
 		{"Report", showReport},
 		{"Contact", showContact},
 	}
@@ -41,6 +44,7 @@ func Sidebar(window fyne.Window, showParameters, showIncome,
 	buttons = append(buttons, widget.NewButton("Logout", func() {
 		utils.Logger("User Logged out", "SUCCESS", window)
 		helpers.CurrentUserID = primitive.NilObjectID
+		StopHealthMonitor()
 		showLogin()
 	}))
 
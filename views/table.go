@@ -0,0 +1,447 @@
+package views
+
+import (
+	"fmt"
+	"fynance/utils"
+	"image/color"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// tableColumn is one column of a dataTable
+type tableColumn[T any] struct {
+	Title string
+	Field string // the document field the column sorts on, empty if it doesn't
+	Width float32
+	Text  func(T) string
+	Icon  func(T) fyne.Resource // shown before the text when set; nil shows none
+}
+
+// tableAction is a button shown at the end of every row
+type tableAction[T any] struct {
+	Icon   fyne.Resource
+	Tapped func(T)
+}
+
+// dataTable shows a collection in a virtualised table shared by the list
+// views. Rows are fetched a page at a time in the background as the table
+// scrolls, sorted on the server by the column whose header was tapped, and
+// selected with the check box at the start of each row.
+type dataTable[T any] struct {
+	columns  []tableColumn[T]
+	actions  []tableAction[T]
+	pageSize int
+	id       func(T) primitive.ObjectID
+	fetch    func(utils.PageRequest) utils.Page[T]
+
+	// OnSelectionChanged is called with the number of selected rows
+	OnSelectionChanged func(selected int)
+
+	table  *widget.Table
+	status *widget.Label
+
+	mu         sync.Mutex
+	rows       []T
+	next       string
+	total      int64
+	loading    bool
+	done       bool
+	generation int
+	filter     bson.M
+	sort       string
+	ascending  bool
+	selected   map[primitive.ObjectID]T
+}
+
+const checkColumnWidth = 44
+
+func newDataTable[T any](columns []tableColumn[T], actions []tableAction[T], pageSize int, id func(T) primitive.ObjectID, fetch func(utils.PageRequest) utils.Page[T]) *dataTable[T] {
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	d := &dataTable[T]{
+		columns:  columns,
+		actions:  actions,
+		pageSize: pageSize,
+		id:       id,
+		fetch:    fetch,
+		status:   widget.NewLabel(""),
+		selected: map[primitive.ObjectID]T{},
+	}
+
+	d.table = widget.NewTableWithHeaders(d.length, d.createCell, d.updateCell)
+	d.table.ShowHeaderColumn = false
+	d.table.CreateHeader = d.createHeader
+	d.table.UpdateHeader = d.updateHeader
+	d.table.StickyColumnCount = 1
+	d.table.OnSelected = func(cell widget.TableCellID) {
+		d.table.Unselect(cell)
+		d.toggle(cell.Row)
+	}
+
+	d.table.SetColumnWidth(0, checkColumnWidth)
+	for i, column := range columns {
+		width := column.Width
+		if width == 0 {
+			width = 160
+		}
+		d.table.SetColumnWidth(i+1, width)
+	}
+	if len(actions) > 0 {
+		d.table.SetColumnWidth(len(columns)+1, float32(len(actions))*44+8)
+	}
+
+	d.load()
+	return d
+}
+
+// Widget is the table with its status line underneath
+func (d *dataTable[T]) Widget() fyne.CanvasObject {
+	return container.NewBorder(nil, d.status, nil, nil, d.table)
+}
+
+// SetFilter shows the rows matching filter, as a search does; nil shows all
+func (d *dataTable[T]) SetFilter(filter bson.M) {
+	d.mu.Lock()
+	d.filter = filter
+	d.mu.Unlock()
+	d.Reload()
+}
+
+// Reload fetches the rows again from the top, after they were changed
+func (d *dataTable[T]) Reload() {
+	d.mu.Lock()
+	d.generation++
+	d.rows, d.next, d.total = nil, "", 0
+	d.loading, d.done = false, false
+	clear(d.selected)
+	d.mu.Unlock()
+
+	d.table.ScrollToTop()
+	d.table.Refresh()
+	d.selectionChanged()
+	d.load()
+}
+
+// Selected returns the selected rows
+func (d *dataTable[T]) Selected() []T {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var rows []T
+	for _, row := range d.rows {
+		if _, ok := d.selected[d.id(row)]; ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// SelectedIDs returns the ids of the selected rows
+func (d *dataTable[T]) SelectedIDs() []primitive.ObjectID {
+	var ids []primitive.ObjectID
+	for _, row := range d.Selected() {
+		ids = append(ids, d.id(row))
+	}
+	return ids
+}
+
+// load fetches the next page in the background unless one is on its way or
+// every row is loaded
+func (d *dataTable[T]) load() {
+	d.mu.Lock()
+	if d.loading || d.done {
+		d.mu.Unlock()
+		return
+	}
+	d.loading = true
+	generation := d.generation
+	req := utils.PageRequest{Limit: d.pageSize, After: d.next, Filter: d.filter, Sort: d.sort, Ascending: d.ascending}
+	d.mu.Unlock()
+	d.showStatus()
+
+	go func() {
+		page := d.fetch(req)
+
+		d.mu.Lock()
+		if generation != d.generation {
+			// the sort or filter changed while this page was loading
+			d.mu.Unlock()
+			return
+		}
+		d.rows = append(d.rows, page.Items...)
+		d.next = page.Next
+		d.done = page.Next == ""
+		if req.After == "" {
+			d.total = page.Total
+		}
+		d.loading = false
+		d.mu.Unlock()
+
+		d.table.Refresh()
+		d.showStatus()
+	}()
+}
+
+func (d *dataTable[T]) showStatus() {
+	d.mu.Lock()
+	text := fmt.Sprintf("Showing %d of %d", len(d.rows), max(d.total, int64(len(d.rows))))
+	if d.loading {
+		text += " · Loading…"
+	}
+	if len(d.selected) > 0 {
+		text += fmt.Sprintf(" · %d selected", len(d.selected))
+	}
+	d.mu.Unlock()
+	d.status.SetText(text)
+}
+
+func (d *dataTable[T]) length() (int, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	columns := len(d.columns) + 1
+	if len(d.actions) > 0 {
+		columns++
+	}
+	return len(d.rows), columns
+}
+
+// row returns a loaded row and whether it is selected
+func (d *dataTable[T]) row(index int) (T, bool, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var row T
+	if index < 0 || index >= len(d.rows) {
+		return row, false, false
+	}
+	row = d.rows[index]
+	_, selected := d.selected[d.id(row)]
+	return row, selected, true
+}
+
+// toggle selects or unselects a row
+func (d *dataTable[T]) toggle(index int) {
+	row, selected, ok := d.row(index)
+	if !ok {
+		return
+	}
+	d.setSelected(row, !selected)
+	d.table.Refresh()
+}
+
+func (d *dataTable[T]) setSelected(row T, selected bool) {
+	d.mu.Lock()
+	if selected {
+		d.selected[d.id(row)] = row
+	} else {
+		delete(d.selected, d.id(row))
+	}
+	d.mu.Unlock()
+	d.selectionChanged()
+}
+
+// selectAll selects or unselects every loaded row
+func (d *dataTable[T]) selectAll(selected bool) {
+	d.mu.Lock()
+	clear(d.selected)
+	if selected {
+		for _, row := range d.rows {
+			d.selected[d.id(row)] = row
+		}
+	}
+	d.mu.Unlock()
+	d.table.Refresh()
+	d.selectionChanged()
+}
+
+func (d *dataTable[T]) selectionChanged() {
+	d.mu.Lock()
+	selected := len(d.selected)
+	d.mu.Unlock()
+	d.showStatus()
+	if d.OnSelectionChanged != nil {
+		d.OnSelectionChanged(selected)
+	}
+}
+
+// createCell makes a cell able to show any column: a label, a label with an
+// icon, the selection check box or the row's action buttons, over a
+// background marking selected rows
+func (d *dataTable[T]) createCell() fyne.CanvasObject {
+	background := canvas.NewRectangle(color.Transparent)
+	label := widget.NewLabel("")
+	label.Truncation = fyne.TextTruncateEllipsis
+	check := widget.NewCheck("", nil)
+	buttons := container.NewHBox()
+	for _, action := range d.actions {
+		buttons.Add(widget.NewButtonWithIcon("", action.Icon, nil))
+	}
+	icon := canvas.NewImageFromResource(nil)
+	icon.FillMode = canvas.ImageFillContain
+	icon.SetMinSize(fyne.NewSquareSize(theme.IconInlineSize() * 1.5))
+	iconLabel := widget.NewLabel("")
+	iconLabel.Truncation = fyne.TextTruncateEllipsis
+	withIcon := container.NewBorder(nil, nil, icon, nil, iconLabel)
+	return container.NewStack(background, label, check, buttons, withIcon)
+}
+
+func (d *dataTable[T]) updateCell(cell widget.TableCellID, template fyne.CanvasObject) {
+	objects := template.(*fyne.Container).Objects
+	background := objects[0].(*canvas.Rectangle)
+	label := objects[1].(*widget.Label)
+	check := objects[2].(*widget.Check)
+	buttons := objects[3].(*fyne.Container)
+	withIcon := objects[4].(*fyne.Container)
+
+	row, selected, ok := d.row(cell.Row)
+	if !ok {
+		return
+	}
+	// fetch more as the end of the loaded rows comes into view
+	d.mu.Lock()
+	nearEnd := cell.Row >= len(d.rows)-d.pageSize/2
+	d.mu.Unlock()
+	if nearEnd {
+		d.load()
+	}
+
+	background.FillColor = color.Transparent
+	if selected {
+		background.FillColor = theme.Color(theme.ColorNameSelection)
+	}
+	background.Refresh()
+
+	label.Hide()
+	check.Hide()
+	buttons.Hide()
+	withIcon.Hide()
+	switch column := cell.Col - 1; {
+	case column < 0:
+		check.OnChanged = nil
+		check.SetChecked(selected)
+		check.OnChanged = func(checked bool) {
+			d.setSelected(row, checked)
+			d.table.Refresh()
+		}
+		check.Show()
+	case column < len(d.columns) && d.columns[column].Icon != nil:
+		icon := withIcon.Objects[1].(*canvas.Image)
+		icon.Resource = d.columns[column].Icon(row)
+		icon.Refresh()
+		withIcon.Objects[0].(*widget.Label).SetText(d.columns[column].Text(row))
+		withIcon.Show()
+	case column < len(d.columns):
+		label.SetText(d.columns[column].Text(row))
+		label.Show()
+	default:
+		for i, action := range d.actions {
+			tapped := action.Tapped
+			buttons.Objects[i].(*widget.Button).OnTapped = func() {
+				tapped(row)
+			}
+		}
+		buttons.Show()
+	}
+}
+
+func (d *dataTable[T]) createHeader() fyne.CanvasObject {
+	button := widget.NewButton("", nil)
+	button.Importance = widget.LowImportance
+	button.Alignment = widget.ButtonAlignLeading
+	check := widget.NewCheck("", nil)
+	label := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	return container.NewStack(button, check, label)
+}
+
+func (d *dataTable[T]) updateHeader(cell widget.TableCellID, template fyne.CanvasObject) {
+	objects := template.(*fyne.Container).Objects
+	button := objects[0].(*widget.Button)
+	check := objects[1].(*widget.Check)
+	label := objects[2].(*widget.Label)
+
+	button.Hide()
+	check.Hide()
+	label.Hide()
+	switch column := cell.Col - 1; {
+	case column < 0:
+		d.mu.Lock()
+		all := len(d.rows) > 0 && len(d.selected) == len(d.rows)
+		d.mu.Unlock()
+		check.OnChanged = nil
+		check.SetChecked(all)
+		check.OnChanged = d.selectAll
+		check.Show()
+	case column < len(d.columns) && d.columns[column].Field != "":
+		field := d.columns[column].Field
+		d.mu.Lock()
+		sort, ascending := d.sort, d.ascending
+		d.mu.Unlock()
+
+		text := d.columns[column].Title
+		if sort == field && ascending {
+			text += " ▲"
+		} else if sort == field {
+			text += " ▼"
+		}
+		button.SetText(text)
+		button.OnTapped = func() {
+			d.sortBy(field)
+		}
+		button.Show()
+	case column < len(d.columns):
+		label.SetText(d.columns[column].Title)
+		label.Show()
+	default:
+		label.SetText("Actions")
+		label.Show()
+	}
+}
+
+// sortBy orders the rows by field, reversing the order when they already are
+func (d *dataTable[T]) sortBy(field string) {
+	d.mu.Lock()
+	if d.sort == field {
+		d.ascending = !d.ascending
+	} else {
+		d.sort, d.ascending = field, true
+	}
+	d.mu.Unlock()
+	d.Reload()
+}
+
+// transactionRow is what the income and expense tables show of a record
+type transactionRow struct {
+	Category  string
+	Month     string
+	Year      string
+	Amount    float64
+	CreatedAt time.Time
+	Payee     string
+	Tags      []string
+}
+
+// transactionColumns are the columns of the income and expense tables
+func transactionColumns[T any](row func(T) transactionRow) []tableColumn[T] {
+	return []tableColumn[T]{
+		{Title: "Category", Field: "category", Width: 200, Text: func(item T) string { return row(item).Category }},
+		{Title: "Payee", Width: 150, Text: func(item T) string { return row(item).Payee }},
+		{Title: "Month", Width: 100, Text: func(item T) string { return row(item).Month }},
+		{Title: "Year", Field: "year", Width: 90, Text: func(item T) string { return row(item).Year }},
+		{Title: "Amount", Field: "amount", Width: 130, Text: func(item T) string {
+			return strconv.FormatFloat(row(item).Amount, 'f', -1, 64)
+		}},
+		{Title: "Tags", Width: 160, Text: func(item T) string { return strings.Join(row(item).Tags, ", ") }},
+		{Title: "Added", Field: "created_at", Width: 170, Text: func(item T) string {
+			return row(item).CreatedAt.Format("2006-01-02 15:04")
+		}},
+	}
+}
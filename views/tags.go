@@ -0,0 +1,39 @@
+package views
+
+import (
+	"context"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const allTags = "All Tags"
+
+// tagSelect picks the tag a list is narrowed to; onChanged is given "" for
+// all tags. The tags are read in the background by reload, which is called
+// again after records change.
+func tagSelect(window fyne.Window, load func(context.Context) ([]string, error), onChanged func(tag string)) (*widget.Select, func()) {
+	tags := widget.NewSelect([]string{allTags}, nil)
+	tags.SetSelected(allTags)
+	tags.OnChanged = func(tag string) {
+		if tag == allTags {
+			tag = ""
+		}
+		onChanged(tag)
+	}
+
+	reload := func() {
+		go func() {
+			names, err := load(context.Background())
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			tags.Options = append([]string{allTags}, names...)
+			tags.Refresh()
+		}()
+	}
+	reload()
+	return tags, reload
+}
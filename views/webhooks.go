@@ -0,0 +1,228 @@
+package views
+
+import (
+	"context"
+	"fmt"
+	"fynance/models"
+	"fynance/utils"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showWebhooksDialog lists the webhooks with actions to add, edit, test and
+// delete them and to view their deliveries
+func showWebhooksDialog(window fyne.Window) {
+	webhookList := container.NewVBox()
+
+	var refreshWebhooks func()
+	refreshWebhooks = func() {
+		webhooks, err := utils.ListWebhooks(context.Background())
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		webhookList.Objects = nil
+		if len(webhooks) == 0 {
+			webhookList.Add(widget.NewLabel("No webhooks yet"))
+		}
+		for _, webhook := range webhooks {
+			status := ""
+			if !webhook.Active {
+				status = " (paused)"
+			}
+			label := widget.NewLabel(webhook.URL + status + "\n" + strings.Join(webhook.Events, ", "))
+			label.Wrapping = fyne.TextWrapWord
+
+			actions := container.NewHBox(
+				widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), func() {
+					showWebhookForm(window, &webhook, refreshWebhooks)
+				}),
+				widget.NewButtonWithIcon("", theme.MailSendIcon(), func() {
+					delivery := utils.PingWebhook(context.Background(), webhook)
+					if delivery.Success {
+						dialog.ShowInformation("Webhook Test", fmt.Sprintf("Delivered, the receiver answered %d.", delivery.StatusCode), window)
+					} else {
+						dialog.ShowInformation("Webhook Test", "Delivery failed: "+delivery.Error, window)
+					}
+				}),
+				widget.NewButtonWithIcon("", theme.HistoryIcon(), func() {
+					showWebhookDeliveries(window, webhook)
+				}),
+				widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+					dialog.ShowConfirm("Delete Webhook", "Delete the webhook for "+webhook.URL+" and its delivery log?", func(ok bool) {
+						if !ok {
+							return
+						}
+						if err := utils.DeleteWebhook(context.Background(), webhook.ID); err != nil {
+							dialog.ShowError(err, window)
+							return
+						}
+						utils.Logger("Deleted webhook "+webhook.URL, "SUCCESS", window)
+						refreshWebhooks()
+					}, window)
+				}),
+			)
+			webhookList.Add(container.NewBorder(nil, nil, nil, actions, label))
+		}
+		webhookList.Refresh()
+	}
+	refreshWebhooks()
+
+	addButton := widget.NewButtonWithIcon("Add Webhook", theme.ContentAddIcon(), func() {
+		showWebhookForm(window, nil, refreshWebhooks)
+	})
+
+	help := widget.NewLabel("Events are POSTed as JSON, signed with the webhook secret in the " +
+		utils.WebhookSignatureHeader + " header. Failed deliveries are retried with increasing delays.")
+	help.Wrapping = fyne.TextWrapWord
+
+	content := container.NewBorder(help, addButton, nil, nil, container.NewVScroll(webhookList))
+	webhooksDialog := dialog.NewCustom("Webhooks", "Close", content, window)
+	webhooksDialog.Resize(fyne.NewSize(650, 450))
+	webhooksDialog.Show()
+}
+
+// showWebhookForm adds a webhook, or edits it when webhook isn't nil
+func showWebhookForm(window fyne.Window, webhook *models.Webhook, onSaved func()) {
+	isEdit := webhook != nil
+	if !isEdit {
+		webhook = &models.Webhook{Active: true, Events: utils.WebhookEvents}
+	}
+
+	urlEntry := widget.NewEntry()
+	urlEntry.SetPlaceHolder("https://example.com/fynance")
+	urlEntry.SetText(webhook.URL)
+
+	eventsGroup := widget.NewCheckGroup(utils.WebhookEvents, nil)
+	eventsGroup.SetSelected(webhook.Events)
+
+	activeCheck := widget.NewCheck("Active", nil)
+	activeCheck.SetChecked(webhook.Active)
+
+	items := []*widget.FormItem{
+		{Text: "URL", Widget: urlEntry},
+		{Text: "Events", Widget: eventsGroup},
+		{Text: "", Widget: activeCheck},
+	}
+	if isEdit {
+		// the secret is needed by the receiver to check signatures
+		secretEntry := widget.NewEntry()
+		secretEntry.SetText(webhook.Secret)
+		secretEntry.Disable()
+		copyButton := widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() {
+			window.Clipboard().SetContent(webhook.Secret)
+		})
+		items = append(items, &widget.FormItem{Text: "Secret", Widget: container.NewBorder(nil, nil, nil, copyButton, secretEntry)})
+	}
+
+	title := "Add Webhook"
+	if isEdit {
+		title = "Edit Webhook"
+	}
+
+	dialog.ShowCustomConfirm(title, "Save", "Cancel", widget.NewForm(items...), func(ok bool) {
+		if !ok {
+			return
+		}
+
+		edited := *webhook
+		edited.URL = strings.TrimSpace(urlEntry.Text)
+		edited.Events = eventsGroup.Selected
+		edited.Active = activeCheck.Checked
+
+		if isEdit {
+			if err := utils.UpdateWebhook(context.Background(), edited); err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			utils.Logger("Edited webhook "+edited.URL, "SUCCESS", window)
+		} else {
+			created, err := utils.AddWebhook(context.Background(), edited)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			utils.Logger("Added webhook "+created.URL, "SUCCESS", window)
+			showWebhookSecret(window, created.Secret)
+		}
+		onSaved()
+	}, window)
+}
+
+// showWebhookSecret shows the secret of a new webhook for the receiver
+func showWebhookSecret(window fyne.Window, secret string) {
+	secretEntry := widget.NewEntry()
+	secretEntry.SetText(secret)
+
+	copyButton := widget.NewButtonWithIcon("Copy", theme.ContentCopyIcon(), func() {
+		window.Clipboard().SetContent(secret)
+	})
+
+	content := container.NewVBox(
+		widget.NewLabel("Give this secret to the receiver to verify signatures.\nIt can be seen again by editing the webhook."),
+		container.NewBorder(nil, nil, nil, copyButton, secretEntry),
+	)
+	dialog.ShowCustom("Webhook Secret", "Done", content, window)
+}
+
+// showWebhookDeliveries shows the latest delivery attempts of a webhook
+func showWebhookDeliveries(window fyne.Window, webhook models.Webhook) {
+	deliveries, err := utils.ListWebhookDeliveries(context.Background(), webhook.ID, 100)
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+
+	list := widget.NewList(
+		func() int {
+			return len(deliveries)
+		},
+		func() fyne.CanvasObject {
+			return container.NewGridWithColumns(4, widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""), widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			delivery := deliveries[id]
+			result := fmt.Sprintf("%d", delivery.StatusCode)
+			if delivery.Error != "" {
+				result = delivery.Error
+			}
+			if delivery.Success {
+				result = "✔ " + result
+			} else {
+				result = "✘ " + result
+			}
+
+			row := obj.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(delivery.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+			row.Objects[1].(*widget.Label).SetText(delivery.Event)
+			row.Objects[2].(*widget.Label).SetText(fmt.Sprintf("attempt %d", delivery.Attempt))
+			row.Objects[3].(*widget.Label).SetText(result)
+		},
+	)
+
+	// show the payload of the selected attempt
+	list.OnSelected = func(id widget.ListItemID) {
+		payload := widget.NewMultiLineEntry()
+		payload.SetText(deliveries[id].Payload)
+		payload.Wrapping = fyne.TextWrapWord
+		payloadDialog := dialog.NewCustom("Payload", "Close", payload, window)
+		payloadDialog.Resize(fyne.NewSize(500, 300))
+		payloadDialog.Show()
+		list.UnselectAll()
+	}
+
+	var content fyne.CanvasObject = list
+	if len(deliveries) == 0 {
+		content = widget.NewLabel("Nothing delivered yet")
+	}
+
+	deliveriesDialog := dialog.NewCustom("Deliveries to "+webhook.URL, "Close", content, window)
+	deliveriesDialog.Resize(fyne.NewSize(700, 450))
+	deliveriesDialog.Show()
+}